@@ -22,9 +22,18 @@ type Configuration struct {
 	KeyValueDb *KeyValueDb
 }
 
+// New returns an App over the given Configuration,
+// to hand explicitly to the parts needing it
+func New(c Configuration) App {
+	return &builtApp{conf: c}
+}
+
 // Initialize is a one-time initialization of
 // the app Configuration required at runtime,
 // it errors when called twice
+//
+// Deprecated: pass dependencies explicitly, the
+// global app only serves parts configured without
 func Initialize(c Configuration) error {
 	if app != nil {
 		return errors.New("application already initialized")
@@ -39,6 +48,8 @@ var app App
 // GetApp returns an App that should have been
 // initialized at the application start, otherwise
 // an error asking for initialization
+//
+// Deprecated: pass dependencies explicitly, see New
 func GetApp() (App, error) {
 	if app == nil {
 		return nil, errors.New("app not initialized")
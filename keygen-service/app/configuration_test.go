@@ -5,7 +5,14 @@ import (
 	"testing"
 )
 
+// reset clears the global app after the test
+func reset(t *testing.T) {
+	t.Cleanup(func() { app = nil })
+}
+
 func TestInitialize_GivenAlreadyInitialized(t *testing.T) {
+	reset(t)
+
 	if err := Initialize(Configuration{}); err != nil {
 		t.Fatalf("Initialize() failed: %v", err)
 	}
@@ -24,6 +31,8 @@ func TestInitialize_GivenAlreadyInitialized(t *testing.T) {
 }
 
 func TestGetApp_GivenNotInitialized(t *testing.T) {
+	reset(t)
+
 	want := "not initialized"
 
 	got, gotErr := GetApp()
@@ -42,6 +51,8 @@ func TestGetApp_GivenNotInitialized(t *testing.T) {
 }
 
 func TestGetApp_GivenInitialized(t *testing.T) {
+	reset(t)
+
 	if err := Initialize(Configuration{}); err != nil {
 		t.Fatalf("Initialize() failed: %v", err)
 	}
@@ -55,3 +66,16 @@ func TestGetApp_GivenInitialized(t *testing.T) {
 		t.Errorf("GetApp() = nil, want an app")
 	}
 }
+
+func TestNew(t *testing.T) {
+	reset(t)
+
+	db := &KeyValueDb{Host: "testhost"}
+	if got := New(Configuration{KeyValueDb: db}).GetKeyValueDb(); got != db {
+		t.Errorf("New().GetKeyValueDb() = %v, want %v", got, db)
+	}
+
+	if _, err := GetApp(); err == nil {
+		t.Error("GetApp() succeeded, want New to leave the global app alone")
+	}
+}
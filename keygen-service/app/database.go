@@ -9,6 +9,9 @@ type KeyValueDbClient interface {
 
 	// Flush erases all data
 	Flush() error
+
+	// Close releases the connection
+	Close()
 }
 
 // KeyValueEntity represents a set of methods over
@@ -26,6 +29,65 @@ type KeyValueEntity interface {
 	Deallocate(interface{}) error
 }
 
+// KeyValueBatchEntity is implemented by entities
+// able to save many instances at once
+type KeyValueBatchEntity interface {
+	// CreateBatch saves new instances to db in a single
+	// round trip, returns how many weren't there yet
+	CreateBatch([]interface{}) (int64, error)
+}
+
+// KeyValueBatchAllocator is implemented by entities
+// able to allocate many instances at once
+type KeyValueBatchAllocator interface {
+	// AllocateBatch moves up to n values between
+	// collections in a single round trip and return them
+	AllocateBatch(n int) ([]interface{}, error)
+}
+
+// KeyValueReserver is implemented by entities
+// able to allocate a given instance
+type KeyValueReserver interface {
+	// Reserve moves the given value to the allocated
+	// collection, failing when already there
+	Reserve(interface{}) error
+}
+
+// KeyValueCounter is implemented by entities
+// able to count their elements
+type KeyValueCounter interface {
+	// Count returns how many elements are available
+	// and how many are allocated
+	Count() (int64, int64, error)
+}
+
+// KeyValueWrapper is implemented by entities
+// decorating another entity
+type KeyValueWrapper interface {
+	// Unwrap returns the decorated entity
+	Unwrap() KeyValueEntity
+}
+
+// As finds the first entity implementing T along the
+// chain of wrapped entities, like errors.As does for errors
+func As[T any](entity KeyValueEntity) (T, bool) {
+	for entity != nil {
+		if t, ok := entity.(T); ok {
+			return t, true
+		}
+
+		wrapper, ok := entity.(KeyValueWrapper)
+		if !ok {
+			break
+		}
+
+		entity = wrapper.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
 // KeyValueDb holds key-value concrete databases implementations
 // and configuration
 type KeyValueDb struct {
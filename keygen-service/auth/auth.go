@@ -0,0 +1,313 @@
+// Package auth authenticates the clients of the keys gRPC
+// services, by static API token or mTLS client certificate,
+// and authorizes their calls by role
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Roles known to the default rules
+const (
+	// RoleClient allocates keys and releases its own
+	RoleClient = "client"
+
+	// RoleCleaner releases keys of removed links
+	RoleCleaner = "cleaner"
+
+	// RoleAdmin manages the keys service
+	RoleAdmin = "admin"
+
+	// RoleWatcher watches the keys events, e.g. analytics
+	RoleWatcher = "watcher"
+)
+
+// Public grants a method to anyone, authenticated or not
+const Public = "*"
+
+// DefaultRules only lets clients allocate and release the
+// keys they own, the cleaner release and reclaim, watchers,
+// the cleaner and admins watch events and admins call the
+// admin service; health checks stay public
+var DefaultRules = map[string][]string{
+	"/keys.Keys/GetKey":        {RoleClient, RoleAdmin},
+	"/keys.Keys/ReleaseKey":    {RoleClient, RoleCleaner},
+	"/keys.Keys/ReclaimKey":    {RoleCleaner},
+	"/keys.Keys/WatchEvents":   {RoleWatcher, RoleCleaner, RoleAdmin},
+	"/keys.KeysAdmin/*":        {RoleAdmin},
+	"/grpc.health.v1.Health/*": {Public},
+}
+
+var (
+	// ErrUnauthenticated is returned for calls
+	// with missing or unknown credentials
+	ErrUnauthenticated = status.Error(codes.Unauthenticated, "unknown client credentials")
+
+	// ErrPermissionDenied is returned for calls of
+	// identities lacking the roles of the method
+	ErrPermissionDenied = status.Error(codes.PermissionDenied, "method not allowed to this client")
+)
+
+// Identity is an authenticated client
+type Identity struct {
+	Name  string
+	Roles []string
+}
+
+// HasRole tells whether the identity holds one of the roles
+func (i Identity) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(i.Roles, role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Client configures an identity and its credentials
+type Client struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+
+	// Token authenticates calls sent with an
+	// "authorization: Bearer <token>" header
+	Token string `json:"token,omitempty"`
+
+	// Certificate authenticates calls over mTLS whose
+	// verified client certificate has this common name,
+	// DNS or URI subject alternative name
+	Certificate string `json:"certificate,omitempty"`
+}
+
+// Config lists the clients and, optionally,
+// rules replacing DefaultRules
+type Config struct {
+	Clients []Client            `json:"clients"`
+	Rules   map[string][]string `json:"rules,omitempty"`
+}
+
+// LoadConfig reads a JSON config file
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from the operator
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read auth config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to decode auth config: %w", err)
+	}
+
+	return config, nil
+}
+
+// Authenticator authenticates and authorizes calls
+type Authenticator struct {
+	clients []Client
+	rules   map[string][]string
+}
+
+// NewAuthenticator checks the config, with DefaultRules
+// when it has none
+func NewAuthenticator(config Config) (*Authenticator, error) {
+	names := map[string]bool{}
+	for _, c := range config.Clients {
+		if c.Name == "" {
+			return nil, errors.New("invalid auth config: client without name")
+		}
+
+		if names[c.Name] {
+			return nil, fmt.Errorf("invalid auth config: duplicate client %s", c.Name)
+		}
+		names[c.Name] = true
+
+		if c.Token == "" && c.Certificate == "" {
+			return nil, fmt.Errorf("invalid auth config: client %s without token nor certificate", c.Name)
+		}
+	}
+
+	rules := config.Rules
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+
+	return &Authenticator{clients: config.Clients, rules: rules}, nil
+}
+
+// Authenticate returns the identity of the caller
+func (a *Authenticator) Authenticate(ctx context.Context) (Identity, error) {
+	if token := bearerToken(ctx); token != "" {
+		for _, c := range a.clients {
+			if c.Token != "" && subtle.ConstantTimeCompare([]byte(c.Token), []byte(token)) == 1 {
+				return Identity{Name: c.Name, Roles: c.Roles}, nil
+			}
+		}
+
+		return Identity{}, ErrUnauthenticated
+	}
+
+	if cert := peerCertificate(ctx); cert != nil {
+		for _, c := range a.clients {
+			if c.Certificate != "" && certificateNames(cert, c.Certificate) {
+				return Identity{Name: c.Name, Roles: c.Roles}, nil
+			}
+		}
+	}
+
+	return Identity{}, ErrUnauthenticated
+}
+
+// Authorize checks the identity against the rule of
+// the method, methods without rules being denied
+func (a *Authenticator) Authorize(identity Identity, method string) error {
+	roles, ok := a.rule(method)
+	if !ok || !identity.HasRole(roles...) {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// UnaryServerInterceptor authenticates and authorizes
+// unary calls, handlers find the caller in the context
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.check(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates and
+// authorizes streaming calls
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.check(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &identityStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// check lets public methods through without credentials
+func (a *Authenticator) check(ctx context.Context, method string) (context.Context, error) {
+	if roles, ok := a.rule(method); ok && slices.Contains(roles, Public) {
+		return ctx, nil
+	}
+
+	identity, err := a.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.Authorize(identity, method); err != nil {
+		return nil, err
+	}
+
+	return WithIdentity(ctx, identity), nil
+}
+
+// rule returns the roles of a "/package.Service/Method",
+// falling back to the "/package.Service/*" rule
+func (a *Authenticator) rule(method string) ([]string, bool) {
+	if roles, ok := a.rules[method]; ok {
+		return roles, true
+	}
+
+	if i := strings.LastIndex(method, "/"); i > 0 {
+		roles, ok := a.rules[method[:i]+"/*"]
+		return roles, ok
+	}
+
+	return nil, false
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying the caller
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the authenticated caller,
+// false for public methods or when auth is disabled
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+// identityStream serves the context carrying the caller
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	for _, value := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	return ""
+}
+
+// peerCertificate returns the client certificate
+// verified by the TLS handshake, if any
+func peerCertificate(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return info.State.VerifiedChains[0][0]
+}
+
+func certificateNames(cert *x509.Certificate, name string) bool {
+	if cert.Subject.CommonName == name || slices.Contains(cert.DNSNames, name) {
+		return true
+	}
+
+	for _, uri := range cert.URIs {
+		if uri.String() == name {
+			return true
+		}
+	}
+
+	return false
+}
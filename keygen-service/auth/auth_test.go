@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/url"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func testAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+
+	a, err := NewAuthenticator(Config{Clients: []Client{
+		{Name: "url-shortener", Roles: []string{RoleClient}, Token: "shortener-token"},
+		{Name: "cleaner", Roles: []string{RoleCleaner}, Certificate: "spiffe://example.org/cleaner"},
+		{Name: "operator", Roles: []string{RoleAdmin}, Certificate: "operator"},
+	}})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() failed: %v", err)
+	}
+
+	return a
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+// withCertificate pretends the handshake verified the certificate
+func withCertificate(cert *x509.Certificate) context.Context {
+	info := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: info})
+}
+
+func TestAuthenticator_GivenCredentials(t *testing.T) {
+	a := testAuthenticator(t)
+	cleanerURI, _ := url.Parse("spiffe://example.org/cleaner")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"token", withToken("shortener-token"), "url-shortener"},
+		{"certificate URI", withCertificate(&x509.Certificate{URIs: []*url.URL{cleanerURI}}), "cleaner"},
+		{"certificate common name", withCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "operator"}}), "operator"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := a.Authenticate(tt.ctx)
+			if err != nil {
+				t.Fatalf("Authenticate() failed: %v", err)
+			}
+
+			if identity.Name != tt.want {
+				t.Errorf("Authenticate() = %v, want %v", identity.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_GivenUnknownCredentials(t *testing.T) {
+	a := testAuthenticator(t)
+
+	for _, ctx := range []context.Context{
+		context.Background(),
+		withToken("guessed-token"),
+		withCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "intruder"}}),
+		peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}}), // unverified
+	} {
+		if _, err := a.Authenticate(ctx); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Authenticate() = %v, want %v", err, ErrUnauthenticated)
+		}
+	}
+}
+
+func TestAuthenticator_GivenDefaultRules(t *testing.T) {
+	a := testAuthenticator(t)
+	client := Identity{Name: "url-shortener", Roles: []string{RoleClient}}
+	cleaner := Identity{Name: "cleaner", Roles: []string{RoleCleaner}}
+	admin := Identity{Name: "operator", Roles: []string{RoleAdmin}}
+
+	tests := []struct {
+		identity Identity
+		method   string
+		allowed  bool
+	}{
+		{client, "/keys.Keys/GetKey", true},
+		{client, "/keys.Keys/ReleaseKey", true},
+		{client, "/keys.KeysAdmin/CreateNamespace", false},
+		{cleaner, "/keys.Keys/ReleaseKey", true},
+		{cleaner, "/keys.Keys/ReclaimKey", true},
+		{client, "/keys.Keys/ReclaimKey", false},
+		{cleaner, "/keys.Keys/GetKey", false},
+		{admin, "/keys.Keys/ReleaseKey", false},
+		{cleaner, "/keys.Keys/WatchEvents", true},
+		{client, "/keys.Keys/WatchEvents", false},
+		{admin, "/keys.KeysAdmin/RetireNamespace", true},
+		{admin, "/keys.Unknown/Method", false},
+	}
+
+	for _, tt := range tests {
+		err := a.Authorize(tt.identity, tt.method)
+		if got := err == nil; got != tt.allowed {
+			t.Errorf("Authorize(%s, %s) = %v, want allowed %v", tt.identity.Name, tt.method, err, tt.allowed)
+		}
+	}
+}
+
+func TestAuthenticator_GivenUnaryCall(t *testing.T) {
+	a := testAuthenticator(t)
+	interceptor := a.UnaryServerInterceptor()
+
+	var got Identity
+	handler := func(ctx context.Context, _ any) (any, error) {
+		got, _ = IdentityFromContext(ctx)
+		return nil, nil
+	}
+
+	if _, err := interceptor(withToken("shortener-token"), nil, &grpc.UnaryServerInfo{FullMethod: "/keys.Keys/GetKey"}, handler); err != nil {
+		t.Fatalf("interceptor() failed: %v", err)
+	}
+
+	if got.Name != "url-shortener" {
+		t.Errorf("IdentityFromContext() = %v, want url-shortener", got.Name)
+	}
+
+	if _, err := interceptor(withToken("shortener-token"), nil, &grpc.UnaryServerInfo{FullMethod: "/keys.Keys/ReclaimKey"}, handler); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("interceptor() = %v, want %v", err, ErrPermissionDenied)
+	}
+
+	if _, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler); err != nil {
+		t.Errorf("interceptor() = %v, want public health checks", err)
+	}
+}
+
+func TestNewAuthenticator_GivenInvalidConfig(t *testing.T) {
+	for _, config := range []Config{
+		{Clients: []Client{{Token: "token"}}},
+		{Clients: []Client{{Name: "cleaner"}}},
+		{Clients: []Client{{Name: "cleaner", Token: "a"}, {Name: "cleaner", Token: "b"}}},
+	} {
+		if _, err := NewAuthenticator(config); err == nil {
+			t.Errorf("NewAuthenticator(%+v) succeeded, want an error", config)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateReloader serves the certificate of CertFile
+// and KeyFile, reloaded when the files are rotated
+type CertificateReloader struct {
+	CertFile string
+	KeyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+// NewCertificateReloader loads the certificate files
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{CertFile: certFile, KeyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload loads the certificate files, the previous
+// certificate staying in use when they are invalid
+func (r *CertificateReloader) Reload() error {
+	modified, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert, r.modified = &cert, modified
+	return nil
+}
+
+// Watch should be launched in its own goroutine where it
+// checks the certificate files every interval, reloading
+// them when changed and sending failures to the channel
+func (r *CertificateReloader) Watch(interval time.Duration, ch chan error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		modified, err := r.lastModified()
+		if err != nil {
+			ch <- err
+			continue
+		}
+
+		r.mu.RLock()
+		changed := !modified.Equal(r.modified)
+		r.mu.RUnlock()
+
+		if changed {
+			if err := r.Reload(); err != nil {
+				ch <- err
+			}
+		}
+	}
+}
+
+// GetCertificate serves the current certificate to TLS handshakes
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// lastModified returns when either file was last written
+func (r *CertificateReloader) lastModified() (time.Time, error) {
+	var modified time.Time
+	for _, file := range []string{r.CertFile, r.KeyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to read certificate file: %w", err)
+		}
+
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+
+	return modified, nil
+}
+
+// ServerTLSConfig serves the reloader certificate; with a
+// clientCAFile, client certificates signed by its CAs are
+// verified, and required when requireClientCert is set
+func ServerTLSConfig(reloader *CertificateReloader, clientCAFile string, requireClientCert bool) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if clientCAFile == "" {
+		if requireClientCert {
+			return nil, errors.New("client certificates can't be required without client CAs")
+		}
+
+		return config, nil
+	}
+
+	pool, err := LoadCertPool(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if requireClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// LoadCertPool reads the PEM encoded certificates of a file
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file) // #nosec G304 -- path comes from the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in CA file %s", file)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate CA key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("could not create CA certificate: %v", err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	ca := &testCA{cert: cert, key: key, dir: t.TempDir()}
+	ca.write(t, "ca.pem", "CERTIFICATE", der)
+
+	return ca
+}
+
+// issue writes a certificate for name and its key, returning their files
+func (ca *testCA) issue(t *testing.T, name string, serial int64, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("could not create certificate: %v", err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("could not encode key: %v", err)
+	}
+
+	return ca.write(t, name+".pem", "CERTIFICATE", der), ca.write(t, name+"-key.pem", "EC PRIVATE KEY", keyDer)
+}
+
+func (ca *testCA) write(t *testing.T, name, block string, der []byte) string {
+	t.Helper()
+
+	file := filepath.Join(ca.dir, name)
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: block, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("could not write %s: %v", name, err)
+	}
+
+	return file
+}
+
+func (ca *testCA) file() string {
+	return filepath.Join(ca.dir, "ca.pem")
+}
+
+// dial connects to the server over TLS, with a
+// client certificate when files are given
+func (ca *testCA) dial(t *testing.T, address string, files ...string) healthgrpc.HealthClient {
+	t.Helper()
+
+	pool, err := LoadCertPool(ca.file())
+	if err != nil {
+		t.Fatalf("LoadCertPool() failed: %v", err)
+	}
+
+	config := &tls.Config{RootCAs: pool, ServerName: "localhost", MinVersion: tls.VersionTLS12}
+	if len(files) == 2 {
+		cert, err := tls.LoadX509KeyPair(files[0], files[1])
+		if err != nil {
+			t.Fatalf("could not load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	if err != nil {
+		t.Fatalf("could not connect to test server: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return healthgrpc.NewHealthClient(conn)
+}
+
+// startTLSServer serves health checks to admins only
+func startTLSServer(t *testing.T, config *tls.Config) string {
+	t.Helper()
+
+	a, err := NewAuthenticator(Config{
+		Clients: []Client{{Name: "operator", Roles: []string{RoleAdmin}, Certificate: "operator"}},
+		Rules:   map[string][]string{"/grpc.health.v1.Health/*": {RoleAdmin}},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() failed: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(config)), grpc.UnaryInterceptor(a.UnaryServerInterceptor()))
+	healthgrpc.RegisterHealthServer(s, health.NewServer())
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	return lis.Addr().String()
+}
+
+func TestServerTLSConfig_GivenOptionalClientCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	reloader, err := NewCertificateReloader(ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth))
+	if err != nil {
+		t.Fatalf("NewCertificateReloader() failed: %v", err)
+	}
+
+	config, err := ServerTLSConfig(reloader, ca.file(), false)
+	if err != nil {
+		t.Fatalf("ServerTLSConfig() failed: %v", err)
+	}
+
+	address := startTLSServer(t, config)
+	operatorCert, operatorKey := ca.issue(t, "operator", 3, x509.ExtKeyUsageClientAuth)
+	intruderCert, intruderKey := ca.issue(t, "intruder", 4, x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		name  string
+		files []string
+		want  codes.Code
+	}{
+		{"known certificate", []string{operatorCert, operatorKey}, codes.OK},
+		{"unknown certificate", []string{intruderCert, intruderKey}, codes.Unauthenticated},
+		{"no certificate", nil, codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ca.dial(t, address, tt.files...).Check(context.Background(), &healthgrpc.HealthCheckRequest{})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("Check() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerTLSConfig_GivenRequiredClientCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	reloader, err := NewCertificateReloader(ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth))
+	if err != nil {
+		t.Fatalf("NewCertificateReloader() failed: %v", err)
+	}
+
+	if _, err := ServerTLSConfig(reloader, "", true); err == nil {
+		t.Error("ServerTLSConfig() succeeded, want an error requiring client certificates without CAs")
+	}
+
+	config, err := ServerTLSConfig(reloader, ca.file(), true)
+	if err != nil {
+		t.Fatalf("ServerTLSConfig() failed: %v", err)
+	}
+
+	address := startTLSServer(t, config)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := ca.dial(t, address).Check(ctx, &healthgrpc.HealthCheckRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("Check() = %v, want the handshake refused", err)
+	}
+
+	operatorCert, operatorKey := ca.issue(t, "operator", 3, x509.ExtKeyUsageClientAuth)
+	if _, err := ca.dial(t, address, operatorCert, operatorKey).Check(ctx, &healthgrpc.HealthCheckRequest{}); err != nil {
+		t.Errorf("Check() = %v, want no errors", err)
+	}
+}
+
+func TestCertificateReloader_GivenRotatedCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth)
+
+	reloader, err := NewCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertificateReloader() failed: %v", err)
+	}
+
+	ch := make(chan error, 10)
+	go reloader.Watch(time.Millisecond, ch)
+
+	ca.issue(t, "localhost", 5, x509.ExtKeyUsageServerAuth)
+	later := time.Now().Add(time.Minute) // rotated within the mtime resolution
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatalf("could not touch %s: %v", file, err)
+		}
+	}
+
+	serial := func() int64 {
+		cert, _ := reloader.GetCertificate(nil)
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf.SerialNumber.Int64()
+	}
+
+	for deadline := time.Now().Add(time.Second); serial() != 5 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	if got := serial(); got != 5 {
+		t.Errorf("GetCertificate() served serial %v, want the rotated certificate 5", got)
+	}
+
+	if err := os.WriteFile(certFile, []byte("invalid"), 0o600); err != nil {
+		t.Fatalf("could not write %s: %v", certFile, err)
+	}
+
+	if err := os.Chtimes(certFile, later.Add(time.Minute), later.Add(time.Minute)); err != nil {
+		t.Fatalf("could not touch %s: %v", certFile, err)
+	}
+
+	select {
+	case err := <-ch:
+		if got := serial(); got != 5 {
+			t.Errorf("GetCertificate() served serial %v after %v, want the previous certificate 5", got, err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Watch() sent no error, want the invalid certificate reported")
+	}
+}
@@ -0,0 +1,108 @@
+package cleaner
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQPConsumer consumes a durable queue of an AMQP broker,
+// e.g. RabbitMQ, whose dead letters are routed to the
+// "<queue>.dead" queue through the exchange of that name
+type AMQPConsumer struct {
+	URL   string
+	Queue string
+
+	// Prefetch messages are delivered unsettled at most, 10 when zero
+	Prefetch int
+}
+
+// Consume declares the queues and delivers the messages
+// until ctx is done or the connection is lost
+func (c *AMQPConsumer) Consume(ctx context.Context) (<-chan Delivery, error) {
+	conn, err := amqp.Dial(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to broker: %w", err)
+	}
+
+	deliveries, err := c.consume(ctx, conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	ch := make(chan Delivery)
+	go func() {
+		defer close(ch)
+		defer conn.Close()
+
+		for {
+			select {
+			case d, ok := <-deliveries:
+				if !ok { // connection lost
+					return
+				}
+
+				select {
+				case ch <- &amqpDelivery{Delivery: d}:
+				case <-ctx.Done():
+					return // unsettled, delivered again
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// consume declares the queue, with its dead letters, and consumes it
+func (c *AMQPConsumer) consume(ctx context.Context, conn *amqp.Connection) (<-chan amqp.Delivery, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open broker channel: %w", err)
+	}
+
+	deadLetters := c.Queue + ".dead"
+	if err := channel.ExchangeDeclare(deadLetters, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare dead letters exchange: %w", err)
+	}
+
+	if _, err := channel.QueueDeclare(deadLetters, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare dead letters queue: %w", err)
+	}
+
+	if err := channel.QueueBind(deadLetters, "", deadLetters, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to bind dead letters queue: %w", err)
+	}
+
+	args := amqp.Table{"x-dead-letter-exchange": deadLetters}
+	if _, err := channel.QueueDeclare(c.Queue, true, false, false, false, args); err != nil {
+		return nil, fmt.Errorf("failed to declare queue %s: %w", c.Queue, err)
+	}
+
+	if err := channel.Qos(cmp.Or(c.Prefetch, 10), 0, false); err != nil {
+		return nil, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	deliveries, err := channel.ConsumeWithContext(ctx, c.Queue, "", false, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume queue %s: %w", c.Queue, err)
+	}
+
+	return deliveries, nil
+}
+
+type amqpDelivery struct {
+	amqp.Delivery
+}
+
+func (d *amqpDelivery) Body() []byte      { return d.Delivery.Body }
+func (d *amqpDelivery) MessageID() string { return d.MessageId }
+func (d *amqpDelivery) Redelivered() bool { return d.Delivery.Redelivered }
+func (d *amqpDelivery) Ack() error        { return d.Delivery.Ack(false) }
+func (d *amqpDelivery) Requeue() error    { return d.Nack(false, true) }
+func (d *amqpDelivery) DeadLetter() error { return d.Nack(false, false) }
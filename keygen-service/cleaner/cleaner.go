@@ -0,0 +1,250 @@
+// Package cleaner releases the keys of expired short links,
+// notified through a message broker
+package cleaner
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"keygen-service/client"
+	"keygen-service/keys"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MessageVersion is the version of the notifications understood
+const MessageVersion = 1
+
+// Message notifies an expired short link whose key is released
+type Message struct {
+	Version   int    `json:"version"`
+	ID        string `json:"id,omitempty"` // the message ID when empty
+	Key       string `json:"key"`
+	Namespace string `json:"namespace,omitempty"`
+	Token     string `json:"token,omitempty"` // returned with the key by GetKey, when kept
+}
+
+// errPoison marks messages never handled, whatever the retries
+var errPoison = errors.New("poison message")
+
+// Cleaner releases the keys of the consumed notifications:
+// messages are acknowledged once their key is released, or
+// was by an earlier delivery, requeued after failures worth
+// retrying and dead-lettered when they can't succeed
+type Cleaner struct {
+	Consumer Consumer
+
+	// Keys returns the keys client of a namespace
+	Keys func(namespace string) client.Reclaimer
+
+	// Workers handle messages concurrently, 1 when zero
+	Workers int
+
+	// MaxDeliveries of a message failing are tried
+	// before it is dead-lettered, 10 when zero
+	MaxDeliveries int
+
+	// Backoff spaces the requeues, and the reconnections
+	Backoff keys.Backoff
+
+	// Processed remembers the messages handled, acknowledged
+	// without a release when delivered again
+	Processed *ProcessedSet
+
+	deliveries sync.Map // failed deliveries by message ID
+
+	released, duplicates, requeued, deadLettered atomic.Int64
+}
+
+// CleanerStats is a snapshot of a Cleaner
+type CleanerStats struct {
+	Released     int64
+	Duplicates   int64 // messages handled already
+	Requeued     int64
+	DeadLettered int64
+}
+
+// Run should be launched in its own goroutine where it handles
+// messages until ctx is done, reconnecting to the broker after
+// failures, then closes the channel
+func (c *Cleaner) Run(ctx context.Context, ch chan error) {
+	defer close(ch)
+
+	for attempt := 0; ctx.Err() == nil; {
+		deliveries, err := c.Consumer.Consume(ctx)
+		if err != nil {
+			select {
+			case ch <- err:
+			case <-ctx.Done():
+			}
+
+			sleepCtx(ctx, c.Backoff.Delay(attempt))
+			attempt++
+			continue
+		}
+		attempt = 0
+
+		var workers sync.WaitGroup
+		for range max(c.Workers, 1) {
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+
+				for d := range deliveries {
+					if err := c.Handle(ctx, d); err != nil {
+						select {
+						case ch <- err:
+						case <-ctx.Done():
+						}
+					}
+				}
+			}()
+		}
+		workers.Wait()
+	}
+}
+
+// Handle releases the key of a delivered message and settles it,
+// returning the failure of a message requeued or dead-lettered
+func (c *Cleaner) Handle(ctx context.Context, d Delivery) error {
+	var m Message
+	if err := json.Unmarshal(d.Body(), &m); err != nil {
+		return c.deadLetter(d, fmt.Errorf("%w: %w", errPoison, err))
+	}
+
+	if m.Version != MessageVersion || m.Key == "" {
+		return c.deadLetter(d, fmt.Errorf("%w: version %d, key %q", errPoison, m.Version, m.Key))
+	}
+
+	id := cmp.Or(d.MessageID(), m.ID, m.Namespace+"/"+m.Key+"/"+m.Token)
+	if c.Processed != nil && c.Processed.Contains(id) {
+		c.duplicates.Add(1)
+		return settle(d.Ack())
+	}
+
+	// keys released already, e.g. by a delivery whose
+	// acknowledgement was lost, are reclaimed successfully
+	err := c.Keys(m.Namespace).ReclaimKey(ctx, client.Allocation{Key: []byte(m.Key), Token: m.Token})
+	switch {
+	case err == nil:
+		c.released.Add(1)
+	case ctx.Err() != nil:
+		return nil // unsettled, delivered again
+	default:
+		failure := fmt.Errorf("failed to release key %s of namespace %q: %w", m.Key, m.Namespace, err)
+		deliveries := c.delivered(id)
+		if permanent(err) || deliveries >= cmp.Or(c.MaxDeliveries, 10) {
+			c.deliveries.Delete(id)
+			return c.deadLetter(d, failure)
+		}
+
+		sleepCtx(ctx, c.Backoff.Delay(deliveries-1)) // spares the service while it fails
+		c.requeued.Add(1)
+
+		return errors.Join(failure, settle(d.Requeue()))
+	}
+
+	c.deliveries.Delete(id)
+	if c.Processed != nil {
+		c.Processed.Add(id)
+	}
+
+	return settle(d.Ack())
+}
+
+// delivered counts a failed delivery of the message, returning the count
+func (c *Cleaner) delivered(id string) int {
+	count, _ := c.deliveries.LoadOrStore(id, &atomic.Int64{})
+	return int(count.(*atomic.Int64).Add(1))
+}
+
+func (c *Cleaner) deadLetter(d Delivery, err error) error {
+	c.deadLettered.Add(1)
+	log.Printf("dead-lettering message %s: %v", d.MessageID(), err)
+
+	return errors.Join(err, settle(d.DeadLetter()))
+}
+
+// Stats returns the cleaner counters
+func (c *Cleaner) Stats() CleanerStats {
+	return CleanerStats{
+		Released:     c.released.Load(),
+		Duplicates:   c.duplicates.Load(),
+		Requeued:     c.requeued.Load(),
+		DeadLettered: c.deadLettered.Load(),
+	}
+}
+
+// permanent tells failures no retry fixes, e.g. an invalid
+// key or an unknown namespace
+func permanent(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.NotFound, codes.PermissionDenied, codes.FailedPrecondition:
+		return true
+	default:
+		return false
+	}
+}
+
+func settle(err error) error {
+	if err != nil {
+		return fmt.Errorf("failed to settle message: %w", err)
+	}
+
+	return nil
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
+
+// ProcessedSet remembers the IDs of the messages handled
+// for TTL, forgetting the oldest beyond Size IDs
+type ProcessedSet struct {
+	TTL  time.Duration
+	Size int
+
+	mu    sync.Mutex
+	ids   map[string]time.Time
+	order []string
+}
+
+// NewProcessedSet returns a set of up to size IDs kept for ttl
+func NewProcessedSet(size int, ttl time.Duration) *ProcessedSet {
+	return &ProcessedSet{TTL: ttl, Size: size, ids: map[string]time.Time{}}
+}
+
+// Add remembers the ID
+func (s *ProcessedSet) Add(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ids[id]; !ok {
+		s.order = append(s.order, id)
+	}
+	s.ids[id] = time.Now().Add(s.TTL)
+
+	for len(s.order) > max(s.Size, 1) {
+		delete(s.ids, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// Contains tells whether the ID was added, and not forgotten
+func (s *ProcessedSet) Contains(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.ids[id]
+	return ok && time.Now().Before(expiry)
+}
@@ -0,0 +1,223 @@
+package cleaner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"keygen-service/client"
+	"keygen-service/keys"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestCleaner returns a cleaner of the fake's keys,
+// consuming a memory queue
+func newTestCleaner(fake client.Reclaimer) (*Cleaner, *MemoryConsumer) {
+	consumer := NewMemoryConsumer(10)
+
+	return &Cleaner{
+		Consumer:      consumer,
+		Keys:          func(string) client.Reclaimer { return fake },
+		MaxDeliveries: 3,
+		Backoff:       keys.Backoff{Initial: time.Millisecond, Max: time.Millisecond},
+		Processed:     NewProcessedSet(10, time.Hour),
+	}, consumer
+}
+
+func message(t *testing.T, allocation client.Allocation) []byte {
+	t.Helper()
+
+	body, err := json.Marshal(Message{Version: MessageVersion, Key: string(allocation.Key), Token: allocation.Token})
+	if err != nil {
+		t.Fatalf("could not encode message: %v", err)
+	}
+
+	return body
+}
+
+func TestCleaner_GivenExpiredLinks(t *testing.T) {
+	fake := client.NewFake()
+	cleaner, consumer := newTestCleaner(fake)
+
+	for _, id := range []string{"1", "2"} {
+		allocation, err := fake.GetKey(context.Background())
+		if err != nil {
+			t.Fatalf("GetKey() failed: %v", err)
+		}
+		consumer.Publish(id, message(t, allocation))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan error)
+	go cleaner.Run(ctx, ch)
+	go func() {
+		for len(consumer.Acked()) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	for err := range ch {
+		t.Errorf("Run() sent %v, want none", err)
+	}
+
+	if fake.Taken() != 0 {
+		t.Errorf("Taken() = %d, want the keys released", fake.Taken())
+	}
+
+	if stats := cleaner.Stats(); stats.Released != 2 {
+		t.Errorf("Stats() = %+v, want 2 released", stats)
+	}
+}
+
+func TestCleaner_GivenDuplicates(t *testing.T) {
+	fake := client.NewFake()
+	cleaner, consumer := newTestCleaner(fake)
+
+	allocation, err := fake.GetKey(context.Background())
+	if err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+	body := message(t, allocation)
+
+	if err := cleaner.Handle(context.Background(), &memoryDelivery{consumer: consumer, id: "1", body: body}); err != nil {
+		t.Fatalf("Handle() failed: %v", err)
+	}
+
+	// delivered again with the same ID
+	if err := cleaner.Handle(context.Background(), &memoryDelivery{consumer: consumer, id: "1", body: body}); err != nil {
+		t.Errorf("Handle() = %v, want the processed message acked", err)
+	}
+
+	// published twice, after the key was allocated again
+	if _, err := fake.GetKey(context.Background()); err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+	cleaner.Processed = nil
+	if err := cleaner.Handle(context.Background(), &memoryDelivery{consumer: consumer, id: "2", body: body}); err != nil {
+		t.Errorf("Handle() = %v, want the message of a released key acked", err)
+	}
+
+	if acked := consumer.Acked(); len(acked) != 3 {
+		t.Errorf("Acked() = %v, want every delivery acked", acked)
+	}
+
+	if fake.Taken() != 1 {
+		t.Errorf("Taken() = %d, want the reallocated key left taken", fake.Taken())
+	}
+
+	if stats := cleaner.Stats(); stats.Released != 2 || stats.Duplicates != 1 {
+		t.Errorf("Stats() = %+v, want 2 released and 1 duplicate", stats)
+	}
+}
+
+func TestCleaner_GivenNoToken(t *testing.T) {
+	fake := client.NewFake()
+	cleaner, consumer := newTestCleaner(fake)
+
+	allocation, err := fake.GetKey(context.Background())
+	if err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+
+	body := message(t, client.Allocation{Key: allocation.Key})
+	if err := cleaner.Handle(context.Background(), &memoryDelivery{consumer: consumer, id: "1", body: body}); err != nil {
+		t.Fatalf("Handle() = %v, want the message acked", err)
+	}
+
+	if fake.Taken() != 0 || len(consumer.Acked()) != 1 {
+		t.Errorf("Taken() = %d, want the key released without its token", fake.Taken())
+	}
+}
+
+func TestCleaner_GivenPoisonMessages(t *testing.T) {
+	fake := client.NewFake()
+	cleaner, consumer := newTestCleaner(fake)
+
+	allocation, err := fake.GetKey(context.Background())
+	if err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+
+	bodies := map[string][]byte{
+		"not json":      []byte("{"),
+		"wrong version": []byte(fmt.Sprintf(`{"version":2,"key":%q}`, allocation.Key)),
+		"no key":        []byte(fmt.Sprintf(`{"version":1,"token":%q}`, allocation.Token)),
+	}
+
+	for id, body := range bodies {
+		err := cleaner.Handle(context.Background(), &memoryDelivery{consumer: consumer, id: id, body: body})
+		if err == nil {
+			t.Errorf("Handle(%s) = nil, want an error", id)
+		}
+	}
+
+	if dead := consumer.DeadLettered(); len(dead) != len(bodies) || len(consumer.Acked()) != 0 {
+		t.Errorf("DeadLettered() = %v, want every message dead-lettered", dead)
+	}
+
+	if fake.Taken() != 1 {
+		t.Errorf("Taken() = %d, want the key left taken", fake.Taken())
+	}
+}
+
+func TestCleaner_GivenFailingService(t *testing.T) {
+	fake := client.NewFake()
+	cleaner, consumer := newTestCleaner(fake)
+
+	allocation, err := fake.GetKey(context.Background())
+	if err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+	fake.Err = status.Error(codes.Unavailable, "service down")
+
+	consumer.Publish("1", message(t, allocation))
+	for range 2 {
+		d := <-consumer.queue
+		if err := cleaner.Handle(context.Background(), d); !errors.Is(err, fake.Err) {
+			t.Errorf("Handle() = %v, want %v", err, fake.Err)
+		}
+	}
+
+	if consumer.Pending() != 1 || len(consumer.DeadLettered()) != 0 {
+		t.Fatalf("Pending() = %d, want the message requeued", consumer.Pending())
+	}
+
+	d := <-consumer.queue
+	if !d.Redelivered() {
+		t.Errorf("Redelivered() = false, want true once requeued")
+	}
+
+	if err := cleaner.Handle(context.Background(), d); err == nil {
+		t.Errorf("Handle() = nil, want an error")
+	}
+
+	if dead := consumer.DeadLettered(); len(dead) != 1 || consumer.Pending() != 0 {
+		t.Errorf("DeadLettered() = %v, want the message dead-lettered after 3 deliveries", dead)
+	}
+
+	if stats := cleaner.Stats(); stats.Requeued != 2 || stats.DeadLettered != 1 {
+		t.Errorf("Stats() = %+v, want 2 requeued and 1 dead-lettered", stats)
+	}
+}
+
+func TestProcessedSet_GivenSize(t *testing.T) {
+	set := NewProcessedSet(2, time.Hour)
+	for _, id := range []string{"1", "2", "3"} {
+		set.Add(id)
+	}
+
+	if set.Contains("1") || !set.Contains("2") || !set.Contains("3") {
+		t.Errorf("Contains() kept %v, want the 2 latest IDs", set.ids)
+	}
+
+	expired := NewProcessedSet(2, -time.Second)
+	expired.Add("1")
+	if expired.Contains("1") {
+		t.Errorf("Contains() = true, want false once expired")
+	}
+}
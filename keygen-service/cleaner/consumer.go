@@ -0,0 +1,141 @@
+package cleaner
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// Delivery is a message received from the broker, settled
+// once by Ack, Requeue or DeadLetter
+type Delivery interface {
+	Body() []byte
+
+	// MessageID identifies the message, empty when unset
+	MessageID() string
+
+	// Redelivered tells whether the message was delivered
+	// before, its settlement possibly lost
+	Redelivered() bool
+
+	// Ack removes the handled message from the queue
+	Ack() error
+
+	// Requeue puts the message back in the queue, delivered again later
+	Requeue() error
+
+	// DeadLetter moves the message to the dead letters, for good
+	DeadLetter() error
+}
+
+// Consumer receives the messages of a queue
+type Consumer interface {
+	// Consume delivers the messages until ctx is done or the
+	// connection is lost, when the channel is closed; unsettled
+	// messages are delivered again
+	Consume(ctx context.Context) (<-chan Delivery, error)
+}
+
+// MemoryConsumer queues messages in memory, e.g. in tests
+type MemoryConsumer struct {
+	mu           sync.Mutex
+	queue        chan *memoryDelivery
+	acked        []string
+	deadLettered []string
+	published    int
+}
+
+// NewMemoryConsumer returns a consumer of up to size queued messages
+func NewMemoryConsumer(size int) *MemoryConsumer {
+	return &MemoryConsumer{queue: make(chan *memoryDelivery, size)}
+}
+
+// Publish queues a message, identified by its
+// position when published without ID
+func (c *MemoryConsumer) Publish(id string, body []byte) {
+	c.mu.Lock()
+	c.published++
+	if id == "" {
+		id = strconv.Itoa(c.published)
+	}
+	c.mu.Unlock()
+
+	c.queue <- &memoryDelivery{consumer: c, id: id, body: body}
+}
+
+// Consume delivers the queued messages until ctx is done
+func (c *MemoryConsumer) Consume(ctx context.Context) (<-chan Delivery, error) {
+	ch := make(chan Delivery)
+	go func() {
+		defer close(ch)
+
+		for {
+			select {
+			case d := <-c.queue:
+				select {
+				case ch <- d:
+				case <-ctx.Done():
+					c.queue <- d
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// Acked returns the IDs of the acknowledged messages
+func (c *MemoryConsumer) Acked() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.acked...)
+}
+
+// DeadLettered returns the IDs of the dead-lettered messages
+func (c *MemoryConsumer) DeadLettered() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.deadLettered...)
+}
+
+// Pending returns how many messages are queued
+func (c *MemoryConsumer) Pending() int {
+	return len(c.queue)
+}
+
+type memoryDelivery struct {
+	consumer    *MemoryConsumer
+	id          string
+	body        []byte
+	redelivered bool
+}
+
+func (d *memoryDelivery) Body() []byte      { return d.body }
+func (d *memoryDelivery) MessageID() string { return d.id }
+func (d *memoryDelivery) Redelivered() bool { return d.redelivered }
+
+func (d *memoryDelivery) Ack() error {
+	d.consumer.mu.Lock()
+	defer d.consumer.mu.Unlock()
+
+	d.consumer.acked = append(d.consumer.acked, d.id)
+	return nil
+}
+
+func (d *memoryDelivery) Requeue() error {
+	d.consumer.queue <- &memoryDelivery{consumer: d.consumer, id: d.id, body: d.body, redelivered: true}
+	return nil
+}
+
+func (d *memoryDelivery) DeadLetter() error {
+	d.consumer.mu.Lock()
+	defer d.consumer.mu.Unlock()
+
+	d.consumer.deadLettered = append(d.consumer.deadLettered, d.id)
+	return nil
+}
@@ -0,0 +1,344 @@
+// Package client wraps the Keys gRPC service for Go
+// consumers, with deadlines, retries and an optional
+// buffer of keys fetched ahead of time
+package client
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"keygen-service/keys"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrClosed is returned by calls on a closed client
+var ErrClosed = errors.New("client closed")
+
+// Allocation is an allocated key with the token
+// proving its ownership, needed to release it
+type Allocation struct {
+	Key   []byte
+	Token string
+}
+
+// Keys is what consumers of the keys service depend
+// on, implemented by Client and by Fake for tests
+type Keys interface {
+	// GetKey allocates a key
+	GetKey(ctx context.Context) (Allocation, error)
+
+	// ReleaseKey makes the allocated key available again
+	ReleaseKey(ctx context.Context, allocation Allocation) error
+
+	// Close releases buffered keys and the connection
+	Close() error
+}
+
+// Reclaimer is what the cleaner depends on to release the
+// keys of expired short links, implemented by Client and Fake
+type Reclaimer interface {
+	// ReclaimKey releases the key of an expired allocation,
+	// checking the token when given, succeeding when the key
+	// was released already
+	ReclaimKey(ctx context.Context, allocation Allocation) error
+}
+
+// Options configures a Client, zero values pick the defaults
+type Options struct {
+	// Timeout bounds each attempt, 5s when zero
+	Timeout time.Duration
+
+	// Retries is how many times retryable failures
+	// are retried, 3 when zero and none when negative
+	Retries int
+
+	// Backoff spaces the retries
+	Backoff keys.Backoff
+
+	// Namespace of the keys, the default one when empty
+	Namespace string
+
+	// Token authenticates the client to the service,
+	// sent as an "authorization: Bearer" header
+	Token string
+
+	// BufferSize keys are fetched ahead of
+	// time when positive, none by default
+	BufferSize int
+
+	// Wait makes GetKey wait, up to Timeout per attempt, for
+	// the service to create keys while none is available
+	// instead of failing
+	Wait bool
+
+	// DialOptions replace the default insecure credentials
+	DialOptions []grpc.DialOption
+}
+
+// Client calls the keys service
+type Client struct {
+	keys    keys.KeysClient
+	conn    *grpc.ClientConn // owned connection, nil when given one
+	options Options
+
+	buffer chan Allocation
+	cancel context.CancelFunc
+	filled sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// New connects to the keys service at address
+func New(address string, options Options) (*Client, error) {
+	dialOptions := options.DialOptions
+	if len(dialOptions) == 0 {
+		dialOptions = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+
+	conn, err := grpc.NewClient(address, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to keys service: %w", err)
+	}
+
+	c := NewFromConn(conn, options)
+	c.conn = conn
+
+	return c, nil
+}
+
+// NewFromConn uses a connection owned by the caller,
+// Close leaves it open
+func NewFromConn(conn grpc.ClientConnInterface, options Options) *Client {
+	if options.Token != "" {
+		conn = &tokenConn{ClientConnInterface: conn, token: options.Token}
+	}
+
+	c := &Client{keys: keys.NewKeysClient(conn), options: options}
+
+	if options.BufferSize > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.buffer, c.cancel = make(chan Allocation, options.BufferSize), cancel
+
+		c.filled.Add(1)
+		go c.fill(ctx)
+	}
+
+	return c
+}
+
+// GetKey serves a buffered key when there is one,
+// otherwise allocates one from the service
+func (c *Client) GetKey(ctx context.Context) (Allocation, error) {
+	if c.isClosed() {
+		return Allocation{}, ErrClosed
+	}
+
+	select {
+	case allocation, ok := <-c.buffer: // never ready when unbuffered
+		if !ok { // closed meanwhile, the buffer drained by Close
+			return Allocation{}, ErrClosed
+		}
+
+		return allocation, nil
+	default:
+	}
+
+	return c.getKey(ctx)
+}
+
+// ReleaseKey makes the allocated key available again
+func (c *Client) ReleaseKey(ctx context.Context, allocation Allocation) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+
+	// an attempt timing out may have released the key,
+	// which is then not found by the next one
+	timedOut := false
+	return c.retry(ctx, isReleaseRetryable, func(ctx context.Context) error {
+		_, err := c.keys.ReleaseKey(ctx, c.releaseRequest(allocation))
+		if timedOut && status.Code(err) == codes.NotFound {
+			return nil
+		}
+		timedOut = status.Code(err) == codes.DeadlineExceeded
+
+		return err
+	})
+}
+
+// ReclaimKey releases the key of an expired allocation,
+// the token being optional; reclaiming twice is harmless
+func (c *Client) ReclaimKey(ctx context.Context, allocation Allocation) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+
+	return c.retry(ctx, isReleaseRetryable, func(ctx context.Context) error {
+		_, err := c.keys.ReclaimKey(ctx, c.releaseRequest(allocation))
+		return err
+	})
+}
+
+// Close stops buffering, waiting for a key being fetched,
+// releases the buffered keys and closes the connection
+// when owned
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	var errs []error
+	if c.buffer != nil {
+		c.cancel()
+		c.filled.Wait()
+		close(c.buffer)
+
+		for allocation := range c.buffer {
+			ctx, cancel := context.WithTimeout(context.Background(), c.timeout())
+			if _, err := c.keys.ReleaseKey(ctx, c.releaseRequest(allocation)); err != nil {
+				errs = append(errs, fmt.Errorf("failed to release buffered key %s: %w", allocation.Key, err))
+			}
+			cancel()
+		}
+	}
+
+	if c.conn != nil {
+		if err := c.conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close connection: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// IsRetryable tells failures that may succeed if
+// retried, like an unavailable service. A deadline
+// exceeded is not, the call may have gone through
+func IsRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// isReleaseRetryable also retries deadlines exceeded,
+// releasing a key twice is harmless
+func isReleaseRetryable(err error) bool {
+	return IsRetryable(err) || status.Code(err) == codes.DeadlineExceeded
+}
+
+func (c *Client) getKey(ctx context.Context) (Allocation, error) {
+	var allocation Allocation
+	err := c.retry(ctx, IsRetryable, func(ctx context.Context) error {
+		res, err := c.keys.GetKey(ctx, &keys.GetKeyRequest{Namespace: c.options.Namespace, Wait: c.options.Wait})
+		if err == nil {
+			allocation = Allocation{Key: res.GetKey(), Token: res.GetToken()}
+		}
+
+		return err
+	})
+
+	return allocation, err
+}
+
+func (c *Client) releaseRequest(allocation Allocation) *keys.KeyRequest {
+	return &keys.KeyRequest{Key: allocation.Key, Namespace: c.options.Namespace, Token: allocation.Token}
+}
+
+// retry calls f with a deadline per attempt, retrying
+// retryable failures with backoff while ctx allows
+func (c *Client) retry(ctx context.Context, retryable func(error) bool, f func(ctx context.Context) error) error {
+	retries := cmp.Or(c.options.Retries, 3)
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, c.timeout())
+		err := f(attemptCtx)
+		cancel()
+
+		if err == nil || !retryable(err) || attempt >= retries {
+			return err
+		}
+
+		select {
+		case <-time.After(c.options.Backoff.Delay(attempt)):
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+	}
+}
+
+// fill keeps the buffer full until ctx is done
+func (c *Client) fill(ctx context.Context) {
+	defer c.filled.Done()
+
+	for ctx.Err() == nil {
+		// not cancelled midway, a key allocated
+		// by the service would never be released
+		allocation, err := c.getKey(context.WithoutCancel(ctx))
+		if err != nil {
+			select { // GetKey falls back to the service meanwhile
+			case <-time.After(c.options.Backoff.Delay(0)):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		select {
+		case c.buffer <- allocation:
+		case <-ctx.Done():
+			c.release(allocation)
+		}
+	}
+}
+
+// release gives back a key fetched while closing
+func (c *Client) release(allocation Allocation) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout())
+	defer cancel()
+
+	_, _ = c.keys.ReleaseKey(ctx, c.releaseRequest(allocation))
+}
+
+// tokenConn sends the token with every call
+type tokenConn struct {
+	grpc.ClientConnInterface
+	token string
+}
+
+func (c *tokenConn) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	return c.ClientConnInterface.Invoke(c.withToken(ctx), method, args, reply, opts...)
+}
+
+func (c *tokenConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return c.ClientConnInterface.NewStream(c.withToken(ctx), desc, method, opts...)
+}
+
+func (c *tokenConn) withToken(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+}
+
+func (c *Client) timeout() time.Duration {
+	return cmp.Or(c.options.Timeout, 5*time.Second)
+}
+
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"keygen-service/auth"
+	"keygen-service/keys"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// keysServerMock serves keys out of a Fake, failing
+// the first calls with the given errors
+type keysServerMock struct {
+	keys.UnimplementedKeysServer
+
+	fake  *Fake
+	delay time.Duration
+
+	mu       sync.Mutex
+	failures []error
+	calls    int
+}
+
+func (s *keysServerMock) GetKey(ctx context.Context, _ *keys.GetKeyRequest) (*keys.KeyResponse, error) {
+	if err := s.call(ctx); err != nil {
+		return nil, err
+	}
+
+	allocation, err := s.fake.GetKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keys.KeyResponse{Key: allocation.Key, Token: allocation.Token}, nil
+}
+
+func (s *keysServerMock) ReleaseKey(ctx context.Context, req *keys.KeyRequest) (*keys.Void, error) {
+	if err := s.call(ctx); err != nil {
+		return nil, err
+	}
+
+	return &keys.Void{}, s.fake.ReleaseKey(ctx, Allocation{Key: req.Key, Token: req.Token})
+}
+
+func (s *keysServerMock) call(ctx context.Context) error {
+	s.mu.Lock()
+	s.calls++
+	var err error
+	if len(s.failures) > 0 {
+		err, s.failures = s.failures[0], s.failures[1:]
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-time.After(s.delay):
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (s *keysServerMock) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls
+}
+
+func startServer(t *testing.T, server *keysServerMock, options ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(options...)
+	keys.RegisterKeysServer(s, server)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("could not connect to test server: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+var fastBackoff = keys.Backoff{Initial: time.Millisecond, Max: time.Millisecond}
+
+func TestClient_GivenRetryableFailures(t *testing.T) {
+	server := &keysServerMock{
+		fake:     NewFake([]byte("testk1")),
+		failures: []error{status.Error(codes.Unavailable, "down"), status.Error(codes.ResourceExhausted, "busy")},
+	}
+	c := NewFromConn(startServer(t, server), Options{Backoff: fastBackoff})
+
+	allocation, err := c.GetKey(context.Background())
+	if err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+
+	if string(allocation.Key) != "testk1" || allocation.Token == "" {
+		t.Errorf("GetKey() = %+v, want testk1 with its token", allocation)
+	}
+
+	if got := server.callCount(); got != 3 {
+		t.Errorf("GetKey() called the service %v times, want 3", got)
+	}
+}
+
+func TestClient_GivenNonRetryableFailure(t *testing.T) {
+	server := &keysServerMock{fake: NewFake(), failures: []error{status.Error(codes.InvalidArgument, "invalid")}}
+	c := NewFromConn(startServer(t, server), Options{Backoff: fastBackoff})
+
+	err := c.ReleaseKey(context.Background(), Allocation{Key: []byte("testk1")})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ReleaseKey() = %v, want %v", err, codes.InvalidArgument)
+	}
+
+	if got := server.callCount(); got != 1 {
+		t.Errorf("ReleaseKey() called the service %v times, want 1", got)
+	}
+}
+
+func TestClient_GivenSlowService(t *testing.T) {
+	server := &keysServerMock{fake: NewFake(), delay: time.Second}
+	c := NewFromConn(startServer(t, server), Options{Timeout: 10 * time.Millisecond, Retries: 2, Backoff: fastBackoff})
+
+	start := time.Now()
+	_, err := c.GetKey(context.Background())
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("GetKey() = %v, want %v", err, codes.DeadlineExceeded)
+	}
+
+	if got := server.callCount(); got != 1 {
+		t.Errorf("GetKey() called the service %v times, want 1", got)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("GetKey() took %v, want attempts bounded by the timeout", elapsed)
+	}
+}
+
+func TestClient_GivenReleaseTimingOut(t *testing.T) {
+	fake := NewFake([]byte("testk1"))
+	allocation, _ := fake.GetKey(context.Background())
+	_ = fake.ReleaseKey(context.Background(), allocation) // released by the timed out attempt
+
+	server := &keysServerMock{fake: fake, failures: []error{status.Error(codes.DeadlineExceeded, "slow")}}
+	c := NewFromConn(startServer(t, server), Options{Backoff: fastBackoff})
+
+	if err := c.ReleaseKey(context.Background(), allocation); err != nil {
+		t.Errorf("ReleaseKey() = %v, want nil", err)
+	}
+
+	if got := server.callCount(); got != 2 {
+		t.Errorf("ReleaseKey() called the service %v times, want 2", got)
+	}
+}
+
+func TestClient_GivenBuffer(t *testing.T) {
+	server := &keysServerMock{fake: NewFake()}
+	c := NewFromConn(startServer(t, server), Options{BufferSize: 5, Backoff: fastBackoff})
+
+	for deadline := time.Now().Add(time.Second); len(c.buffer) < 5 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	calls := server.callCount()
+	allocation, err := c.GetKey(context.Background())
+	if err != nil || len(allocation.Key) == 0 {
+		t.Fatalf("GetKey() = %+v, %v, want a key", allocation, err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("Close() = %v, want no errors", err)
+	}
+
+	if got := server.fake.Taken(); got != 1 {
+		t.Errorf("Close() left %v keys taken, want only the served key (%v calls before serving)", got, calls)
+	}
+
+	if _, err := c.GetKey(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("GetKey() = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestClient_GivenBufferDrained(t *testing.T) {
+	c := &Client{buffer: make(chan Allocation)}
+	close(c.buffer) // as by a concurrent Close
+
+	if allocation, err := c.GetKey(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("GetKey() = %+v, %v, want %v", allocation, err, ErrClosed)
+	}
+}
+
+func TestClient_GivenToken(t *testing.T) {
+	authenticator, err := auth.NewAuthenticator(auth.Config{Clients: []auth.Client{{Name: "test", Roles: []string{auth.RoleClient}, Token: "secret"}}})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() failed: %v", err)
+	}
+
+	conn := startServer(t, &keysServerMock{fake: NewFake()}, grpc.UnaryInterceptor(authenticator.UnaryServerInterceptor()))
+
+	if _, err := NewFromConn(conn, Options{Token: "secret"}).GetKey(context.Background()); err != nil {
+		t.Errorf("GetKey() = %v, want the token accepted", err)
+	}
+
+	if _, err := NewFromConn(conn, Options{}).GetKey(context.Background()); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("GetKey() = %v, want %v", err, codes.Unauthenticated)
+	}
+
+	c := NewFromConn(conn, Options{Token: "secret"})
+	allocation, err := c.GetKey(context.Background())
+	if err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+
+	if err := c.ReleaseKey(context.Background(), allocation); err != nil {
+		t.Errorf("ReleaseKey() = %v, want clients releasing their keys", err)
+	}
+
+	if err := c.ReclaimKey(context.Background(), allocation); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ReclaimKey() = %v, want %v", err, codes.PermissionDenied)
+	}
+}
+
+func TestFake(t *testing.T) {
+	fake := NewFake([]byte("testk1"))
+
+	allocation, err := fake.GetKey(context.Background())
+	if err != nil || string(allocation.Key) != "testk1" {
+		t.Fatalf("GetKey() = %+v, %v, want testk1", allocation, err)
+	}
+
+	if generated, err := fake.GetKey(context.Background()); err != nil || len(generated.Key) != keys.MinKeyLength {
+		t.Errorf("GetKey() = %+v, %v, want a generated key", generated, err)
+	}
+
+	if err := fake.ReleaseKey(context.Background(), Allocation{Key: allocation.Key, Token: "forged"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ReleaseKey() = %v, want %v releasing with a forged token", err, codes.PermissionDenied)
+	}
+
+	if err := fake.ReleaseKey(context.Background(), allocation); err != nil {
+		t.Errorf("ReleaseKey() = %v, want no errors", err)
+	}
+
+	if err := fake.ReleaseKey(context.Background(), allocation); err == nil {
+		t.Error("ReleaseKey() succeeded twice, want a error releasing an available key")
+	}
+
+	if err := fake.ReclaimKey(context.Background(), allocation); err != nil {
+		t.Errorf("ReclaimKey() = %v, want an available key reclaimed already", err)
+	}
+
+	if _, err := fake.GetKey(context.Background()); err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+
+	if err := fake.ReclaimKey(context.Background(), Allocation{Key: allocation.Key}); err != nil || fake.Taken() != 1 {
+		t.Errorf("ReclaimKey() = %v, want the key reclaimed without its token", err)
+	}
+
+	fake.Err = status.Error(codes.Unavailable, "down")
+	if _, err := fake.GetKey(context.Background()); !IsRetryable(err) {
+		t.Errorf("GetKey() = %v, want the injected error", err)
+	}
+}
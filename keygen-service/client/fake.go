@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"keygen-service/keys"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Fake keeps keys in memory for consumers' tests,
+// generating new keys whenever none is available
+type Fake struct {
+	// Err, when set, fails every call
+	Err error
+
+	mu        sync.Mutex
+	available [][]byte
+	taken     map[string]string // key tokens
+	closed    bool
+}
+
+// NewFake returns a Fake serving the given keys first
+func NewFake(available ...[]byte) *Fake {
+	return &Fake{available: available, taken: map[string]string{}}
+}
+
+// GetKey allocates a key
+func (f *Fake) GetKey(ctx context.Context) (Allocation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.check(ctx); err != nil {
+		return Allocation{}, err
+	}
+
+	var key []byte
+	if len(f.available) > 0 {
+		key, f.available = f.available[0], f.available[1:]
+	} else {
+		newKey, err := keys.NextKey()
+		if err != nil {
+			return Allocation{}, err
+		}
+		key = newKey.Bytes()
+	}
+
+	token, err := keys.NewOwnershipToken()
+	if err != nil {
+		return Allocation{}, err
+	}
+
+	f.taken[string(key)] = token
+	return Allocation{Key: key, Token: token}, nil
+}
+
+// ReleaseKey makes an allocated key available again,
+// given the token of its allocation
+func (f *Fake) ReleaseKey(ctx context.Context, allocation Allocation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.check(ctx); err != nil {
+		return err
+	}
+
+	token, ok := f.taken[string(allocation.Key)]
+	if !ok {
+		return status.Errorf(codes.NotFound, "key %s not allocated", allocation.Key)
+	}
+
+	if token != allocation.Token {
+		return status.Errorf(codes.PermissionDenied, "key %s not owned by the caller", allocation.Key)
+	}
+
+	delete(f.taken, string(allocation.Key))
+	f.available = append(f.available, allocation.Key)
+
+	return nil
+}
+
+// ReclaimKey releases an allocated key, given the token of its
+// allocation or none; keys released already are left as is
+func (f *Fake) ReclaimKey(ctx context.Context, allocation Allocation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.check(ctx); err != nil {
+		return err
+	}
+
+	token, ok := f.taken[string(allocation.Key)]
+	if !ok || (allocation.Token != "" && token != allocation.Token) {
+		return nil
+	}
+
+	delete(f.taken, string(allocation.Key))
+	f.available = append(f.available, allocation.Key)
+
+	return nil
+}
+
+// Close fails later calls
+func (f *Fake) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	return nil
+}
+
+// Taken returns how many keys are allocated
+func (f *Fake) Taken() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.taken)
+}
+
+func (f *Fake) check(ctx context.Context) error {
+	switch {
+	case f.closed:
+		return ErrClosed
+	case f.Err != nil:
+		return f.Err
+	default:
+		return ctx.Err()
+	}
+}
+
+var (
+	_ Keys      = (*Client)(nil)
+	_ Keys      = (*Fake)(nil)
+	_ Reclaimer = (*Client)(nil)
+	_ Reclaimer = (*Fake)(nil)
+)
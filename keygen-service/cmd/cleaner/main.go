@@ -0,0 +1,185 @@
+// Command cleaner releases the keys of expired short links, consuming
+// the notifications of an AMQP queue, configured from the environment
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"expvar"
+	"fmt"
+	"keygen-service/auth"
+	"keygen-service/cleaner"
+	"keygen-service/client"
+	"keygen-service/keys"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// processedTTL is how long handled message IDs are remembered
+const processedTTL = 24 * time.Hour
+
+func main() {
+	c, closeKeys, err := newCleaner()
+	if err != nil {
+		log.Fatal("invalid cleaner configuration: ", err)
+	}
+	defer closeKeys()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	expvar.Publish("cleaner", expvar.Func(func() any { return c.Stats() }))
+	launchMonitoringServer()
+
+	ch := make(chan error)
+	go c.Run(ctx, ch)
+
+	log.Println("cleaner started")
+	for err := range ch {
+		log.Printf("cleaner: %v", err)
+	}
+	log.Println("cleaner stopped")
+}
+
+// newCleaner configures the cleaner from the environment; the returned
+// func closes the connection to the keys service
+func newCleaner() (*cleaner.Cleaner, func(), error) {
+	address := os.Getenv("KEYS_SERVICE_ADDRESS")
+	if address == "" {
+		return nil, nil, errors.New("KEYS_SERVICE_ADDRESS is required")
+	}
+
+	url := os.Getenv("CLEANER_AMQP_URL")
+	if url == "" {
+		return nil, nil, errors.New("CLEANER_AMQP_URL is required")
+	}
+
+	workers, err := positiveEnv("CLEANER_WORKERS", 4)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	maxDeliveries, err := positiveEnv("CLEANER_MAX_DELIVERIES", 10)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	processed, err := positiveEnv("CLEANER_PROCESSED_SIZE", 100000)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := dial(address, os.Getenv("KEYS_CLIENT_CA_FILE"), os.Getenv("KEYS_CLIENT_CERT_FILE"), os.Getenv("KEYS_CLIENT_KEY_FILE"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clients := &namespaceClients{conn: conn, token: os.Getenv("KEYS_CLIENT_TOKEN")}
+	c := &cleaner.Cleaner{
+		Consumer:      &cleaner.AMQPConsumer{URL: url, Queue: cmp.Or(os.Getenv("CLEANER_QUEUE"), "expired-links"), Prefetch: workers * 2},
+		Keys:          clients.get,
+		Workers:       workers,
+		MaxDeliveries: maxDeliveries,
+		Backoff:       keys.Backoff{Initial: time.Second, Max: time.Minute},
+		Processed:     cleaner.NewProcessedSet(processed, processedTTL),
+	}
+
+	return c, func() { _ = conn.Close() }, nil
+}
+
+// namespaceClients shares a connection between
+// the keys clients of the namespaces
+type namespaceClients struct {
+	conn  *grpc.ClientConn
+	token string
+
+	mu      sync.Mutex
+	clients map[string]client.Reclaimer
+}
+
+func (n *namespaceClients) get(namespace string) client.Reclaimer {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.clients == nil {
+		n.clients = map[string]client.Reclaimer{}
+	}
+
+	c, ok := n.clients[namespace]
+	if !ok {
+		c = client.NewFromConn(n.conn, client.Options{Namespace: namespace, Token: n.token})
+		n.clients[namespace] = c
+	}
+
+	return c
+}
+
+// dial connects to the keys service, over TLS when
+// given a CA and with a client certificate when given one
+func dial(addr, caFile, certFile, keyFile string) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if caFile != "" {
+		pool, err := auth.LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		if certFile != "" {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+
+		creds = credentials.NewTLS(config)
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to keys service: %w", err)
+	}
+
+	return conn, nil
+}
+
+// positiveEnv reads a positive integer variable, fallback when unset
+func positiveEnv(name string, fallback int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive integer", name, v)
+	}
+
+	return n, nil
+}
+
+// launchMonitoringServer serves expvar metrics at
+// CLEANER_MONITORING_ADDRESS or 127.0.0.1:9090
+func launchMonitoringServer() {
+	go func() {
+		server := &http.Server{Addr: cmp.Or(os.Getenv("CLEANER_MONITORING_ADDRESS"), "127.0.0.1:9090"), Handler: expvar.Handler(), ReadHeaderTimeout: 5 * time.Second}
+
+		log.Printf("monitoring server listening at %v", server.Addr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("monitoring server closed: %v", err)
+		}
+	}()
+}
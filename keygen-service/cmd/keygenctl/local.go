@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"keygen-service/databases"
+	"keygen-service/keys"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// localAdmin serves the admin API in process, straight
+// from the keys storage, for tools next to the storage
+type localAdmin struct {
+	handler *keys.AdminRPCHandler
+
+	storage func(namespace string) app.KeyValueEntity
+	owners  func(namespace string) keys.Ownership
+}
+
+// newLocalAdmin reaches the keys storage configured like the
+// keys service: VALKEY_DATABASE_* and KEYS_STORAGE variables
+func newLocalAdmin() (*localAdmin, error) {
+	host := os.Getenv("VALKEY_DATABASE_HOST")
+	if host == "" {
+		return nil, errors.New("VALKEY_DATABASE_HOST is required without -addr")
+	}
+
+	client := &databases.ValkeyClient{
+		Host:     host,
+		Port:     6379,
+		Username: os.Getenv("VALKEY_DATABASE_USERNAME"),
+		Password: os.Getenv("VALKEY_DATABASE_PASSWORD"),
+	}
+
+	if v := os.Getenv("VALKEY_DATABASE_TLS"); v != "" {
+		useTLS, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VALKEY_DATABASE_TLS %q", v)
+		}
+		client.TLS = useTLS
+	}
+
+	if v := os.Getenv("VALKEY_DATABASE_DB"); v != "" {
+		db, err := strconv.Atoi(v)
+		if err != nil || db < 0 {
+			return nil, fmt.Errorf("invalid VALKEY_DATABASE_DB %q", v)
+		}
+		client.Database = db
+	}
+
+	// the migrated keys live in a target only the service reaches
+	phase, err := (&keys.ValkeyMigrationStore{Client: client}).Phase()
+	if err != nil {
+		return nil, err
+	}
+	if phase != keys.MigrationIdle {
+		return nil, fmt.Errorf("%w: keys migration %s, -addr is required", keys.ErrMigrationPhase, phase)
+	}
+
+	storage := func(namespace string) app.KeyValueEntity {
+		return &keys.Valkey{Client: client, Namespace: namespace}
+	}
+	generator := keys.NextKeyOfLength
+
+	if os.Getenv("KEYS_STORAGE") == "bitmap" {
+		segments := uint64(16)
+		if v := os.Getenv("KEYS_BITMAP_SEGMENTS"); v != "" {
+			var err error
+			if segments, err = strconv.ParseUint(v, 10, 64); err != nil || segments == 0 {
+				return nil, fmt.Errorf("invalid KEYS_BITMAP_SEGMENTS %q", v)
+			}
+		}
+
+		storage = func(namespace string) app.KeyValueEntity {
+			return &keys.ValkeyBitmap{Client: client, Namespace: namespace}
+		}
+		generator = keys.SegmentedKeys(segments)
+	}
+
+	owners := func(namespace string) keys.Ownership {
+		return &keys.ValkeyOwnership{Client: client, Namespace: namespace}
+	}
+
+	// keys released or banned here are audited like through the service
+	var audit func(namespace string) keys.AuditLog
+	retention := keys.DefaultAuditRetention
+	if v := os.Getenv("KEYS_AUDIT_RETENTION"); v != "" {
+		var err error
+		if retention, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid KEYS_AUDIT_RETENTION %q", v)
+		}
+	}
+	if retention != 0 {
+		audit = func(namespace string) keys.AuditLog {
+			return &keys.ValkeyAuditLog{Client: client, Namespace: namespace, Retention: retention}
+		}
+	}
+
+	allocator := keys.NewAllocator(storage(""), nil)
+	allocator.Owners = owners("")
+	if audit != nil {
+		allocator.Audit = audit("")
+	}
+
+	return &localAdmin{
+		handler: &keys.AdminRPCHandler{
+			Namespaces: &keys.Namespaces{
+				Registry: &keys.ValkeyNamespaces{Client: client},
+				Storage:  storage,
+				Default:  allocator,
+				Owners:   owners,
+				Audit:    audit,
+			},
+			Generator: generator,
+		},
+		storage: storage,
+		owners:  owners,
+	}, nil
+}
+
+func (l *localAdmin) CreateNamespace(ctx context.Context, in *keys.Namespace, _ ...grpc.CallOption) (*keys.Namespace, error) {
+	return l.handler.CreateNamespace(ctx, in)
+}
+
+func (l *localAdmin) ListNamespaces(ctx context.Context, in *keys.Void, _ ...grpc.CallOption) (*keys.NamespaceList, error) {
+	return l.handler.ListNamespaces(ctx, in)
+}
+
+func (l *localAdmin) RetireNamespace(ctx context.Context, in *keys.NamespaceRequest, _ ...grpc.CallOption) (*keys.Namespace, error) {
+	return l.handler.RetireNamespace(ctx, in)
+}
+
+func (l *localAdmin) ForceReleaseKey(ctx context.Context, in *keys.KeyRequest, _ ...grpc.CallOption) (*keys.Void, error) {
+	return l.handler.ForceReleaseKey(ctx, in)
+}
+
+func (l *localAdmin) BanKey(ctx context.Context, in *keys.KeyRequest, _ ...grpc.CallOption) (*keys.Void, error) {
+	return l.handler.BanKey(ctx, in)
+}
+
+func (l *localAdmin) QuarantineKey(ctx context.Context, in *keys.KeyRequest, _ ...grpc.CallOption) (*keys.Void, error) {
+	return l.handler.QuarantineKey(ctx, in)
+}
+
+func (l *localAdmin) LiftQuarantine(ctx context.Context, in *keys.KeyRequest, _ ...grpc.CallOption) (*keys.Void, error) {
+	return l.handler.LiftQuarantine(ctx, in)
+}
+
+func (l *localAdmin) LookupKey(ctx context.Context, in *keys.KeyRequest, _ ...grpc.CallOption) (*keys.KeyStatus, error) {
+	return l.handler.LookupKey(ctx, in)
+}
+
+func (l *localAdmin) ListKeys(ctx context.Context, in *keys.ListKeysRequest, _ ...grpc.CallOption) (*keys.KeyList, error) {
+	return l.handler.ListKeys(ctx, in)
+}
+
+func (l *localAdmin) GetStats(ctx context.Context, in *keys.StatsRequest, _ ...grpc.CallOption) (*keys.Stats, error) {
+	return l.handler.GetStats(ctx, in)
+}
+
+func (l *localAdmin) SeedKeys(ctx context.Context, in *keys.SeedRequest, _ ...grpc.CallOption) (*keys.SeedResponse, error) {
+	return l.handler.SeedKeys(ctx, in)
+}
+
+func (l *localAdmin) CheckKeys(ctx context.Context, in *keys.NamespaceRequest, _ ...grpc.CallOption) (*keys.CheckReport, error) {
+	return l.handler.CheckKeys(ctx, in)
+}
+
+func (l *localAdmin) Reconcile(ctx context.Context, in *keys.ReconcileRequest, _ ...grpc.CallOption) (*keys.Reconciliation, error) {
+	return l.handler.Reconcile(ctx, in)
+}
+
+// reconcileInUse reconciles the keys of the namespace,
+// cross-checked with the keys in use listed in the file
+func (l *localAdmin) reconcileInUse(ctx context.Context, namespace, path string, repair bool) (*keys.Reconciliation, error) {
+	inUse, err := loadKeys(path)
+	if err != nil {
+		return nil, err
+	}
+
+	allocator, err := l.handler.Namespaces.Allocator(namespace, false)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := allocator.Reconcile(ctx, &keys.Reconciler{Repair: repair, InUse: inUse})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys.ReconciliationMessage(namespace, report), nil
+}
+
+// loadKeys reads a file of keys, one per line
+func loadKeys(path string) (map[string]bool, error) {
+	f, err := os.Open(path) // #nosec G304 -- file named by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keys file: %w", err)
+	}
+	defer f.Close()
+
+	loaded := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		k := strings.TrimSpace(scanner.Text())
+		if k == "" {
+			continue
+		}
+
+		if _, err := keys.NewKeyFromBytes([]byte(k)); err != nil {
+			return nil, fmt.Errorf("invalid key %q at line %d of %s: %w", k, line, path, err)
+		}
+		loaded[k] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read keys file: %w", err)
+	}
+
+	return loaded, nil
+}
+
+func (l *localAdmin) AuditKeys(ctx context.Context, in *keys.AuditRequest, _ ...grpc.CallOption) (*keys.KeyAudit, error) {
+	return l.handler.AuditKeys(ctx, in)
+}
+
+func (l *localAdmin) Migrate(ctx context.Context, in *keys.MigrationRequest, _ ...grpc.CallOption) (*keys.MigrationStatus, error) {
+	return l.handler.Migrate(ctx, in)
+}
+
+func (l *localAdmin) ListJobs(ctx context.Context, in *keys.Void, _ ...grpc.CallOption) (*keys.JobList, error) {
+	return l.handler.ListJobs(ctx, in)
+}
+
+func (l *localAdmin) TriggerJob(ctx context.Context, in *keys.JobRequest, _ ...grpc.CallOption) (*keys.ScheduledJob, error) {
+	return l.handler.TriggerJob(ctx, in)
+}
+
+var _ keys.KeysAdminClient = (*localAdmin)(nil)
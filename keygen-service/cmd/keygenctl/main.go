@@ -0,0 +1,457 @@
+// Command keygenctl inspects and maintains the keys pool, through
+// the KeysAdmin API with -addr or straight from the keys storage
+// configured like the keys service otherwise
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"keygen-service/auth"
+	"keygen-service/keys"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const usage = `usage: keygenctl [flags] <command> [args]
+
+commands:
+  stats [-by-length]      count available, taken, reserved, quarantined and banned keys
+  list [-state S] [-cursor C] [-count N]
+                          list a page of keys
+  seed [-length N] COUNT  create COUNT new keys
+  lookup KEY              tell whether KEY is available, taken, reserved, quarantined
+                          or banned
+  release KEY             make KEY available again whoever allocated it
+  quarantine KEY          hold KEY out of the pool, unless taken, until lifted
+  lift KEY                make quarantined KEY available again
+  ban KEY                 take KEY out of the pool for good
+  check                   look for keys both available and taken
+  reconcile [-repair] [-in-use FILE]
+                          scan the keys for duplicated, malformed or banned available
+                          ones, cross-checked with the keys in use listed in FILE
+  export [-format F] FILE snapshot every key to FILE, jsonl or csv
+  import [-mode M] FILE   restore a snapshot, merging or replacing the keys
+  verify FILE             check the keys and checksum of a snapshot
+  audit [-since D] [-limit N] [KEY]
+                          print who allocated and released KEY, and its latest events,
+                          or the latest events of every key
+  migrate ACTION          run a step of the keys storage migration: status, start,
+                          copy, verify, cutover, rollback or finish
+  jobs [trigger NAME]     list the background jobs of the replica serving -addr,
+                          or run NAME now
+
+flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("keygenctl", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	addr := flags.String("addr", "", "keys service address, the keys storage is used directly when empty")
+	token := flags.String("token", os.Getenv("KEYGENCTL_TOKEN"), "bearer token of the admin identity")
+	caFile := flags.String("ca", "", "CA file verifying the keys service, plaintext when empty")
+	certFile := flags.String("cert", "", "client certificate file for mTLS")
+	keyFile := flags.String("key", "", "client certificate key file for mTLS")
+	namespace := flags.String("namespace", "", "namespace of the keys, the default one when empty")
+	timeout := flags.Duration("timeout", time.Minute, "bound of the command")
+
+	_ = flags.Parse(os.Args[1:]) // exits on error
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	switch flags.Arg(0) {
+	case "verify":
+		if err := runSnapshot(ctx, nil, *namespace, flags.Args(), os.Stdout); err != nil {
+			fatal(err)
+		}
+		return
+	case "export", "import":
+		if *addr != "" {
+			fatal(fmt.Errorf("%s reaches the keys storage directly, without -addr", flags.Arg(0)))
+		}
+
+		local, err := newLocalAdmin()
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := runSnapshot(ctx, local, *namespace, flags.Args(), os.Stdout); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	var admin keys.KeysAdminClient
+	if *addr != "" {
+		conn, err := dial(*addr, *caFile, *certFile, *keyFile)
+		if err != nil {
+			fatal(err)
+		}
+		defer conn.Close()
+
+		admin = keys.NewKeysAdminClient(conn)
+		if *token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+*token)
+		}
+	} else {
+		local, err := newLocalAdmin()
+		if err != nil {
+			fatal(err)
+		}
+		admin = local
+		ctx = auth.WithIdentity(ctx, auth.Identity{Name: localCaller()})
+	}
+
+	if err := run(ctx, admin, *namespace, flags.Args(), os.Stdout); err != nil {
+		fatal(err)
+	}
+}
+
+// run runs the command named by args[0] against the admin API
+func run(ctx context.Context, admin keys.KeysAdminClient, namespace string, args []string, out io.Writer) error {
+	command, args := args[0], args[1:]
+
+	switch command {
+	case "stats":
+		flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+		flags.SetOutput(out)
+		byLength := flags.Bool("by-length", false, "count the keys of each length too, scanning every key")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+
+		stats, err := admin.GetStats(ctx, &keys.StatsRequest{Namespace: namespace, ByLength: *byLength})
+		if err != nil {
+			return fmt.Errorf("failed to get stats: %w", err)
+		}
+
+		fmt.Fprintf(out, "available: %d\ntaken: %d\nreserved: %d\nquarantined: %d\nbanned: %d\n",
+			stats.GetAvailable(), stats.GetTaken(), stats.GetReserved(), stats.GetQuarantined(), stats.GetBanned())
+
+		for _, length := range stats.GetLengths() {
+			fmt.Fprintf(out, "length %d: %d available, %d taken, %d reserved, %d quarantined, %d banned\n", length.GetKeyLength(),
+				length.GetAvailable(), length.GetTaken(), length.GetReserved(), length.GetQuarantined(), length.GetBanned())
+		}
+	case "list":
+		flags := flag.NewFlagSet("list", flag.ContinueOnError)
+		flags.SetOutput(out)
+		state := flags.String("state", "", "available, taken, reserved, quarantined or banned, every key when empty")
+		cursor := flags.String("cursor", "", "cursor printed by the previous page, the first page when empty")
+		count := flags.Int("count", keys.DefaultListCount, "hint of the page size")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+
+		// #nosec G115 -- a hint only
+		res, err := admin.ListKeys(ctx, &keys.ListKeysRequest{Namespace: namespace, State: *state, Cursor: *cursor, Count: int32(*count)})
+		if err != nil {
+			return fmt.Errorf("failed to list keys: %w", err)
+		}
+
+		for _, k := range res.GetKeys() {
+			fmt.Fprintf(out, "%s\n", k)
+		}
+
+		if res.GetCursor() != "" {
+			fmt.Fprintf(out, "next page: -cursor %s\n", res.GetCursor())
+		}
+	case "seed":
+		flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+		flags.SetOutput(out)
+		length := flags.Int("length", 0, "length of the new keys, the namespace one when 0")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+
+		if flags.NArg() != 1 {
+			return errors.New("seed takes the count of new keys")
+		}
+
+		count, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+		if err != nil || count <= 0 {
+			return fmt.Errorf("invalid keys count %q", flags.Arg(0))
+		}
+
+		// #nosec G115 -- validated by the service
+		res, err := admin.SeedKeys(ctx, &keys.SeedRequest{Namespace: namespace, Count: count, KeyLength: int32(*length)})
+		if err != nil {
+			return fmt.Errorf("failed to seed keys: %w", err)
+		}
+
+		fmt.Fprintf(out, "created: %d\n", res.GetCreated())
+	case "lookup", "release", "quarantine", "lift", "ban":
+		if len(args) != 1 {
+			return fmt.Errorf("%s takes a key", command)
+		}
+
+		req := &keys.KeyRequest{Key: []byte(args[0]), Namespace: namespace}
+		switch command {
+		case "lookup":
+			status, err := admin.LookupKey(ctx, req)
+			if err != nil {
+				return fmt.Errorf("failed to look up key: %w", err)
+			}
+
+			fmt.Fprintf(out, "%s: %s\n", status.GetKey(), status.GetState())
+		case "release":
+			if _, err := admin.ForceReleaseKey(ctx, req); err != nil {
+				return fmt.Errorf("failed to release key: %w", err)
+			}
+
+			fmt.Fprintf(out, "%s: released\n", args[0])
+		case "quarantine":
+			if _, err := admin.QuarantineKey(ctx, req); err != nil {
+				return fmt.Errorf("failed to quarantine key: %w", err)
+			}
+
+			fmt.Fprintf(out, "%s: quarantined\n", args[0])
+		case "lift":
+			if _, err := admin.LiftQuarantine(ctx, req); err != nil {
+				return fmt.Errorf("failed to lift key quarantine: %w", err)
+			}
+
+			fmt.Fprintf(out, "%s: available\n", args[0])
+		case "ban":
+			if _, err := admin.BanKey(ctx, req); err != nil {
+				return fmt.Errorf("failed to ban key: %w", err)
+			}
+
+			fmt.Fprintf(out, "%s: banned\n", args[0])
+		}
+	case "check":
+		report, err := admin.CheckKeys(ctx, &keys.NamespaceRequest{Name: namespace})
+		if err != nil {
+			return fmt.Errorf("failed to check keys: %w", err)
+		}
+
+		fmt.Fprintf(out, "available: %d\ntaken: %d\nduplicated: %d\nbanned available: %d\n",
+			report.GetAvailable(), report.GetTaken(), report.GetDuplicated(), report.GetBannedAvailable())
+
+		if report.GetDuplicated() > 0 || report.GetBannedAvailable() > 0 {
+			return errors.New("inconsistent keys found")
+		}
+	case "reconcile":
+		flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+		flags.SetOutput(out)
+		repair := flags.Bool("repair", false, "repair the anomalies found, only reported otherwise")
+		inUse := flags.String("in-use", "", "file of the keys in use, one per line, e.g. the short links hashes")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+
+		var report *keys.Reconciliation
+		var err error
+		if *inUse != "" {
+			local, ok := admin.(*localAdmin)
+			if !ok {
+				return errors.New("reconcile -in-use reaches the keys storage directly, without -addr")
+			}
+
+			report, err = local.reconcileInUse(ctx, namespace, *inUse, *repair)
+		} else {
+			report, err = admin.Reconcile(ctx, &keys.ReconcileRequest{Namespace: namespace, Repair: *repair})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to reconcile keys: %w", err)
+		}
+
+		fmt.Fprintf(out, "scanned: %d\nduplicated: %d\nmalformed: %d\nbanned available: %d\n",
+			report.GetScanned(), report.GetDuplicated(), report.GetMalformed(), report.GetBannedAvailable())
+		if *inUse != "" {
+			fmt.Fprintf(out, "in use not taken: %d\nunused: %d\n", report.GetInUseNotTaken(), report.GetUnused())
+		}
+		fmt.Fprintf(out, "repaired: %d\n", report.GetRepaired())
+
+		for _, example := range report.GetExamples() {
+			fmt.Fprintf(out, "anomaly: %s\n", example)
+		}
+
+		anomalies := report.GetDuplicated() + report.GetMalformed() + report.GetBannedAvailable() + report.GetInUseNotTaken()
+		if anomalies > 0 && !*repair {
+			return errors.New("anomalies found, repair them with -repair")
+		}
+	case "audit":
+		flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+		flags.SetOutput(out)
+		since := flags.Duration("since", 0, "print the events of that long ago at most, every retained event when 0")
+		limit := flags.Int("limit", 20, "print that many events at most")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+
+		if flags.NArg() > 1 {
+			return errors.New("audit takes a key at most")
+		}
+
+		req := &keys.AuditRequest{Namespace: namespace, Key: []byte(flags.Arg(0)), Limit: int32(*limit)} // #nosec G115 -- a bound only
+		if *since > 0 {
+			req.Since = time.Now().Add(-*since).Unix()
+		}
+
+		audit, err := admin.AuditKeys(ctx, req)
+		if err != nil {
+			return fmt.Errorf("failed to audit keys: %w", err)
+		}
+
+		if m := audit.GetMetadata(); m != nil {
+			fmt.Fprintf(out, "state: %s\n", m.GetState())
+			fmt.Fprintf(out, "allocated: %s %s\n",
+				m.GetAllocatedAt(), auditedBy(m.GetAllocatedBy(), m.GetAllocatedPeer(), m.GetAllocatedRequestId()))
+			if m.GetReleasedAt() != "" {
+				fmt.Fprintf(out, "released: %s %s\n",
+					m.GetReleasedAt(), auditedBy(m.GetReleasedBy(), m.GetReleasedPeer(), m.GetReleasedRequestId()))
+			}
+		}
+
+		for _, e := range audit.GetEvents() {
+			fmt.Fprintf(out, "%s %s %s, %s %s\n",
+				e.GetTime(), e.GetKey(), e.GetAction(), e.GetState(), auditedBy(e.GetCaller(), e.GetPeer(), e.GetRequestId()))
+		}
+	case "migrate":
+		if len(args) != 1 {
+			return errors.New("migrate takes an action")
+		}
+
+		status, err := admin.Migrate(ctx, &keys.MigrationRequest{Action: args[0]})
+		if err != nil {
+			return fmt.Errorf("failed to migrate keys: %w", err)
+		}
+
+		fmt.Fprintf(out, "phase: %s\n", status.GetPhase())
+		if args[0] == "start" || args[0] == "copy" {
+			fmt.Fprintf(out, "copied: %d\n", status.GetCopied())
+		}
+
+		if source, target := status.GetSource(), status.GetTarget(); source != nil && target != nil {
+			fmt.Fprintf(out, "source: %d available, %d taken, %d reserved, %d quarantined, %d banned\n",
+				source.GetAvailable(), source.GetTaken(), source.GetReserved(), source.GetQuarantined(), source.GetBanned())
+			fmt.Fprintf(out, "target: %d available, %d taken, %d reserved, %d quarantined, %d banned\n",
+				target.GetAvailable(), target.GetTaken(), target.GetReserved(), target.GetQuarantined(), target.GetBanned())
+			fmt.Fprintf(out, "checked: %d\nmismatched: %d\n", status.GetChecked(), status.GetMismatched())
+
+			for _, k := range status.GetMismatches() {
+				fmt.Fprintf(out, "mismatch: %s\n", k)
+			}
+
+			if status.GetMismatched() > 0 || !proto.Equal(source, target) {
+				return errors.New("migration target diverged from source")
+			}
+		}
+	case "jobs":
+		if len(args) == 2 && args[0] == "trigger" {
+			job, err := admin.TriggerJob(ctx, &keys.JobRequest{Name: args[1]})
+			if err != nil {
+				return fmt.Errorf("failed to trigger job: %w", err)
+			}
+
+			fmt.Fprintf(out, "%s running\n", job.GetName())
+			return nil
+		}
+
+		if len(args) != 0 {
+			return errors.New("jobs takes no argument, or trigger NAME")
+		}
+
+		list, err := admin.ListJobs(ctx, &keys.Void{})
+		if err != nil {
+			return fmt.Errorf("failed to list jobs: %w", err)
+		}
+
+		for _, job := range list.GetJobs() {
+			state := "idle"
+			if job.GetRunning() {
+				state = "running"
+			}
+
+			fmt.Fprintf(out, "%s\t%s\t%s\tnext %s\tlast %s (%dms)\truns %d\tfailures %d\tskipped %d\n",
+				job.GetName(), job.GetSchedule(), state, job.GetNextRun(), cmp.Or(job.GetLastRun(), "never"),
+				job.GetLastDurationMs(), job.GetRuns(), job.GetFailures(), job.GetSkipped())
+			if job.GetLastError() != "" {
+				fmt.Fprintf(out, "  last error: %s\n", job.GetLastError())
+			}
+		}
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	return nil
+}
+
+// auditedBy tells who did an audited action, from where
+func auditedBy(caller, peer, requestID string) string {
+	by := "by " + caller
+	if peer != "" {
+		by += " from " + peer
+	}
+	if requestID != "" {
+		by += " request " + requestID
+	}
+
+	return by
+}
+
+// localCaller names the operator in the audit
+// of the keys changed without the service
+func localCaller() string {
+	if u, err := user.Current(); err == nil {
+		return "keygenctl:" + u.Username
+	}
+
+	return "keygenctl"
+}
+
+// dial connects to the keys service, over TLS when
+// given a CA and with a client certificate when given one
+func dial(addr, caFile, certFile, keyFile string) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if caFile != "" {
+		pool, err := auth.LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		if certFile != "" {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+
+		creds = credentials.NewTLS(config)
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to keys service: %w", err)
+	}
+
+	return conn, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "keygenctl:", err)
+	os.Exit(1)
+}
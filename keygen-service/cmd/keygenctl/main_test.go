@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"keygen-service/keys"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+// adminMock records the requests of the admin API
+type adminMock struct {
+	keys.KeysAdminClient
+
+	requests []string
+	report   *keys.CheckReport
+}
+
+func (a *adminMock) GetStats(_ context.Context, in *keys.StatsRequest, _ ...grpc.CallOption) (*keys.Stats, error) {
+	a.requests = append(a.requests, "stats "+in.GetNamespace())
+
+	stats := &keys.Stats{Available: 3, Taken: 3, Reserved: 1, Quarantined: 1}
+	if in.GetByLength() {
+		stats.Lengths = []*keys.LengthStats{{KeyLength: 6, Available: 3, Taken: 3, Reserved: 1, Quarantined: 1}}
+	}
+
+	return stats, nil
+}
+
+func (a *adminMock) ListKeys(_ context.Context, in *keys.ListKeysRequest, _ ...grpc.CallOption) (*keys.KeyList, error) {
+	a.requests = append(a.requests, "list "+in.GetState())
+
+	if in.GetCursor() == "" {
+		return &keys.KeyList{Keys: [][]byte{[]byte("abcdef"), []byte("bcdefg")}, Cursor: "42"}, nil
+	}
+
+	return &keys.KeyList{Keys: [][]byte{[]byte("cdefgh")}}, nil
+}
+
+func (a *adminMock) SeedKeys(_ context.Context, in *keys.SeedRequest, _ ...grpc.CallOption) (*keys.SeedResponse, error) {
+	a.requests = append(a.requests, "seed "+in.GetNamespace())
+	return &keys.SeedResponse{Created: in.GetCount()}, nil
+}
+
+func (a *adminMock) LookupKey(_ context.Context, in *keys.KeyRequest, _ ...grpc.CallOption) (*keys.KeyStatus, error) {
+	a.requests = append(a.requests, "lookup "+string(in.GetKey()))
+	return &keys.KeyStatus{Key: in.GetKey(), State: string(keys.KeyTaken)}, nil
+}
+
+func (a *adminMock) BanKey(_ context.Context, in *keys.KeyRequest, _ ...grpc.CallOption) (*keys.Void, error) {
+	a.requests = append(a.requests, "ban "+string(in.GetKey()))
+	return &keys.Void{}, nil
+}
+
+func (a *adminMock) QuarantineKey(_ context.Context, in *keys.KeyRequest, _ ...grpc.CallOption) (*keys.Void, error) {
+	a.requests = append(a.requests, "quarantine "+string(in.GetKey()))
+	return &keys.Void{}, nil
+}
+
+func (a *adminMock) LiftQuarantine(_ context.Context, in *keys.KeyRequest, _ ...grpc.CallOption) (*keys.Void, error) {
+	a.requests = append(a.requests, "lift "+string(in.GetKey()))
+	return &keys.Void{}, nil
+}
+
+func (a *adminMock) CheckKeys(_ context.Context, in *keys.NamespaceRequest, _ ...grpc.CallOption) (*keys.CheckReport, error) {
+	a.requests = append(a.requests, "check "+in.GetName())
+	return a.report, nil
+}
+
+func (a *adminMock) Migrate(_ context.Context, in *keys.MigrationRequest, _ ...grpc.CallOption) (*keys.MigrationStatus, error) {
+	a.requests = append(a.requests, "migrate "+in.GetAction())
+
+	status := &keys.MigrationStatus{Phase: string(keys.MigrationCopying)}
+	if in.GetAction() == "verify" {
+		status.Source, status.Target = &keys.Stats{Available: 3}, &keys.Stats{Available: 3}
+		status.Checked = 3
+	}
+
+	return status, nil
+}
+
+func (a *adminMock) Reconcile(_ context.Context, in *keys.ReconcileRequest, _ ...grpc.CallOption) (*keys.Reconciliation, error) {
+	a.requests = append(a.requests, "reconcile "+in.GetNamespace())
+
+	if in.GetRepair() {
+		return &keys.Reconciliation{Scanned: 5, Duplicated: 1, Repaired: 1, Examples: []string{`duplicated "abcdef"`}}, nil
+	}
+
+	return &keys.Reconciliation{Scanned: 5}, nil
+}
+
+func (a *adminMock) AuditKeys(_ context.Context, in *keys.AuditRequest, _ ...grpc.CallOption) (*keys.KeyAudit, error) {
+	a.requests = append(a.requests, "audit "+string(in.GetKey()))
+
+	return &keys.KeyAudit{
+		Metadata: &keys.AuditMetadata{State: "taken", AllocatedAt: "2025-01-02T03:04:05Z", AllocatedBy: "url-shortener", AllocatedRequestId: "42"},
+		Events: []*keys.AuditEntry{
+			{Time: "2025-01-02T03:04:05Z", Key: in.GetKey(), Action: "allocated", State: "taken", Caller: "url-shortener", Peer: "10.0.0.1:5000", RequestId: "42"},
+		},
+	}, nil
+}
+
+func (a *adminMock) ListJobs(_ context.Context, _ *keys.Void, _ ...grpc.CallOption) (*keys.JobList, error) {
+	a.requests = append(a.requests, "jobs")
+
+	return &keys.JobList{Jobs: []*keys.ScheduledJob{{
+		Name: "reconcile", Schedule: "@every 1h0m0s", NextRun: "2025-01-02T04:00:00Z",
+		LastRun: "2025-01-02T03:00:00Z", LastDurationMs: 1200, LastError: "storage down", Runs: 3, Failures: 1, Skipped: 2,
+	}}}, nil
+}
+
+func (a *adminMock) TriggerJob(_ context.Context, in *keys.JobRequest, _ ...grpc.CallOption) (*keys.ScheduledJob, error) {
+	a.requests = append(a.requests, "trigger "+in.GetName())
+
+	return &keys.ScheduledJob{Name: in.GetName(), Running: true}, nil
+}
+
+func TestRun_GivenCommands(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"stats"}, "available: 3\ntaken: 3\nreserved: 1\nquarantined: 1\nbanned: 0\n"},
+		{[]string{"stats", "-by-length"}, "available: 3\ntaken: 3\nreserved: 1\nquarantined: 1\nbanned: 0\n" +
+			"length 6: 3 available, 3 taken, 1 reserved, 1 quarantined, 0 banned\n"},
+		{[]string{"list", "-state", "taken"}, "abcdef\nbcdefg\nnext page: -cursor 42\n"},
+		{[]string{"list", "-cursor", "42"}, "cdefgh\n"},
+		{[]string{"seed", "-length", "7", "1000"}, "created: 1000\n"},
+		{[]string{"lookup", "abcdef"}, "abcdef: taken\n"},
+		{[]string{"quarantine", "abcdef"}, "abcdef: quarantined\n"},
+		{[]string{"lift", "abcdef"}, "abcdef: available\n"},
+		{[]string{"ban", "abcdef"}, "abcdef: banned\n"},
+		{[]string{"check"}, "available: 3\ntaken: 2\nduplicated: 0\nbanned available: 0\n"},
+		{[]string{"reconcile"}, "scanned: 5\nduplicated: 0\nmalformed: 0\nbanned available: 0\nrepaired: 0\n"},
+		{[]string{"reconcile", "-repair"}, "scanned: 5\nduplicated: 1\nmalformed: 0\nbanned available: 0\nrepaired: 1\n" +
+			"anomaly: duplicated \"abcdef\"\n"},
+		{[]string{"audit", "abcdef"}, "state: taken\nallocated: 2025-01-02T03:04:05Z by url-shortener request 42\n" +
+			"2025-01-02T03:04:05Z abcdef allocated, taken by url-shortener from 10.0.0.1:5000 request 42\n"},
+		{[]string{"migrate", "status"}, "phase: copying\n"},
+		{[]string{"migrate", "verify"}, "phase: copying\n" +
+			"source: 3 available, 0 taken, 0 reserved, 0 quarantined, 0 banned\n" +
+			"target: 3 available, 0 taken, 0 reserved, 0 quarantined, 0 banned\n" +
+			"checked: 3\nmismatched: 0\n"},
+		{[]string{"jobs"}, "reconcile\t@every 1h0m0s\tidle\tnext 2025-01-02T04:00:00Z\tlast 2025-01-02T03:00:00Z (1200ms)" +
+			"\truns 3\tfailures 1\tskipped 2\n  last error: storage down\n"},
+		{[]string{"jobs", "trigger", "reconcile"}, "reconcile running\n"},
+	}
+
+	admin := &adminMock{report: &keys.CheckReport{Available: 3, Taken: 2}}
+	for _, tt := range tests {
+		out := &bytes.Buffer{}
+		if err := run(context.Background(), admin, "brand", tt.args, out); err != nil {
+			t.Errorf("run(%v) failed: %v", tt.args, err)
+		}
+
+		if out.String() != tt.want {
+			t.Errorf("run(%v) printed %q, want %q", tt.args, out.String(), tt.want)
+		}
+	}
+
+	if len(admin.requests) != len(tests) || admin.requests[0] != "stats brand" {
+		t.Errorf("run() sent %v, want a request per command of the namespace", admin.requests)
+	}
+}
+
+func TestRun_GivenInvalidCommands(t *testing.T) {
+	admin := &adminMock{report: &keys.CheckReport{Duplicated: 1}}
+
+	for _, args := range [][]string{{"unknown"}, {"seed", "-1"}, {"seed"}, {"lookup"}, {"ban", "a", "b"}, {"audit", "a", "b"}, {"migrate"}, {"jobs", "reconcile"}, {"reconcile", "-in-use", "keys.txt"}, {"check"}} {
+		if err := run(context.Background(), admin, "", args, &bytes.Buffer{}); err == nil {
+			t.Errorf("run(%v) succeeded, want an error", args)
+		}
+	}
+
+	if len(admin.requests) != 1 || !strings.HasPrefix(admin.requests[0], "check") {
+		t.Errorf("run() sent %v, want only the check request", admin.requests)
+	}
+}
+
+func TestLoadKeys_GivenFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.txt")
+	if err := os.WriteFile(valid, []byte("abcdef\n\n bcdefg\nabcdef\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := loadKeys(valid); err != nil || len(got) != 2 || !got["bcdefg"] {
+		t.Errorf("loadKeys() = %v, %v, want abcdef and bcdefg", got, err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.txt")
+	if err := os.WriteFile(invalid, []byte("abcdef\nab/def\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadKeys(invalid); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("loadKeys() = %v, want an error at line 2", err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"keygen-service/app"
+	"keygen-service/keys"
+	"keygen-service/snapshot"
+	"os"
+)
+
+// runSnapshot exports, imports or verifies snapshots
+// of the keys storage, given in process
+func runSnapshot(ctx context.Context, local *localAdmin, namespace string, args []string, out io.Writer) error {
+	command, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(out)
+	format := flags.String("format", string(snapshot.JSONL), "jsonl or csv, for export")
+	mode := flags.String("mode", string(snapshot.Merge), "merge or replace, for import")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%s takes a snapshot file", command)
+	}
+	file := flags.Arg(0)
+
+	switch command {
+	case "verify":
+		f, err := os.Open(file) // #nosec G304 -- path comes from the operator
+		if err != nil {
+			return fmt.Errorf("failed to open snapshot: %w", err)
+		}
+		defer f.Close()
+
+		header, trailer, err := snapshot.Verify(f)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "namespace %q, %d keys, created at %s, sha256 %s\n",
+			header.Namespace, trailer.Count, header.CreatedAt, trailer.SHA256)
+	case "export":
+		allocator, err := local.handler.Namespaces.Allocator(namespace, false)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Create(file) // #nosec G304 -- path comes from the operator
+		if err != nil {
+			return fmt.Errorf("failed to create snapshot: %w", err)
+		}
+		defer f.Close()
+
+		w := bufio.NewWriter(f)
+		exporter := &snapshot.Exporter{
+			Source:    allocator,
+			Owners:    local.owners(namespace),
+			Namespace: namespace,
+			Format:    snapshot.Format(*format),
+		}
+
+		trailer, err := exporter.Export(ctx, w)
+		if err != nil {
+			return err
+		}
+
+		if err := errors.Join(w.Flush(), f.Close()); err != nil {
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+
+		fmt.Fprintf(out, "exported %d keys, sha256 %s\n", trailer.Count, trailer.SHA256)
+	case "import":
+		if _, err := local.handler.Namespaces.Allocator(namespace, false); err != nil {
+			return err
+		}
+
+		storage, ok := app.As[keys.KeyRestorer](local.storage(namespace))
+		if !ok {
+			return errors.New("keys storage can't import keys")
+		}
+
+		f, err := os.Open(file) // #nosec G304 -- path comes from the operator
+		if err != nil {
+			return fmt.Errorf("failed to open snapshot: %w", err)
+		}
+		defer f.Close()
+
+		importer := &snapshot.Importer{Storage: storage, Owners: local.owners(namespace), Mode: snapshot.Mode(*mode)}
+
+		header, stats, err := importer.Import(ctx, f)
+		fmt.Fprintf(out, "imported %d keys, %d skipped\n", stats.Restored, stats.Skipped)
+		if err != nil {
+			return err
+		}
+
+		if header.Namespace != namespace {
+			fmt.Fprintf(out, "snapshot of namespace %q imported into %q\n", header.Namespace, namespace)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotLeader is returned by fences of lost leaderships
+var ErrNotLeader = errors.New("not the leader")
+
+// Elector grants a lease to a single identity at a time
+type Elector interface {
+	// TryAcquire takes, or renews, the lease for the identity
+	// during ttl; returns the fencing token of the lease when
+	// held, tokens grow with every new lease holder
+	TryAcquire(identity string, ttl time.Duration) (int64, bool, error)
+
+	// Release gives up the lease if held by the identity
+	Release(identity string) error
+
+	// Leader returns the identity holding the lease and
+	// its fencing token, empty when there is none
+	Leader() (string, int64, error)
+}
+
+// ElectionStatus is a snapshot of an Election
+type ElectionStatus struct {
+	Identity string
+	Leader   string
+	IsLeader bool
+	Token    int64
+}
+
+// Election keeps campaigning for a lease, renewing it
+// every third of TTL while held; a leader that dies
+// is replaced once its lease expires
+type Election struct {
+	Elector  Elector
+	Identity string
+	TTL      time.Duration
+
+	mu     sync.Mutex
+	status ElectionStatus
+}
+
+// Run should be launched in its own goroutine where it
+// campaigns until ctx is done; lead is called in its own
+// goroutine on each won election, with a context cancelled
+// when leadership is lost, and the lease fencing token
+func (e *Election) Run(ctx context.Context, lead func(ctx context.Context, token int64), ch chan error) {
+	defer close(ch)
+
+	var current *term
+	stopLeading := func() {
+		if current != nil {
+			current.cancel()
+			current = nil
+		}
+	}
+	defer stopLeading()
+
+	ticker := time.NewTicker(max(e.TTL/3, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		token, held, err := e.Elector.TryAcquire(e.Identity, e.TTL)
+		if err != nil {
+			held = false
+			select {
+			case ch <- fmt.Errorf("failed to campaign: %w", err):
+			case <-ctx.Done():
+			}
+		}
+
+		if current != nil && (!held || token != current.token) { // lost, or lost and won again
+			stopLeading()
+		}
+
+		if held && current == nil {
+			leadCtx, cancel := context.WithCancel(ctx)
+			current = &term{cancel: cancel, token: token}
+			go lead(leadCtx, token)
+		}
+
+		e.update(held, token)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			stopLeading()
+
+			if err := e.Elector.Release(e.Identity); err != nil {
+				ch <- fmt.Errorf("failed to release leadership: %w", err)
+			}
+			e.update(false, 0)
+
+			return
+		}
+	}
+}
+
+// Fence returns a check failing once the lease with the
+// given fencing token is gone, to run before writes
+// only the leader may do
+func (e *Election) Fence(token int64) func() error {
+	return func() error {
+		leader, current, err := e.Elector.Leader()
+		if err != nil {
+			return fmt.Errorf("failed to check leadership: %w", err)
+		}
+
+		if leader != e.Identity || current != token {
+			return fmt.Errorf("%w: lease %d is over", ErrNotLeader, token)
+		}
+
+		return nil
+	}
+}
+
+// term is a period of leadership
+type term struct {
+	cancel context.CancelFunc
+	token  int64
+}
+
+// Status returns who leads as last seen
+func (e *Election) Status() ElectionStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.status
+}
+
+func (e *Election) update(held bool, token int64) {
+	leader := ""
+	if held {
+		leader = e.Identity
+	} else if l, _, err := e.Elector.Leader(); err == nil {
+		leader = l
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.status = ElectionStatus{Identity: e.Identity, Leader: leader, IsLeader: held, Token: token}
+}
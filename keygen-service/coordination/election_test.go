@@ -0,0 +1,214 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// electorMock keeps the lease in memory; crashed
+// identities can't release their lease
+type electorMock struct {
+	mu        sync.Mutex
+	holder    string
+	expiresAt time.Time
+	token     int64
+	crashed   map[string]bool
+	err       error
+}
+
+func (e *electorMock) TryAcquire(identity string, ttl time.Duration) (int64, bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.err != nil {
+		return 0, false, e.err
+	}
+
+	if e.holder != "" && e.holder != identity && time.Now().Before(e.expiresAt) {
+		return 0, false, nil
+	}
+
+	if e.holder != identity || time.Now().After(e.expiresAt) {
+		e.token++
+	}
+
+	e.holder, e.expiresAt = identity, time.Now().Add(ttl)
+	return e.token, true, nil
+}
+
+func (e *electorMock) Release(identity string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.holder == identity && !e.crashed[identity] {
+		e.holder = ""
+	}
+
+	return nil
+}
+
+func (e *electorMock) Leader() (string, int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.holder == "" || time.Now().After(e.expiresAt) {
+		return "", 0, nil
+	}
+
+	return e.holder, e.token, nil
+}
+
+func (e *electorMock) crash(identity string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.crashed = map[string]bool{identity: true}
+}
+
+type replica struct {
+	election *Election
+	leading  atomic.Int64 // token while leading
+	cancel   context.CancelFunc
+	done     chan error
+}
+
+func startReplica(elector Elector, identity string) *replica {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &replica{
+		election: &Election{Elector: elector, Identity: identity, TTL: 30 * time.Millisecond},
+		cancel:   cancel,
+		done:     make(chan error),
+	}
+
+	go r.election.Run(ctx, func(ctx context.Context, token int64) {
+		r.leading.Store(token)
+		<-ctx.Done()
+		r.leading.Store(0)
+	}, r.done)
+
+	return r
+}
+
+func (r *replica) stop() {
+	r.cancel()
+	for range r.done { // wait for the election to end
+	}
+}
+
+func waitFor(condition func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestElection_GivenManyReplicas(t *testing.T) {
+	elector := &electorMock{}
+	replicas := []*replica{startReplica(elector, "a"), startReplica(elector, "b"), startReplica(elector, "c")}
+	defer func() {
+		for _, r := range replicas {
+			r.stop()
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	var leaders []string
+	for _, r := range replicas {
+		if r.leading.Load() > 0 {
+			leaders = append(leaders, r.election.Identity)
+		}
+	}
+
+	if len(leaders) != 1 {
+		t.Fatalf("Run() leaders = %v, want exactly one", leaders)
+	}
+
+	for _, r := range replicas {
+		if got := r.election.Status().Leader; got != leaders[0] {
+			t.Errorf("Status().Leader of %s = %v, want %v", r.election.Identity, got, leaders[0])
+		}
+	}
+}
+
+func TestElection_GivenLeaderStopped(t *testing.T) {
+	elector := &electorMock{}
+
+	first := startReplica(elector, "a")
+	if !waitFor(func() bool { return first.leading.Load() > 0 }) {
+		t.Fatal("Run() never led, want first replica leading")
+	}
+	firstToken := first.leading.Load()
+
+	second := startReplica(elector, "b")
+	defer second.stop()
+
+	first.stop()
+
+	if !waitFor(func() bool { return second.leading.Load() > 0 }) {
+		t.Fatal("Run() never failed over, want second replica leading")
+	}
+
+	if got := second.leading.Load(); got <= firstToken {
+		t.Errorf("Run() led with token %v, want greater than %v", got, firstToken)
+	}
+
+	if err := first.election.Fence(firstToken)(); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Fence(%v)() = %v, want %v", firstToken, err, ErrNotLeader)
+	}
+
+	if err := second.election.Fence(second.leading.Load())(); err != nil {
+		t.Errorf("Fence(%v)() = %v, want no errors", second.leading.Load(), err)
+	}
+
+	if status := first.election.Status(); status.IsLeader {
+		t.Errorf("Status() of stopped replica = %+v, want not leading", status)
+	}
+}
+
+func TestElection_GivenLeaderCrashed(t *testing.T) {
+	elector := &electorMock{}
+
+	first := startReplica(elector, "a")
+	if !waitFor(func() bool { return first.leading.Load() > 0 }) {
+		t.Fatal("Run() never led, want first replica leading")
+	}
+
+	second := startReplica(elector, "b")
+	defer second.stop()
+
+	elector.crash("a") // the lease is left to expire
+	first.stop()
+
+	if !waitFor(func() bool { return second.leading.Load() > 0 }) {
+		t.Fatal("Run() never failed over, want second replica leading after the lease expired")
+	}
+}
+
+func TestElection_GivenFailingElector(t *testing.T) {
+	elector := &electorMock{err: errors.New("failing elector")}
+	r := startReplica(elector, "a")
+	defer r.stop()
+
+	select {
+	case e := <-r.done:
+		want := "failed to campaign"
+		if e == nil || !strings.Contains(e.Error(), want) {
+			t.Errorf("Run() sent %v, want containing %v", e, want)
+		}
+	case <-time.After(time.Second):
+		t.Error("Run() timed out, want it to send a error")
+	}
+
+	if r.leading.Load() > 0 {
+		t.Error("Run() led with a failing elector, want no leadership")
+	}
+}
@@ -0,0 +1,144 @@
+package coordination
+
+import (
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valkey-io/valkey-glide/go/api"
+)
+
+// acquireScript renews the lease of its holder, or grants
+// a free lease with the next fencing token, returns the
+// token or -1 when someone else holds the lease
+const acquireScript = `
+local current = redis.call('GET', KEYS[1])
+if current then
+  local holder, token = string.match(current, '^(.*):(%d+)$')
+  if holder == ARGV[1] then
+    redis.call('PEXPIRE', KEYS[1], ARGV[2])
+    return tonumber(token)
+  end
+  return -1
+end
+local token = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], ARGV[1] .. ':' .. token, 'PX', ARGV[2])
+return token`
+
+// releaseScript drops the lease only when held by ARGV[1]
+const releaseScript = `
+local current = redis.call('GET', KEYS[1])
+if current and string.match(current, '^(.*):%d+$') == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0`
+
+// ValkeyElector keeps the lease in a valkey key expiring
+// with the lease, holding the holder identity and
+// fencing token taken from a counter key
+type ValkeyElector struct {
+	// Name of the lease, valkey keys are derived from it
+	Name string
+
+	// Client connects to the db, required
+	Client app.KeyValueDbClient
+}
+
+// TryAcquire takes, or renews, the lease for the identity
+func (v *ValkeyElector) TryAcquire(identity string, ttl time.Duration) (int64, bool, error) {
+	valkeyClient, err := v.conn()
+	if err != nil {
+		return 0, false, err
+	}
+
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", acquireScript, "2", v.leaseName(), v.tokenName(), identity, strconv.FormatInt(ttl.Milliseconds(), 10),
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+
+	token, ok := res.(int64)
+	if !ok {
+		return 0, false, fmt.Errorf("incompatible lease token: %v", res)
+	}
+
+	if token < 0 {
+		return 0, false, nil
+	}
+
+	return token, true, nil
+}
+
+// Release gives up the lease if held by the identity
+func (v *ValkeyElector) Release(identity string) error {
+	valkeyClient, err := v.conn()
+	if err != nil {
+		return err
+	}
+
+	if _, err := valkeyClient.CustomCommand([]string{"EVAL", releaseScript, "1", v.leaseName(), identity}); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+
+	return nil
+}
+
+// Leader returns the identity holding the lease
+// and its fencing token
+func (v *ValkeyElector) Leader() (string, int64, error) {
+	valkeyClient, err := v.conn()
+	if err != nil {
+		return "", 0, err
+	}
+
+	res, err := valkeyClient.Get(v.leaseName())
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get lease: %w", err)
+	}
+
+	if res.IsNil() {
+		return "", 0, nil
+	}
+
+	i := strings.LastIndex(res.Value(), ":")
+	if i < 0 {
+		return "", 0, fmt.Errorf("incompatible lease %q", res.Value())
+	}
+
+	token, err := strconv.ParseInt(res.Value()[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("incompatible lease token: %w", err)
+	}
+
+	return res.Value()[:i], token, nil
+}
+
+func (v *ValkeyElector) leaseName() string {
+	return v.Name + "Lease"
+}
+
+func (v *ValkeyElector) tokenName() string {
+	return v.Name + "LeaseToken"
+}
+
+func (v *ValkeyElector) conn() (api.GlideClientCommands, error) {
+	if v.Client == nil {
+		return nil, errors.New("no db client")
+	}
+
+	conn, err := v.Client.GetConn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to db: %w", err)
+	}
+
+	valkeyClient, ok := conn.(api.GlideClientCommands)
+	if !ok {
+		return nil, errors.New("incompatible db client")
+	}
+
+	return valkeyClient, nil
+}
@@ -3,25 +3,50 @@ package databases
 import (
 	"errors"
 	"fmt"
-	"keygen-service/app"
+	"sync"
 
 	"github.com/valkey-io/valkey-glide/go/api"
 )
 
-type ValkeyClient struct{}
+// ValkeyClient connects to the valkey at Host and Port
+type ValkeyClient struct {
+	Host string
+	Port int16
 
-// GetConn returns a valkey client for
-// commands execution
+	// TLS encrypts the connection, the server being
+	// verified against the system CAs (SSL_CERT_FILE)
+	TLS bool
+
+	// Username and Password authenticate the connection
+	// when a password is set, with the default user when
+	// no username is
+	Username string
+	Password string
+
+	// Database selects the logical database, 0 by default
+	Database int
+
+	mu     sync.Mutex
+	client api.GlideClientCommands
+}
+
+// GetConn returns the valkey client for commands
+// execution, connected on first use and shared by
+// every caller, reconnecting by itself when the
+// connection drops; callers must not close it
 func (v *ValkeyClient) GetConn() (interface{}, error) {
-	builtApp, err := app.GetApp()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get app: %w", err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.client != nil {
+		return v.client, nil
 	}
 
-	config := api.NewGlideClientConfiguration().WithAddress(
-		&api.NodeAddress{Host: builtApp.GetKeyValueDb().Host, Port: int(builtApp.GetKeyValueDb().Port)})
+	if v.Host == "" && v.Port == 0 {
+		return nil, errors.New("no valkey address")
+	}
 
-	client, err := api.NewGlideClient(config)
+	client, err := api.NewGlideClient(v.config(v.Host, v.Port))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to valkey: %w", err)
 	}
@@ -31,9 +56,36 @@ func (v *ValkeyClient) GetConn() (interface{}, error) {
 		return nil, fmt.Errorf("failed to contact valkey: %w", err)
 	}
 
+	v.client = client
 	return client, nil
 }
 
+// Close closes the shared client, the
+// next GetConn connecting again
+func (v *ValkeyClient) Close() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.client != nil {
+		v.client.Close()
+		v.client = nil
+	}
+}
+
+// config returns the connection configuration of the client
+func (v *ValkeyClient) config(host string, port int16) *api.GlideClientConfiguration {
+	config := api.NewGlideClientConfiguration().
+		WithAddress(&api.NodeAddress{Host: host, Port: int(port)}).
+		WithUseTLS(v.TLS).
+		WithDatabaseId(v.Database)
+
+	if v.Password != "" {
+		config = config.WithCredentials(api.NewServerCredentials(v.Username, v.Password))
+	}
+
+	return config
+}
+
 // Flush always return an error:
 // flush isn't supported in valkey client yet;
 // this should change in v2
@@ -3,6 +3,7 @@ module keygen-service
 go 1.24.2
 
 require (
+	github.com/rabbitmq/amqp091-go v1.10.0
 	github.com/valkey-io/valkey-glide/go v1.3.4
 	google.golang.org/grpc v1.72.2
 	google.golang.org/protobuf v1.36.5
@@ -0,0 +1,192 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule tells when a job runs next
+type Schedule interface {
+	// Next returns the first run time strictly after the given time
+	Next(after time.Time) time.Time
+
+	String() string
+}
+
+// Every runs a job every interval, at multiples of the
+// interval since the zero time (e.g. on the hour for 1h)
+// for every replica to agree on the run times
+type Every time.Duration
+
+// Next returns the next multiple of the interval after the given time
+func (e Every) Next(after time.Time) time.Time {
+	interval := max(time.Duration(e), time.Second)
+	return after.Truncate(interval).Add(interval)
+}
+
+func (e Every) String() string {
+	return "@every " + time.Duration(e).String()
+}
+
+// ParseSchedule reads "@every <duration>", a bare duration,
+// "@hourly", "@daily", "@weekly", "@monthly" or a cron
+// expression of 5 fields, minute hour day month weekday,
+// each "*", a value, a range "a-b", a list "a,b" or a step
+// "*/n" or "a-b/n"; cron times are UTC
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	descriptors := map[string]string{
+		"@hourly":   "0 * * * *",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@weekly":   "0 0 * * 0",
+		"@monthly":  "0 0 1 * *",
+	}
+	if expr, ok := descriptors[spec]; ok {
+		return parseCron(expr, spec)
+	}
+
+	if v, ok := strings.CutPrefix(spec, "@every "); ok || !strings.ContainsAny(spec, " *") {
+		interval, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil || interval < time.Second {
+			return nil, fmt.Errorf("invalid schedule %q: a second at least", spec)
+		}
+
+		return Every(interval), nil
+	}
+
+	return parseCron(spec, spec)
+}
+
+// cron matches the times whose fields are set in its bitmasks
+type cron struct {
+	spec                              string
+	minutes, hours, days, months, dow uint64
+	anyDay, anyWeekday                bool
+}
+
+// cronFields bounds the fields of a cron expression
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day", 1, 31},
+	{"month", 1, 12},
+	{"weekday", 0, 7}, // 7 is sunday too
+}
+
+func parseCron(expr, spec string) (*cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule %q: want %d cron fields", spec, len(cronFields))
+	}
+
+	var masks [5]uint64
+	for i, field := range fields {
+		mask, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %s: %w", spec, cronFields[i].name, err)
+		}
+		masks[i] = mask
+	}
+
+	if masks[4]&(1<<7) != 0 {
+		masks[4] |= 1
+	}
+
+	return &cron{
+		spec:    spec,
+		minutes: masks[0], hours: masks[1], days: masks[2], months: masks[3], dow: masks[4],
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, low, high int) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		from, to := low, high
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			if from, err = strconv.Atoi(first); err != nil {
+				return 0, fmt.Errorf("invalid value %q", first)
+			}
+
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(last); err != nil {
+					return 0, fmt.Errorf("invalid value %q", last)
+				}
+			} else if hasStep {
+				to = high
+			}
+		}
+
+		if from < low || to > high || from > to {
+			return 0, fmt.Errorf("%q out of %d-%d", part, low, high)
+		}
+
+		for v := from; v <= to; v += step {
+			mask |= 1 << v
+		}
+	}
+
+	return mask, nil
+}
+
+// Next returns the first matching minute after the given time, the zero
+// time when none matches within 5 years (e.g. on february 30th)
+func (c *cron) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		switch {
+		case c.months&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hours&(1<<t.Hour()) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case c.minutes&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// matchesDay matches the day of the month or the weekday when
+// both are restricted, as cron does, the restricted one otherwise
+func (c *cron) matchesDay(t time.Time) bool {
+	day := c.days&(1<<t.Day()) != 0
+	weekday := c.dow&(1<<int(t.Weekday())) != 0
+
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+
+	return day || weekday
+}
+
+func (c *cron) String() string {
+	return c.spec
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule_GivenSpecs(t *testing.T) {
+	after := time.Date(2026, time.March, 14, 10, 7, 30, 0, time.UTC) // a saturday
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"@every 15m", time.Date(2026, time.March, 14, 10, 15, 0, 0, time.UTC)},
+		{"1h", time.Date(2026, time.March, 14, 11, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, time.March, 14, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/5 * * * *", time.Date(2026, time.March, 14, 10, 10, 0, 0, time.UTC)},
+		{"30 2-4 * * *", time.Date(2026, time.March, 15, 2, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, time.March, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * 7", time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)}, // the 15th, or sundays
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		schedule, err := ParseSchedule(test.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) failed: %v", test.spec, err)
+			continue
+		}
+
+		if got := schedule.Next(after); !got.Equal(test.want) {
+			t.Errorf("ParseSchedule(%q).Next() = %v, want %v", test.spec, got, test.want)
+		}
+	}
+}
+
+func TestParseSchedule_GivenInvalidSpecs(t *testing.T) {
+	for _, spec := range []string{"", "@every 1ms", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) = nil, want an error", spec)
+		}
+	}
+
+	schedule, err := ParseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("ParseSchedule() failed: %v", err)
+	}
+
+	if next := schedule.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Next() = %v, want the zero time on february 30th", next)
+	}
+}
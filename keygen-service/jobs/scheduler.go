@@ -0,0 +1,308 @@
+// Package jobs runs the periodic background work of the
+// service, once per scheduled time across replicas
+package jobs
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrJobNotFound is returned for unknown jobs
+	ErrJobNotFound = errors.New("job not found")
+
+	// ErrJobRunning is returned when triggering a job
+	// already running, on any replica
+	ErrJobRunning = errors.New("job already running")
+)
+
+// Job is some work run on a schedule
+type Job struct {
+	Name     string
+	Schedule Schedule
+
+	// Jitter delays each run by a random duration up to it,
+	// sparing the storage from jobs scheduled together
+	Jitter time.Duration
+
+	// Timeout bounds a run, 1h when zero
+	Timeout time.Duration
+
+	Run func(ctx context.Context) error
+}
+
+// Locker grants named locks expiring after a ttl,
+// shared by the replicas
+type Locker interface {
+	// TryLock takes the lock for the owner unless held,
+	// returns whether it was taken
+	TryLock(name, owner string, ttl time.Duration) (bool, error)
+
+	// Unlock releases the lock if held by the owner
+	Unlock(name, owner string) error
+}
+
+// JobStatus is a snapshot of a scheduled job
+// as seen by the replica
+type JobStatus struct {
+	Name         string
+	Schedule     string
+	Running      bool
+	NextRun      time.Time
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    string
+	Runs         int64
+	Failures     int64
+	Skipped      int64 // run by another replica, or still running
+}
+
+// Scheduler runs jobs on their schedules; with a Locker, each
+// scheduled time is run by the first replica locking it and a
+// job runs on a single replica at a time
+type Scheduler struct {
+	Locker Locker
+
+	// Identity owns the locks taken by the replica
+	Identity string
+
+	mu   sync.Mutex
+	jobs []*scheduled
+	ctx  context.Context // of Run, bounding the triggered runs
+}
+
+type scheduled struct {
+	job    Job
+	status JobStatus
+}
+
+// Add schedules a job, before Run
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return errors.New("invalid job: a name, a schedule and a run func are required")
+	}
+
+	if job.Schedule.Next(time.Now().UTC()).IsZero() {
+		return fmt.Errorf("invalid job %s: schedule %s never runs", job.Name, job.Schedule)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.jobs {
+		if e.job.Name == job.Name {
+			return fmt.Errorf("invalid job %s: already scheduled", job.Name)
+		}
+	}
+
+	s.jobs = append(s.jobs, &scheduled{job: job, status: JobStatus{Name: job.Name, Schedule: job.Schedule.String()}})
+	return nil
+}
+
+// Run should be launched in its own goroutine where it runs
+// the jobs until ctx is done, then closes the channel once
+// the running jobs return
+func (s *Scheduler) Run(ctx context.Context, ch chan error) {
+	defer close(ch)
+
+	s.mu.Lock()
+	s.ctx = ctx
+	jobs := s.jobs
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, e, ch)
+		}()
+	}
+	wg.Wait()
+}
+
+// loop runs the job at its scheduled times until ctx is done
+func (s *Scheduler) loop(ctx context.Context, e *scheduled, ch chan error) {
+	for {
+		slot := e.job.Schedule.Next(time.Now().UTC())
+
+		s.mu.Lock()
+		e.status.NextRun = slot
+		s.mu.Unlock()
+
+		delay := time.Until(slot)
+		if e.job.Jitter > 0 {
+			// #nosec G404 -- jitter only spreads runs
+			delay += rand.N(e.job.Jitter)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+
+		run, err := s.begin(e, slot)
+		if err == nil && run != nil {
+			err = run(ctx)
+		}
+
+		if err != nil {
+			select {
+			case ch <- fmt.Errorf("job %s: %w", e.job.Name, err):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+// Trigger runs the job now on the replica, in the background,
+// unless it is running; returns its status once started
+func (s *Scheduler) Trigger(name string) (JobStatus, error) {
+	s.mu.Lock()
+	var e *scheduled
+	for _, candidate := range s.jobs {
+		if candidate.job.Name == name {
+			e = candidate
+			break
+		}
+	}
+	ctx := cmp.Or(s.ctx, context.Background())
+	s.mu.Unlock()
+
+	if e == nil {
+		return JobStatus{}, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+
+	run, err := s.begin(e, time.Time{})
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	if run == nil {
+		return JobStatus{}, fmt.Errorf("%w: %s", ErrJobRunning, name)
+	}
+
+	go func() {
+		if err := run(ctx); err != nil {
+			log.Printf("triggered job %s failed: %v", name, err)
+		}
+	}()
+
+	return s.status(e), nil
+}
+
+// begin claims a run of the job for the scheduled time, or a
+// triggered run when zero; returns the run, nil when skipped
+func (s *Scheduler) begin(e *scheduled, slot time.Time) (func(ctx context.Context) error, error) {
+	s.mu.Lock()
+	if e.status.Running {
+		e.status.Skipped++
+		s.mu.Unlock()
+		return nil, nil
+	}
+	e.status.Running = true
+	s.mu.Unlock()
+
+	skip := func(err error) (func(ctx context.Context) error, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		e.status.Running = false
+		e.status.Skipped++
+		return nil, err
+	}
+
+	timeout := cmp.Or(e.job.Timeout, time.Hour)
+	running := e.job.Name + ":running"
+
+	if s.Locker != nil {
+		if !slot.IsZero() {
+			// held until the next scheduled time for replicas running late to skip it
+			ttl := max(e.job.Schedule.Next(slot).Sub(slot), time.Minute)
+
+			locked, err := s.Locker.TryLock(e.job.Name+":"+strconv.FormatInt(slot.Unix(), 10), s.Identity, ttl)
+			if err != nil || !locked {
+				return skip(lockError(err))
+			}
+		}
+
+		locked, err := s.Locker.TryLock(running, s.Identity, timeout)
+		if err != nil || !locked {
+			return skip(lockError(err))
+		}
+	}
+
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		start := time.Now()
+		err := e.job.Run(ctx)
+
+		if s.Locker != nil {
+			if unlockErr := s.Locker.Unlock(running, s.Identity); unlockErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to unlock job: %w", unlockErr))
+			}
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		e.status.Running = false
+		e.status.LastRun, e.status.LastDuration = start.UTC(), time.Since(start)
+		e.status.Runs++
+		e.status.LastError = ""
+		if err != nil {
+			e.status.Failures++
+			e.status.LastError = err.Error()
+		}
+
+		return err
+	}, nil
+}
+
+func lockError(err error) error {
+	if err != nil {
+		return fmt.Errorf("failed to lock job: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the status of the jobs, in scheduling order
+func (s *Scheduler) List() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		statuses = append(statuses, e.status)
+	}
+
+	return statuses
+}
+
+// Stats returns the status of the jobs by name
+func (s *Scheduler) Stats() map[string]JobStatus {
+	stats := map[string]JobStatus{}
+	for _, status := range s.List() {
+		stats[status.Name] = status
+	}
+
+	return stats
+}
+
+func (s *Scheduler) status(e *scheduled) JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return e.status
+}
@@ -0,0 +1,195 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// lockerMock keeps the locks in memory, shared by schedulers
+type lockerMock struct {
+	mu    sync.Mutex
+	locks map[string]string
+}
+
+func (l *lockerMock) TryLock(name, owner string, _ time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locks == nil {
+		l.locks = map[string]string{}
+	}
+
+	if _, held := l.locks[name]; held {
+		return false, nil
+	}
+
+	l.locks[name] = owner
+	return true, nil
+}
+
+func (l *lockerMock) Unlock(name, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locks[name] == owner {
+		delete(l.locks, name)
+	}
+
+	return nil
+}
+
+// tickSchedule runs every few milliseconds, unaligned
+type tickSchedule struct{}
+
+func (tickSchedule) Next(after time.Time) time.Time { return after.Add(5 * time.Millisecond) }
+func (tickSchedule) String() string                 { return "tick" }
+
+func TestScheduler_GivenReplicas(t *testing.T) {
+	locker := &lockerMock{}
+
+	var runs atomic.Int64
+	job := Job{Name: "reconcile", Schedule: Every(time.Hour), Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}}
+
+	replicas := []*Scheduler{{Locker: locker, Identity: "a"}, {Locker: locker, Identity: "b"}}
+	slot := time.Date(2026, time.March, 14, 10, 0, 0, 0, time.UTC)
+
+	for _, replica := range replicas {
+		if err := replica.Add(job); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+
+		run, err := replica.begin(replica.jobs[0], slot)
+		if err != nil {
+			t.Fatalf("begin() failed: %v", err)
+		}
+
+		if run != nil {
+			if err := run(context.Background()); err != nil {
+				t.Fatalf("run() failed: %v", err)
+			}
+		}
+	}
+
+	if runs.Load() != 1 {
+		t.Errorf("runs = %d, want the scheduled time run by a single replica", runs.Load())
+	}
+
+	if a, b := replicas[0].List()[0], replicas[1].List()[0]; a.Runs != 1 || b.Runs != 0 || b.Skipped != 1 {
+		t.Errorf("List() = %+v and %+v, want the second replica skipping", a, b)
+	}
+
+	if _, held := locker.locks["reconcile:running"]; held {
+		t.Errorf("locks = %v, want the running lock released", locker.locks)
+	}
+}
+
+func TestScheduler_GivenRun(t *testing.T) {
+	scheduler := &Scheduler{Locker: &lockerMock{}, Identity: "a"}
+
+	ran := make(chan struct{}, 10)
+	failure := errors.New("storage down")
+
+	err := scheduler.Add(Job{Name: "snapshot", Schedule: tickSchedule{}, Jitter: time.Millisecond, Run: func(context.Context) error {
+		ran <- struct{}{}
+		return failure
+	}})
+	if err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan error)
+	go scheduler.Run(ctx, ch)
+
+	if err := <-ch; !errors.Is(err, failure) {
+		t.Errorf("Run() sent %v, want %v", err, failure)
+	}
+	<-ran
+
+	cancel()
+	for range ch {
+	}
+
+	status := scheduler.Stats()["snapshot"]
+	if status.Runs == 0 || status.Failures != status.Runs || status.LastError == "" || status.NextRun.IsZero() || status.Schedule != "tick" {
+		t.Errorf("Stats() = %+v, want the failed runs", status)
+	}
+}
+
+func TestScheduler_GivenTrigger(t *testing.T) {
+	locker := &lockerMock{}
+	scheduler := &Scheduler{Locker: locker, Identity: "a"}
+
+	release := make(chan struct{})
+	err := scheduler.Add(Job{Name: "reconcile", Schedule: Every(time.Hour), Run: func(context.Context) error {
+		<-release
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	status, err := scheduler.Trigger("reconcile")
+	if err != nil {
+		t.Fatalf("Trigger() failed: %v", err)
+	}
+
+	if !status.Running {
+		t.Errorf("Trigger() = %+v, want the job running", status)
+	}
+
+	if _, err := scheduler.Trigger("reconcile"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Trigger() = %v, want %v", err, ErrJobRunning)
+	}
+
+	other := &Scheduler{Locker: locker, Identity: "b"}
+	_ = other.Add(Job{Name: "reconcile", Schedule: Every(time.Hour), Run: func(context.Context) error { return nil }})
+	if _, err := other.Trigger("reconcile"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Trigger() = %v, want %v while running on another replica", err, ErrJobRunning)
+	}
+
+	close(release)
+	for scheduler.List()[0].Running {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := other.Trigger("reconcile"); err != nil {
+		t.Errorf("Trigger() failed once the job returned: %v", err)
+	}
+
+	if _, err := scheduler.Trigger("unknown"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Trigger() = %v, want %v", err, ErrJobNotFound)
+	}
+}
+
+func TestScheduler_GivenInvalidJobs(t *testing.T) {
+	scheduler := &Scheduler{}
+	run := func(context.Context) error { return nil }
+
+	never, _ := ParseSchedule("0 0 30 2 *")
+	for _, job := range []Job{
+		{Schedule: Every(time.Hour), Run: run},
+		{Name: "a", Run: run},
+		{Name: "a", Schedule: Every(time.Hour)},
+		{Name: "a", Schedule: never, Run: run},
+	} {
+		if err := scheduler.Add(job); err == nil {
+			t.Errorf("Add(%+v) = nil, want an error", job)
+		}
+	}
+
+	if err := scheduler.Add(Job{Name: "a", Schedule: Every(time.Hour), Run: run}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	if err := scheduler.Add(Job{Name: "a", Schedule: Every(time.Minute), Run: run}); err == nil {
+		t.Errorf("Add() = nil, want an error for a job scheduled twice")
+	}
+}
@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-glide/go/api"
+)
+
+// unlockScript drops the lock only when held by ARGV[1]
+const unlockScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0`
+
+// ValkeyLocker keeps each lock in a "keysJobLock:<name>"
+// valkey key holding its owner, expiring with the lock
+type ValkeyLocker struct {
+	Client app.KeyValueDbClient
+}
+
+// TryLock takes the lock for the owner unless held
+func (v *ValkeyLocker) TryLock(name, owner string, ttl time.Duration) (bool, error) {
+	valkeyClient, err := v.conn()
+	if err != nil {
+		return false, err
+	}
+
+	res, err := valkeyClient.CustomCommand([]string{
+		"SET", lockName(name), owner, "NX", "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to take lock %s: %w", name, err)
+	}
+
+	return res == "OK", nil
+}
+
+// Unlock releases the lock if held by the owner
+func (v *ValkeyLocker) Unlock(name, owner string) error {
+	valkeyClient, err := v.conn()
+	if err != nil {
+		return err
+	}
+
+	if _, err := valkeyClient.CustomCommand([]string{"EVAL", unlockScript, "1", lockName(name), owner}); err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func lockName(name string) string {
+	return "keysJobLock:" + name
+}
+
+func (v *ValkeyLocker) conn() (api.GlideClientCommands, error) {
+	if v.Client == nil {
+		return nil, errors.New("no db client")
+	}
+
+	conn, err := v.Client.GetConn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to db: %w", err)
+	}
+
+	valkeyClient, ok := conn.(api.GlideClientCommands)
+	if !ok {
+		return nil, errors.New("incompatible db client")
+	}
+
+	return valkeyClient, nil
+}
@@ -0,0 +1,600 @@
+package keys
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"keygen-service/jobs"
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AdminRPCHandler handles requests managing the keys service
+type AdminRPCHandler struct {
+	UnimplementedKeysAdminServer
+
+	Namespaces *Namespaces
+
+	// Generator returns generators of keys of a given
+	// length for SeedKeys, NextKeyOfLength when nil
+	Generator func(length int) func() (*ShortKey, error)
+
+	// Migration, when set, migrates the default
+	// namespace keys to another storage
+	Migration *MigratingEntity
+
+	// Jobs, when set, are the background jobs of the replica
+	Jobs *jobs.Scheduler
+}
+
+func (s *AdminRPCHandler) CreateNamespace(ctx context.Context, req *Namespace) (*Namespace, error) {
+	log.Printf("keys.CreateNamespace RPC called by %s for namespace %q", caller(ctx), req.GetName())
+
+	namespace, err := s.Namespaces.Create(KeyNamespace{
+		Name:         req.GetName(),
+		MinAvailable: req.GetMinAvailable(),
+		KeyLength:    int(req.GetKeyLength()),
+	})
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	return namespaceMessage(namespace), nil
+}
+
+func (s *AdminRPCHandler) ListNamespaces(ctx context.Context, _ *Void) (*NamespaceList, error) {
+	log.Printf("keys.ListNamespaces RPC called by %s", caller(ctx))
+
+	namespaces, err := s.Namespaces.List()
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	res := &NamespaceList{}
+	for _, namespace := range namespaces {
+		res.Namespaces = append(res.Namespaces, namespaceMessage(namespace))
+	}
+
+	return res, nil
+}
+
+func (s *AdminRPCHandler) RetireNamespace(ctx context.Context, req *NamespaceRequest) (*Namespace, error) {
+	log.Printf("keys.RetireNamespace RPC called by %s for namespace %q", caller(ctx), req.GetName())
+
+	namespace, err := s.Namespaces.Retire(req.GetName())
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	return namespaceMessage(namespace), nil
+}
+
+// ForceReleaseKey releases a key whoever owns it,
+// e.g. keys allocated before ownership was recorded
+func (s *AdminRPCHandler) ForceReleaseKey(ctx context.Context, req *KeyRequest) (*Void, error) {
+	log.Printf("keys.ForceReleaseKey RPC called by %s for key %v (%s) of namespace %q", caller(ctx), req.Key, req.Key, req.GetNamespace())
+
+	k, err := NewKeyFromBytes(req.Key)
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	allocator, err := s.Namespaces.Allocator(req.GetNamespace(), false)
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	if err := allocator.Release(ctx, *k); err != nil {
+		return nil, adminError(err)
+	}
+
+	log.Printf("audit: %s force released key %s of namespace %q", caller(ctx), k, req.GetNamespace())
+	return &Void{}, nil
+}
+
+// BanKey takes a key out of the pool for good, e.g. abusive keys
+func (s *AdminRPCHandler) BanKey(ctx context.Context, req *KeyRequest) (*Void, error) {
+	log.Printf("keys.BanKey RPC called by %s for key %v (%s) of namespace %q", caller(ctx), req.Key, req.Key, req.GetNamespace())
+
+	k, allocator, err := s.keyAllocator(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := allocator.Ban(ctx, *k); err != nil {
+		return nil, adminError(err)
+	}
+
+	log.Printf("audit: %s banned key %s of namespace %q", caller(ctx), k, req.GetNamespace())
+	return &Void{}, nil
+}
+
+// QuarantineKey holds a key nobody holds out of the
+// pool, e.g. while reviewing a reported key
+func (s *AdminRPCHandler) QuarantineKey(ctx context.Context, req *KeyRequest) (*Void, error) {
+	log.Printf("keys.QuarantineKey RPC called by %s for key %v (%s) of namespace %q", caller(ctx), req.Key, req.Key, req.GetNamespace())
+
+	k, allocator, err := s.keyAllocator(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := allocator.Quarantine(ctx, *k); err != nil {
+		return nil, adminError(err)
+	}
+
+	log.Printf("audit: %s quarantined key %s of namespace %q", caller(ctx), k, req.GetNamespace())
+	return &Void{}, nil
+}
+
+// LiftQuarantine makes a quarantined key available again
+func (s *AdminRPCHandler) LiftQuarantine(ctx context.Context, req *KeyRequest) (*Void, error) {
+	log.Printf("keys.LiftQuarantine RPC called by %s for key %v (%s) of namespace %q", caller(ctx), req.Key, req.Key, req.GetNamespace())
+
+	k, allocator, err := s.keyAllocator(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := allocator.LiftQuarantine(ctx, *k); err != nil {
+		return nil, adminError(err)
+	}
+
+	log.Printf("audit: %s lifted the quarantine of key %s of namespace %q", caller(ctx), k, req.GetNamespace())
+	return &Void{}, nil
+}
+
+// LookupKey tells whether a key is available, taken, reserved,
+// quarantined or banned, and whether its ownership is recorded
+func (s *AdminRPCHandler) LookupKey(ctx context.Context, req *KeyRequest) (*KeyStatus, error) {
+	log.Printf("keys.LookupKey RPC called by %s for key %v (%s) of namespace %q", caller(ctx), req.Key, req.Key, req.GetNamespace())
+
+	k, allocator, err := s.keyAllocator(req)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := allocator.State(ctx, *k)
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	res := &KeyStatus{
+		Key:       *k,
+		State:     string(state),
+		Namespace: req.GetNamespace(),
+		KeyLength: int32(len(*k)), // #nosec G115 -- validated key length
+	}
+
+	if allocator.Owners != nil && state == KeyTaken {
+		owner, err := allocator.Owners.Owner(*k)
+		if err != nil {
+			return nil, adminError(err)
+		}
+		res.Owned = owner != ""
+	}
+
+	return res, nil
+}
+
+// AuditKeys returns the metadata and audit events of a key,
+// or the latest events of the namespace without a key
+func (s *AdminRPCHandler) AuditKeys(ctx context.Context, req *AuditRequest) (*KeyAudit, error) {
+	log.Printf("keys.AuditKeys RPC called by %s for key %s of namespace %q", caller(ctx), req.GetKey(), req.GetNamespace())
+
+	var k ShortKey
+	if len(req.GetKey()) > 0 {
+		key, err := NewKeyFromBytes(req.GetKey())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "validation error: %v", err)
+		}
+		k = *key
+	}
+
+	allocator, err := s.namespaceAllocator(req.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	if allocator.Audit == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no keys audit configured")
+	}
+
+	var since time.Time
+	if req.GetSince() > 0 {
+		since = time.Unix(req.GetSince(), 0)
+	}
+
+	events, err := allocator.Audit.Events(k, since, int(req.GetLimit()))
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	res := &KeyAudit{Events: make([]*AuditEntry, len(events))}
+	for i, event := range events {
+		res.Events[i] = &AuditEntry{
+			Time:      event.Time.Format(time.RFC3339Nano),
+			Key:       []byte(event.Key),
+			Action:    string(event.Action),
+			State:     string(event.State),
+			Caller:    event.Caller,
+			Peer:      event.Peer,
+			RequestId: event.RequestID,
+		}
+	}
+
+	if k == nil {
+		return res, nil
+	}
+
+	metadata, ok, err := allocator.Audit.Metadata(k)
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	if ok {
+		res.Metadata = &AuditMetadata{
+			State:              string(metadata.State),
+			AllocatedAt:        auditTime(metadata.AllocatedAt),
+			AllocatedBy:        metadata.AllocatedBy,
+			AllocatedPeer:      metadata.AllocatedPeer,
+			AllocatedRequestId: metadata.AllocatedRequestID,
+			ReleasedAt:         auditTime(metadata.ReleasedAt),
+			ReleasedBy:         metadata.ReleasedBy,
+			ReleasedPeer:       metadata.ReleasedPeer,
+			ReleasedRequestId:  metadata.ReleasedRequestID,
+		}
+	}
+
+	return res, nil
+}
+
+// ListKeys pages through the keys of a namespace in a state
+func (s *AdminRPCHandler) ListKeys(ctx context.Context, req *ListKeysRequest) (*KeyList, error) {
+	log.Printf("keys.ListKeys RPC called by %s for %q keys of namespace %q", caller(ctx), req.GetState(), req.GetNamespace())
+
+	allocator, err := s.namespaceAllocator(req.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	page, cursor, err := allocator.ListKeys(ctx, KeyState(req.GetState()), req.GetCursor(), int(req.GetCount()))
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	res := &KeyList{Cursor: cursor, Keys: make([][]byte, len(page))}
+	for i, k := range page {
+		res.Keys[i] = k
+	}
+
+	return res, nil
+}
+
+// GetStats counts the keys of a namespace in each
+// state, by key length when asked to
+func (s *AdminRPCHandler) GetStats(ctx context.Context, req *StatsRequest) (*Stats, error) {
+	log.Printf("keys.GetStats RPC called by %s for namespace %q", caller(ctx), req.GetNamespace())
+
+	allocator, err := s.namespaceAllocator(req.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := allocator.CountStates(ctx, req.GetByLength())
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	res := &Stats{
+		Namespace:   req.GetNamespace(),
+		Available:   stats.Available,
+		Taken:       stats.Taken,
+		Reserved:    stats.Reserved,
+		Quarantined: stats.Quarantined,
+		Banned:      stats.Banned,
+	}
+
+	for _, length := range slices.Sorted(maps.Keys(stats.Lengths)) {
+		counts := stats.Lengths[length]
+		res.Lengths = append(res.Lengths, &LengthStats{
+			KeyLength:   int32(length), // #nosec G115 -- key lengths
+			Available:   counts.Available,
+			Taken:       counts.Taken,
+			Reserved:    counts.Reserved,
+			Quarantined: counts.Quarantined,
+			Banned:      counts.Banned,
+		})
+	}
+
+	return res, nil
+}
+
+// SeedKeys creates new keys in a namespace, e.g. ahead of a
+// traffic peak, with the namespace key length by default
+func (s *AdminRPCHandler) SeedKeys(ctx context.Context, req *SeedRequest) (*SeedResponse, error) {
+	log.Printf("keys.SeedKeys RPC called by %s for %d keys of namespace %q", caller(ctx), req.GetCount(), req.GetNamespace())
+
+	if req.GetCount() <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid keys count %d", req.GetCount())
+	}
+
+	length := int(req.GetKeyLength())
+	if req.GetNamespace() != "" && length == 0 {
+		namespace, err := s.Namespaces.Registry.Get(req.GetNamespace())
+		if err != nil {
+			return nil, adminError(err)
+		}
+		length = namespace.KeyLength
+	}
+
+	length = cmp.Or(length, MinKeyLength)
+	if length < MinKeyLength || length > MaxKeyLength {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid key length %d", length)
+	}
+
+	allocator, err := s.namespaceAllocator(req.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	generator := NextKeyOfLength
+	if s.Generator != nil {
+		generator = s.Generator
+	}
+
+	created, err := allocator.Seed(ctx, generator(length), req.GetCount())
+	log.Printf("audit: %s seeded %d keys of namespace %q", caller(ctx), created, req.GetNamespace())
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	return &SeedResponse{Created: created}, nil
+}
+
+// CheckKeys looks for keys both available and
+// taken, and for banned keys still available
+func (s *AdminRPCHandler) CheckKeys(ctx context.Context, req *NamespaceRequest) (*CheckReport, error) {
+	log.Printf("keys.CheckKeys RPC called by %s for namespace %q", caller(ctx), req.GetName())
+
+	allocator, err := s.namespaceAllocator(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := allocator.Check(ctx)
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	return &CheckReport{
+		Namespace:       req.GetName(),
+		Available:       report.Available,
+		Taken:           report.Taken,
+		Duplicated:      report.Duplicated,
+		BannedAvailable: report.BannedAvailable,
+	}, nil
+}
+
+// Reconcile looks for duplicated, malformed and banned available
+// keys of a namespace, removed from the available ones with repair
+func (s *AdminRPCHandler) Reconcile(ctx context.Context, req *ReconcileRequest) (*Reconciliation, error) {
+	log.Printf("keys.Reconcile RPC called by %s for namespace %q, repairing: %t", caller(ctx), req.GetNamespace(), req.GetRepair())
+
+	allocator, err := s.namespaceAllocator(req.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := allocator.Reconcile(ctx, &Reconciler{Repair: req.GetRepair()})
+	if req.GetRepair() {
+		log.Printf("audit: %s repaired %d keys of namespace %q", caller(ctx), report.Repaired, req.GetNamespace())
+	}
+
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	return ReconciliationMessage(req.GetNamespace(), report), nil
+}
+
+// Migrate runs a step of the keys storage migration
+func (s *AdminRPCHandler) Migrate(ctx context.Context, req *MigrationRequest) (*MigrationStatus, error) {
+	log.Printf("keys.Migrate RPC called by %s for %q", caller(ctx), req.GetAction())
+
+	if s.Migration == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no keys migration configured")
+	}
+
+	res := &MigrationStatus{}
+
+	var err error
+	var report MigrationReport
+	switch req.GetAction() {
+	case "status":
+	case "start":
+		res.Copied, err = s.Migration.Start(ctx)
+	case "copy":
+		res.Copied, err = s.Migration.Copy(ctx)
+	case "verify":
+		report, err = s.Migration.Verify(ctx)
+	case "cutover":
+		report, err = s.Migration.CutOver(ctx)
+	case "rollback":
+		_, err = s.Migration.Rollback()
+	case "finish":
+		err = s.Migration.Finish()
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown migration action %q", req.GetAction())
+	}
+
+	if req.GetAction() != "status" && req.GetAction() != "verify" {
+		log.Printf("audit: %s ran migration %s, %d keys copied: %v", caller(ctx), req.GetAction(), res.Copied, err)
+	}
+
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	phase, err := s.Migration.Phase()
+	if err != nil {
+		return nil, adminError(err)
+	}
+	res.Phase = string(phase)
+
+	if req.GetAction() == "verify" || req.GetAction() == "cutover" {
+		res.Source = countsMessage(report.Source)
+		res.Target = countsMessage(report.Target)
+		res.Checked, res.Mismatched = report.Checked, report.Mismatched
+		for _, k := range report.Mismatches {
+			res.Mismatches = append(res.Mismatches, k)
+		}
+	}
+
+	return res, nil
+}
+
+// ListJobs returns the background jobs of the replica
+func (s *AdminRPCHandler) ListJobs(ctx context.Context, _ *Void) (*JobList, error) {
+	log.Printf("keys.ListJobs RPC called by %s", caller(ctx))
+
+	if s.Jobs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no background jobs scheduled")
+	}
+
+	res := &JobList{}
+	for _, job := range s.Jobs.List() {
+		res.Jobs = append(res.Jobs, jobMessage(job))
+	}
+
+	return res, nil
+}
+
+// TriggerJob runs a background job now, on the replica
+func (s *AdminRPCHandler) TriggerJob(ctx context.Context, req *JobRequest) (*ScheduledJob, error) {
+	log.Printf("keys.TriggerJob RPC called by %s for job %q", caller(ctx), req.GetName())
+
+	if s.Jobs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no background jobs scheduled")
+	}
+
+	job, err := s.Jobs.Trigger(req.GetName())
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	log.Printf("audit: %s triggered job %s", caller(ctx), req.GetName())
+	return jobMessage(job), nil
+}
+
+// keyAllocator validates the key of the request
+// and returns the allocator of its namespace
+func (s *AdminRPCHandler) keyAllocator(req *KeyRequest) (*ShortKey, *Allocator, error) {
+	k, err := NewKeyFromBytes(req.Key)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "validation error: %v", err)
+	}
+
+	allocator, err := s.namespaceAllocator(req.GetNamespace())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return k, allocator, nil
+}
+
+func (s *AdminRPCHandler) namespaceAllocator(namespace string) (*Allocator, error) {
+	allocator, err := s.Namespaces.Allocator(namespace, false)
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	return allocator, nil
+}
+
+// adminError maps failures to status codes, for tools to tell
+// a wrong request from a failure worth retrying
+func adminError(err error) error {
+	var validation ShortKeyValidationError
+	switch {
+	case errors.Is(err, ErrNamespaceNotFound), errors.Is(err, ErrKeyNotFound), errors.Is(err, jobs.ErrJobNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrInvalidListing), errors.Is(err, ErrInvalidNamespace), errors.As(err, &validation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrNamespaceExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrKeyBanned), errors.Is(err, ErrKeyQuarantined), errors.Is(err, ErrKeyTaken):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrInspectUnsupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, ErrKeyExists):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, ErrMigrationPhase), errors.Is(err, ErrMigrationDiverged), errors.Is(err, jobs.ErrJobRunning):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Errorf(codes.Internal, "internal error: %v", err)
+	}
+}
+
+func namespaceMessage(namespace KeyNamespace) *Namespace {
+	return &Namespace{
+		Name:         namespace.Name,
+		MinAvailable: namespace.MinAvailable,
+		KeyLength:    int32(namespace.KeyLength), // #nosec G115 -- validated key length
+		Retired:      namespace.Retired,
+	}
+}
+
+// auditTime formats a time of the metadata, empty when unset
+func auditTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
+
+func jobMessage(job jobs.JobStatus) *ScheduledJob {
+	return &ScheduledJob{
+		Name:           job.Name,
+		Schedule:       job.Schedule,
+		Running:        job.Running,
+		NextRun:        auditTime(job.NextRun),
+		LastRun:        auditTime(job.LastRun),
+		LastDurationMs: job.LastDuration.Milliseconds(),
+		LastError:      job.LastError,
+		Runs:           job.Runs,
+		Failures:       job.Failures,
+		Skipped:        job.Skipped,
+	}
+}
+
+func countsMessage(counts StateCounts) *Stats {
+	return &Stats{
+		Available:   counts.Available,
+		Taken:       counts.Taken,
+		Reserved:    counts.Reserved,
+		Quarantined: counts.Quarantined,
+		Banned:      counts.Banned,
+	}
+}
+
+// ReconciliationMessage returns the report of a namespace reconciliation
+func ReconciliationMessage(namespace string, report ReconcileReport) *Reconciliation {
+	return &Reconciliation{
+		Namespace:       namespace,
+		Scanned:         report.Scanned,
+		Duplicated:      report.Duplicated,
+		Malformed:       report.Malformed,
+		BannedAvailable: report.BannedAvailable,
+		Unused:          report.Unused,
+		InUseNotTaken:   report.InUseNotTaken,
+		Repaired:        report.Repaired,
+		Examples:        report.Examples,
+	}
+}
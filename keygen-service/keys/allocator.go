@@ -0,0 +1,487 @@
+package keys
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"sync/atomic"
+	"time"
+)
+
+// ErrReserveUnsupported is returned when reserving
+// keys on a storage unable to do so
+var ErrReserveUnsupported = errors.New("keys storage can't reserve keys")
+
+// Allocator hands keys out of a storage in-process,
+// without the RPC service in between
+type Allocator struct {
+	// Keys is the keys storage, required
+	Keys app.KeyValueEntity
+
+	// Generator, when set, creates keys on
+	// demand while the storage has none available
+	Generator func() (*ShortKey, error)
+
+	// Prefetch, when set, serves allocations from memory
+	Prefetch *PrefetchBuffer
+
+	// Owners, when set, records the token of owned
+	// allocations, then required to release them;
+	// AllowUnowned releases keys without token, e.g.
+	// allocated before tokens were recorded, whatever
+	// the token given
+	Owners       Ownership
+	AllowUnowned bool
+
+	// Audit, when set, records who allocated
+	// and released keys, and when
+	Audit AuditLog
+
+	// Events, when set, publishes the changes of keys
+	// as events of the Namespace
+	Events    *EventPublisher
+	Namespace string
+
+	// Retries is how many times transient storage
+	// failures are retried, spaced by Backoff
+	Retries int
+	Backoff Backoff
+
+	// Replenishment, when set, lets allocations of contexts
+	// WithWait wait for keys, up to MaxWait (5s when zero)
+	// without deadline
+	Replenishment *Replenishment
+	MaxWait       time.Duration
+
+	allocated, generated, released, reserved, failures, denied, auditFailures atomic.Int64
+	waits, waitTimeouts                                                       atomic.Int64
+}
+
+// AllocatorStats is a snapshot of an Allocator, with
+// the storage counts when it can count its keys
+type AllocatorStats struct {
+	Allocated int64
+	Generated int64 // allocated keys created on demand
+	Released  int64
+	Reserved  int64
+	Failures  int64
+	Denied    int64 // releases without the owner token
+
+	AuditFailures int64
+
+	Waits        int64 // allocations waiting for keys to be created
+	WaitTimeouts int64
+
+	Available      int64
+	StorageInUse   int64
+	StorageCounted bool
+}
+
+// NewAllocator returns an Allocator over the given storage,
+// retrying transient failures twice; the generator may be nil
+func NewAllocator(keys app.KeyValueEntity, generator func() (*ShortKey, error)) *Allocator {
+	return &Allocator{Keys: keys, Generator: generator, Retries: 2}
+}
+
+// Allocate takes an available key, or a new one from Generator
+// when there is none; allocations of contexts WithWait wait for
+// the keys created meanwhile, see Replenishment
+func (a *Allocator) Allocate(ctx context.Context) (ShortKey, error) {
+	allocate := a.allocate
+	if a.Replenishment != nil && waits(ctx) {
+		allocate = a.await
+	}
+
+	key, generated, err := allocate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a.allocated.Add(1)
+	a.audit(ctx, key, AuditAllocated, KeyTaken)
+	if generated {
+		a.publish(ctx, EventCreated, key, 1)
+	}
+	a.publish(ctx, EventAllocated, key, 1)
+
+	return key, nil
+}
+
+// allocate takes a key, telling whether it was generated
+func (a *Allocator) allocate(ctx context.Context) (ShortKey, bool, error) {
+	var key ShortKey
+	var generated bool
+	err := a.retry(ctx, func(entity app.KeyValueEntity) error {
+		allocate := entity.AllocateFirst
+		if a.Prefetch != nil {
+			allocate = a.Prefetch.AllocateFirst
+		}
+
+		i, err := allocate()
+		if errors.Is(err, ErrNoAvailableKeys) && a.Generator != nil {
+			err = a.generate(entity, &key)
+			generated = err == nil
+
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+
+		k, ok := i.(ShortKey)
+		if !ok {
+			return errors.New("could not convert allocated value into a key")
+		}
+		key = k
+
+		return nil
+	})
+
+	return key, generated, err
+}
+
+// await allocates a key, waking the generator and allocating again
+// once keys are created while there is none, until ctx is done or,
+// without deadline, MaxWait passed
+func (a *Allocator) await(ctx context.Context) (ShortKey, bool, error) {
+	for waiting := false; ; {
+		created := a.Replenishment.next() // before allocating, not to miss a creation
+
+		key, generated, err := a.allocate(ctx)
+		if !errors.Is(err, ErrNoAvailableKeys) {
+			return key, generated, err
+		}
+
+		if !waiting {
+			waiting = true
+			a.waits.Add(1)
+			a.Replenishment.waiting.Add(1)
+			defer a.Replenishment.waiting.Add(-1)
+
+			if _, ok := ctx.Deadline(); !ok {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, cmp.Or(a.MaxWait, 5*time.Second))
+				defer cancel()
+			}
+		}
+
+		a.Replenishment.wake()
+
+		select {
+		case <-created:
+		case <-ctx.Done():
+			a.waitTimeouts.Add(1)
+			return nil, false, fmt.Errorf("%w: %w", err, ctx.Err())
+		}
+	}
+}
+
+// AllocateOwned allocates a key and records a new token
+// of its allocation, returned to prove its ownership;
+// the token is empty without Owners
+func (a *Allocator) AllocateOwned(ctx context.Context) (ShortKey, string, error) {
+	key, err := a.Allocate(ctx)
+	if err != nil || a.Owners == nil {
+		return key, "", err
+	}
+
+	token, err := NewOwnershipToken()
+	if err == nil {
+		err = a.retry(ctx, func(app.KeyValueEntity) error { return a.Owners.Own(key, token) })
+	}
+
+	if err != nil { // an unowned key could never be released
+		if releaseErr := a.Release(ctx, key); releaseErr != nil {
+			return nil, "", errors.Join(err, releaseErr)
+		}
+
+		return nil, "", err
+	}
+
+	return key, token, nil
+}
+
+// ReleaseOwned makes a key available again given the token of its
+// allocation, failing with ErrNotOwner otherwise; without Owners,
+// the token is ignored
+func (a *Allocator) ReleaseOwned(ctx context.Context, key ShortKey, token string) error {
+	if a.Owners == nil {
+		return a.Release(ctx, key)
+	}
+
+	if _, err := NewKeyFromBytes(key); err != nil {
+		return err
+	}
+
+	err := a.releaseOwned(ctx, key, token)
+	if errors.Is(err, ErrNotOwner) {
+		a.denied.Add(1)
+		a.audit(ctx, key, AuditReleaseDenied, KeyTaken)
+	}
+
+	return err
+}
+
+// Release makes an allocated key available again,
+// whoever owns it
+func (a *Allocator) Release(ctx context.Context, key ShortKey) error {
+	if _, err := NewKeyFromBytes(key); err != nil {
+		return err
+	}
+
+	owner, err := a.owner(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if err := a.deallocate(ctx, key); err != nil {
+		return err
+	}
+
+	if err := a.disown(ctx, key, owner); err != nil {
+		return fmt.Errorf("key released but its owner kept: %w", err)
+	}
+
+	return nil
+}
+
+// Reclaim releases the key of an expired allocation, checking
+// the token when given. A key released already, even if
+// allocated again since, with another token, counts as
+// reclaimed; without a token it is released whoever owns it
+func (a *Allocator) Reclaim(ctx context.Context, key ShortKey, token string) error {
+	if _, err := NewKeyFromBytes(key); err != nil {
+		return err
+	}
+
+	var err error
+	if token != "" && a.Owners != nil {
+		err = a.releaseOwned(ctx, key, token)
+	} else {
+		err = a.Release(ctx, key)
+	}
+
+	switch {
+	case errors.Is(err, ErrNotOwner): // the token is forgotten once released
+		return nil
+	case errors.Is(err, ErrKeyNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
+// releaseOwned claims the key by forgetting its token, when
+// it is the given one, before deallocating it: the key is then
+// never released for a former owner once allocated again; keys
+// without token, allocated before ownership was recorded, are
+// released with any token when unowned keys are allowed
+func (a *Allocator) releaseOwned(ctx context.Context, key ShortKey, token string) error {
+	// the token is forgotten atomically, a token missing after
+	// a transient failure was forgotten by the failed attempt
+	failed, owned := false, false
+	err := a.retry(ctx, func(app.KeyValueEntity) error {
+		recorded, err := a.Owners.Disown(key, token)
+		owned = recorded || failed && err == nil
+		failed = err != nil
+
+		return err
+	})
+	if err == nil && !owned && !a.AllowUnowned {
+		err = fmt.Errorf("%w: %s", ErrNotOwner, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = a.deallocate(ctx, key)
+	if err == nil || !owned || errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+
+	// the key is still taken, its owner may try again
+	if ownErr := a.retry(ctx, func(app.KeyValueEntity) error { return a.Owners.Own(key, token) }); ownErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to restore the key owner: %w", ownErr))
+	}
+
+	return err
+}
+
+// deallocate makes the key available again
+func (a *Allocator) deallocate(ctx context.Context, key ShortKey) error {
+	// deallocations are atomic, a key not found after a
+	// transient failure was released by the failed attempt
+	failed := false
+	err := a.retry(ctx, func(entity app.KeyValueEntity) error {
+		err := entity.Deallocate(&key)
+		if failed && errors.Is(err, ErrKeyNotFound) {
+			return nil
+		}
+		failed = err != nil
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	a.audit(ctx, key, AuditReleased, KeyAvailable)
+	a.publish(ctx, EventReleased, key, 1)
+	if a.Replenishment != nil && a.Replenishment.Waiting() > 0 {
+		a.Replenishment.Created()
+	}
+
+	a.released.Add(1)
+	return nil
+}
+
+// owner returns the token of the key, empty without Owners
+func (a *Allocator) owner(ctx context.Context, key ShortKey) (string, error) {
+	if a.Owners == nil {
+		return "", nil
+	}
+
+	var owner string
+	err := a.retry(ctx, func(app.KeyValueEntity) error {
+		var err error
+		owner, err = a.Owners.Owner(key)
+		return err
+	})
+
+	return owner, err
+}
+
+// disown forgets the token of the key unless the key
+// was allocated again since, with another token
+func (a *Allocator) disown(ctx context.Context, key ShortKey, token string) error {
+	if a.Owners == nil || token == "" {
+		return nil
+	}
+
+	err := a.retry(ctx, func(app.KeyValueEntity) error {
+		_, err := a.Owners.Disown(key, token)
+		return err
+	})
+	if errors.Is(err, ErrNotOwner) {
+		return nil
+	}
+
+	return err
+}
+
+// Reserve allocates the given key, e.g. a custom one,
+// failing with ErrKeyTaken when already allocated
+func (a *Allocator) Reserve(ctx context.Context, key ShortKey) error {
+	if _, err := NewKeyFromBytes(key); err != nil {
+		return err
+	}
+
+	err := a.retry(ctx, func(entity app.KeyValueEntity) error {
+		reserver, ok := app.As[app.KeyValueReserver](entity)
+		if !ok {
+			return ErrReserveUnsupported
+		}
+
+		return reserver.Reserve(&key)
+	})
+	if err != nil {
+		return err
+	}
+
+	a.reserved.Add(1)
+	a.audit(ctx, key, AuditReserved, KeyReserved)
+	a.publish(ctx, EventReserved, key, 1)
+
+	return nil
+}
+
+// Stats returns the allocator counters
+func (a *Allocator) Stats() AllocatorStats {
+	stats := AllocatorStats{
+		Allocated: a.allocated.Load(),
+		Generated: a.generated.Load(),
+		Released:  a.released.Load(),
+		Reserved:  a.reserved.Load(),
+		Failures:  a.failures.Load(),
+		Denied:    a.denied.Load(),
+
+		AuditFailures: a.auditFailures.Load(),
+
+		Waits:        a.waits.Load(),
+		WaitTimeouts: a.waitTimeouts.Load(),
+	}
+
+	if entity, err := a.entity(); err == nil {
+		if counter, ok := app.As[app.KeyValueCounter](entity); ok {
+			if available, allocated, err := counter.Count(); err == nil {
+				stats.Available, stats.StorageInUse, stats.StorageCounted = available, allocated, true
+			}
+		}
+	}
+
+	return stats
+}
+
+// generate reserves a new key, generating
+// again when the key was already known
+func (a *Allocator) generate(entity app.KeyValueEntity, key *ShortKey) error {
+	reserver, ok := app.As[app.KeyValueReserver](entity)
+	if !ok {
+		return ErrNoAvailableKeys
+	}
+
+	for {
+		newKey, err := a.Generator()
+		if err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+
+		if err := reserver.Reserve(newKey); errors.Is(err, ErrKeyTaken) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		a.generated.Add(1)
+		*key = *newKey
+
+		return nil
+	}
+}
+
+// retry calls f with the storage, retrying transient
+// failures with backoff until ctx is done
+func (a *Allocator) retry(ctx context.Context, f func(entity app.KeyValueEntity) error) error {
+	entity, err := a.entity()
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := f(entity)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= a.Retries || !IsTransient(err) || errors.Is(err, ErrNoAvailableKeys) || errors.Is(err, ErrReserveUnsupported) {
+			a.failures.Add(1)
+			return err
+		}
+
+		sleep(ctx, a.Backoff.Delay(attempt))
+		if ctx.Err() != nil {
+			a.failures.Add(1)
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+	}
+}
+
+func (a *Allocator) entity() (app.KeyValueEntity, error) {
+	if a.Keys == nil {
+		return nil, ErrNoKeysStorage
+	}
+
+	return a.Keys, nil
+}
@@ -0,0 +1,114 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"keygen-service/app"
+)
+
+func TestAllocator_GivenAvailableKeys(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(2))
+	allocator := NewAllocator(entity, nil)
+
+	k, err := allocator.Allocate(context.Background())
+	if err != nil {
+		t.Fatalf("Allocate() failed: %v", err)
+	}
+
+	if err := allocator.Release(context.Background(), k); err != nil {
+		t.Errorf("Release() = %v, want no errors", err)
+	}
+
+	if err := allocator.Release(context.Background(), k); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Release() = %v, want %v", err, ErrKeyNotFound)
+	}
+
+	stats := allocator.Stats()
+	if stats.Allocated != 1 || stats.Released != 1 || stats.Failures != 1 || !stats.StorageCounted || stats.Available != 2 {
+		t.Errorf("Stats() = %+v, want a key allocated and released out of 2", stats)
+	}
+}
+
+func TestAllocator_GivenNoAvailableKeys(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(nil)
+
+	if _, err := NewAllocator(entity, nil).Allocate(context.Background()); !errors.Is(err, ErrNoAvailableKeys) {
+		t.Errorf("Allocate() = %v, want %v", err, ErrNoAvailableKeys)
+	}
+
+	allocator := NewAllocator(entity, NextKey)
+	k, err := allocator.Allocate(context.Background())
+	if err != nil {
+		t.Fatalf("Allocate() failed: %v", err)
+	}
+
+	if _, taken := entity.count(); taken != 1 {
+		t.Errorf("Allocate() left %v taken keys, want the generated key %s", taken, k)
+	}
+
+	if got := allocator.Stats().Generated; got != 1 {
+		t.Errorf("Stats().Generated = %v, want 1", got)
+	}
+}
+
+func TestAllocator_GivenFlakyStorage(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(1))
+	entity.flaky = 2
+	allocator := NewAllocator(entity, nil)
+	allocator.Backoff = Backoff{Initial: time.Millisecond, Max: time.Millisecond}
+
+	if _, err := allocator.Allocate(context.Background()); err != nil {
+		t.Errorf("Allocate() = %v, want transient failures retried", err)
+	}
+
+	entity.flaky = 3
+	if _, err := allocator.Allocate(context.Background()); err == nil {
+		t.Error("Allocate() succeeded, want a error after 2 retries")
+	}
+}
+
+func TestAllocator_GivenLostReleaseReply(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(1))
+	entity.lostReplies = 1
+	allocator := NewAllocator(entity, nil)
+	allocator.Backoff = Backoff{Initial: time.Millisecond, Max: time.Millisecond}
+
+	k, err := allocator.Allocate(context.Background())
+	if err != nil {
+		t.Fatalf("Allocate() failed: %v", err)
+	}
+
+	if err := allocator.Release(context.Background(), k); err != nil {
+		t.Errorf("Release() = %v, want the retry to find the key released", err)
+	}
+
+	if err := allocator.Release(context.Background(), k); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Release() = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestAllocator_GivenReservedKey(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(nil)
+	allocator := NewAllocator(entity, nil)
+
+	k := ShortKey("custom")
+	if err := allocator.Reserve(context.Background(), k); err != nil {
+		t.Fatalf("Reserve() failed: %v", err)
+	}
+
+	if err := allocator.Reserve(context.Background(), k); !errors.Is(err, ErrKeyTaken) {
+		t.Errorf("Reserve() = %v, want %v", err, ErrKeyTaken)
+	}
+
+	if err := allocator.Reserve(context.Background(), ShortKey("bad key!")); err == nil {
+		t.Error("Reserve() succeeded, want a validation error")
+	}
+
+	unsupported := NewAllocator(struct{ app.KeyValueEntity }{entity}, nil)
+	if err := unsupported.Reserve(context.Background(), k); !errors.Is(err, ErrReserveUnsupported) {
+		t.Errorf("Reserve() = %v, want %v", err, ErrReserveUnsupported)
+	}
+}
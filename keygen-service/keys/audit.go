@@ -0,0 +1,272 @@
+package keys
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"keygen-service/app"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-glide/go/api/options"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	KeysAuditName    = "keysAudit"
+	KeysMetadataName = "keysMetadata"
+)
+
+// DefaultAuditRetention is how long audit events, and the
+// metadata of released keys, are kept by default
+const DefaultAuditRetention = 90 * 24 * time.Hour
+
+// AuditAction names what happened to a key
+type AuditAction string
+
+const (
+	AuditAllocated        AuditAction = "allocated"
+	AuditReleased         AuditAction = "released"
+	AuditReleaseDenied    AuditAction = "release_denied" // without the owner token
+	AuditReserved         AuditAction = "reserved"
+	AuditQuarantined      AuditAction = "quarantined"
+	AuditQuarantineLifted AuditAction = "quarantine_lifted"
+	AuditBanned           AuditAction = "banned"
+)
+
+// AuditEvent records an action on a key, with
+// the state of the key it resulted in
+type AuditEvent struct {
+	Time      time.Time   `json:"time"`
+	Key       string      `json:"key"`
+	Action    AuditAction `json:"action"`
+	State     KeyState    `json:"state"`
+	Caller    string      `json:"caller"`               // authenticated identity
+	Peer      string      `json:"peer,omitempty"`       // address of the client
+	RequestID string      `json:"request_id,omitempty"` // x-request-id metadata
+}
+
+// KeyMetadata tells when a key was last handed out, and
+// released, by whom; the release is empty while allocated
+type KeyMetadata struct {
+	State KeyState
+
+	AllocatedAt        time.Time
+	AllocatedBy        string
+	AllocatedPeer      string
+	AllocatedRequestID string
+
+	ReleasedAt        time.Time
+	ReleasedBy        string
+	ReleasedPeer      string
+	ReleasedRequestID string
+}
+
+// AuditLog records the actions on keys in an append-only
+// log, and the last allocation of each key in its metadata
+type AuditLog interface {
+	Record(event AuditEvent) error
+
+	// Metadata returns the metadata of the key,
+	// false when none was recorded or retained
+	Metadata(key ShortKey) (KeyMetadata, bool, error)
+
+	// Events returns up to limit events of the key, of every
+	// key when nil, since the given time, newest first
+	Events(key ShortKey, since time.Time, limit int) ([]AuditEvent, error)
+}
+
+// auditEvent returns the event of an action by the caller of the RPC
+func auditEvent(ctx context.Context, key ShortKey, action AuditAction, state KeyState) AuditEvent {
+	event := AuditEvent{Time: time.Now().UTC(), Key: string(key), Action: action, State: state, Caller: caller(ctx)}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		event.Peer = p.Addr.String()
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get("x-request-id"); len(ids) > 0 {
+			event.RequestID = ids[0]
+		}
+	}
+
+	return event
+}
+
+// audit records an action on the key when auditing; failures
+// are logged, allocations aren't refused for their audit
+func (a *Allocator) audit(ctx context.Context, key ShortKey, action AuditAction, state KeyState) {
+	if a.Audit == nil {
+		return
+	}
+
+	if err := a.Audit.Record(auditEvent(ctx, key, action, state)); err != nil {
+		a.auditFailures.Add(1)
+		log.Printf("failed to audit key %s %s: %v", key, action, err)
+	}
+}
+
+// ValkeyAuditLog appends the events to a stream trimmed to
+// Retention, and keeps the metadata of each key in a hash
+// expiring Retention after its release
+type ValkeyAuditLog struct {
+	// Client connects to the db, required
+	Client app.KeyValueDbClient
+
+	// Namespace of the keys, the default one when empty
+	Namespace string
+
+	// Retention, DefaultAuditRetention when zero
+	Retention time.Duration
+}
+
+// Record appends the event and updates the key metadata
+func (v *ValkeyAuditLog) Record(event AuditEvent) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return err
+	}
+
+	retention := cmp.Or(v.Retention, DefaultAuditRetention)
+	minID := strconv.FormatInt(event.Time.Add(-retention).UnixMilli(), 10)
+	opts := options.NewXAddOptions().SetTrimOptions(options.NewXTrimOptionsWithMinId(minID).SetNearlyExactTrimming())
+	if _, err := valkeyClient.XAddWithOptions(v.auditName(), [][]string{{"event", string(value)}}, *opts); err != nil {
+		return fmt.Errorf("failed to append audit event: %w", err)
+	}
+
+	name := v.metadataName(ShortKey(event.Key))
+	fields := map[string]string{"state": string(event.State)}
+
+	switch event.Action {
+	case AuditAllocated, AuditReserved:
+		fields["allocatedAt"] = event.Time.Format(time.RFC3339Nano)
+		fields["allocatedBy"] = event.Caller
+		fields["allocatedPeer"] = event.Peer
+		fields["allocatedRequestID"] = event.RequestID
+	case AuditReleased:
+		fields["releasedAt"] = event.Time.Format(time.RFC3339Nano)
+		fields["releasedBy"] = event.Caller
+		fields["releasedPeer"] = event.Peer
+		fields["releasedRequestID"] = event.RequestID
+	}
+
+	if _, err := valkeyClient.HSet(name, fields); err != nil {
+		return fmt.Errorf("failed to save key metadata: %w", err)
+	}
+
+	switch event.Action {
+	case AuditAllocated, AuditReserved:
+		_, err = valkeyClient.HDel(name, []string{"releasedAt", "releasedBy", "releasedPeer", "releasedRequestID"})
+		if err == nil {
+			_, err = valkeyClient.Persist(name)
+		}
+	case AuditReleased:
+		_, err = valkeyClient.Expire(name, int64(retention.Seconds()))
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to save key metadata: %w", err)
+	}
+
+	return nil
+}
+
+// Metadata returns the metadata hash of the key
+func (v *ValkeyAuditLog) Metadata(key ShortKey) (KeyMetadata, bool, error) {
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return KeyMetadata{}, false, err
+	}
+
+	fields, err := valkeyClient.HGetAll(v.metadataName(key))
+	if err != nil {
+		return KeyMetadata{}, false, fmt.Errorf("failed to get key metadata: %w", err)
+	}
+
+	if len(fields) == 0 {
+		return KeyMetadata{}, false, nil
+	}
+
+	// times were written by Record, malformed ones are left zero
+	allocatedAt, _ := time.Parse(time.RFC3339Nano, fields["allocatedAt"])
+	releasedAt, _ := time.Parse(time.RFC3339Nano, fields["releasedAt"])
+
+	return KeyMetadata{
+		State:              KeyState(fields["state"]),
+		AllocatedAt:        allocatedAt,
+		AllocatedBy:        fields["allocatedBy"],
+		AllocatedPeer:      fields["allocatedPeer"],
+		AllocatedRequestID: fields["allocatedRequestID"],
+		ReleasedAt:         releasedAt,
+		ReleasedBy:         fields["releasedBy"],
+		ReleasedPeer:       fields["releasedPeer"],
+		ReleasedRequestID:  fields["releasedRequestID"],
+	}, true, nil
+}
+
+// Events reads the stream backwards, by pages, until limit events
+// of the key are found; finding the events of a key reads every
+// event since the given time
+func (v *ValkeyAuditLog) Events(key ShortKey, since time.Time, limit int) ([]AuditEvent, error) {
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	limit = cmp.Or(limit, DefaultListCount)
+	start := options.NewInfiniteStreamBoundary(options.PositiveInfinity)
+	end := options.NewInfiniteStreamBoundary(options.NegativeInfinity)
+	if !since.IsZero() {
+		end = options.NewStreamBoundary(strconv.FormatInt(since.UnixMilli(), 10), true)
+	}
+
+	var events []AuditEvent
+	for {
+		page, err := valkeyClient.XRevRangeWithOptions(v.auditName(), start, end, *options.NewXRangeOptions().SetCount(1000))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit events: %w", err)
+		}
+
+		for _, entry := range page {
+			for _, field := range entry.Entries {
+				if len(field) != 2 || field[0] != "event" {
+					continue
+				}
+
+				var event AuditEvent
+				if err := json.Unmarshal([]byte(field[1]), &event); err != nil {
+					return nil, fmt.Errorf("failed to decode audit event %s: %w", entry.StreamId, err)
+				}
+
+				if key != nil && event.Key != string(key) {
+					continue
+				}
+
+				if events = append(events, event); len(events) >= limit {
+					return events, nil
+				}
+			}
+		}
+
+		if len(page) < 1000 {
+			return events, nil
+		}
+		start = options.NewStreamBoundary(page[len(page)-1].StreamId, false)
+	}
+}
+
+func (v *ValkeyAuditLog) auditName() string {
+	return namespacedName(KeysAuditName, v.Namespace)
+}
+
+func (v *ValkeyAuditLog) metadataName(key ShortKey) string {
+	return namespacedName(KeysMetadataName, v.Namespace) + ":" + string(key)
+}
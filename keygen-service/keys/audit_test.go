@@ -0,0 +1,151 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"keygen-service/auth"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// auditLogMock keeps the events in memory, oldest first
+type auditLogMock struct {
+	events []AuditEvent
+	err    error
+}
+
+func (l *auditLogMock) Record(event AuditEvent) error {
+	if l.err != nil {
+		return l.err
+	}
+
+	l.events = append(l.events, event)
+	return nil
+}
+
+func (l *auditLogMock) Metadata(key ShortKey) (KeyMetadata, bool, error) {
+	events, _ := l.Events(key, time.Time{}, len(l.events))
+	if len(events) == 0 {
+		return KeyMetadata{}, false, nil
+	}
+
+	return KeyMetadata{State: events[0].State, AllocatedAt: events[len(events)-1].Time, AllocatedBy: events[len(events)-1].Caller}, true, nil
+}
+
+func (l *auditLogMock) Events(key ShortKey, since time.Time, limit int) ([]AuditEvent, error) {
+	var events []AuditEvent
+	for _, event := range slices.Backward(l.events) {
+		if (key == nil || event.Key == string(key)) && !event.Time.Before(since) && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func auditedContext() context.Context {
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Name: "url-shortener"})
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs("x-request-id", "42"))
+}
+
+func TestAllocator_GivenAudit(t *testing.T) {
+	ctx := auditedContext()
+	log := &auditLogMock{}
+	allocator := NewAllocator(newMemoryKeyValueEntityMock(map[string]KeyState{"aaaaaa": KeyAvailable, "bbbbbb": KeyAvailable}), nil)
+	allocator.Owners = &ownershipMock{}
+	allocator.Audit = log
+
+	key, token, err := allocator.AllocateOwned(ctx)
+	if err != nil {
+		t.Fatalf("AllocateOwned() failed: %v", err)
+	}
+
+	if err := allocator.ReleaseOwned(ctx, key, "forged"); !errors.Is(err, ErrNotOwner) {
+		t.Fatalf("ReleaseOwned() = %v, want %v", err, ErrNotOwner)
+	}
+
+	if err := allocator.ReleaseOwned(ctx, key, token); err != nil {
+		t.Fatalf("ReleaseOwned() failed: %v", err)
+	}
+
+	if err := allocator.Ban(ctx, ShortKey("bbbbbb")); err != nil {
+		t.Fatalf("Ban() failed: %v", err)
+	}
+
+	want := []AuditEvent{
+		{Key: "aaaaaa", Action: AuditAllocated, State: KeyTaken},
+		{Key: "aaaaaa", Action: AuditReleaseDenied, State: KeyTaken},
+		{Key: "aaaaaa", Action: AuditReleased, State: KeyAvailable},
+		{Key: "bbbbbb", Action: AuditBanned, State: KeyBanned},
+	}
+	if len(log.events) != len(want) {
+		t.Fatalf("Audit recorded %+v, want %+v", log.events, want)
+	}
+
+	for i, event := range log.events {
+		if event.Key != want[i].Key || event.Action != want[i].Action || event.State != want[i].State {
+			t.Errorf("Audit recorded %+v, want %+v", event, want[i])
+		}
+
+		if event.Caller != "url-shortener" || event.Peer != "10.0.0.1:5000" || event.RequestID != "42" || event.Time.IsZero() {
+			t.Errorf("Audit recorded %+v, want the caller, peer, request ID and time", event)
+		}
+	}
+}
+
+func TestAllocator_GivenFailingAudit(t *testing.T) {
+	allocator := NewAllocator(newMemoryKeyValueEntityMock(map[string]KeyState{"aaaaaa": KeyAvailable}), nil)
+	allocator.Audit = &auditLogMock{err: errors.New("audit log down")}
+
+	key, err := allocator.Allocate(context.Background())
+	if err != nil {
+		t.Fatalf("Allocate() = %v, want allocations despite the audit", err)
+	}
+
+	if err := allocator.Release(context.Background(), key); err != nil {
+		t.Fatalf("Release() = %v, want releases despite the audit", err)
+	}
+
+	if stats := allocator.Stats(); stats.AuditFailures != 2 {
+		t.Errorf("Stats() = %+v, want 2 audit failures", stats)
+	}
+}
+
+func TestAdminRPCHandler_GivenAudit(t *testing.T) {
+	allocator := NewAllocator(newMemoryKeyValueEntityMock(map[string]KeyState{"aaaaaa": KeyAvailable}), nil)
+	handler := &AdminRPCHandler{Namespaces: &Namespaces{Default: allocator}}
+
+	if _, err := handler.AuditKeys(context.Background(), &AuditRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("AuditKeys() = %v, want %v without audit", err, codes.FailedPrecondition)
+	}
+
+	allocator.Audit = &auditLogMock{}
+	if _, err := allocator.Allocate(auditedContext()); err != nil {
+		t.Fatalf("Allocate() failed: %v", err)
+	}
+
+	res, err := handler.AuditKeys(context.Background(), &AuditRequest{Key: []byte("aaaaaa"), Limit: 10})
+	if err != nil {
+		t.Fatalf("AuditKeys() failed: %v", err)
+	}
+
+	if m := res.GetMetadata(); m.GetState() != string(KeyTaken) || m.GetAllocatedBy() != "url-shortener" || m.GetAllocatedAt() == "" {
+		t.Errorf("AuditKeys() metadata = %v, want the allocation", m)
+	}
+
+	if len(res.GetEvents()) != 1 || res.GetEvents()[0].GetRequestId() != "42" {
+		t.Errorf("AuditKeys() events = %v, want the allocation", res.GetEvents())
+	}
+
+	if _, err := handler.AuditKeys(context.Background(), &AuditRequest{Key: []byte("a")}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("AuditKeys() = %v, want %v", err, codes.InvalidArgument)
+	}
+}
@@ -0,0 +1,157 @@
+package keys
+
+import (
+	"cmp"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	glideErrors "github.com/valkey-io/valkey-glide/go/api/errors"
+)
+
+// IsTransient tells failures worth retrying, those reaching
+// the storage like lost connections and timeouts, from any other
+// failure, like invalid or already existing keys, which would
+// fail again
+func IsTransient(err error) bool {
+	var connection *glideErrors.ConnectionError
+	var disconnect *glideErrors.DisconnectError
+	var timeout *glideErrors.TimeoutError
+	var netErr net.Error
+
+	return errors.As(err, &connection) || errors.As(err, &disconnect) || errors.As(err, &timeout) ||
+		errors.As(err, &netErr) || errors.Is(err, os.ErrDeadlineExceeded) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// Backoff computes exponentially growing
+// delays, with jitter, between retries
+type Backoff struct {
+	Initial time.Duration // 100ms when zero
+	Max     time.Duration // 30s when zero
+}
+
+// Delay returns how long to wait before the given retry,
+// a random duration between half and all of Initial*2^attempt
+func (b Backoff) Delay(attempt int) time.Duration {
+	initial := cmp.Or(b.Initial, 100*time.Millisecond)
+	maxDelay := cmp.Or(b.Max, 30*time.Second)
+
+	delay := maxDelay
+	if attempt < 32 && initial<<attempt < maxDelay {
+		delay = initial << attempt
+	}
+
+	// #nosec G404 -- jitter only spreads retries
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// CircuitState tells whether a CircuitBreaker lets calls through
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreaker stops calls after Threshold consecutive
+// failures, letting a single trial call through once
+// Cooldown passes; a successful trial closes it again
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	// OnChange is called with the new state on transitions
+	OnChange func(CircuitState)
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+}
+
+// Allow tells if a call may go through now, otherwise
+// how long until the breaker lets a trial call through
+func (b *CircuitBreaker) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if wait := b.Cooldown - time.Since(b.openedAt); wait > 0 {
+			return false, wait
+		}
+
+		b.setState(CircuitHalfOpen)
+		return true, 0
+	case CircuitHalfOpen: // a trial is already going
+		return false, b.Cooldown
+	default:
+		return true, 0
+	}
+}
+
+// Success records a successful call
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.setState(CircuitClosed)
+}
+
+// Failure records a failed call,
+// tells if it opened the circuit
+func (b *CircuitBreaker) Failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.failures >= max(b.Threshold, 1)) {
+		b.openedAt = time.Now()
+		b.setState(CircuitOpen)
+
+		return true
+	}
+
+	return false
+}
+
+// State returns the current circuit state
+// and count of consecutive failures
+func (b *CircuitBreaker) State() (CircuitState, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state, b.failures
+}
+
+func (b *CircuitBreaker) setState(state CircuitState) {
+	if b.state == state {
+		return
+	}
+
+	b.state = state
+	if b.OnChange != nil {
+		b.OnChange(state)
+	}
+}
@@ -0,0 +1,104 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	glideErrors "github.com/valkey-io/valkey-glide/go/api/errors"
+)
+
+func TestIsTransient(t *testing.T) {
+	_, validationErr := NewKeyFromBytes([]byte("ab/"))
+
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("failed to connect to db: %w", &glideErrors.ConnectionError{Msg: "refused"}), true},
+		{fmt.Errorf("failed to get a key: %w", &glideErrors.TimeoutError{}), true},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{errors.New("unknown failure"), false},
+		{errors.New("incompatible db client"), false},
+		{fmt.Errorf("failed to push to db: %w", ErrKeyExists), false},
+		{fmt.Errorf("failed key validation: %w", validationErr), false},
+		{fmt.Errorf("failed to push to db: %w", &glideErrors.RequestError{Msg: "WRONGTYPE"}), false},
+	}
+
+	for _, c := range cases {
+		if got := IsTransient(c.err); got != c.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := Backoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond}
+
+	cases := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 5 * time.Millisecond, 10 * time.Millisecond},
+		{2, 20 * time.Millisecond, 40 * time.Millisecond},
+		{3, 25 * time.Millisecond, 50 * time.Millisecond}, // capped
+		{100, 25 * time.Millisecond, 50 * time.Millisecond},
+	}
+
+	for _, c := range cases {
+		for range 10 {
+			if got := backoff.Delay(c.attempt); got < c.min || got > c.max {
+				t.Errorf("Delay(%v) = %v, want between %v and %v", c.attempt, got, c.min, c.max)
+			}
+		}
+	}
+}
+
+func TestCircuitBreaker_GivenRepeatedFailures(t *testing.T) {
+	var changes []CircuitState
+	breaker := &CircuitBreaker{Threshold: 2, Cooldown: time.Millisecond, OnChange: func(s CircuitState) {
+		changes = append(changes, s)
+	}}
+
+	if opened := breaker.Failure(); opened {
+		t.Error("Failure() = true below threshold, want false")
+	}
+
+	if opened := breaker.Failure(); !opened {
+		t.Error("Failure() = false at threshold, want true")
+	}
+
+	if ok, wait := breaker.Allow(); ok || wait <= 0 {
+		t.Errorf("Allow() = (%v, %v) while open, want (false, cooldown)", ok, wait)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	if ok, _ := breaker.Allow(); !ok {
+		t.Error("Allow() = false after cooldown, want a trial call")
+	}
+
+	if ok, _ := breaker.Allow(); ok {
+		t.Error("Allow() = true during a trial call, want false")
+	}
+
+	if opened := breaker.Failure(); !opened {
+		t.Error("Failure() = false on a failing trial, want true")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	breaker.Allow()
+	breaker.Success()
+
+	if state, failures := breaker.State(); state != CircuitClosed || failures != 0 {
+		t.Errorf("State() = (%v, %v) after a successful trial, want (closed, 0)", state, failures)
+	}
+
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("OnChange() called with %v, want %v", changes, want)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"keygen-service/app"
+	"strconv"
 
 	"github.com/valkey-io/valkey-glide/go/api"
 )
@@ -13,7 +14,121 @@ const (
 	TakenKeysListName = "takenKeys"
 )
 
-type Valkey struct{}
+var (
+	// ErrKeyExists is returned when creating a key
+	// that is already known by the storage
+	ErrKeyExists = errors.New("already exist")
+
+	// ErrKeyTaken is returned when reserving
+	// a key that is already allocated
+	ErrKeyTaken = errors.New("already allocated")
+
+	// ErrKeyNotFound is returned when releasing
+	// a key that is not allocated
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrNoKeysStorage is returned by components
+	// configured without keys storage
+	ErrNoKeysStorage = errors.New("no keys storage")
+
+	// ErrNoAvailableKeys is returned when allocating
+	// keys while the storage has none available
+	ErrNoAvailableKeys = errors.New("no available keys, try again in a moment")
+)
+
+// allocateScript moves up to ARGV[1] random keys of
+// the KEYS[1] available set to the KEYS[2] taken set,
+// returns them
+const allocateScript = `
+local keys = redis.call('SPOP', KEYS[1], ARGV[1])
+for _, key in ipairs(keys) do
+  redis.call('SADD', KEYS[2], key)
+end
+return keys`
+
+// reserveScript moves the ARGV[1] key to the KEYS[2]
+// taken set and the KEYS[3] reserved set, out of the
+// KEYS[1] available set; returns 0 when already taken
+const reserveScript = `
+if redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 1 then
+  return 0
+end
+redis.call('SREM', KEYS[1], ARGV[1])
+redis.call('SADD', KEYS[2], ARGV[1])
+redis.call('SADD', KEYS[3], ARGV[1])
+return 1`
+
+// deallocateScript moves the ARGV[1] key from the KEYS[2]
+// taken set back to the KEYS[1] available set, out of the
+// KEYS[3] reserved set; returns -1 when in the KEYS[4]
+// banned set, -2 when in the KEYS[5] quarantined set and
+// 0 when not taken
+const deallocateScript = `
+if redis.call('SISMEMBER', KEYS[4], ARGV[1]) == 1 then
+  return -1
+end
+if redis.call('SISMEMBER', KEYS[5], ARGV[1]) == 1 then
+  return -2
+end
+if redis.call('SMOVE', KEYS[2], KEYS[1], ARGV[1]) == 0 then
+  return 0
+end
+redis.call('SREM', KEYS[3], ARGV[1])
+return 1`
+
+// quarantineScript moves the ARGV[1] key to the KEYS[2] taken
+// set and the KEYS[4] quarantined set, out of the KEYS[1]
+// available set; returns 1 when quarantined, already or
+// now, -1 when in the KEYS[3] banned set and 0 when taken
+const quarantineScript = `
+if redis.call('SISMEMBER', KEYS[4], ARGV[1]) == 1 then
+  return 1
+end
+if redis.call('SISMEMBER', KEYS[3], ARGV[1]) == 1 then
+  return -1
+end
+if redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 1 then
+  return 0
+end
+redis.call('SREM', KEYS[1], ARGV[1])
+redis.call('SADD', KEYS[2], ARGV[1])
+redis.call('SADD', KEYS[4], ARGV[1])
+return 1`
+
+// banScript moves the ARGV[1] key to the KEYS[2] taken set
+// and the KEYS[3] banned set, out of the KEYS[1] available,
+// KEYS[4] reserved and KEYS[5] quarantined sets, whatever
+// its state
+const banScript = `
+redis.call('SREM', KEYS[1], ARGV[1])
+redis.call('SADD', KEYS[2], ARGV[1])
+redis.call('SADD', KEYS[3], ARGV[1])
+redis.call('SREM', KEYS[4], ARGV[1])
+redis.call('SREM', KEYS[5], ARGV[1])
+return 1`
+
+// liftScript moves the ARGV[1] key out of the KEYS[3]
+// quarantined set and from the KEYS[2] taken set back
+// to the KEYS[1] available set; returns 0 when not
+// quarantined
+const liftScript = `
+if redis.call('SREM', KEYS[3], ARGV[1]) == 0 then
+  return 0
+end
+redis.call('SREM', KEYS[2], ARGV[1])
+redis.call('SADD', KEYS[1], ARGV[1])
+return 1`
+
+// Valkey keeps keys in two sets, available and taken,
+// moving keys between them with scripts so that each
+// operation is atomic and safe to retry
+type Valkey struct {
+	// Client connects to the db, required
+	Client app.KeyValueDbClient
+
+	// Namespace of the keys, the default one when empty
+	Namespace string
+}
 
 // Create persists a new key
 func (k *Valkey) Create(i interface{}) error {
@@ -22,25 +137,14 @@ func (k *Valkey) Create(i interface{}) error {
 		return fmt.Errorf("i is not a valid shortkey")
 	}
 
-	builtApp, err := app.GetApp()
-	if err != nil {
-		return fmt.Errorf("failed to get app: %w", err)
-	}
-
-	client, err := builtApp.GetKeyValueDb().Client.GetConn()
+	valkeyClient, err := valkeyConn(k.Client)
 	if err != nil {
-		return fmt.Errorf("failed to connect to db: %w", err)
-	}
-
-	valkeyClient, ok := client.(api.GlideClientCommands)
-	if !ok {
-		return errors.New("incompatible db client")
+		return err
 	}
-	defer valkeyClient.Close()
 
-	if added, err := valkeyClient.SAdd(KeysListName, []string{string(newKey[:])}); added < 1 || err != nil {
+	if added, err := valkeyClient.SAdd(k.keysName(), []string{string(*newKey)}); added < 1 || err != nil {
 		if err == nil {
-			err = errors.New("already exist")
+			err = ErrKeyExists
 		}
 		return fmt.Errorf("failed to push to db: %w", err)
 	}
@@ -48,47 +152,105 @@ func (k *Valkey) Create(i interface{}) error {
 	return nil
 }
 
+// CreateBatch persists many new keys at once,
+// returns how many weren't there yet
+func (k *Valkey) CreateBatch(items []interface{}) (int64, error) {
+	members := make([]string, len(items))
+	for n, i := range items {
+		newKey, ok := i.(*ShortKey)
+		if !ok {
+			return 0, fmt.Errorf("item %d is not a valid shortkey", n)
+		}
+
+		members[n] = string(*newKey)
+	}
+
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return 0, err
+	}
+
+	added, err := valkeyClient.SAdd(k.keysName(), members)
+	if err != nil {
+		return 0, fmt.Errorf("failed to push to db: %w", err)
+	}
+
+	return added, nil
+}
+
 // AllocateFirst moves the first available key
 // to an unavailables set and returns that key
 func (k *Valkey) AllocateFirst() (interface{}, error) {
-	builtApp, err := app.GetApp()
+	keys, err := k.allocate(1)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get app: %w", err)
+		return nil, err
 	}
 
-	client, err := builtApp.GetKeyValueDb().Client.GetConn()
+	return keys[0], nil
+}
+
+// AllocateBatch moves up to n available keys
+// to the unavailables set and returns them
+func (k *Valkey) AllocateBatch(n int) ([]interface{}, error) {
+	return k.allocate(n)
+}
+
+// allocate moves up to n available keys to the taken set
+func (k *Valkey) allocate(n int) ([]interface{}, error) {
+	valkeyClient, err := valkeyConn(k.Client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to db: %w", err)
+		return nil, err
 	}
 
-	valkeyClient, ok := client.(api.GlideClientCommands)
-	if !ok {
-		return nil, errors.New("incompatible db client")
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", allocateScript, "2", k.keysName(), k.takenKeysName(), strconv.Itoa(n),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keys: %w", err)
 	}
-	defer valkeyClient.Close()
 
-	res, err := valkeyClient.SPop(KeysListName)
+	members, err := setMembers(res)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get a key: %w", err)
+		return nil, err
 	}
 
-	if res.Value() == "" {
-		return nil, errors.New("no available keys, try again in a moment")
+	if len(members) == 0 {
+		return nil, ErrNoAvailableKeys
 	}
 
-	if added, err := valkeyClient.SAdd(TakenKeysListName, []string{res.Value()}); added < 1 || err != nil {
-		if err == nil {
-			err = errors.New("already exist")
-		}
-		return nil, fmt.Errorf("failed to mark key as allocated: %w", err)
+	movedKeys := make([]interface{}, len(members))
+	for i, m := range members {
+		movedKeys[i] = ShortKey([]byte(m))
 	}
 
-	movedKey := ShortKey([]byte(res.Value()))
+	return movedKeys, nil
+}
+
+// Reserve allocates the given key, known
+// by the storage or not, unless allocated
+func (k *Valkey) Reserve(i interface{}) error {
+	key, ok := i.(*ShortKey)
 	if !ok {
-		return nil, fmt.Errorf("incompatible result type: %w", err)
+		return errors.New("incompatible key type")
 	}
 
-	return movedKey, nil
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", reserveScript, "3", k.keysName(), k.takenKeysName(), k.reservedKeysName(), string(*key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reserve the key: %w", err)
+	}
+
+	if reserved, _ := res.(int64); reserved != 1 {
+		return fmt.Errorf("failed to reserve the key: %w", ErrKeyTaken)
+	}
+
+	return nil
 }
 
 // Deallocate moves the given key back to a availables set
@@ -98,28 +260,343 @@ func (k *Valkey) Deallocate(i interface{}) error {
 		return errors.New("incompatible key type")
 	}
 
-	builtApp, err := app.GetApp()
+	valkeyClient, err := valkeyConn(k.Client)
 	if err != nil {
-		return fmt.Errorf("failed to get app: %w", err)
+		return err
 	}
 
-	client, err := builtApp.GetKeyValueDb().Client.GetConn()
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", deallocateScript, "5",
+		k.keysName(), k.takenKeysName(), k.reservedKeysName(), k.bannedKeysName(), k.quarantinedKeysName(),
+		string(*key),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to connect to db: %w", err)
+		return fmt.Errorf("failed to deallocate the key: %w", err)
 	}
 
-	valkeyClient, ok := client.(api.GlideClientCommands)
-	if !ok {
-		return fmt.Errorf("incompatible db client: %w", err)
+	return deallocated(res)
+}
+
+// deallocated reads the reply of a deallocation script
+func deallocated(res interface{}) error {
+	switch res, _ := res.(int64); res {
+	case 1:
+		return nil
+	case -1:
+		return fmt.Errorf("failed to deallocate the key: %w", ErrKeyBanned)
+	case -2:
+		return fmt.Errorf("failed to deallocate the key: %w", ErrKeyQuarantined)
+	default:
+		return fmt.Errorf("failed to deallocate the key: %w", ErrKeyNotFound)
 	}
-	defer valkeyClient.Close()
+}
 
-	if found, err := valkeyClient.SMove(TakenKeysListName, KeysListName, string(key[:])); !found || err != nil {
-		if err == nil {
-			err = errors.New("key not found")
+// quarantined reads the reply of a quarantine script
+func quarantined(res interface{}) error {
+	switch res, _ := res.(int64); res {
+	case 1:
+		return nil
+	case -1:
+		return fmt.Errorf("failed to quarantine the key: %w", ErrKeyBanned)
+	default:
+		return fmt.Errorf("failed to quarantine the key: %w", ErrKeyTaken)
+	}
+}
+
+// lifted reads the reply of a quarantine lifting script
+func lifted(res interface{}) error {
+	if res, _ := res.(int64); res != 1 {
+		return fmt.Errorf("failed to lift the key quarantine: %w", ErrKeyNotFound)
+	}
+
+	return nil
+}
+
+// State returns the state of the given key
+func (k *Valkey) State(key ShortKey) (KeyState, error) {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return "", err
+	}
+
+	// banned, quarantined and reserved keys are
+	// taken too, so they are looked up first
+	sets := []struct {
+		name  string
+		state KeyState
+	}{
+		{k.bannedKeysName(), KeyBanned},
+		{k.quarantinedKeysName(), KeyQuarantined},
+		{k.reservedKeysName(), KeyReserved},
+		{k.takenKeysName(), KeyTaken},
+		{k.keysName(), KeyAvailable},
+	}
+
+	for _, set := range sets {
+		found, err := valkeyClient.SIsMember(set.name, string(key))
+		if err != nil {
+			return "", fmt.Errorf("failed to look up the key: %w", err)
 		}
-		return fmt.Errorf("failed to deallocate the key: %w", err)
+
+		if found {
+			return set.state, nil
+		}
+	}
+
+	return KeyUnknown, nil
+}
+
+// Ban takes the given key for good, whatever its state
+func (k *Valkey) Ban(key ShortKey) error {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	if _, err := valkeyClient.CustomCommand([]string{
+		"EVAL", banScript, "5",
+		k.keysName(), k.takenKeysName(), k.bannedKeysName(), k.reservedKeysName(), k.quarantinedKeysName(), string(key),
+	}); err != nil {
+		return fmt.Errorf("failed to ban the key: %w", err)
 	}
 
 	return nil
 }
+
+// Quarantine moves the given key, unless taken, to the
+// taken and quarantined sets
+func (k *Valkey) Quarantine(key ShortKey) error {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", quarantineScript, "4",
+		k.keysName(), k.takenKeysName(), k.bannedKeysName(), k.quarantinedKeysName(), string(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to quarantine the key: %w", err)
+	}
+
+	return quarantined(res)
+}
+
+// Lift moves the given quarantined key back to the available set
+func (k *Valkey) Lift(key ShortKey) error {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", liftScript, "3", k.keysName(), k.takenKeysName(), k.quarantinedKeysName(), string(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to lift the key quarantine: %w", err)
+	}
+
+	return lifted(res)
+}
+
+// Check counts the keys both available and taken,
+// and the banned keys still available
+func (k *Valkey) Check() (ConsistencyReport, error) {
+	available, taken, err := k.Count()
+	if err != nil {
+		return ConsistencyReport{}, err
+	}
+
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return ConsistencyReport{}, err
+	}
+
+	duplicated, err := valkeyClient.CustomCommand([]string{"SINTERCARD", "2", k.keysName(), k.takenKeysName()})
+	if err != nil {
+		return ConsistencyReport{}, fmt.Errorf("failed to count duplicated keys: %w", err)
+	}
+
+	bannedAvailable, err := valkeyClient.CustomCommand([]string{"SINTERCARD", "2", k.keysName(), k.bannedKeysName()})
+	if err != nil {
+		return ConsistencyReport{}, fmt.Errorf("failed to count banned keys: %w", err)
+	}
+
+	report := ConsistencyReport{Available: available, Taken: taken}
+	report.Duplicated, _ = duplicated.(int64)
+	report.BannedAvailable, _ = bannedAvailable.(int64)
+
+	return report, nil
+}
+
+// Count returns how many keys are available
+// and how many are allocated
+func (k *Valkey) Count() (int64, int64, error) {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	available, err := valkeyClient.SCard(k.keysName())
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count available keys: %w", err)
+	}
+
+	allocated, err := valkeyClient.SCard(k.takenKeysName())
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count allocated keys: %w", err)
+	}
+
+	return available, allocated, nil
+}
+
+// CountStates counts the keys of each set,
+// scanning them to count by length
+func (k *Valkey) CountStates(byLength bool) (PoolStats, error) {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return PoolStats{}, err
+	}
+
+	var stats PoolStats
+	sets := []struct {
+		name  string
+		count *int64
+		add   func(*StateCounts)
+	}{
+		{k.keysName(), &stats.Available, func(c *StateCounts) { c.Available++ }},
+		{k.takenKeysName(), &stats.Taken, func(c *StateCounts) { c.Taken++ }},
+		{k.reservedKeysName(), &stats.Reserved, func(c *StateCounts) { c.Reserved++ }},
+		{k.bannedKeysName(), &stats.Banned, func(c *StateCounts) { c.Banned++ }},
+		{k.quarantinedKeysName(), &stats.Quarantined, func(c *StateCounts) { c.Quarantined++ }},
+	}
+
+	if byLength {
+		stats.Lengths = map[int]StateCounts{}
+	}
+
+	for _, set := range sets {
+		if *set.count, err = valkeyClient.SCard(set.name); err != nil {
+			return PoolStats{}, fmt.Errorf("failed to count %s: %w", set.name, err)
+		}
+
+		if byLength {
+			if err := countSetLengths(valkeyClient, set.name, stats.Lengths, set.add); err != nil {
+				return PoolStats{}, err
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// ListKeys returns a page of the set of the given state
+func (k *Valkey) ListKeys(state KeyState, cursor string, count int) ([]ShortKey, string, error) {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return nil, "", err
+	}
+
+	set, err := k.stateSetName(state)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return scanSet(valkeyClient, set, cursor, count)
+}
+
+// stateSetName returns the set of the keys in the state
+func (k *Valkey) stateSetName(state KeyState) (string, error) {
+	switch state {
+	case KeyAvailable:
+		return k.keysName(), nil
+	case KeyTaken:
+		return k.takenKeysName(), nil
+	case KeyReserved:
+		return k.reservedKeysName(), nil
+	case KeyBanned:
+		return k.bannedKeysName(), nil
+	case KeyQuarantined:
+		return k.quarantinedKeysName(), nil
+	}
+
+	return "", fmt.Errorf("%w: state %q", ErrInvalidListing, state)
+}
+
+func (k *Valkey) keysName() string {
+	return namespacedName(KeysListName, k.Namespace)
+}
+
+func (k *Valkey) takenKeysName() string {
+	return namespacedName(TakenKeysListName, k.Namespace)
+}
+
+func (k *Valkey) reservedKeysName() string {
+	return namespacedName(ReservedKeysName, k.Namespace)
+}
+
+func (k *Valkey) bannedKeysName() string {
+	return namespacedName(BannedKeysName, k.Namespace)
+}
+
+func (k *Valkey) quarantinedKeysName() string {
+	return namespacedName(QuarantinedKeysName, k.Namespace)
+}
+
+// namespacedName suffixes db names of namespaced keys,
+// default namespace keys keep the plain name
+func namespacedName(name, namespace string) string {
+	if namespace == "" {
+		return name
+	}
+
+	return name + "." + namespace
+}
+
+// setMembers reads the members of a set reply,
+// an array over RESP2 and a set over RESP3
+func setMembers(res interface{}) ([]string, error) {
+	switch values := res.(type) {
+	case nil:
+		return nil, nil
+	case map[string]struct{}:
+		members := make([]string, 0, len(values))
+		for m := range values {
+			members = append(members, m)
+		}
+
+		return members, nil
+	case []interface{}:
+		members := make([]string, len(values))
+		for i, v := range values {
+			m, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("incompatible set member: %v", v)
+			}
+			members[i] = m
+		}
+
+		return members, nil
+	}
+
+	return nil, fmt.Errorf("incompatible set result: %v", res)
+}
+
+// valkeyConn connects with the given client
+func valkeyConn(client app.KeyValueDbClient) (api.GlideClientCommands, error) {
+	if client == nil {
+		return nil, errors.New("no db client")
+	}
+
+	conn, err := client.GetConn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to db: %w", err)
+	}
+
+	valkeyClient, ok := conn.(api.GlideClientCommands)
+	if !ok {
+		return nil, errors.New("incompatible db client")
+	}
+
+	return valkeyClient, nil
+}
@@ -0,0 +1,56 @@
+package keys
+
+import (
+	"math"
+	"time"
+)
+
+// SupplyPlanner tells how many keys are missing
+// from the supply a GeneratorPool should keep
+type SupplyPlanner interface {
+	Deficit() int64
+}
+
+// DemandPlanner keeps enough keys available to serve
+// the allocations forecast by a KeySpaceMonitor
+// during Supply, never less than MinAvailable
+type DemandPlanner struct {
+	Monitor      *KeySpaceMonitor
+	Supply       time.Duration
+	MinAvailable int64
+}
+
+// SupplyPlan is a snapshot of a DemandPlanner
+type SupplyPlan struct {
+	Supply             time.Duration
+	ForecastRate       float64 // keys per second
+	ForecastAllocation int64   // keys allocated during Supply
+	Target             int64
+	Available          int64
+	Deficit            int64
+}
+
+// Plan compares the available keys
+// with the forecast demand
+func (d *DemandPlanner) Plan() SupplyPlan {
+	stats := d.Monitor.Stats()
+	forecast := d.Monitor.ForecastAllocations(d.Supply)
+
+	plan := SupplyPlan{
+		Supply:             d.Supply,
+		ForecastRate:       stats.AllocationRate,
+		ForecastAllocation: int64(math.Ceil(forecast)),
+		Available:          stats.Available,
+	}
+
+	plan.Target = max(plan.ForecastAllocation, d.MinAvailable)
+	plan.Deficit = max(plan.Target-plan.Available, 0)
+
+	return plan
+}
+
+// Deficit returns how many keys to create
+// to meet the forecast demand
+func (d *DemandPlanner) Deficit() int64 {
+	return d.Plan().Deficit
+}
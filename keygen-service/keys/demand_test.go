@@ -0,0 +1,89 @@
+package keys
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDemandPlanner_GivenNoDemand(t *testing.T) {
+	planner := &DemandPlanner{Monitor: NewKeySpaceMonitor(1, 0), Supply: time.Hour, MinAvailable: 10}
+
+	got := planner.Plan()
+	if got.Target != 10 || got.Deficit != 10 {
+		t.Errorf("Plan() = %+v, want a target and deficit of MinAvailable", got)
+	}
+}
+
+func TestDemandPlanner_GivenSteadyDemand(t *testing.T) {
+	monitor := NewKeySpaceMonitor(1, 0)
+	planner := &DemandPlanner{Monitor: monitor, Supply: time.Minute}
+	counter := &keyValueCounterMock{available: 100}
+	now := time.Now()
+
+	for i := range 5 {
+		counter.allocated = int64(i) * 10 // 1 key per second
+		if err := monitor.Sample(counter, now.Add(time.Duration(i)*10*time.Second)); err != nil {
+			t.Fatalf("Sample() failed: %v", err)
+		}
+	}
+
+	got := planner.Plan()
+	if got.ForecastAllocation != 60 || got.Deficit != 0 {
+		t.Errorf("Plan() = %+v, want 60 keys forecast and no deficit out of 100 available", got)
+	}
+
+	planner.Supply = 5 * time.Minute
+	if got := planner.Plan(); got.Deficit != 200 {
+		t.Errorf("Plan() = %+v, want a deficit of 200 keys for 5 minutes", got)
+	}
+}
+
+func TestDemandPlanner_GivenGrowingDemand(t *testing.T) {
+	monitor := NewKeySpaceMonitor(1, 0)
+	planner := &DemandPlanner{Monitor: monitor, Supply: time.Minute}
+	counter := &keyValueCounterMock{}
+	now := time.Now()
+
+	for i := range 10 {
+		counter.allocated += int64(i) * 10 // rate grows by 1 key per second every 10s
+		if err := monitor.Sample(counter, now.Add(time.Duration(i)*10*time.Second)); err != nil {
+			t.Fatalf("Sample() failed: %v", err)
+		}
+	}
+
+	stats := monitor.Stats()
+	if stats.AllocationTrend <= 0 {
+		t.Errorf("Stats().AllocationTrend = %v, want positive", stats.AllocationTrend)
+	}
+
+	if got, steady := planner.Plan().ForecastAllocation, int64(stats.AllocationRate*60); got <= steady {
+		t.Errorf("Plan().ForecastAllocation = %v, want more than %v at a steady rate", got, steady)
+	}
+}
+
+func TestGeneratorPool_GivenSupplyPlanner(t *testing.T) {
+	monitor := NewKeySpaceMonitor(1, 0)
+	entity := &MonitoredEntity{KeyValueEntity: newMemoryKeyValueEntityMock(nil), Monitor: monitor}
+	pool := &GeneratorPool{
+		BatchSize: 10,
+		Buffer:    10,
+		Interval:  time.Millisecond,
+		Keys:      entity,
+		Supply:    &DemandPlanner{Monitor: monitor, Supply: time.Minute, MinAvailable: 35},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan error)
+	go pool.GenerateKeys(ctx, NextKey, ch)
+
+	for e := range ch {
+		t.Errorf("GenerateKeys() sent %v, want no errors", e)
+	}
+
+	if got := pool.Stats().Created; got != 35 {
+		t.Errorf("GenerateKeys() created %v keys, want the 35 planned", got)
+	}
+}
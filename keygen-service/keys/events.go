@@ -0,0 +1,696 @@
+package keys
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"log"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valkey-io/valkey-glide/go/api/options"
+)
+
+const (
+	KeysEventsName       = "keysEvents"
+	KeysEventsOutboxName = "keysEventsOutbox"
+)
+
+// eventsRelayGroup is the consumer group of
+// the publishers relaying the outbox events
+const eventsRelayGroup = "publishers"
+
+// EventSchemaVersion is the version of the events published,
+// bumped whenever a change would mislead existing consumers
+const EventSchemaVersion = 1
+
+// EventType names a change in the lifecycle of keys
+type EventType string
+
+const (
+	EventCreated          EventType = "created"
+	EventAllocated        EventType = "allocated"
+	EventReleased         EventType = "released"
+	EventReserved         EventType = "reserved" // taken out of the pool, e.g. a custom key
+	EventQuarantined      EventType = "quarantined"
+	EventQuarantineLifted EventType = "quarantine_lifted"
+	EventBanned           EventType = "banned"
+)
+
+// ErrInvalidCursor is returned when reading
+// events after a malformed cursor
+var ErrInvalidCursor = errors.New("invalid events cursor")
+
+// Event is a change in the lifecycle of keys; consumers
+// should skip the versions they don't know, and the
+// IDs they already handled as events may be redelivered
+type Event struct {
+	Version   int       `json:"version"`
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Type      EventType `json:"type"`
+	Namespace string    `json:"namespace,omitempty"`
+	Key       string    `json:"key,omitempty"`    // empty for created batches
+	Count     int64     `json:"count"`            // of keys, 1 but for created batches
+	Caller    string    `json:"caller,omitempty"` // of the RPC causing the change
+
+	// Cursor locates the event in the sink it was read from
+	Cursor string `json:"-"`
+}
+
+// EventSink receives the published events, in order
+type EventSink interface {
+	Publish(events []Event) error
+}
+
+// EventOutbox keeps the published events until the
+// sink took them, surviving restarts of the publisher
+type EventOutbox interface {
+	// Add appends the events
+	Add(events []Event) error
+
+	// Claim returns up to count events to deliver, the oldest
+	// first, events claimed long ago by publishers gone included;
+	// their Cursor locates them in the outbox
+	Claim(count int) ([]Event, error)
+
+	// Done removes the delivered events
+	Done(events []Event) error
+}
+
+// EventReader is implemented by sinks whose events are read back
+type EventReader interface {
+	// Read returns up to count events published after
+	// the cursor, from the oldest one when empty
+	Read(after string, count int) ([]Event, error)
+
+	// Last returns the cursor of the latest event
+	Last() (string, error)
+}
+
+// EventPublisher delivers the events to the sink in the
+// background, in batches, retrying failures with backoff until
+// the sink takes them: events are delivered at least once,
+// unless its buffer overflows while the sink fails or, without
+// outbox, the publisher stops before delivering them
+type EventPublisher struct {
+	Sink EventSink
+
+	// Outbox, when set, keeps each event from its publication until
+	// the sink took it, the buffer only holding events the outbox
+	// failed to take; publishers sharing the outbox deliver the
+	// events of each other
+	Outbox EventOutbox
+
+	// BatchSize events are delivered at once, 100 when zero
+	BatchSize int
+
+	// Backoff spaces retries of failing deliveries
+	Backoff Backoff
+
+	// Interval between claims of the outbox events left
+	// undelivered, e.g. by a publisher gone, 1s when zero
+	Interval time.Duration
+
+	queue chan Event
+	added chan struct{}
+
+	published, outboxed, dropped, failures atomic.Int64
+}
+
+// EventPublisherStats is a snapshot of an EventPublisher
+type EventPublisherStats struct {
+	Published int64
+	Outboxed  int64 // taken by the outbox
+	Pending   int   // in the buffer
+	Dropped   int64 // published while the buffer was full
+	Failures  int64 // failed deliveries, retried
+}
+
+// NewEventPublisher returns a publisher of up to
+// buffer events waiting for the sink
+func NewEventPublisher(sink EventSink, buffer int) *EventPublisher {
+	return &EventPublisher{Sink: sink, queue: make(chan Event, max(buffer, 1)), added: make(chan struct{}, 1)}
+}
+
+// Publish adds the event to the outbox, or queues it,
+// stamped with the schema version, an ID and the time when
+// missing; never blocks on a full buffer, dropping the event
+func (p *EventPublisher) Publish(event Event) {
+	event.Version = EventSchemaVersion
+	if event.ID == "" {
+		event.ID = newEventID()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	event.Count = cmp.Or(event.Count, 1)
+
+	if p.Outbox != nil {
+		err := p.Outbox.Add([]Event{event})
+		if err == nil {
+			p.outboxed.Add(1)
+			select {
+			case p.added <- struct{}{}:
+			default:
+			}
+			return
+		}
+
+		p.failures.Add(1)
+		log.Printf("keys events outbox failed, buffering %s event of key %s: %v", event.Type, event.Key, err)
+	}
+
+	select {
+	case p.queue <- event:
+	default:
+		if p.dropped.Add(1) == 1 {
+			log.Printf("keys events buffer full, dropping %s event of key %s", event.Type, event.Key)
+		}
+	}
+}
+
+// Run should be launched in its own goroutine where it delivers
+// the events until ctx is done, then delivers those still
+// queued, trying a few times, and closes the channel; its
+// errors must be received until then
+func (p *EventPublisher) Run(ctx context.Context, ch chan error) {
+	defer close(ch)
+
+	send := func(err error) { ch <- err }
+
+	var claims <-chan time.Time
+	if p.Outbox != nil {
+		ticker := time.NewTicker(cmp.Or(p.Interval, time.Second))
+		defer ticker.Stop()
+		claims = ticker.C
+	}
+
+	for {
+		select {
+		case event := <-p.queue:
+			batch := p.batch(event)
+			if !p.deliverRetrying(ctx, batch, send) {
+				p.flush(batch, send)
+				return
+			}
+		case <-p.added:
+			p.relay(ctx, send)
+		case <-claims:
+			p.relay(ctx, send)
+		case <-ctx.Done():
+			p.flush(nil, send)
+			return
+		}
+	}
+}
+
+// deliverRetrying delivers the batch, retrying with
+// backoff until delivered or ctx is done
+func (p *EventPublisher) deliverRetrying(ctx context.Context, batch []Event, send func(error)) bool {
+	for attempt := 0; ctx.Err() == nil; attempt++ {
+		err := p.deliver(batch)
+		if err == nil {
+			return true
+		}
+
+		if attempt == 0 {
+			send(err)
+		}
+		sleep(ctx, p.Backoff.Delay(attempt))
+	}
+
+	return false
+}
+
+// relay delivers the events claimed from the outbox until
+// none is left; events left undelivered when ctx is done
+// stay in the outbox, claimed again later
+func (p *EventPublisher) relay(ctx context.Context, send func(error)) {
+	for ctx.Err() == nil {
+		batch, err := p.Outbox.Claim(cmp.Or(p.BatchSize, 100))
+		if err != nil {
+			p.failures.Add(1)
+			send(fmt.Errorf("failed to claim keys events: %w", err))
+			return
+		}
+
+		if len(batch) == 0 || !p.deliverRetrying(ctx, batch, send) {
+			return
+		}
+
+		if err := p.Outbox.Done(batch); err != nil {
+			p.failures.Add(1)
+			send(fmt.Errorf("failed to remove delivered keys events, delivered again later: %w", err))
+			return
+		}
+	}
+}
+
+// flush delivers the undelivered batch and the queued
+// events, trying each batch a few times
+func (p *EventPublisher) flush(batch []Event, send func(error)) {
+	for {
+		if len(batch) == 0 {
+			select {
+			case event := <-p.queue:
+				batch = p.batch(event)
+			default:
+				return
+			}
+		}
+
+		var err error
+		for attempt := range 3 {
+			if err = p.deliver(batch); err == nil {
+				break
+			}
+			time.Sleep(p.Backoff.Delay(attempt))
+		}
+
+		if err != nil {
+			p.dropped.Add(int64(len(batch)))
+			send(fmt.Errorf("dropped %d keys events: %w", len(batch), err))
+		}
+		batch = nil
+	}
+}
+
+// batch returns the event with the queued ones, up to BatchSize
+func (p *EventPublisher) batch(event Event) []Event {
+	batch := []Event{event}
+	for len(batch) < cmp.Or(p.BatchSize, 100) {
+		select {
+		case event := <-p.queue:
+			batch = append(batch, event)
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+func (p *EventPublisher) deliver(batch []Event) error {
+	if err := p.Sink.Publish(batch); err != nil {
+		p.failures.Add(1)
+		return fmt.Errorf("failed to publish keys events: %w", err)
+	}
+
+	p.published.Add(int64(len(batch)))
+	return nil
+}
+
+// Stats returns the publisher counters
+func (p *EventPublisher) Stats() EventPublisherStats {
+	return EventPublisherStats{
+		Published: p.published.Load(),
+		Outboxed:  p.outboxed.Load(),
+		Pending:   len(p.queue),
+		Dropped:   p.dropped.Load(),
+		Failures:  p.failures.Load(),
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b) // never fails, see rand.Read
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// publish publishes an event of the allocator namespace when set
+func (a *Allocator) publish(ctx context.Context, eventType EventType, key ShortKey, count int64) {
+	if a.Events == nil {
+		return
+	}
+
+	a.Events.Publish(Event{Type: eventType, Namespace: a.Namespace, Key: string(key), Count: count, Caller: caller(ctx)})
+}
+
+// MemoryEventSink keeps the events in memory, e.g. in tests;
+// cursors are the positions of the events
+type MemoryEventSink struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+// Publish appends the events
+func (s *MemoryEventSink) Publish(events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.events = append(s.events, events...)
+	return nil
+}
+
+// Fail has the next publications fail with err, until nil
+func (s *MemoryEventSink) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+// Events returns every published event
+func (s *MemoryEventSink) Events() []Event {
+	events, _ := s.Read("", 0)
+	return events
+}
+
+// Read returns up to count events after the cursor, every one when count is 0
+func (s *MemoryEventSink) Read(after string, count int) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := 0
+	if after != "" {
+		var err error
+		if start, err = strconv.Atoi(after); err != nil || start < 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, after)
+		}
+	}
+
+	var events []Event
+	for i := start; i < len(s.events) && (count == 0 || len(events) < count); i++ {
+		event := s.events[i]
+		event.Cursor = strconv.Itoa(i + 1)
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// Last returns the position of the latest event
+func (s *MemoryEventSink) Last() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return strconv.Itoa(len(s.events)), nil
+}
+
+// FileEventSink appends the events to a file, a JSON object
+// per line, synced to disk before they count as delivered
+type FileEventSink struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Publish appends the events to the file
+func (s *FileEventSink) Publish(events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lines []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode keys event: %w", err)
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+
+	// #nosec G304 -- path configured by the operator
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open keys events file: %w", err)
+	}
+
+	if _, err := f.Write(lines); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write keys events: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write keys events: %w", err)
+	}
+
+	return f.Close()
+}
+
+// streamID matches the IDs of stream entries
+var streamID = regexp.MustCompile(`^[0-9]+(-[0-9]+)?$`)
+
+// ValkeyEventSink appends the events to a stream capped
+// to about MaxLen events, whose IDs are the cursors;
+// consumers may also read it with consumer groups
+type ValkeyEventSink struct {
+	// Client connects to the db, required
+	Client app.KeyValueDbClient
+
+	// MaxLen events are kept, 1,000,000 when zero
+	MaxLen int64
+}
+
+// Publish appends the events to the stream
+func (s *ValkeyEventSink) Publish(events []Event) error {
+	valkeyClient, err := valkeyConn(s.Client)
+	if err != nil {
+		return err
+	}
+
+	opts := options.NewXAddOptions().SetTrimOptions(options.NewXTrimOptionsWithMaxLen(cmp.Or(s.MaxLen, 1_000_000)).SetNearlyExactTrimming())
+	for _, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode keys event: %w", err)
+		}
+
+		fields := [][]string{{"version", strconv.Itoa(event.Version)}, {"event", string(value)}}
+		if _, err := valkeyClient.XAddWithOptions(KeysEventsName, fields, *opts); err != nil {
+			return fmt.Errorf("failed to append keys event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Read returns up to count events after the stream ID
+func (s *ValkeyEventSink) Read(after string, count int) ([]Event, error) {
+	start := options.NewInfiniteStreamBoundary(options.NegativeInfinity)
+	if after != "" {
+		if !streamID.MatchString(after) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, after)
+		}
+		start = options.NewStreamBoundary(after, false)
+	}
+
+	valkeyClient, err := valkeyConn(s.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := valkeyClient.XRangeWithOptions(KeysEventsName, start,
+		options.NewInfiniteStreamBoundary(options.PositiveInfinity), *options.NewXRangeOptions().SetCount(int64(max(count, 1))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys events: %w", err)
+	}
+
+	events := make([]Event, 0, len(entries))
+	for _, entry := range entries {
+		for _, field := range entry.Entries {
+			if len(field) != 2 || field[0] != "event" {
+				continue
+			}
+
+			var event Event
+			if err := json.Unmarshal([]byte(field[1]), &event); err != nil {
+				return nil, fmt.Errorf("failed to decode keys event %s: %w", entry.StreamId, err)
+			}
+			event.Cursor = entry.StreamId
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// Last returns the ID of the latest entry, "0" for an empty stream
+func (s *ValkeyEventSink) Last() (string, error) {
+	valkeyClient, err := valkeyConn(s.Client)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := valkeyClient.XRevRangeWithOptions(KeysEventsName, options.NewInfiniteStreamBoundary(options.PositiveInfinity),
+		options.NewInfiniteStreamBoundary(options.NegativeInfinity), *options.NewXRangeOptions().SetCount(1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read keys events: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return "0", nil
+	}
+
+	return entries[0].StreamId, nil
+}
+
+// ValkeyEventOutbox keeps the events in a stream read by the
+// consumer group of the publishers, removing each event once
+// delivered; the events a publisher claimed and left undelivered
+// for MinIdle go to the next publisher claiming events
+type ValkeyEventOutbox struct {
+	// Client connects to the db, required
+	Client app.KeyValueDbClient
+
+	// Consumer names the publisher in the group, required
+	Consumer string
+
+	// MinIdle before claiming the events claimed by
+	// another publisher, a minute when zero
+	MinIdle time.Duration
+
+	grouped atomic.Bool
+}
+
+// Add appends the events to the stream
+func (o *ValkeyEventOutbox) Add(events []Event) error {
+	valkeyClient, err := valkeyConn(o.Client)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode keys event: %w", err)
+		}
+
+		if _, err := valkeyClient.XAdd(KeysEventsOutboxName, [][]string{{"event", string(value)}}); err != nil {
+			return fmt.Errorf("failed to add keys event to the outbox: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Claim claims the events left idle by publishers first,
+// then the events no publisher claimed yet
+func (o *ValkeyEventOutbox) Claim(count int) ([]Event, error) {
+	valkeyClient, err := valkeyConn(o.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	if !o.grouped.Load() {
+		_, err := valkeyClient.XGroupCreateWithOptions(KeysEventsOutboxName, eventsRelayGroup, "0", *options.NewXGroupCreateOptions().SetMakeStream())
+		if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+			return nil, fmt.Errorf("failed to create keys events outbox group: %w", err)
+		}
+		o.grouped.Store(true)
+	}
+
+	idle := cmp.Or(o.MinIdle, time.Minute).Milliseconds()
+	claimed, err := valkeyClient.XAutoClaimWithOptions(KeysEventsOutboxName, eventsRelayGroup, o.Consumer, idle, "0-0", *options.NewXAutoClaimOptions().SetCount(int64(count)))
+	if err != nil {
+		o.grouped.Store(!strings.Contains(err.Error(), "NOGROUP"))
+		return nil, fmt.Errorf("failed to claim keys events: %w", err)
+	}
+
+	entries := claimed.ClaimedEntries
+	if len(entries) == 0 {
+		res, err := valkeyClient.XReadGroupWithOptions(eventsRelayGroup, o.Consumer, map[string]string{KeysEventsOutboxName: ">"},
+			*options.NewXReadGroupOptions().SetCount(int64(count)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keys events: %w", err)
+		}
+		entries = res[KeysEventsOutboxName]
+	}
+
+	events, malformed := outboxEvents(entries)
+	if len(malformed) > 0 {
+		log.Printf("keys events outbox removing %d malformed events: %v", len(malformed), malformed)
+		if err := o.Done(malformed); err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+// Done acknowledges and removes the delivered events
+func (o *ValkeyEventOutbox) Done(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	valkeyClient, err := valkeyConn(o.Client)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.Cursor
+	}
+
+	if _, err := valkeyClient.XAck(KeysEventsOutboxName, eventsRelayGroup, ids); err != nil {
+		return fmt.Errorf("failed to acknowledge keys events: %w", err)
+	}
+
+	if _, err := valkeyClient.XDel(KeysEventsOutboxName, ids); err != nil {
+		return fmt.Errorf("failed to remove keys events: %w", err)
+	}
+
+	return nil
+}
+
+// outboxEvents decodes the stream entries, the oldest first,
+// returning apart those that aren't events to be removed
+func outboxEvents(entries map[string][][]string) ([]Event, []Event) {
+	ids := slices.SortedFunc(maps.Keys(entries), compareStreamIDs)
+
+	var events, malformed []Event
+	for _, id := range ids {
+		var event Event
+		decoded := false
+		for _, field := range entries[id] {
+			if len(field) == 2 && field[0] == "event" {
+				decoded = json.Unmarshal([]byte(field[1]), &event) == nil
+			}
+		}
+
+		event.Cursor = id
+		if !decoded {
+			malformed = append(malformed, Event{Cursor: id})
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events, malformed
+}
+
+// compareStreamIDs orders stream IDs by time, then sequence
+func compareStreamIDs(a, b string) int {
+	parse := func(id string) (uint64, uint64) {
+		ms, seq, _ := strings.Cut(id, "-")
+		m, _ := strconv.ParseUint(ms, 10, 64)
+		s, _ := strconv.ParseUint(seq, 10, 64)
+		return m, s
+	}
+
+	am, as := parse(a)
+	bm, bs := parse(b)
+
+	return cmp.Or(cmp.Compare(am, bm), cmp.Compare(as, bs))
+}
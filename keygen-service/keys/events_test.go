@@ -0,0 +1,371 @@
+package keys
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// runEventPublisher runs the publisher until the returned func
+// is called, which returns once the pending events are delivered
+func runEventPublisher(p *EventPublisher) func() []error {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan error)
+	go p.Run(ctx, ch)
+
+	errs := make(chan []error)
+	go func() {
+		var received []error
+		for err := range ch {
+			received = append(received, err)
+		}
+		errs <- received
+	}()
+
+	return func() []error {
+		cancel()
+		return <-errs
+	}
+}
+
+func TestEventPublisher_GivenFailingSink(t *testing.T) {
+	sink := &MemoryEventSink{}
+	sink.Fail(errors.New("sink down"))
+
+	publisher := NewEventPublisher(sink, 10)
+	publisher.Backoff = Backoff{Initial: time.Millisecond, Max: time.Millisecond}
+	stop := runEventPublisher(publisher)
+
+	publisher.Publish(Event{Type: EventAllocated, Key: "aaaaaa"})
+	publisher.Publish(Event{Type: EventReleased, Key: "aaaaaa"})
+
+	time.Sleep(20 * time.Millisecond)
+	sink.Fail(nil)
+
+	for publisher.Stats().Published < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+
+	events := sink.Events()
+	if len(events) != 2 || events[0].Type != EventAllocated || events[1].Type != EventReleased {
+		t.Fatalf("Events() = %+v, want the events delivered once, in order", events)
+	}
+
+	if events[0].Version != EventSchemaVersion || events[0].ID == "" || events[0].ID == events[1].ID || events[0].Count != 1 {
+		t.Errorf("Events() = %+v, want versioned events with unique IDs", events)
+	}
+
+	if stats := publisher.Stats(); stats.Failures == 0 || stats.Dropped != 0 {
+		t.Errorf("Stats() = %+v, want failures retried", stats)
+	}
+}
+
+func TestEventPublisher_GivenFullBuffer(t *testing.T) {
+	sink := &MemoryEventSink{}
+	publisher := NewEventPublisher(sink, 1)
+
+	publisher.Publish(Event{Type: EventAllocated, Key: "aaaaaa"})
+	publisher.Publish(Event{Type: EventAllocated, Key: "bbbbbb"})
+
+	if errs := runEventPublisher(publisher)(); len(errs) != 0 {
+		t.Errorf("Run() sent %v, want none", errs)
+	}
+
+	if events := sink.Events(); len(events) != 1 || events[0].Key != "aaaaaa" {
+		t.Errorf("Events() = %+v, want the buffered event delivered on stop", events)
+	}
+
+	if stats := publisher.Stats(); stats.Dropped != 1 {
+		t.Errorf("Stats() = %+v, want 1 dropped", stats)
+	}
+}
+
+// eventOutboxMock keeps the events in memory, every
+// event left claimable until done, failing with err
+type eventOutboxMock struct {
+	mu     sync.Mutex
+	events []Event
+	added  int
+	err    error
+}
+
+func (o *eventOutboxMock) Add(events []Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.err != nil {
+		return o.err
+	}
+
+	for _, event := range events {
+		o.added++
+		event.Cursor = strconv.Itoa(o.added)
+		o.events = append(o.events, event)
+	}
+
+	return nil
+}
+
+func (o *eventOutboxMock) Claim(count int) ([]Event, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return slices.Clone(o.events[:min(count, len(o.events))]), nil
+}
+
+func (o *eventOutboxMock) Done(events []Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.events = slices.DeleteFunc(o.events, func(e Event) bool {
+		return slices.ContainsFunc(events, func(done Event) bool { return done.Cursor == e.Cursor })
+	})
+
+	return nil
+}
+
+func (o *eventOutboxMock) fail(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.err = err
+}
+
+func (o *eventOutboxMock) pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.events)
+}
+
+func TestEventPublisher_GivenOutbox(t *testing.T) {
+	outbox, sink := &eventOutboxMock{}, &MemoryEventSink{}
+	sink.Fail(errors.New("sink down"))
+
+	publisher := NewEventPublisher(sink, 10)
+	publisher.Outbox, publisher.Backoff = outbox, Backoff{Initial: time.Millisecond, Max: time.Millisecond}
+	stop := runEventPublisher(publisher)
+
+	publisher.Publish(Event{Type: EventAllocated, Key: "aaaaaa"})
+	publisher.Publish(Event{Type: EventReleased, Key: "aaaaaa"})
+
+	time.Sleep(10 * time.Millisecond)
+	stop()
+
+	if pending := outbox.pending(); pending != 2 {
+		t.Fatalf("Run() left %d events in the outbox, want the 2 undelivered ones", pending)
+	}
+
+	// another publisher relays the events left
+	sink.Fail(nil)
+	relay := NewEventPublisher(sink, 10)
+	relay.Outbox, relay.Interval = outbox, time.Millisecond
+	stop = runEventPublisher(relay)
+
+	for relay.Stats().Published < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+
+	events := sink.Events()
+	if len(events) != 2 || events[0].Type != EventAllocated || events[1].Type != EventReleased || outbox.pending() != 0 {
+		t.Errorf("Events() = %+v, want the outbox events delivered in order", events)
+	}
+
+	outbox.fail(errors.New("outbox down"))
+	publisher.Publish(Event{Type: EventBanned, Key: "aaaaaa"})
+
+	if stats := publisher.Stats(); stats.Outboxed != 2 || stats.Pending != 1 {
+		t.Errorf("Stats() = %+v, want the event buffered without outbox", stats)
+	}
+}
+
+func TestValkeyEventOutbox_GivenEntries(t *testing.T) {
+	events, malformed := outboxEvents(map[string][][]string{
+		"1700000000000-10": {{"event", `{"type":"released","key":"aaaaaa"}`}},
+		"1700000000000-9":  {{"event", `{"type":"allocated","key":"aaaaaa"}`}},
+		"1600000000000-0":  {{"event", "not an event"}},
+	})
+
+	if len(events) != 2 || events[0].Type != EventAllocated || events[0].Cursor != "1700000000000-9" || events[1].Type != EventReleased {
+		t.Errorf("outboxEvents() = %+v, want the events in stream order", events)
+	}
+
+	if len(malformed) != 1 || malformed[0].Cursor != "1600000000000-0" {
+		t.Errorf("outboxEvents() = %+v, want the malformed entry apart", malformed)
+	}
+}
+
+func TestAllocator_GivenEvents(t *testing.T) {
+	sink := &MemoryEventSink{}
+	publisher := NewEventPublisher(sink, 100)
+
+	allocator := NewAllocator(newMemoryKeyValueEntityMock(map[string]KeyState{"aaaaaa": KeyAvailable}), NextKey)
+	allocator.Events, allocator.Namespace = publisher, "brand"
+	ctx := auditedContext()
+
+	key, err := allocator.Allocate(ctx)
+	if err != nil {
+		t.Fatalf("Allocate() failed: %v", err)
+	}
+
+	if _, err := allocator.Allocate(ctx); err != nil {
+		t.Fatalf("Allocate() failed: %v", err)
+	}
+
+	if err := allocator.Release(ctx, key); err != nil {
+		t.Fatalf("Release() failed: %v", err)
+	}
+
+	if err := allocator.Reserve(ctx, ShortKey("bbbbbb")); err != nil {
+		t.Fatalf("Reserve() failed: %v", err)
+	}
+
+	if err := allocator.Ban(ctx, ShortKey("bbbbbb")); err != nil {
+		t.Fatalf("Ban() failed: %v", err)
+	}
+
+	if err := allocator.Quarantine(ctx, ShortKey("cccccc")); err != nil {
+		t.Fatalf("Quarantine() failed: %v", err)
+	}
+
+	if err := allocator.LiftQuarantine(ctx, ShortKey("cccccc")); err != nil {
+		t.Fatalf("LiftQuarantine() failed: %v", err)
+	}
+
+	if _, err := allocator.Seed(ctx, NextKey, 3); err != nil {
+		t.Fatalf("Seed() failed: %v", err)
+	}
+
+	runEventPublisher(publisher)()
+
+	want := []struct {
+		eventType EventType
+		count     int64
+	}{
+		{EventAllocated, 1},
+		{EventCreated, 1}, // generated on demand
+		{EventAllocated, 1},
+		{EventReleased, 1},
+		{EventReserved, 1},
+		{EventBanned, 1},
+		{EventQuarantined, 1},
+		{EventQuarantineLifted, 1},
+		{EventCreated, 3},
+	}
+
+	events := sink.Events()
+	if len(events) != len(want) {
+		t.Fatalf("Events() = %+v, want %d events", events, len(want))
+	}
+
+	for i, event := range events {
+		if event.Type != want[i].eventType || event.Count != want[i].count || event.Namespace != "brand" || event.Caller != "url-shortener" {
+			t.Errorf("Events()[%d] = %+v, want %d %s of namespace brand", i, event, want[i].count, want[i].eventType)
+		}
+	}
+}
+
+func TestFileEventSink_GivenEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink := &FileEventSink{Path: path}
+
+	for _, key := range []string{"aaaaaa", "bbbbbb"} {
+		if err := sink.Publish([]Event{{Version: EventSchemaVersion, Type: EventAllocated, Key: key}}); err != nil {
+			t.Fatalf("Publish() failed: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open events file: %v", err)
+	}
+	defer f.Close()
+
+	var keys []string
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("could not decode event %q: %v", scanner.Text(), err)
+		}
+		keys = append(keys, event.Key)
+	}
+
+	if len(keys) != 2 || keys[0] != "aaaaaa" || keys[1] != "bbbbbb" {
+		t.Errorf("Publish() wrote %v, want the events appended", keys)
+	}
+}
+
+// eventStreamMock receives the events sent to a watcher
+type eventStreamMock struct {
+	grpc.ServerStream
+
+	ctx    context.Context
+	events []*KeyEvent
+	sent   chan struct{}
+}
+
+func (s *eventStreamMock) Context() context.Context {
+	return s.ctx
+}
+
+func (s *eventStreamMock) Send(event *KeyEvent) error {
+	s.events = append(s.events, event)
+	s.sent <- struct{}{}
+
+	return nil
+}
+
+func TestRPCHandler_GivenWatchedEvents(t *testing.T) {
+	sink := &MemoryEventSink{}
+	_ = sink.Publish([]Event{
+		{Version: 1, ID: "1", Type: EventAllocated, Key: "aaaaaa"},
+		{Version: 1, ID: "2", Type: EventAllocated, Key: "bbbbbb", Namespace: "brand"},
+		{Version: 1, ID: "3", Type: EventReleased, Key: "aaaaaa"},
+	})
+
+	handler := &RPCHandler{Allocator: NewAllocator(nil, nil), Events: sink, WatchInterval: time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &eventStreamMock{ctx: ctx, sent: make(chan struct{})}
+	done := make(chan error)
+	go func() {
+		done <- handler.WatchEvents(&WatchEventsRequest{Cursor: "1", Types: []string{"released", "created"}}, stream)
+	}()
+
+	<-stream.sent // the released event, after the cursor
+	_ = sink.Publish([]Event{{Version: 1, ID: "4", Type: EventCreated, Count: 10}})
+	<-stream.sent // published while watching
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("WatchEvents() failed: %v", err)
+	}
+
+	if len(stream.events) != 2 || stream.events[0].GetId() != "3" || stream.events[0].GetCursor() != "3" || stream.events[1].GetCount() != 10 {
+		t.Errorf("WatchEvents() sent %v, want the released and created events of the default namespace", stream.events)
+	}
+
+	err := handler.WatchEvents(&WatchEventsRequest{Cursor: "not a cursor"}, &eventStreamMock{ctx: context.Background()})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("WatchEvents() = %v, want %v", err, codes.InvalidArgument)
+	}
+
+	handler.Events = nil
+	if err := handler.WatchEvents(&WatchEventsRequest{}, stream); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("WatchEvents() = %v, want %v without events", err, codes.FailedPrecondition)
+	}
+}
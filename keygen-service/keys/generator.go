@@ -1,6 +1,7 @@
 package keys
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
@@ -10,46 +11,37 @@ import (
 
 // GenerateKeys should be launched in its own
 // goroutine where it will use the generator function
-// to create new keys indefinitely
-func GenerateKeys(generator func() (*ShortKey, error), interval time.Duration, ch chan error) {
-	builtApp, err := app.GetApp()
-	if err != nil {
-		ch <- fmt.Errorf("failed to get app: %w", err)
-		close(ch)
-
-		return
-	}
-
-	for {
-		newKey, err := generator()
-		if err != nil {
-			ch <- fmt.Errorf("failed to generate key: %w", err)
-		}
-
-		if err := builtApp.GetKeyValueDb().Keys.Create(newKey); err != nil {
-			ch <- fmt.Errorf("failed to create key: %w", err)
-		}
-
-		time.Sleep(interval)
-	}
+// to create new keys into entity indefinitely, a key per
+// interval; see GeneratorPool for concurrent generation
+func GenerateKeys(entity app.KeyValueEntity, generator func() (*ShortKey, error), interval time.Duration, ch chan error) {
+	pool := &GeneratorPool{Workers: 1, Writers: 1, BatchSize: 1, Buffer: 1, Interval: interval, Keys: entity}
+	pool.GenerateKeys(context.Background(), generator, ch)
 }
 
 // NextKey generates 6-bytes URL safe short keys encoded in base64
 func NextKey() (*ShortKey, error) {
-	bytes := make([]byte, 6)
+	return NextKeyOfLength(MinKeyLength)()
+}
 
-	_, err := rand.Read(bytes)
-	if err != nil { // this should never happen
-		return nil, fmt.Errorf("failed to create random bytes: %w", err)
-	}
+// NextKeyOfLength returns a generator of URL safe
+// short keys with the given length encoded in base64
+func NextKeyOfLength(length int) func() (*ShortKey, error) {
+	return func() (*ShortKey, error) {
+		bytes := make([]byte, length)
 
-	encodedBytes := make([]byte, base64.URLEncoding.EncodedLen(len(bytes)))
-	base64.URLEncoding.Encode(encodedBytes, bytes)
+		_, err := rand.Read(bytes)
+		if err != nil { // this should never happen
+			return nil, fmt.Errorf("failed to create random bytes: %w", err)
+		}
 
-	key, err := NewKeyFromBytes(encodedBytes[:6])
-	if err != nil {
-		return key, fmt.Errorf("failed key validation: %w", err)
-	}
+		encodedBytes := make([]byte, base64.URLEncoding.EncodedLen(len(bytes)))
+		base64.URLEncoding.Encode(encodedBytes, bytes)
 
-	return key, nil
+		key, err := NewKeyFromBytes(encodedBytes[:length])
+		if err != nil {
+			return key, fmt.Errorf("failed key validation: %w", err)
+		}
+
+		return key, nil
+	}
 }
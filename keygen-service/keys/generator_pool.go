@@ -0,0 +1,401 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// GeneratorPool generates keys concurrently: Workers
+// goroutines produce keys that Writers goroutines save
+// in batches of up to BatchSize keys; at most Buffer
+// keys wait to be written, holding producers back
+// whenever writes fall behind
+type GeneratorPool struct {
+	Workers   int
+	Writers   int
+	BatchSize int
+	Buffer    int
+
+	// Interval is the pause of a writer after each batch
+	Interval time.Duration
+
+	// Keys receives the generated keys, required
+	Keys app.KeyValueEntity
+
+	// Backoff spaces retries of writes failing
+	// with transient errors, see IsTransient
+	Backoff Backoff
+
+	// Breaker, when set, holds writes back
+	// after repeated failures
+	Breaker *CircuitBreaker
+
+	// Supply, when set, bounds batches to the keys missing
+	// from the planned supply, writers idle for Interval
+	// while there are enough keys
+	Supply SupplyPlanner
+
+	// Fence, when set, is checked before each write,
+	// its failure drops the batch; e.g. lost leadership
+	Fence func() error
+
+	// Events, when set, publishes the keys created
+	// as events of the Namespace
+	Events    *EventPublisher
+	Namespace string
+
+	// Replenishment, when set, wakes writers pausing while
+	// allocations wait for keys, which are woken once
+	// keys are created
+	Replenishment *Replenishment
+
+	generated, created, invalid, failures atomic.Int64
+	lastError                             atomic.Value
+}
+
+// GeneratorStats is a snapshot of a GeneratorPool
+type GeneratorStats struct {
+	Circuit             string
+	ConsecutiveFailures int
+	Generated           int64 // valid keys produced
+	Created             int64 // new keys written
+	Invalid             int64 // failed or invalid generations
+	Failures            int64 // failed writes
+	LastError           string
+}
+
+// Stats returns the pool counters and circuit state
+func (p *GeneratorPool) Stats() GeneratorStats {
+	stats := GeneratorStats{
+		Circuit:   CircuitClosed.String(),
+		Generated: p.generated.Load(),
+		Created:   p.created.Load(),
+		Invalid:   p.invalid.Load(),
+		Failures:  p.failures.Load(),
+	}
+
+	if p.Breaker != nil {
+		state, failures := p.Breaker.State()
+		stats.Circuit, stats.ConsecutiveFailures = state.String(), failures
+	}
+
+	if err, ok := p.lastError.Load().(string); ok {
+		stats.LastError = err
+	}
+
+	return stats
+}
+
+// GenerateKeys should be launched in its own goroutine
+// where it will use the generator function to create new
+// keys until ctx is done, when it closes the channel
+func (p *GeneratorPool) GenerateKeys(ctx context.Context, generator func() (*ShortKey, error), ch chan error) {
+	entity := p.Keys
+	if entity == nil {
+		ch <- ErrNoKeysStorage
+		close(ch)
+
+		return
+	}
+
+	pending := make(chan *ShortKey, max(p.Buffer, 1))
+	inflight := &inflightKeys{keys: map[string]bool{}}
+
+	var producers sync.WaitGroup
+	for range max(p.Workers, 1) {
+		producers.Add(1)
+		go func() {
+			defer producers.Done()
+			p.produceKeys(ctx, generator, pending, ch)
+		}()
+	}
+
+	go func() {
+		producers.Wait()
+		close(pending)
+	}()
+
+	var writers sync.WaitGroup
+	for range max(p.Writers, 1) {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			p.writeKeys(ctx, entity, pending, inflight, ch)
+		}()
+	}
+
+	writers.Wait()
+	close(ch)
+}
+
+// produceKeys skips the keys the generator fails to produce,
+// backing off while it fails and reporting only the first
+// failure in a row
+func (p *GeneratorPool) produceKeys(ctx context.Context, generator func() (*ShortKey, error), pending chan<- *ShortKey, ch chan error) {
+	for failures := 0; ctx.Err() == nil; {
+		newKey, err := generator()
+		if err != nil || newKey == nil {
+			if err == nil {
+				err = errors.New("no key generated")
+			}
+
+			p.invalid.Add(1)
+			if failures == 0 {
+				p.sendError(ctx, ch, fmt.Errorf("failed to generate key: %w", err))
+			}
+
+			sleep(ctx, p.Backoff.Delay(failures))
+			failures++
+			continue
+		}
+		p.generated.Add(1)
+		failures = 0
+
+		select {
+		case pending <- newKey:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// writeKeys saves pending keys until there are no more; keys
+// still pending once ctx is done are dropped unwritten, the
+// generator may have lost its leadership meanwhile
+func (p *GeneratorPool) writeKeys(ctx context.Context, entity app.KeyValueEntity, pending <-chan *ShortKey, inflight *inflightKeys, ch chan error) {
+	for failed := 0; ; {
+		size := p.batchSize()
+		if size == 0 {
+			if ctx.Err() != nil {
+				return
+			}
+
+			p.pause(ctx, 0)
+			continue
+		}
+
+		newKey, ok := <-pending
+		if !ok {
+			return
+		}
+
+		batch := []*ShortKey{newKey}
+
+	fill:
+		for len(batch) < size {
+			select {
+			case k, ok := <-pending:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, k)
+			default:
+				break fill
+			}
+		}
+
+		batch = inflight.claim(batch)
+		if len(batch) == 0 { // every key already being written
+			continue
+		}
+
+		err := p.writeBatch(ctx, entity, batch, ch)
+		inflight.release(batch)
+
+		if err != nil {
+			failed++
+		} else {
+			failed = 0
+		}
+		p.pause(ctx, failed)
+	}
+}
+
+// pause waits for Interval, or until allocations wait for keys,
+// backing off first after failed batches whoever waits
+func (p *GeneratorPool) pause(ctx context.Context, failed int) {
+	if failed > 0 {
+		sleep(ctx, p.Backoff.Delay(failed-1))
+	}
+
+	if p.Replenishment == nil {
+		sleep(ctx, p.Interval)
+		return
+	}
+
+	if p.Replenishment.Waiting() > 0 {
+		return
+	}
+
+	select {
+	case <-time.After(p.Interval):
+	case <-p.Replenishment.Demanded():
+	case <-ctx.Done():
+	}
+}
+
+// batchSize is BatchSize, or a writer share of the supply
+// deficit when smaller and no allocation waits for keys
+func (p *GeneratorPool) batchSize() int {
+	size := max(p.BatchSize, 1)
+	if p.Supply == nil || p.Replenishment != nil && p.Replenishment.Waiting() > 0 {
+		return size
+	}
+
+	writers := int64(max(p.Writers, 1))
+	share := (p.Supply.Deficit() + writers - 1) / writers
+
+	return int(min(int64(size), share))
+}
+
+// writeBatch saves the batch retrying transient failures with
+// backoff, reporting only the first failure of a batch and the
+// circuit opening, until the batch is written or ctx is done;
+// returns why the batch was dropped
+func (p *GeneratorPool) writeBatch(ctx context.Context, entity app.KeyValueEntity, batch []*ShortKey, ch chan error) error {
+	for attempt := 0; ctx.Err() == nil; {
+		if p.Breaker != nil {
+			if ok, wait := p.Breaker.Allow(); !ok {
+				sleep(ctx, wait)
+				continue
+			}
+		}
+
+		if p.Fence != nil {
+			if err := p.Fence(); err != nil {
+				err = fmt.Errorf("dropped keys batch: %w", err)
+				p.sendError(ctx, ch, err)
+				return err
+			}
+		}
+
+		created, err := createKeys(entity, batch)
+		p.created.Add(created)
+		if created > 0 && p.Events != nil {
+			p.Events.Publish(Event{Type: EventCreated, Namespace: p.Namespace, Count: created})
+		}
+		if created > 0 && p.Replenishment != nil {
+			p.Replenishment.Created()
+		}
+
+		if err == nil || !IsTransient(err) { // the storage is reachable
+			if p.Breaker != nil {
+				p.Breaker.Success()
+			}
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		p.lastError.Store(err.Error())
+
+		if !IsTransient(err) {
+			err = fmt.Errorf("dropped keys batch: %w", err)
+			p.sendError(ctx, ch, err)
+			return err
+		}
+
+		p.failures.Add(1)
+
+		if p.Breaker != nil && p.Breaker.Failure() {
+			p.sendError(ctx, ch, fmt.Errorf("circuit opened after repeated failures: %w", err))
+		} else if attempt == 0 {
+			p.sendError(ctx, ch, err)
+		}
+
+		sleep(ctx, p.Backoff.Delay(attempt))
+		attempt++
+	}
+
+	return ctx.Err()
+}
+
+// createKeys saves the keys in a single batch when the
+// entity supports it, otherwise one by one skipping those
+// already known; returns how many keys were new
+func createKeys(entity app.KeyValueEntity, batch []*ShortKey) (int64, error) {
+	batchEntity, ok := app.As[app.KeyValueBatchEntity](entity)
+	if !ok {
+		var created int64
+		for _, k := range batch {
+			err := entity.Create(k)
+			if errors.Is(err, ErrKeyExists) {
+				continue
+			}
+
+			if err != nil {
+				return created, fmt.Errorf("failed to create key: %w", err)
+			}
+
+			created++
+		}
+
+		return created, nil
+	}
+
+	items := make([]interface{}, len(batch))
+	for i, k := range batch {
+		items[i] = k
+	}
+
+	created, err := batchEntity.CreateBatch(items)
+	if err != nil {
+		return created, fmt.Errorf("failed to create keys: %w", err)
+	}
+
+	return created, nil
+}
+
+func (p *GeneratorPool) sendError(ctx context.Context, ch chan error, err error) {
+	select {
+	case ch <- err:
+	case <-ctx.Done():
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
+
+// inflightKeys deduplicates keys among
+// batches written at the same time
+type inflightKeys struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+// claim drops keys already being written
+// and reserves the others
+func (f *inflightKeys) claim(batch []*ShortKey) []*ShortKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	claimed := batch[:0]
+	for _, k := range batch {
+		if f.keys[string(*k)] {
+			continue
+		}
+
+		f.keys[string(*k)] = true
+		claimed = append(claimed, k)
+	}
+
+	return claimed
+}
+
+func (f *inflightKeys) release(batch []*ShortKey) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, k := range batch {
+		delete(f.keys, string(*k))
+	}
+}
@@ -0,0 +1,182 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	glideErrors "github.com/valkey-io/valkey-glide/go/api/errors"
+)
+
+func TestGeneratorPool_GivenWorkingSetup(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(nil)
+	pool := &GeneratorPool{Workers: 2, Writers: 2, BatchSize: 10, Buffer: 20, Keys: entity}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan error)
+	go pool.GenerateKeys(ctx, NextKey, ch)
+
+	for e := range ch {
+		t.Errorf("GenerateKeys() sent %v, want no errors", e)
+	}
+
+	created, _ := entity.count()
+	batches := entity.batches
+	if created == 0 {
+		t.Error("GenerateKeys() closed, want created keys")
+	}
+
+	if batches >= created {
+		t.Errorf("GenerateKeys() wrote %v keys in %v batches, want many keys per batch", created, batches)
+	}
+}
+
+func TestGeneratorPool_GivenDuplicatedKeys(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(nil)
+	pool := &GeneratorPool{Workers: 4, Writers: 4, BatchSize: 10, Buffer: 20, Keys: entity}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	key, _ := NextKey()
+
+	ch := make(chan error)
+	go pool.GenerateKeys(ctx, func() (*ShortKey, error) { return key, nil }, ch)
+
+	for e := range ch {
+		t.Errorf("GenerateKeys() sent %v, want no errors", e)
+	}
+
+	if created, _ := entity.count(); created != 1 {
+		t.Errorf("GenerateKeys() created %v keys, want 1", created)
+	}
+}
+
+func TestGeneratorPool_GivenFailingGenerator(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(nil)
+	pool := &GeneratorPool{Keys: entity, Backoff: Backoff{Initial: time.Millisecond, Max: 10 * time.Millisecond}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan error)
+	go pool.GenerateKeys(ctx, func() (*ShortKey, error) { return nil, errors.New("failing generator") }, ch)
+
+	var got []error
+	for e := range ch {
+		got = append(got, e)
+	}
+
+	// the first failure, not every attempt
+	if len(got) != 1 {
+		t.Fatalf("GenerateKeys() sent %v, want a single error", got)
+	}
+
+	want := "failed to generate key"
+	if !strings.Contains(got[0].Error(), want) {
+		t.Errorf("GenerateKeys() sent %v, want containing %v", got[0], want)
+	}
+
+	if invalid := pool.Stats().Invalid; invalid > 20 {
+		t.Errorf("Stats().Invalid = %v, want attempts spaced by backoff", invalid)
+	}
+
+	if created, _ := entity.count(); created != 0 {
+		t.Errorf("GenerateKeys() created %v keys, want none out of a failing generator", created)
+	}
+}
+
+func TestGeneratorPool_GivenFailingDb(t *testing.T) {
+	pool := &GeneratorPool{BatchSize: 10, Keys: &unimplementedKeyValueEntityMock{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan error)
+	go pool.GenerateKeys(ctx, NextKey, ch)
+
+	select {
+	case e := <-ch:
+		want := "failed to create key"
+		if !strings.Contains(e.Error(), want) {
+			t.Errorf("GenerateKeys() sent %v, want containing %v", e.Error(), want)
+		}
+	case <-time.After(time.Second):
+		t.Error("GenerateKeys() timed out, want it to send a error")
+	}
+}
+
+// BenchmarkGeneratorPool compares throughput of a single
+// key by round trip against concurrent batched writes
+func BenchmarkGeneratorPool(b *testing.B) {
+	pools := []struct {
+		name                                string
+		workers, writers, batchSize, buffer int
+	}{
+		{"Sequential", 1, 1, 1, 1},
+		{"Batched", 1, 1, 500, 1000},
+		{"Concurrent", 4, 4, 500, 4000},
+	}
+
+	for _, p := range pools {
+		b.Run(p.name, func(b *testing.B) {
+			entity := newMemoryKeyValueEntityMock(nil)
+			entity.latency = 200 * time.Microsecond
+			pool := &GeneratorPool{Workers: p.workers, Writers: p.writers, BatchSize: p.batchSize, Buffer: p.buffer, Keys: entity}
+
+			start := time.Now()
+			for b.Loop() {
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+				ch := make(chan error)
+				go pool.GenerateKeys(ctx, NextKey, ch)
+
+				for e := range ch {
+					b.Errorf("GenerateKeys() sent %v, want no errors", e)
+				}
+				cancel()
+			}
+
+			created, _ := entity.count()
+			b.ReportMetric(float64(created)/time.Since(start).Seconds(), "keys/s")
+		})
+	}
+}
+
+func TestGeneratorPool_GivenUnreachableDb(t *testing.T) {
+	pool := &GeneratorPool{
+		Keys:    &unimplementedKeyValueEntityMock{err: &glideErrors.ConnectionError{Msg: "connection refused"}},
+		Backoff: Backoff{Initial: time.Millisecond, Max: time.Millisecond},
+		Breaker: &CircuitBreaker{Threshold: 3, Cooldown: time.Hour},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan error)
+	go pool.GenerateKeys(ctx, NextKey, ch)
+
+	var got []error
+	for e := range ch {
+		got = append(got, e)
+	}
+
+	// the first failure and the circuit opening, not every retry
+	if len(got) != 2 {
+		t.Fatalf("GenerateKeys() sent %v, want 2 errors", got)
+	}
+
+	want := "circuit opened"
+	if !strings.Contains(got[1].Error(), want) {
+		t.Errorf("GenerateKeys() sent %v, want containing %v", got[1], want)
+	}
+
+	stats := pool.Stats()
+	if stats.Circuit != "open" || stats.Failures != 3 || stats.LastError == "" {
+		t.Errorf("Stats() = %+v, want an open circuit after 3 failures", stats)
+	}
+}
@@ -2,20 +2,22 @@ package keys
 
 import (
 	"errors"
-	"keygen-service/app"
 	"strings"
 	"testing"
 	"time"
 )
 
-type keyValueClientMock struct{}
-
-func (c *keyValueClientMock) GetConn() (interface{}, error) { return nil, nil }
-func (c *keyValueClientMock) Flush() error                  { return nil }
-
-type unimplementedKeyValueEntityMock struct{}
+// unimplementedKeyValueEntityMock fails every call,
+// with err when set
+type unimplementedKeyValueEntityMock struct {
+	err error
+}
 
 func (e *unimplementedKeyValueEntityMock) Create(_ interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+
 	return errors.New("uimplemented create")
 }
 
@@ -44,11 +46,11 @@ func (e *keyValueEntityMock) Create(i interface{}) error {
 	return nil
 }
 
-func TestGenerateKeys_GivenAppNotInitialized(t *testing.T) {
-	want := "app not initialized"
+func TestGenerateKeys_GivenNoStorage(t *testing.T) {
+	want := ErrNoKeysStorage.Error()
 	got := make(chan error)
 
-	go GenerateKeys(func() (*ShortKey, error) { return nil, nil }, time.Nanosecond, got)
+	go GenerateKeys(nil, func() (*ShortKey, error) { return nil, nil }, time.Nanosecond, got)
 
 	select {
 	case e := <-got:
@@ -61,21 +63,11 @@ func TestGenerateKeys_GivenAppNotInitialized(t *testing.T) {
 }
 
 func TestGenerateKeys_GivenFailingGenerator(t *testing.T) {
-	testConfig := app.Configuration{
-		KeyValueDb: &app.KeyValueDb{
-			Host:   "testhost",
-			Port:   0,
-			Client: &keyValueClientMock{},
-			Keys:   &unimplementedKeyValueEntityMock{},
-		},
-	}
-
-	if err := app.Initialize(testConfig); err != nil {
-		t.Fatalf("app failed to initialize: %v", err)
-	}
+	entity := &unimplementedKeyValueEntityMock{}
 
 	ch := make(chan error)
 	go GenerateKeys(
+		entity,
 		func() (*ShortKey, error) {
 			return nil, errors.New("failing generator")
 		},
@@ -95,21 +87,10 @@ func TestGenerateKeys_GivenFailingGenerator(t *testing.T) {
 }
 
 func TestGenerateKeys_GivenFailingDb(t *testing.T) {
-	testConfig := app.Configuration{
-		KeyValueDb: &app.KeyValueDb{
-			Host:   "testhost",
-			Port:   0,
-			Client: &keyValueClientMock{},
-			Keys:   &unimplementedKeyValueEntityMock{},
-		},
-	}
-
-	if err := app.Initialize(testConfig); err != nil {
-		t.Fatalf("app failed to initialize: %v", err)
-	}
+	entity := &unimplementedKeyValueEntityMock{}
 
 	ch := make(chan error)
-	go GenerateKeys(NextKey, time.Nanosecond, ch)
+	go GenerateKeys(entity, NextKey, time.Nanosecond, ch)
 
 	select {
 	case e := <-ch:
@@ -124,22 +105,10 @@ func TestGenerateKeys_GivenFailingDb(t *testing.T) {
 
 func TestGenerateKeys_GivenWorkingSetup(t *testing.T) {
 	kvEntityMock := keyValueEntityMock{}
-
-	testConfig := app.Configuration{
-		KeyValueDb: &app.KeyValueDb{
-			Host:   "testhost",
-			Port:   0,
-			Client: &keyValueClientMock{},
-			Keys:   &kvEntityMock,
-		},
-	}
-
-	if err := app.Initialize(testConfig); err != nil {
-		t.Fatalf("app failed to initialize: %v", err)
-	}
+	entity := &kvEntityMock
 
 	ch := make(chan error)
-	go GenerateKeys(NextKey, time.Nanosecond, ch)
+	go GenerateKeys(entity, NextKey, time.Nanosecond, ch)
 
 	select {
 	case e := <-ch:
@@ -157,3 +126,16 @@ func TestNextKey(t *testing.T) {
 		t.Fatalf("NextKey() = (%v, %v), want no errors", got, err)
 	}
 }
+
+func TestNextKeyOfLength(t *testing.T) {
+	for length := MinKeyLength; length <= MaxKeyLength; length++ {
+		got, err := NextKeyOfLength(length)()
+		if err != nil {
+			t.Fatalf("NextKeyOfLength(%v)() = (%v, %v), want no errors", length, got, err)
+		}
+
+		if len(*got) != length {
+			t.Errorf("NextKeyOfLength(%v)() = %s, want a key with length %v", length, *got, length)
+		}
+	}
+}
@@ -1,66 +1,234 @@
 package keys
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"keygen-service/app"
+	"keygen-service/auth"
 	"log"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // RPCHandler handles requests over keys
+// by delegating them to an Allocator
 type RPCHandler struct {
 	UnimplementedKeysServer
+
+	// Allocator serves the default namespace
+	Allocator *Allocator
+
+	// Namespaces, when set, serves the other namespaces
+	Namespaces *Namespaces
+
+	// Events, when set, serves the published events to
+	// watchers, polled every WatchInterval (500ms when zero)
+	Events        EventReader
+	WatchInterval time.Duration
 }
 
 // NewRPCHandler returns a ready-to-use RPCHandler
-func NewRPCHandler() KeysServer {
-	return &RPCHandler{}
+// allocating the given keys
+func NewRPCHandler(keys app.KeyValueEntity) KeysServer {
+	return &RPCHandler{Allocator: NewAllocator(keys, nil)}
 }
 
-func (s *RPCHandler) GetKey(_ context.Context, _ *Void) (*KeyResponse, error) {
-	log.Println("keys.GetKey RPC called")
+func (s *RPCHandler) GetKey(ctx context.Context, req *GetKeyRequest) (*KeyResponse, error) {
+	log.Printf("keys.GetKey RPC called by %s for namespace %q, waiting: %t", caller(ctx), req.GetNamespace(), req.GetWait())
 
-	builtApp, err := app.GetApp()
+	allocator, err := s.allocator(req.GetNamespace(), true)
 	if err != nil {
-		return nil, fmt.Errorf("internal error: %w", err)
+		return nil, keysError(err)
 	}
 
-	log.Println("keys.GetKey allocating key")
-	i, err := builtApp.GetKeyValueDb().Keys.AllocateFirst()
-	if err != nil {
-		return nil, fmt.Errorf("internal error: %w", err)
+	if req.GetWait() {
+		ctx = WithWait(ctx)
 	}
 
-	k, ok := i.(ShortKey)
-	if !ok {
-		err := errors.New("could not convert allocated value into a key")
-
-		return nil, fmt.Errorf("internal error: %w", err)
+	k, token, err := allocator.AllocateOwned(ctx)
+	if err != nil {
+		return nil, keysError(err)
 	}
 
 	log.Printf("keys.GetKey responded with key %v (%s)", k, k)
-	return &KeyResponse{Key: k.Bytes()}, nil
+	return &KeyResponse{Key: k.Bytes(), Token: token}, nil
 }
 
-func (s *RPCHandler) ReleaseKey(_ context.Context, req *KeyRequest) (*Void, error) {
-	log.Printf("keys.ReleaseKey RPC called for key %v (%s)", req.Key, req.Key)
+func (s *RPCHandler) ReleaseKey(ctx context.Context, req *KeyRequest) (*Void, error) {
+	log.Printf("keys.ReleaseKey RPC called by %s for key %v (%s) of namespace %q", caller(ctx), req.Key, req.Key, req.GetNamespace())
 
-	builtApp, err := app.GetApp()
+	k, err := NewKeyFromBytes(req.Key)
 	if err != nil {
-		return nil, fmt.Errorf("internal error: %w", err)
+		return nil, keysError(err)
 	}
 
-	k, err := NewKeyFromBytes(req.Key)
+	allocator, err := s.allocator(req.GetNamespace(), false)
 	if err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return nil, keysError(err)
 	}
 
 	log.Printf("keys.ReleaseKey deallocating key %v (%s)", k, k)
-	if err := builtApp.GetKeyValueDb().Keys.Deallocate(k); err != nil {
-		return nil, fmt.Errorf("internal error: %w", err)
+	if err := allocator.ReleaseOwned(ctx, *k, req.GetToken()); err != nil {
+		if errors.Is(err, ErrNotOwner) {
+			log.Printf("audit: %s denied releasing key %s of namespace %q without its owner token", caller(ctx), k, req.GetNamespace())
+		}
+
+		return nil, keysError(err)
 	}
 
 	log.Println("keys.ReleaseKey responded")
 	return &Void{}, nil
 }
+
+// ReclaimKey releases the key of an expired short link for the
+// cleaner, succeeding when the key was released already
+func (s *RPCHandler) ReclaimKey(ctx context.Context, req *KeyRequest) (*Void, error) {
+	log.Printf("keys.ReclaimKey RPC called by %s for key %v (%s) of namespace %q", caller(ctx), req.Key, req.Key, req.GetNamespace())
+
+	k, err := NewKeyFromBytes(req.Key)
+	if err != nil {
+		return nil, keysError(err)
+	}
+
+	allocator, err := s.allocator(req.GetNamespace(), false)
+	if err != nil {
+		return nil, keysError(err)
+	}
+
+	if req.GetToken() == "" {
+		log.Printf("audit: %s reclaiming key %s of namespace %q without its owner token", caller(ctx), k, req.GetNamespace())
+	}
+
+	if err := allocator.Reclaim(ctx, *k, req.GetToken()); err != nil {
+		return nil, keysError(err)
+	}
+
+	log.Println("keys.ReclaimKey responded")
+	return &Void{}, nil
+}
+
+// WatchEvents streams the events after the request cursor until
+// the call is cancelled; watchers resume after the cursor of the
+// last event they handled
+func (s *RPCHandler) WatchEvents(req *WatchEventsRequest, stream grpc.ServerStreamingServer[KeyEvent]) error {
+	ctx := stream.Context()
+	log.Printf("keys.WatchEvents RPC called by %s for namespace %q after %q", caller(ctx), req.GetNamespace(), req.GetCursor())
+
+	if s.Events == nil {
+		return status.Error(codes.FailedPrecondition, "no readable keys events sink configured")
+	}
+
+	if !req.GetAllNamespaces() {
+		if _, err := s.allocator(req.GetNamespace(), false); err != nil {
+			return status.Errorf(codes.NotFound, "validation error: %v", err)
+		}
+	}
+
+	cursor := req.GetCursor()
+	if cursor == "" {
+		var err error
+		if cursor, err = s.Events.Last(); err != nil {
+			return status.Errorf(codes.Unavailable, "failed to watch events: %v", err)
+		}
+	}
+
+	for ctx.Err() == nil {
+		events, err := s.Events.Read(cursor, 100)
+		if errors.Is(err, ErrInvalidCursor) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		} else if err != nil {
+			return status.Errorf(codes.Unavailable, "failed to watch events: %v", err)
+		}
+
+		for _, event := range events {
+			cursor = event.Cursor
+			if !watched(req, event) {
+				continue
+			}
+
+			if err := stream.Send(eventMessage(event)); err != nil {
+				return err
+			}
+		}
+
+		if len(events) == 0 {
+			sleep(ctx, cmp.Or(s.WatchInterval, 500*time.Millisecond))
+		}
+	}
+
+	return nil
+}
+
+// watched tells whether the event is one of those watched
+func watched(req *WatchEventsRequest, event Event) bool {
+	if !req.GetAllNamespaces() && event.Namespace != req.GetNamespace() {
+		return false
+	}
+
+	return len(req.GetTypes()) == 0 || slices.Contains(req.GetTypes(), string(event.Type))
+}
+
+func eventMessage(event Event) *KeyEvent {
+	return &KeyEvent{
+		Version:   int32(event.Version), // #nosec G115 -- small version numbers
+		Id:        event.ID,
+		Time:      event.Time.Format(time.RFC3339Nano),
+		Type:      string(event.Type),
+		Namespace: event.Namespace,
+		Key:       []byte(event.Key),
+		Count:     event.Count,
+		Caller:    event.Caller,
+		Cursor:    event.Cursor,
+	}
+}
+
+func (s *RPCHandler) allocator(namespace string, allocating bool) (*Allocator, error) {
+	if namespace == "" {
+		return s.Allocator, nil
+	}
+
+	if s.Namespaces == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNamespaceNotFound, namespace)
+	}
+
+	return s.Namespaces.Allocator(namespace, allocating)
+}
+
+// keysError maps failures to status codes, for clients to tell
+// a wrong request from a failure worth retrying, like an
+// empty pool
+func keysError(err error) error {
+	var validation ShortKeyValidationError
+
+	switch {
+	case errors.As(err, &validation):
+		return status.Errorf(codes.InvalidArgument, "validation error: %v", err)
+	case errors.Is(err, ErrNamespaceNotFound), errors.Is(err, ErrKeyNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrNotOwner):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrNamespaceRetired), errors.Is(err, ErrKeyBanned), errors.Is(err, ErrKeyQuarantined):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrNoAvailableKeys), IsTransient(err):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Errorf(codes.Internal, "internal error: %v", err)
+	}
+}
+
+// caller names the authenticated client in logs
+func caller(ctx context.Context) string {
+	if identity, ok := auth.IdentityFromContext(ctx); ok {
+		return identity.Name
+	}
+
+	return "anonymous"
+}
@@ -2,54 +2,25 @@ package keys
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"keygen-service/app"
 	"keygen-service/databases"
+	"os"
 	"slices"
 	"strings"
 	"testing"
-)
-
-func setUp() error {
-	appConfig := app.Configuration{
-		KeyValueDb: &app.KeyValueDb{
-			Host:   os.Getenv("VALKEY_DATABASE_HOST"),
-			Port:   6380,
-			Client: &databases.ValkeyClient{},
-			Keys:   &Valkey{},
-		},
-	}
-
-	if err := app.Initialize(appConfig); err != nil {
-		return fmt.Errorf("app failed to initialize: %w", err)
-	}
-
-	// flush is not supported by valkey client;
-	// this should change in v2;
-	// clean it manually for now!
-
-	// testApp, err := app.GetApp()
-	// if err != nil {
-	// 	return fmt.Errorf("failed to get test app: %w", err)
-	// }
 
-	// if err := testApp.GetKeyValueDb().Client.Flush(); err != nil {
-	// 	return fmt.Errorf("failed to clean db: %w", err)
-	// }
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
 
-	return nil
+// testClient connects to the test db; flush is not
+// supported by valkey client, this should change in v2,
+// clean it manually for now!
+func testClient() *databases.ValkeyClient {
+	return &databases.ValkeyClient{Host: os.Getenv("VALKEY_DATABASE_HOST"), Port: 6380}
 }
 
 func TestHandler(t *testing.T) {
-	if err := setUp(); err != nil {
-		t.Fatalf("test app failed to initialize: %v", err)
-	}
-
-	testApp, err := app.GetApp()
-	if err != nil {
-		t.Fatalf("could not get test app: %v", err)
-	}
+	entity := &Valkey{Client: testClient()}
 
 	availableKeys := []string{"testk1", "testk2"}
 	takenKeys := []string{}
@@ -60,16 +31,16 @@ func TestHandler(t *testing.T) {
 			t.Fatalf("invalid generated test keys: %v", err)
 		}
 
-		if err := testApp.GetKeyValueDb().Keys.Create(key); err != nil {
+		if err := entity.Create(key); err != nil {
 			t.Fatalf("could not create test keys: %v", err)
 		}
 	}
 
-	handler := NewRPCHandler()
+	handler := &RPCHandler{Allocator: NewAllocator(entity, nil)}
 
 	t.Run("TestGetKey_GivenSomeAvailableKeys", func(t *testing.T) {
 		for len(availableKeys) > 0 {
-			res, err := handler.GetKey(context.Background(), &Void{})
+			res, err := handler.GetKey(context.Background(), &GetKeyRequest{})
 			if err != nil {
 				t.Fatalf("GetKey failed: %v", err)
 			}
@@ -85,7 +56,7 @@ func TestHandler(t *testing.T) {
 	})
 
 	t.Run("TestGetKey_GivenNoAvailableKeys", func(t *testing.T) {
-		res, err := handler.GetKey(context.Background(), &Void{})
+		res, err := handler.GetKey(context.Background(), &GetKeyRequest{})
 		if err == nil {
 			t.Fatalf("GetKey() = %v, want an error", res)
 		}
@@ -115,3 +86,27 @@ func TestHandler(t *testing.T) {
 		}
 	})
 }
+
+func TestHandler_GivenFailures(t *testing.T) {
+	handler := &RPCHandler{Allocator: NewAllocator(newMemoryKeyValueEntityMock(nil), nil)}
+
+	_, err := handler.GetKey(context.Background(), &GetKeyRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("GetKey() = %v, want %v", err, codes.Unavailable)
+	}
+
+	_, err = handler.GetKey(context.Background(), &GetKeyRequest{Namespace: "unknown"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetKey() = %v, want %v", err, codes.NotFound)
+	}
+
+	_, err = handler.ReleaseKey(context.Background(), &KeyRequest{Key: []byte("!")})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ReleaseKey() = %v, want %v", err, codes.InvalidArgument)
+	}
+
+	_, err = handler.ReleaseKey(context.Background(), &KeyRequest{Key: []byte("testk1")})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("ReleaseKey() = %v, want %v", err, codes.NotFound)
+	}
+}
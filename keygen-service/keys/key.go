@@ -1,20 +1,88 @@
 package keys
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 var (
 	UrlSafePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]*$`)
 )
 
-// ShortKey is a URL-safe 6-byte key
-type ShortKey [6]byte
+// KeyAlphabet lists every URL-safe character a key
+// may hold, in the order used to index keys
+const KeyAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+const (
+	MinKeyLength = 6
+	MaxKeyLength = 7
+)
+
+// KeySpaceSize is the number of distinct short
+// keys with the given length
+func KeySpaceSize(length int) uint64 {
+	size := uint64(1)
+	for range length {
+		size *= uint64(len(KeyAlphabet))
+	}
+
+	return size
+}
+
+// KeySpaceOffset is the index of the first key with
+// the given length, see ShortKey.Index; it is also the
+// number of distinct short keys shorter than that
+func KeySpaceOffset(length int) uint64 {
+	var offset uint64
+	for l := MinKeyLength; l < length; l++ {
+		offset += KeySpaceSize(l)
+	}
+
+	return offset
+}
+
+// ShortKey is a URL-safe key of MinKeyLength
+// to MaxKeyLength bytes
+type ShortKey []byte
 
 func (s *ShortKey) Bytes() []byte {
-	return s[:]
+	return *s
+}
+
+// Index maps the key to its position in the key space,
+// keys of each length follow the shorter ones, read
+// as numbers in base len(KeyAlphabet)
+func (s *ShortKey) Index() uint64 {
+	var position uint64
+	for _, c := range *s {
+		position = position*uint64(len(KeyAlphabet)) + uint64(strings.IndexByte(KeyAlphabet, c))
+	}
+
+	return KeySpaceOffset(len(*s)) + position
+}
+
+// NewKeyFromIndex creates the short key at the given
+// position of the key space, see ShortKey.Index
+func NewKeyFromIndex(index uint64) (*ShortKey, error) {
+	for length := MinKeyLength; length <= MaxKeyLength; length++ {
+		if index >= KeySpaceSize(length) {
+			index -= KeySpaceSize(length)
+			continue
+		}
+
+		shortKey := make(ShortKey, length)
+		for i := len(shortKey) - 1; i >= 0; i-- {
+			shortKey[i] = KeyAlphabet[index%uint64(len(KeyAlphabet))]
+			index /= uint64(len(KeyAlphabet))
+		}
+
+		return &shortKey, nil
+	}
+
+	return nil, fmt.Errorf("index %d out of the key space", index+KeySpaceOffset(MaxKeyLength+1))
 }
 
 // ShortKeyValidationError aggregates errors found
@@ -51,7 +119,7 @@ func NewKeyFromBytes(content []byte) (*ShortKey, error) {
 		return nil, validation
 	}
 
-	shortKey := ShortKey(content)
+	shortKey := ShortKey(bytes.Clone(content))
 	return &shortKey, nil
 }
 
@@ -67,9 +135,9 @@ func validateBytes(content []byte, v *ShortKeyValidationError) {
 }
 
 func validateBytesSize(content []byte, v *ShortKeyValidationError) {
-	if len(content) != len(ShortKey{}) {
+	if len(content) < MinKeyLength || len(content) > MaxKeyLength {
 		size := "big"
-		if len(content) < len(ShortKey{}) {
+		if len(content) < MinKeyLength {
 			size = "small"
 		}
 
@@ -1,6 +1,7 @@
 package keys
 
 import (
+	"bytes"
 	"errors"
 	"slices"
 	"strings"
@@ -8,11 +9,12 @@ import (
 )
 
 func TestNewKeyFromBytes_GivenValidContent(t *testing.T) {
-	content := []byte("abc123")
-	got, err := NewKeyFromBytes(content)
+	for _, content := range [][]byte{[]byte("abc123"), []byte("abc1234")} {
+		got, err := NewKeyFromBytes(content)
 
-	if err != nil {
-		t.Errorf("NewKeyFromBytes(%v) = (%v, %v), want no errors", content, got, err)
+		if err != nil {
+			t.Errorf("NewKeyFromBytes(%v) = (%v, %v), want no errors", content, got, err)
+		}
 	}
 }
 
@@ -47,3 +49,60 @@ func TestNewKeyFromBytes_GivenInvalidContent(t *testing.T) {
 		}
 	}
 }
+
+func TestShortKeyIndex_GivenBoundaryKeys(t *testing.T) {
+	cases := []struct {
+		content string
+		want    uint64
+	}{
+		{"AAAAAA", 0},
+		{"AAAAAB", 1},
+		{"AAAABA", 64},
+		{"______", KeySpaceSize(6) - 1},
+		{"AAAAAAA", KeySpaceSize(6)},
+		{"_______", KeySpaceSize(6) + KeySpaceSize(7) - 1},
+	}
+
+	for _, c := range cases {
+		key, err := NewKeyFromBytes([]byte(c.content))
+		if err != nil {
+			t.Fatalf("NewKeyFromBytes(%v) failed: %v", c.content, err)
+		}
+
+		if got := key.Index(); got != c.want {
+			t.Errorf("%s.Index() = %v, want %v", c.content, got, c.want)
+		}
+	}
+}
+
+func TestNewKeyFromIndex_GivenValidIndex(t *testing.T) {
+	for _, content := range []string{"AAAAAA", "abc123", "-_a0Z9", "______", "AAAAAAA", "abc1234", "_______"} {
+		key, err := NewKeyFromBytes([]byte(content))
+		if err != nil {
+			t.Fatalf("NewKeyFromBytes(%v) failed: %v", content, err)
+		}
+
+		got, err := NewKeyFromIndex(key.Index())
+		if err != nil {
+			t.Fatalf("NewKeyFromIndex(%v) = (%v, %v), want no errors", key.Index(), got, err)
+		}
+
+		if !bytes.Equal(*got, *key) {
+			t.Errorf("NewKeyFromIndex(%v) = %s, want %s", key.Index(), got, key)
+		}
+	}
+}
+
+func TestNewKeyFromIndex_GivenIndexOutOfKeySpace(t *testing.T) {
+	index := KeySpaceOffset(MaxKeyLength + 1)
+
+	got, err := NewKeyFromIndex(index)
+	if err == nil {
+		t.Fatalf("NewKeyFromIndex(%v) = %v, want an error", index, got)
+	}
+
+	want := "out of the key space"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("NewKeyFromIndex(%v) error = %v, want %v", index, err, want)
+	}
+}
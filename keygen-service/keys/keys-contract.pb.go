@@ -57,16 +57,71 @@ func (*Void) Descriptor() ([]byte, []int) {
 	return file_keys_contract_proto_rawDescGZIP(), []int{0}
 }
 
+// GetKeyRequest allocates a key of the namespace,
+// the default namespace when empty
+type GetKeyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Wait          bool                   `protobuf:"varint,2,opt,name=wait,proto3" json:"wait,omitempty"` // for new keys while none is available, up to the call deadline
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetKeyRequest) Reset() {
+	*x = GetKeyRequest{}
+	mi := &file_keys_contract_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetKeyRequest) ProtoMessage() {}
+
+func (x *GetKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetKeyRequest.ProtoReflect.Descriptor instead.
+func (*GetKeyRequest) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetKeyRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *GetKeyRequest) GetWait() bool {
+	if x != nil {
+		return x.Wait
+	}
+	return false
+}
+
 type KeyResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Key           []byte                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"` // opaque proof of the allocation, needed to release the key
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *KeyResponse) Reset() {
 	*x = KeyResponse{}
-	mi := &file_keys_contract_proto_msgTypes[1]
+	mi := &file_keys_contract_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -78,7 +133,7 @@ func (x *KeyResponse) String() string {
 func (*KeyResponse) ProtoMessage() {}
 
 func (x *KeyResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_keys_contract_proto_msgTypes[1]
+	mi := &file_keys_contract_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -91,7 +146,7 @@ func (x *KeyResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeyResponse.ProtoReflect.Descriptor instead.
 func (*KeyResponse) Descriptor() ([]byte, []int) {
-	return file_keys_contract_proto_rawDescGZIP(), []int{1}
+	return file_keys_contract_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *KeyResponse) GetKey() []byte {
@@ -101,16 +156,25 @@ func (x *KeyResponse) GetKey() []byte {
 	return nil
 }
 
+func (x *KeyResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
 type KeyRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Key           []byte                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Namespace     string                 `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Token         string                 `protobuf:"bytes,3,opt,name=token,proto3" json:"token,omitempty"` // returned with the key by GetKey, optional to ReclaimKey
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *KeyRequest) Reset() {
 	*x = KeyRequest{}
-	mi := &file_keys_contract_proto_msgTypes[2]
+	mi := &file_keys_contract_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -122,7 +186,7 @@ func (x *KeyRequest) String() string {
 func (*KeyRequest) ProtoMessage() {}
 
 func (x *KeyRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_keys_contract_proto_msgTypes[2]
+	mi := &file_keys_contract_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -135,7 +199,7 @@ func (x *KeyRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeyRequest.ProtoReflect.Descriptor instead.
 func (*KeyRequest) Descriptor() ([]byte, []int) {
-	return file_keys_contract_proto_rawDescGZIP(), []int{2}
+	return file_keys_contract_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *KeyRequest) GetKey() []byte {
@@ -145,23 +209,1996 @@ func (x *KeyRequest) GetKey() []byte {
 	return nil
 }
 
-var File_keys_contract_proto protoreflect.FileDescriptor
+func (x *KeyRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
 
-const file_keys_contract_proto_rawDesc = "" +
-	"\n" +
-	"\x13keys-contract.proto\x12\x04keys\"\x06\n" +
-	"\x04Void\"\x1f\n" +
-	"\vKeyResponse\x12\x10\n" +
-	"\x03key\x18\x01 \x01(\fR\x03key\"\x1e\n" +
-	"\n" +
-	"KeyRequest\x12\x10\n" +
-	"\x03key\x18\x01 \x01(\fR\x03key2_\n" +
-	"\x04Keys\x12)\n" +
-	"\x06GetKey\x12\n" +
-	".keys.Void\x1a\x11.keys.KeyResponse\"\x00\x12,\n" +
-	"\n" +
-	"ReleaseKey\x12\x10.keys.KeyRequest\x1a\n" +
-	".keys.Void\"\x00B\aZ\x05/keysb\x06proto3"
+func (x *KeyRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+// WatchEventsRequest streams the events after the cursor, the
+// events to come when empty, of the namespace or of all of them
+type WatchEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cursor        string                 `protobuf:"bytes,1,opt,name=cursor,proto3" json:"cursor,omitempty"` // of the last event handled, "0" for the oldest kept
+	Namespace     string                 `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	AllNamespaces bool                   `protobuf:"varint,3,opt,name=all_namespaces,json=allNamespaces,proto3" json:"all_namespaces,omitempty"`
+	Types         []string               `protobuf:"bytes,4,rep,name=types,proto3" json:"types,omitempty"` // every type when empty
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEventsRequest) Reset() {
+	*x = WatchEventsRequest{}
+	mi := &file_keys_contract_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventsRequest) ProtoMessage() {}
+
+func (x *WatchEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventsRequest.ProtoReflect.Descriptor instead.
+func (*WatchEventsRequest) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *WatchEventsRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *WatchEventsRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *WatchEventsRequest) GetAllNamespaces() bool {
+	if x != nil {
+		return x.AllNamespaces
+	}
+	return false
+}
+
+func (x *WatchEventsRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+// KeyEvent is a change in the lifecycle of keys; skip unknown
+// versions, and events already handled as they may be resent
+type KeyEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Time          string                 `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"` // RFC 3339
+	Type          string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"` // created, allocated, released, reserved, quarantined, quarantine_lifted or banned
+	Namespace     string                 `protobuf:"bytes,5,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Key           []byte                 `protobuf:"bytes,6,opt,name=key,proto3" json:"key,omitempty"`      // empty for created batches
+	Count         int64                  `protobuf:"varint,7,opt,name=count,proto3" json:"count,omitempty"` // of keys
+	Caller        string                 `protobuf:"bytes,8,opt,name=caller,proto3" json:"caller,omitempty"`
+	Cursor        string                 `protobuf:"bytes,9,opt,name=cursor,proto3" json:"cursor,omitempty"` // resumes the watch after this event
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyEvent) Reset() {
+	*x = KeyEvent{}
+	mi := &file_keys_contract_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyEvent) ProtoMessage() {}
+
+func (x *KeyEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyEvent.ProtoReflect.Descriptor instead.
+func (*KeyEvent) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *KeyEvent) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *KeyEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *KeyEvent) GetTime() string {
+	if x != nil {
+		return x.Time
+	}
+	return ""
+}
+
+func (x *KeyEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *KeyEvent) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *KeyEvent) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *KeyEvent) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *KeyEvent) GetCaller() string {
+	if x != nil {
+		return x.Caller
+	}
+	return ""
+}
+
+func (x *KeyEvent) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+// Namespace is an independent pool of keys
+type Namespace struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	MinAvailable  int64                  `protobuf:"varint,2,opt,name=min_available,json=minAvailable,proto3" json:"min_available,omitempty"` // keys kept available by generation
+	KeyLength     int32                  `protobuf:"varint,3,opt,name=key_length,json=keyLength,proto3" json:"key_length,omitempty"`          // of generated keys, the shortest when 0
+	Retired       bool                   `protobuf:"varint,4,opt,name=retired,proto3" json:"retired,omitempty"`                               // retired namespaces serve no more keys
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Namespace) Reset() {
+	*x = Namespace{}
+	mi := &file_keys_contract_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Namespace) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Namespace) ProtoMessage() {}
+
+func (x *Namespace) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Namespace.ProtoReflect.Descriptor instead.
+func (*Namespace) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Namespace) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Namespace) GetMinAvailable() int64 {
+	if x != nil {
+		return x.MinAvailable
+	}
+	return 0
+}
+
+func (x *Namespace) GetKeyLength() int32 {
+	if x != nil {
+		return x.KeyLength
+	}
+	return 0
+}
+
+func (x *Namespace) GetRetired() bool {
+	if x != nil {
+		return x.Retired
+	}
+	return false
+}
+
+type NamespaceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NamespaceRequest) Reset() {
+	*x = NamespaceRequest{}
+	mi := &file_keys_contract_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NamespaceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NamespaceRequest) ProtoMessage() {}
+
+func (x *NamespaceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NamespaceRequest.ProtoReflect.Descriptor instead.
+func (*NamespaceRequest) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *NamespaceRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type NamespaceList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespaces    []*Namespace           `protobuf:"bytes,1,rep,name=namespaces,proto3" json:"namespaces,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NamespaceList) Reset() {
+	*x = NamespaceList{}
+	mi := &file_keys_contract_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NamespaceList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NamespaceList) ProtoMessage() {}
+
+func (x *NamespaceList) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NamespaceList.ProtoReflect.Descriptor instead.
+func (*NamespaceList) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *NamespaceList) GetNamespaces() []*Namespace {
+	if x != nil {
+		return x.Namespaces
+	}
+	return nil
+}
+
+type KeyStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           []byte                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	State         string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"` // available, taken, reserved, quarantined, banned or unknown
+	Namespace     string                 `protobuf:"bytes,3,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	KeyLength     int32                  `protobuf:"varint,4,opt,name=key_length,json=keyLength,proto3" json:"key_length,omitempty"`
+	Owned         bool                   `protobuf:"varint,5,opt,name=owned,proto3" json:"owned,omitempty"` // an ownership token is recorded
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyStatus) Reset() {
+	*x = KeyStatus{}
+	mi := &file_keys_contract_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyStatus) ProtoMessage() {}
+
+func (x *KeyStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyStatus.ProtoReflect.Descriptor instead.
+func (*KeyStatus) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *KeyStatus) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *KeyStatus) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *KeyStatus) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *KeyStatus) GetKeyLength() int32 {
+	if x != nil {
+		return x.KeyLength
+	}
+	return 0
+}
+
+func (x *KeyStatus) GetOwned() bool {
+	if x != nil {
+		return x.Owned
+	}
+	return false
+}
+
+// ListKeysRequest pages through the keys of a namespace
+type ListKeysRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	State         string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`   // available, taken, reserved, quarantined or banned, every key when empty
+	Cursor        string                 `protobuf:"bytes,3,opt,name=cursor,proto3" json:"cursor,omitempty"` // of the previous page, empty for the first one
+	Count         int32                  `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`  // hint of the page size, 100 when 0, at most 1000
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListKeysRequest) Reset() {
+	*x = ListKeysRequest{}
+	mi := &file_keys_contract_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListKeysRequest) ProtoMessage() {}
+
+func (x *ListKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListKeysRequest.ProtoReflect.Descriptor instead.
+func (*ListKeysRequest) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListKeysRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ListKeysRequest) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *ListKeysRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *ListKeysRequest) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type KeyList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Keys          [][]byte               `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	Cursor        string                 `protobuf:"bytes,2,opt,name=cursor,proto3" json:"cursor,omitempty"` // of the next page, empty once done
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyList) Reset() {
+	*x = KeyList{}
+	mi := &file_keys_contract_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyList) ProtoMessage() {}
+
+func (x *KeyList) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyList.ProtoReflect.Descriptor instead.
+func (*KeyList) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *KeyList) GetKeys() [][]byte {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+func (x *KeyList) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type StatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	ByLength      bool                   `protobuf:"varint,2,opt,name=by_length,json=byLength,proto3" json:"by_length,omitempty"` // may scan every key
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsRequest) Reset() {
+	*x = StatsRequest{}
+	mi := &file_keys_contract_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsRequest) ProtoMessage() {}
+
+func (x *StatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsRequest.ProtoReflect.Descriptor instead.
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *StatsRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *StatsRequest) GetByLength() bool {
+	if x != nil {
+		return x.ByLength
+	}
+	return false
+}
+
+// Stats counts the keys of a namespace, taken keys
+// include the reserved, quarantined and banned ones
+type Stats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Available     int64                  `protobuf:"varint,2,opt,name=available,proto3" json:"available,omitempty"`
+	Taken         int64                  `protobuf:"varint,3,opt,name=taken,proto3" json:"taken,omitempty"`
+	Reserved      int64                  `protobuf:"varint,4,opt,name=reserved,proto3" json:"reserved,omitempty"`
+	Banned        int64                  `protobuf:"varint,5,opt,name=banned,proto3" json:"banned,omitempty"` // never served again
+	Lengths       []*LengthStats         `protobuf:"bytes,6,rep,name=lengths,proto3" json:"lengths,omitempty"`
+	Quarantined   int64                  `protobuf:"varint,7,opt,name=quarantined,proto3" json:"quarantined,omitempty"` // held out of the pool until lifted
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_keys_contract_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Stats) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Stats) GetAvailable() int64 {
+	if x != nil {
+		return x.Available
+	}
+	return 0
+}
+
+func (x *Stats) GetTaken() int64 {
+	if x != nil {
+		return x.Taken
+	}
+	return 0
+}
+
+func (x *Stats) GetReserved() int64 {
+	if x != nil {
+		return x.Reserved
+	}
+	return 0
+}
+
+func (x *Stats) GetBanned() int64 {
+	if x != nil {
+		return x.Banned
+	}
+	return 0
+}
+
+func (x *Stats) GetLengths() []*LengthStats {
+	if x != nil {
+		return x.Lengths
+	}
+	return nil
+}
+
+func (x *Stats) GetQuarantined() int64 {
+	if x != nil {
+		return x.Quarantined
+	}
+	return 0
+}
+
+type LengthStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	KeyLength     int32                  `protobuf:"varint,1,opt,name=key_length,json=keyLength,proto3" json:"key_length,omitempty"`
+	Available     int64                  `protobuf:"varint,2,opt,name=available,proto3" json:"available,omitempty"`
+	Taken         int64                  `protobuf:"varint,3,opt,name=taken,proto3" json:"taken,omitempty"`
+	Reserved      int64                  `protobuf:"varint,4,opt,name=reserved,proto3" json:"reserved,omitempty"`
+	Banned        int64                  `protobuf:"varint,5,opt,name=banned,proto3" json:"banned,omitempty"`
+	Quarantined   int64                  `protobuf:"varint,6,opt,name=quarantined,proto3" json:"quarantined,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LengthStats) Reset() {
+	*x = LengthStats{}
+	mi := &file_keys_contract_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LengthStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LengthStats) ProtoMessage() {}
+
+func (x *LengthStats) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LengthStats.ProtoReflect.Descriptor instead.
+func (*LengthStats) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *LengthStats) GetKeyLength() int32 {
+	if x != nil {
+		return x.KeyLength
+	}
+	return 0
+}
+
+func (x *LengthStats) GetAvailable() int64 {
+	if x != nil {
+		return x.Available
+	}
+	return 0
+}
+
+func (x *LengthStats) GetTaken() int64 {
+	if x != nil {
+		return x.Taken
+	}
+	return 0
+}
+
+func (x *LengthStats) GetReserved() int64 {
+	if x != nil {
+		return x.Reserved
+	}
+	return 0
+}
+
+func (x *LengthStats) GetBanned() int64 {
+	if x != nil {
+		return x.Banned
+	}
+	return 0
+}
+
+func (x *LengthStats) GetQuarantined() int64 {
+	if x != nil {
+		return x.Quarantined
+	}
+	return 0
+}
+
+type SeedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Count         int64                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`                          // of new keys
+	KeyLength     int32                  `protobuf:"varint,3,opt,name=key_length,json=keyLength,proto3" json:"key_length,omitempty"` // the namespace key length when 0
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SeedRequest) Reset() {
+	*x = SeedRequest{}
+	mi := &file_keys_contract_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SeedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SeedRequest) ProtoMessage() {}
+
+func (x *SeedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SeedRequest.ProtoReflect.Descriptor instead.
+func (*SeedRequest) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *SeedRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *SeedRequest) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *SeedRequest) GetKeyLength() int32 {
+	if x != nil {
+		return x.KeyLength
+	}
+	return 0
+}
+
+type SeedResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Created       int64                  `protobuf:"varint,1,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SeedResponse) Reset() {
+	*x = SeedResponse{}
+	mi := &file_keys_contract_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SeedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SeedResponse) ProtoMessage() {}
+
+func (x *SeedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SeedResponse.ProtoReflect.Descriptor instead.
+func (*SeedResponse) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *SeedResponse) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+type CheckReport struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Namespace       string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Available       int64                  `protobuf:"varint,2,opt,name=available,proto3" json:"available,omitempty"`
+	Taken           int64                  `protobuf:"varint,3,opt,name=taken,proto3" json:"taken,omitempty"`
+	Duplicated      int64                  `protobuf:"varint,4,opt,name=duplicated,proto3" json:"duplicated,omitempty"`                                  // both available and taken
+	BannedAvailable int64                  `protobuf:"varint,5,opt,name=banned_available,json=bannedAvailable,proto3" json:"banned_available,omitempty"` // banned yet available
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *CheckReport) Reset() {
+	*x = CheckReport{}
+	mi := &file_keys_contract_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckReport) ProtoMessage() {}
+
+func (x *CheckReport) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckReport.ProtoReflect.Descriptor instead.
+func (*CheckReport) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *CheckReport) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *CheckReport) GetAvailable() int64 {
+	if x != nil {
+		return x.Available
+	}
+	return 0
+}
+
+func (x *CheckReport) GetTaken() int64 {
+	if x != nil {
+		return x.Taken
+	}
+	return 0
+}
+
+func (x *CheckReport) GetDuplicated() int64 {
+	if x != nil {
+		return x.Duplicated
+	}
+	return 0
+}
+
+func (x *CheckReport) GetBannedAvailable() int64 {
+	if x != nil {
+		return x.BannedAvailable
+	}
+	return 0
+}
+
+// AuditRequest reads the audit trail of a key,
+// of every key of the namespace when empty
+type AuditRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Key           []byte                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Since         int64                  `protobuf:"varint,3,opt,name=since,proto3" json:"since,omitempty"` // unix seconds, the whole retention when 0
+	Limit         int32                  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"` // of events, 100 when 0
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuditRequest) Reset() {
+	*x = AuditRequest{}
+	mi := &file_keys_contract_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditRequest) ProtoMessage() {}
+
+func (x *AuditRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditRequest.ProtoReflect.Descriptor instead.
+func (*AuditRequest) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *AuditRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *AuditRequest) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *AuditRequest) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *AuditRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type KeyAudit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metadata      *AuditMetadata         `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"` // of the key, when recorded
+	Events        []*AuditEntry          `protobuf:"bytes,2,rep,name=events,proto3" json:"events,omitempty"`     // newest first
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyAudit) Reset() {
+	*x = KeyAudit{}
+	mi := &file_keys_contract_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyAudit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyAudit) ProtoMessage() {}
+
+func (x *KeyAudit) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyAudit.ProtoReflect.Descriptor instead.
+func (*KeyAudit) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *KeyAudit) GetMetadata() *AuditMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *KeyAudit) GetEvents() []*AuditEntry {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+// AuditMetadata tells when a key was last handed out, and
+// released, and by whom; times are RFC 3339
+type AuditMetadata struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	State              string                 `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	AllocatedAt        string                 `protobuf:"bytes,2,opt,name=allocated_at,json=allocatedAt,proto3" json:"allocated_at,omitempty"`
+	AllocatedBy        string                 `protobuf:"bytes,3,opt,name=allocated_by,json=allocatedBy,proto3" json:"allocated_by,omitempty"`
+	AllocatedPeer      string                 `protobuf:"bytes,4,opt,name=allocated_peer,json=allocatedPeer,proto3" json:"allocated_peer,omitempty"`
+	AllocatedRequestId string                 `protobuf:"bytes,5,opt,name=allocated_request_id,json=allocatedRequestId,proto3" json:"allocated_request_id,omitempty"`
+	ReleasedAt         string                 `protobuf:"bytes,6,opt,name=released_at,json=releasedAt,proto3" json:"released_at,omitempty"`
+	ReleasedBy         string                 `protobuf:"bytes,7,opt,name=released_by,json=releasedBy,proto3" json:"released_by,omitempty"`
+	ReleasedPeer       string                 `protobuf:"bytes,8,opt,name=released_peer,json=releasedPeer,proto3" json:"released_peer,omitempty"`
+	ReleasedRequestId  string                 `protobuf:"bytes,9,opt,name=released_request_id,json=releasedRequestId,proto3" json:"released_request_id,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *AuditMetadata) Reset() {
+	*x = AuditMetadata{}
+	mi := &file_keys_contract_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditMetadata) ProtoMessage() {}
+
+func (x *AuditMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditMetadata.ProtoReflect.Descriptor instead.
+func (*AuditMetadata) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *AuditMetadata) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *AuditMetadata) GetAllocatedAt() string {
+	if x != nil {
+		return x.AllocatedAt
+	}
+	return ""
+}
+
+func (x *AuditMetadata) GetAllocatedBy() string {
+	if x != nil {
+		return x.AllocatedBy
+	}
+	return ""
+}
+
+func (x *AuditMetadata) GetAllocatedPeer() string {
+	if x != nil {
+		return x.AllocatedPeer
+	}
+	return ""
+}
+
+func (x *AuditMetadata) GetAllocatedRequestId() string {
+	if x != nil {
+		return x.AllocatedRequestId
+	}
+	return ""
+}
+
+func (x *AuditMetadata) GetReleasedAt() string {
+	if x != nil {
+		return x.ReleasedAt
+	}
+	return ""
+}
+
+func (x *AuditMetadata) GetReleasedBy() string {
+	if x != nil {
+		return x.ReleasedBy
+	}
+	return ""
+}
+
+func (x *AuditMetadata) GetReleasedPeer() string {
+	if x != nil {
+		return x.ReleasedPeer
+	}
+	return ""
+}
+
+func (x *AuditMetadata) GetReleasedRequestId() string {
+	if x != nil {
+		return x.ReleasedRequestId
+	}
+	return ""
+}
+
+type AuditEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Time          string                 `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"` // RFC 3339
+	Key           []byte                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Action        string                 `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"` // allocated, released, release_denied, reserved or banned
+	State         string                 `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`   // resulting
+	Caller        string                 `protobuf:"bytes,5,opt,name=caller,proto3" json:"caller,omitempty"`
+	Peer          string                 `protobuf:"bytes,6,opt,name=peer,proto3" json:"peer,omitempty"`
+	RequestId     string                 `protobuf:"bytes,7,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuditEntry) Reset() {
+	*x = AuditEntry{}
+	mi := &file_keys_contract_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditEntry) ProtoMessage() {}
+
+func (x *AuditEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditEntry.ProtoReflect.Descriptor instead.
+func (*AuditEntry) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *AuditEntry) GetTime() string {
+	if x != nil {
+		return x.Time
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *AuditEntry) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetCaller() string {
+	if x != nil {
+		return x.Caller
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+type ReconcileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Repair        bool                   `protobuf:"varint,2,opt,name=repair,proto3" json:"repair,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileRequest) Reset() {
+	*x = ReconcileRequest{}
+	mi := &file_keys_contract_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileRequest) ProtoMessage() {}
+
+func (x *ReconcileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileRequest.ProtoReflect.Descriptor instead.
+func (*ReconcileRequest) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ReconcileRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ReconcileRequest) GetRepair() bool {
+	if x != nil {
+		return x.Repair
+	}
+	return false
+}
+
+type Reconciliation struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Namespace       string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Scanned         int64                  `protobuf:"varint,2,opt,name=scanned,proto3" json:"scanned,omitempty"`
+	Duplicated      int64                  `protobuf:"varint,3,opt,name=duplicated,proto3" json:"duplicated,omitempty"` // both available and taken
+	Malformed       int64                  `protobuf:"varint,4,opt,name=malformed,proto3" json:"malformed,omitempty"`
+	BannedAvailable int64                  `protobuf:"varint,5,opt,name=banned_available,json=bannedAvailable,proto3" json:"banned_available,omitempty"`
+	Unused          int64                  `protobuf:"varint,6,opt,name=unused,proto3" json:"unused,omitempty"` // taken, yet neither in use, reserved nor banned
+	InUseNotTaken   int64                  `protobuf:"varint,7,opt,name=in_use_not_taken,json=inUseNotTaken,proto3" json:"in_use_not_taken,omitempty"`
+	Repaired        int64                  `protobuf:"varint,8,opt,name=repaired,proto3" json:"repaired,omitempty"`
+	Examples        []string               `protobuf:"bytes,9,rep,name=examples,proto3" json:"examples,omitempty"` // the first anomalies
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Reconciliation) Reset() {
+	*x = Reconciliation{}
+	mi := &file_keys_contract_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reconciliation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reconciliation) ProtoMessage() {}
+
+func (x *Reconciliation) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reconciliation.ProtoReflect.Descriptor instead.
+func (*Reconciliation) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *Reconciliation) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Reconciliation) GetScanned() int64 {
+	if x != nil {
+		return x.Scanned
+	}
+	return 0
+}
+
+func (x *Reconciliation) GetDuplicated() int64 {
+	if x != nil {
+		return x.Duplicated
+	}
+	return 0
+}
+
+func (x *Reconciliation) GetMalformed() int64 {
+	if x != nil {
+		return x.Malformed
+	}
+	return 0
+}
+
+func (x *Reconciliation) GetBannedAvailable() int64 {
+	if x != nil {
+		return x.BannedAvailable
+	}
+	return 0
+}
+
+func (x *Reconciliation) GetUnused() int64 {
+	if x != nil {
+		return x.Unused
+	}
+	return 0
+}
+
+func (x *Reconciliation) GetInUseNotTaken() int64 {
+	if x != nil {
+		return x.InUseNotTaken
+	}
+	return 0
+}
+
+func (x *Reconciliation) GetRepaired() int64 {
+	if x != nil {
+		return x.Repaired
+	}
+	return 0
+}
+
+func (x *Reconciliation) GetExamples() []string {
+	if x != nil {
+		return x.Examples
+	}
+	return nil
+}
+
+// MigrationRequest runs a step of the keys storage migration:
+// status, start, copy, verify, cutover, rollback or finish
+type MigrationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Action        string                 `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MigrationRequest) Reset() {
+	*x = MigrationRequest{}
+	mi := &file_keys_contract_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MigrationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MigrationRequest) ProtoMessage() {}
+
+func (x *MigrationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MigrationRequest.ProtoReflect.Descriptor instead.
+func (*MigrationRequest) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *MigrationRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+type MigrationStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Phase         string                 `protobuf:"bytes,1,opt,name=phase,proto3" json:"phase,omitempty"`    // idle, copying, cutover or done
+	Copied        int64                  `protobuf:"varint,2,opt,name=copied,proto3" json:"copied,omitempty"` // by the step
+	Source        *Stats                 `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`  // counted by verify and cutover
+	Target        *Stats                 `protobuf:"bytes,4,opt,name=target,proto3" json:"target,omitempty"`
+	Checked       int64                  `protobuf:"varint,5,opt,name=checked,proto3" json:"checked,omitempty"` // source keys looked up in the target
+	Mismatched    int64                  `protobuf:"varint,6,opt,name=mismatched,proto3" json:"mismatched,omitempty"`
+	Mismatches    [][]byte               `protobuf:"bytes,7,rep,name=mismatches,proto3" json:"mismatches,omitempty"` // the first ones
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MigrationStatus) Reset() {
+	*x = MigrationStatus{}
+	mi := &file_keys_contract_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MigrationStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MigrationStatus) ProtoMessage() {}
+
+func (x *MigrationStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MigrationStatus.ProtoReflect.Descriptor instead.
+func (*MigrationStatus) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *MigrationStatus) GetPhase() string {
+	if x != nil {
+		return x.Phase
+	}
+	return ""
+}
+
+func (x *MigrationStatus) GetCopied() int64 {
+	if x != nil {
+		return x.Copied
+	}
+	return 0
+}
+
+func (x *MigrationStatus) GetSource() *Stats {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *MigrationStatus) GetTarget() *Stats {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+func (x *MigrationStatus) GetChecked() int64 {
+	if x != nil {
+		return x.Checked
+	}
+	return 0
+}
+
+func (x *MigrationStatus) GetMismatched() int64 {
+	if x != nil {
+		return x.Mismatched
+	}
+	return 0
+}
+
+func (x *MigrationStatus) GetMismatches() [][]byte {
+	if x != nil {
+		return x.Mismatches
+	}
+	return nil
+}
+
+type JobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobRequest) Reset() {
+	*x = JobRequest{}
+	mi := &file_keys_contract_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobRequest) ProtoMessage() {}
+
+func (x *JobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobRequest.ProtoReflect.Descriptor instead.
+func (*JobRequest) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *JobRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type JobList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobs          []*ScheduledJob        `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobList) Reset() {
+	*x = JobList{}
+	mi := &file_keys_contract_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobList) ProtoMessage() {}
+
+func (x *JobList) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobList.ProtoReflect.Descriptor instead.
+func (*JobList) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *JobList) GetJobs() []*ScheduledJob {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+// ScheduledJob is a background job as seen by the
+// replica serving the call; times are RFC 3339
+type ScheduledJob struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Name           string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Schedule       string                 `protobuf:"bytes,2,opt,name=schedule,proto3" json:"schedule,omitempty"` // @every <duration> or a cron expression
+	Running        bool                   `protobuf:"varint,3,opt,name=running,proto3" json:"running,omitempty"`
+	NextRun        string                 `protobuf:"bytes,4,opt,name=next_run,json=nextRun,proto3" json:"next_run,omitempty"`
+	LastRun        string                 `protobuf:"bytes,5,opt,name=last_run,json=lastRun,proto3" json:"last_run,omitempty"`
+	LastDurationMs int64                  `protobuf:"varint,6,opt,name=last_duration_ms,json=lastDurationMs,proto3" json:"last_duration_ms,omitempty"`
+	LastError      string                 `protobuf:"bytes,7,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	Runs           int64                  `protobuf:"varint,8,opt,name=runs,proto3" json:"runs,omitempty"`
+	Failures       int64                  `protobuf:"varint,9,opt,name=failures,proto3" json:"failures,omitempty"`
+	Skipped        int64                  `protobuf:"varint,10,opt,name=skipped,proto3" json:"skipped,omitempty"` // run by another replica, or still running
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ScheduledJob) Reset() {
+	*x = ScheduledJob{}
+	mi := &file_keys_contract_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScheduledJob) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScheduledJob) ProtoMessage() {}
+
+func (x *ScheduledJob) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_contract_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScheduledJob.ProtoReflect.Descriptor instead.
+func (*ScheduledJob) Descriptor() ([]byte, []int) {
+	return file_keys_contract_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *ScheduledJob) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ScheduledJob) GetSchedule() string {
+	if x != nil {
+		return x.Schedule
+	}
+	return ""
+}
+
+func (x *ScheduledJob) GetRunning() bool {
+	if x != nil {
+		return x.Running
+	}
+	return false
+}
+
+func (x *ScheduledJob) GetNextRun() string {
+	if x != nil {
+		return x.NextRun
+	}
+	return ""
+}
+
+func (x *ScheduledJob) GetLastRun() string {
+	if x != nil {
+		return x.LastRun
+	}
+	return ""
+}
+
+func (x *ScheduledJob) GetLastDurationMs() int64 {
+	if x != nil {
+		return x.LastDurationMs
+	}
+	return 0
+}
+
+func (x *ScheduledJob) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *ScheduledJob) GetRuns() int64 {
+	if x != nil {
+		return x.Runs
+	}
+	return 0
+}
+
+func (x *ScheduledJob) GetFailures() int64 {
+	if x != nil {
+		return x.Failures
+	}
+	return 0
+}
+
+func (x *ScheduledJob) GetSkipped() int64 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+var File_keys_contract_proto protoreflect.FileDescriptor
+
+const file_keys_contract_proto_rawDesc = "" +
+	"\n" +
+	"\x13keys-contract.proto\x12\x04keys\"\x06\n" +
+	"\x04Void\"A\n" +
+	"\rGetKeyRequest\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x12\n" +
+	"\x04wait\x18\x02 \x01(\bR\x04wait\"5\n" +
+	"\vKeyResponse\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\fR\x03key\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\"R\n" +
+	"\n" +
+	"KeyRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\fR\x03key\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\x12\x14\n" +
+	"\x05token\x18\x03 \x01(\tR\x05token\"\x87\x01\n" +
+	"\x12WatchEventsRequest\x12\x16\n" +
+	"\x06cursor\x18\x01 \x01(\tR\x06cursor\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\x12%\n" +
+	"\x0eall_namespaces\x18\x03 \x01(\bR\rallNamespaces\x12\x14\n" +
+	"\x05types\x18\x04 \x03(\tR\x05types\"\xd2\x01\n" +
+	"\bKeyEvent\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x12\n" +
+	"\x04time\x18\x03 \x01(\tR\x04time\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12\x1c\n" +
+	"\tnamespace\x18\x05 \x01(\tR\tnamespace\x12\x10\n" +
+	"\x03key\x18\x06 \x01(\fR\x03key\x12\x14\n" +
+	"\x05count\x18\a \x01(\x03R\x05count\x12\x16\n" +
+	"\x06caller\x18\b \x01(\tR\x06caller\x12\x16\n" +
+	"\x06cursor\x18\t \x01(\tR\x06cursor\"}\n" +
+	"\tNamespace\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12#\n" +
+	"\rmin_available\x18\x02 \x01(\x03R\fminAvailable\x12\x1d\n" +
+	"\n" +
+	"key_length\x18\x03 \x01(\x05R\tkeyLength\x12\x18\n" +
+	"\aretired\x18\x04 \x01(\bR\aretired\"&\n" +
+	"\x10NamespaceRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"@\n" +
+	"\rNamespaceList\x12/\n" +
+	"\n" +
+	"namespaces\x18\x01 \x03(\v2\x0f.keys.NamespaceR\n" +
+	"namespaces\"\x86\x01\n" +
+	"\tKeyStatus\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\fR\x03key\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x1c\n" +
+	"\tnamespace\x18\x03 \x01(\tR\tnamespace\x12\x1d\n" +
+	"\n" +
+	"key_length\x18\x04 \x01(\x05R\tkeyLength\x12\x14\n" +
+	"\x05owned\x18\x05 \x01(\bR\x05owned\"s\n" +
+	"\x0fListKeysRequest\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x16\n" +
+	"\x06cursor\x18\x03 \x01(\tR\x06cursor\x12\x14\n" +
+	"\x05count\x18\x04 \x01(\x05R\x05count\"5\n" +
+	"\aKeyList\x12\x12\n" +
+	"\x04keys\x18\x01 \x03(\fR\x04keys\x12\x16\n" +
+	"\x06cursor\x18\x02 \x01(\tR\x06cursor\"I\n" +
+	"\fStatsRequest\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x1b\n" +
+	"\tby_length\x18\x02 \x01(\bR\bbyLength\"\xdc\x01\n" +
+	"\x05Stats\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x1c\n" +
+	"\tavailable\x18\x02 \x01(\x03R\tavailable\x12\x14\n" +
+	"\x05taken\x18\x03 \x01(\x03R\x05taken\x12\x1a\n" +
+	"\breserved\x18\x04 \x01(\x03R\breserved\x12\x16\n" +
+	"\x06banned\x18\x05 \x01(\x03R\x06banned\x12+\n" +
+	"\alengths\x18\x06 \x03(\v2\x11.keys.LengthStatsR\alengths\x12 \n" +
+	"\vquarantined\x18\a \x01(\x03R\vquarantined\"\xb6\x01\n" +
+	"\vLengthStats\x12\x1d\n" +
+	"\n" +
+	"key_length\x18\x01 \x01(\x05R\tkeyLength\x12\x1c\n" +
+	"\tavailable\x18\x02 \x01(\x03R\tavailable\x12\x14\n" +
+	"\x05taken\x18\x03 \x01(\x03R\x05taken\x12\x1a\n" +
+	"\breserved\x18\x04 \x01(\x03R\breserved\x12\x16\n" +
+	"\x06banned\x18\x05 \x01(\x03R\x06banned\x12 \n" +
+	"\vquarantined\x18\x06 \x01(\x03R\vquarantined\"`\n" +
+	"\vSeedRequest\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count\x12\x1d\n" +
+	"\n" +
+	"key_length\x18\x03 \x01(\x05R\tkeyLength\"(\n" +
+	"\fSeedResponse\x12\x18\n" +
+	"\acreated\x18\x01 \x01(\x03R\acreated\"\xaa\x01\n" +
+	"\vCheckReport\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x1c\n" +
+	"\tavailable\x18\x02 \x01(\x03R\tavailable\x12\x14\n" +
+	"\x05taken\x18\x03 \x01(\x03R\x05taken\x12\x1e\n" +
+	"\n" +
+	"duplicated\x18\x04 \x01(\x03R\n" +
+	"duplicated\x12)\n" +
+	"\x10banned_available\x18\x05 \x01(\x03R\x0fbannedAvailable\"j\n" +
+	"\fAuditRequest\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\fR\x03key\x12\x14\n" +
+	"\x05since\x18\x03 \x01(\x03R\x05since\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\"e\n" +
+	"\bKeyAudit\x12/\n" +
+	"\bmetadata\x18\x01 \x01(\v2\x13.keys.AuditMetadataR\bmetadata\x12(\n" +
+	"\x06events\x18\x02 \x03(\v2\x10.keys.AuditEntryR\x06events\"\xdb\x02\n" +
+	"\rAuditMetadata\x12\x14\n" +
+	"\x05state\x18\x01 \x01(\tR\x05state\x12!\n" +
+	"\fallocated_at\x18\x02 \x01(\tR\vallocatedAt\x12!\n" +
+	"\fallocated_by\x18\x03 \x01(\tR\vallocatedBy\x12%\n" +
+	"\x0eallocated_peer\x18\x04 \x01(\tR\rallocatedPeer\x120\n" +
+	"\x14allocated_request_id\x18\x05 \x01(\tR\x12allocatedRequestId\x12\x1f\n" +
+	"\vreleased_at\x18\x06 \x01(\tR\n" +
+	"releasedAt\x12\x1f\n" +
+	"\vreleased_by\x18\a \x01(\tR\n" +
+	"releasedBy\x12#\n" +
+	"\rreleased_peer\x18\b \x01(\tR\freleasedPeer\x12.\n" +
+	"\x13released_request_id\x18\t \x01(\tR\x11releasedRequestId\"\xab\x01\n" +
+	"\n" +
+	"AuditEntry\x12\x12\n" +
+	"\x04time\x18\x01 \x01(\tR\x04time\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\fR\x03key\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x12\x14\n" +
+	"\x05state\x18\x04 \x01(\tR\x05state\x12\x16\n" +
+	"\x06caller\x18\x05 \x01(\tR\x06caller\x12\x12\n" +
+	"\x04peer\x18\x06 \x01(\tR\x04peer\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\a \x01(\tR\trequestId\"H\n" +
+	"\x10ReconcileRequest\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x16\n" +
+	"\x06repair\x18\x02 \x01(\bR\x06repair\"\xaa\x02\n" +
+	"\x0eReconciliation\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x18\n" +
+	"\ascanned\x18\x02 \x01(\x03R\ascanned\x12\x1e\n" +
+	"\n" +
+	"duplicated\x18\x03 \x01(\x03R\n" +
+	"duplicated\x12\x1c\n" +
+	"\tmalformed\x18\x04 \x01(\x03R\tmalformed\x12)\n" +
+	"\x10banned_available\x18\x05 \x01(\x03R\x0fbannedAvailable\x12\x16\n" +
+	"\x06unused\x18\x06 \x01(\x03R\x06unused\x12'\n" +
+	"\x10in_use_not_taken\x18\a \x01(\x03R\rinUseNotTaken\x12\x1a\n" +
+	"\brepaired\x18\b \x01(\x03R\brepaired\x12\x1a\n" +
+	"\bexamples\x18\t \x03(\tR\bexamples\"*\n" +
+	"\x10MigrationRequest\x12\x16\n" +
+	"\x06action\x18\x01 \x01(\tR\x06action\"\xe3\x01\n" +
+	"\x0fMigrationStatus\x12\x14\n" +
+	"\x05phase\x18\x01 \x01(\tR\x05phase\x12\x16\n" +
+	"\x06copied\x18\x02 \x01(\x03R\x06copied\x12#\n" +
+	"\x06source\x18\x03 \x01(\v2\v.keys.StatsR\x06source\x12#\n" +
+	"\x06target\x18\x04 \x01(\v2\v.keys.StatsR\x06target\x12\x18\n" +
+	"\achecked\x18\x05 \x01(\x03R\achecked\x12\x1e\n" +
+	"\n" +
+	"mismatched\x18\x06 \x01(\x03R\n" +
+	"mismatched\x12\x1e\n" +
+	"\n" +
+	"mismatches\x18\a \x03(\fR\n" +
+	"mismatches\" \n" +
+	"\n" +
+	"JobRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"1\n" +
+	"\aJobList\x12&\n" +
+	"\x04jobs\x18\x01 \x03(\v2\x12.keys.ScheduledJobR\x04jobs\"\xa1\x02\n" +
+	"\fScheduledJob\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\bschedule\x18\x02 \x01(\tR\bschedule\x12\x18\n" +
+	"\arunning\x18\x03 \x01(\bR\arunning\x12\x19\n" +
+	"\bnext_run\x18\x04 \x01(\tR\anextRun\x12\x19\n" +
+	"\blast_run\x18\x05 \x01(\tR\alastRun\x12(\n" +
+	"\x10last_duration_ms\x18\x06 \x01(\x03R\x0elastDurationMs\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\a \x01(\tR\tlastError\x12\x12\n" +
+	"\x04runs\x18\b \x01(\x03R\x04runs\x12\x1a\n" +
+	"\bfailures\x18\t \x01(\x03R\bfailures\x12\x18\n" +
+	"\askipped\x18\n" +
+	" \x01(\x03R\askipped2\xd3\x01\n" +
+	"\x04Keys\x122\n" +
+	"\x06GetKey\x12\x13.keys.GetKeyRequest\x1a\x11.keys.KeyResponse\"\x00\x12,\n" +
+	"\n" +
+	"ReleaseKey\x12\x10.keys.KeyRequest\x1a\n" +
+	".keys.Void\"\x00\x12,\n" +
+	"\n" +
+	"ReclaimKey\x12\x10.keys.KeyRequest\x1a\n" +
+	".keys.Void\"\x00\x12;\n" +
+	"\vWatchEvents\x12\x18.keys.WatchEventsRequest\x1a\x0e.keys.KeyEvent\"\x000\x012\x84\a\n" +
+	"\tKeysAdmin\x125\n" +
+	"\x0fCreateNamespace\x12\x0f.keys.Namespace\x1a\x0f.keys.Namespace\"\x00\x123\n" +
+	"\x0eListNamespaces\x12\n" +
+	".keys.Void\x1a\x13.keys.NamespaceList\"\x00\x12<\n" +
+	"\x0fRetireNamespace\x12\x16.keys.NamespaceRequest\x1a\x0f.keys.Namespace\"\x00\x121\n" +
+	"\x0fForceReleaseKey\x12\x10.keys.KeyRequest\x1a\n" +
+	".keys.Void\"\x00\x12(\n" +
+	"\x06BanKey\x12\x10.keys.KeyRequest\x1a\n" +
+	".keys.Void\"\x00\x12/\n" +
+	"\rQuarantineKey\x12\x10.keys.KeyRequest\x1a\n" +
+	".keys.Void\"\x00\x120\n" +
+	"\x0eLiftQuarantine\x12\x10.keys.KeyRequest\x1a\n" +
+	".keys.Void\"\x00\x120\n" +
+	"\tLookupKey\x12\x10.keys.KeyRequest\x1a\x0f.keys.KeyStatus\"\x00\x122\n" +
+	"\bListKeys\x12\x15.keys.ListKeysRequest\x1a\r.keys.KeyList\"\x00\x12-\n" +
+	"\bGetStats\x12\x12.keys.StatsRequest\x1a\v.keys.Stats\"\x00\x123\n" +
+	"\bSeedKeys\x12\x11.keys.SeedRequest\x1a\x12.keys.SeedResponse\"\x00\x128\n" +
+	"\tCheckKeys\x12\x16.keys.NamespaceRequest\x1a\x11.keys.CheckReport\"\x00\x121\n" +
+	"\tAuditKeys\x12\x12.keys.AuditRequest\x1a\x0e.keys.KeyAudit\"\x00\x12;\n" +
+	"\tReconcile\x12\x16.keys.ReconcileRequest\x1a\x14.keys.Reconciliation\"\x00\x12:\n" +
+	"\aMigrate\x12\x16.keys.MigrationRequest\x1a\x15.keys.MigrationStatus\"\x00\x12'\n" +
+	"\bListJobs\x12\n" +
+	".keys.Void\x1a\r.keys.JobList\"\x00\x124\n" +
+	"\n" +
+	"TriggerJob\x12\x10.keys.JobRequest\x1a\x12.keys.ScheduledJob\"\x00B\aZ\x05/keysb\x06proto3"
 
 var (
 	file_keys_contract_proto_rawDescOnce sync.Once
@@ -175,22 +2212,93 @@ func file_keys_contract_proto_rawDescGZIP() []byte {
 	return file_keys_contract_proto_rawDescData
 }
 
-var file_keys_contract_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_keys_contract_proto_msgTypes = make([]protoimpl.MessageInfo, 29)
 var file_keys_contract_proto_goTypes = []any{
-	(*Void)(nil),        // 0: keys.Void
-	(*KeyResponse)(nil), // 1: keys.KeyResponse
-	(*KeyRequest)(nil),  // 2: keys.KeyRequest
+	(*Void)(nil),               // 0: keys.Void
+	(*GetKeyRequest)(nil),      // 1: keys.GetKeyRequest
+	(*KeyResponse)(nil),        // 2: keys.KeyResponse
+	(*KeyRequest)(nil),         // 3: keys.KeyRequest
+	(*WatchEventsRequest)(nil), // 4: keys.WatchEventsRequest
+	(*KeyEvent)(nil),           // 5: keys.KeyEvent
+	(*Namespace)(nil),          // 6: keys.Namespace
+	(*NamespaceRequest)(nil),   // 7: keys.NamespaceRequest
+	(*NamespaceList)(nil),      // 8: keys.NamespaceList
+	(*KeyStatus)(nil),          // 9: keys.KeyStatus
+	(*ListKeysRequest)(nil),    // 10: keys.ListKeysRequest
+	(*KeyList)(nil),            // 11: keys.KeyList
+	(*StatsRequest)(nil),       // 12: keys.StatsRequest
+	(*Stats)(nil),              // 13: keys.Stats
+	(*LengthStats)(nil),        // 14: keys.LengthStats
+	(*SeedRequest)(nil),        // 15: keys.SeedRequest
+	(*SeedResponse)(nil),       // 16: keys.SeedResponse
+	(*CheckReport)(nil),        // 17: keys.CheckReport
+	(*AuditRequest)(nil),       // 18: keys.AuditRequest
+	(*KeyAudit)(nil),           // 19: keys.KeyAudit
+	(*AuditMetadata)(nil),      // 20: keys.AuditMetadata
+	(*AuditEntry)(nil),         // 21: keys.AuditEntry
+	(*ReconcileRequest)(nil),   // 22: keys.ReconcileRequest
+	(*Reconciliation)(nil),     // 23: keys.Reconciliation
+	(*MigrationRequest)(nil),   // 24: keys.MigrationRequest
+	(*MigrationStatus)(nil),    // 25: keys.MigrationStatus
+	(*JobRequest)(nil),         // 26: keys.JobRequest
+	(*JobList)(nil),            // 27: keys.JobList
+	(*ScheduledJob)(nil),       // 28: keys.ScheduledJob
 }
 var file_keys_contract_proto_depIdxs = []int32{
-	0, // 0: keys.Keys.GetKey:input_type -> keys.Void
-	2, // 1: keys.Keys.ReleaseKey:input_type -> keys.KeyRequest
-	1, // 2: keys.Keys.GetKey:output_type -> keys.KeyResponse
-	0, // 3: keys.Keys.ReleaseKey:output_type -> keys.Void
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	6,  // 0: keys.NamespaceList.namespaces:type_name -> keys.Namespace
+	14, // 1: keys.Stats.lengths:type_name -> keys.LengthStats
+	20, // 2: keys.KeyAudit.metadata:type_name -> keys.AuditMetadata
+	21, // 3: keys.KeyAudit.events:type_name -> keys.AuditEntry
+	13, // 4: keys.MigrationStatus.source:type_name -> keys.Stats
+	13, // 5: keys.MigrationStatus.target:type_name -> keys.Stats
+	28, // 6: keys.JobList.jobs:type_name -> keys.ScheduledJob
+	1,  // 7: keys.Keys.GetKey:input_type -> keys.GetKeyRequest
+	3,  // 8: keys.Keys.ReleaseKey:input_type -> keys.KeyRequest
+	3,  // 9: keys.Keys.ReclaimKey:input_type -> keys.KeyRequest
+	4,  // 10: keys.Keys.WatchEvents:input_type -> keys.WatchEventsRequest
+	6,  // 11: keys.KeysAdmin.CreateNamespace:input_type -> keys.Namespace
+	0,  // 12: keys.KeysAdmin.ListNamespaces:input_type -> keys.Void
+	7,  // 13: keys.KeysAdmin.RetireNamespace:input_type -> keys.NamespaceRequest
+	3,  // 14: keys.KeysAdmin.ForceReleaseKey:input_type -> keys.KeyRequest
+	3,  // 15: keys.KeysAdmin.BanKey:input_type -> keys.KeyRequest
+	3,  // 16: keys.KeysAdmin.QuarantineKey:input_type -> keys.KeyRequest
+	3,  // 17: keys.KeysAdmin.LiftQuarantine:input_type -> keys.KeyRequest
+	3,  // 18: keys.KeysAdmin.LookupKey:input_type -> keys.KeyRequest
+	10, // 19: keys.KeysAdmin.ListKeys:input_type -> keys.ListKeysRequest
+	12, // 20: keys.KeysAdmin.GetStats:input_type -> keys.StatsRequest
+	15, // 21: keys.KeysAdmin.SeedKeys:input_type -> keys.SeedRequest
+	7,  // 22: keys.KeysAdmin.CheckKeys:input_type -> keys.NamespaceRequest
+	18, // 23: keys.KeysAdmin.AuditKeys:input_type -> keys.AuditRequest
+	22, // 24: keys.KeysAdmin.Reconcile:input_type -> keys.ReconcileRequest
+	24, // 25: keys.KeysAdmin.Migrate:input_type -> keys.MigrationRequest
+	0,  // 26: keys.KeysAdmin.ListJobs:input_type -> keys.Void
+	26, // 27: keys.KeysAdmin.TriggerJob:input_type -> keys.JobRequest
+	2,  // 28: keys.Keys.GetKey:output_type -> keys.KeyResponse
+	0,  // 29: keys.Keys.ReleaseKey:output_type -> keys.Void
+	0,  // 30: keys.Keys.ReclaimKey:output_type -> keys.Void
+	5,  // 31: keys.Keys.WatchEvents:output_type -> keys.KeyEvent
+	6,  // 32: keys.KeysAdmin.CreateNamespace:output_type -> keys.Namespace
+	8,  // 33: keys.KeysAdmin.ListNamespaces:output_type -> keys.NamespaceList
+	6,  // 34: keys.KeysAdmin.RetireNamespace:output_type -> keys.Namespace
+	0,  // 35: keys.KeysAdmin.ForceReleaseKey:output_type -> keys.Void
+	0,  // 36: keys.KeysAdmin.BanKey:output_type -> keys.Void
+	0,  // 37: keys.KeysAdmin.QuarantineKey:output_type -> keys.Void
+	0,  // 38: keys.KeysAdmin.LiftQuarantine:output_type -> keys.Void
+	9,  // 39: keys.KeysAdmin.LookupKey:output_type -> keys.KeyStatus
+	11, // 40: keys.KeysAdmin.ListKeys:output_type -> keys.KeyList
+	13, // 41: keys.KeysAdmin.GetStats:output_type -> keys.Stats
+	16, // 42: keys.KeysAdmin.SeedKeys:output_type -> keys.SeedResponse
+	17, // 43: keys.KeysAdmin.CheckKeys:output_type -> keys.CheckReport
+	19, // 44: keys.KeysAdmin.AuditKeys:output_type -> keys.KeyAudit
+	23, // 45: keys.KeysAdmin.Reconcile:output_type -> keys.Reconciliation
+	25, // 46: keys.KeysAdmin.Migrate:output_type -> keys.MigrationStatus
+	27, // 47: keys.KeysAdmin.ListJobs:output_type -> keys.JobList
+	28, // 48: keys.KeysAdmin.TriggerJob:output_type -> keys.ScheduledJob
+	28, // [28:49] is the sub-list for method output_type
+	7,  // [7:28] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_keys_contract_proto_init() }
@@ -204,9 +2312,9 @@ func file_keys_contract_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_keys_contract_proto_rawDesc), len(file_keys_contract_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   29,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_keys_contract_proto_goTypes,
 		DependencyIndexes: file_keys_contract_proto_depIdxs,
@@ -19,16 +19,20 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Keys_GetKey_FullMethodName     = "/keys.Keys/GetKey"
-	Keys_ReleaseKey_FullMethodName = "/keys.Keys/ReleaseKey"
+	Keys_GetKey_FullMethodName      = "/keys.Keys/GetKey"
+	Keys_ReleaseKey_FullMethodName  = "/keys.Keys/ReleaseKey"
+	Keys_ReclaimKey_FullMethodName  = "/keys.Keys/ReclaimKey"
+	Keys_WatchEvents_FullMethodName = "/keys.Keys/WatchEvents"
 )
 
 // KeysClient is the client API for Keys service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type KeysClient interface {
-	GetKey(ctx context.Context, in *Void, opts ...grpc.CallOption) (*KeyResponse, error)
+	GetKey(ctx context.Context, in *GetKeyRequest, opts ...grpc.CallOption) (*KeyResponse, error)
 	ReleaseKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*Void, error)
+	ReclaimKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*Void, error)
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[KeyEvent], error)
 }
 
 type keysClient struct {
@@ -39,7 +43,7 @@ func NewKeysClient(cc grpc.ClientConnInterface) KeysClient {
 	return &keysClient{cc}
 }
 
-func (c *keysClient) GetKey(ctx context.Context, in *Void, opts ...grpc.CallOption) (*KeyResponse, error) {
+func (c *keysClient) GetKey(ctx context.Context, in *GetKeyRequest, opts ...grpc.CallOption) (*KeyResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(KeyResponse)
 	err := c.cc.Invoke(ctx, Keys_GetKey_FullMethodName, in, out, cOpts...)
@@ -59,12 +63,43 @@ func (c *keysClient) ReleaseKey(ctx context.Context, in *KeyRequest, opts ...grp
 	return out, nil
 }
 
+func (c *keysClient) ReclaimKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*Void, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Void)
+	err := c.cc.Invoke(ctx, Keys_ReclaimKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[KeyEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Keys_ServiceDesc.Streams[0], Keys_WatchEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchEventsRequest, KeyEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Keys_WatchEventsClient = grpc.ServerStreamingClient[KeyEvent]
+
 // KeysServer is the server API for Keys service.
 // All implementations must embed UnimplementedKeysServer
 // for forward compatibility.
 type KeysServer interface {
-	GetKey(context.Context, *Void) (*KeyResponse, error)
+	GetKey(context.Context, *GetKeyRequest) (*KeyResponse, error)
 	ReleaseKey(context.Context, *KeyRequest) (*Void, error)
+	ReclaimKey(context.Context, *KeyRequest) (*Void, error)
+	WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[KeyEvent]) error
 	mustEmbedUnimplementedKeysServer()
 }
 
@@ -75,12 +110,18 @@ type KeysServer interface {
 // pointer dereference when methods are called.
 type UnimplementedKeysServer struct{}
 
-func (UnimplementedKeysServer) GetKey(context.Context, *Void) (*KeyResponse, error) {
+func (UnimplementedKeysServer) GetKey(context.Context, *GetKeyRequest) (*KeyResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetKey not implemented")
 }
 func (UnimplementedKeysServer) ReleaseKey(context.Context, *KeyRequest) (*Void, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ReleaseKey not implemented")
 }
+func (UnimplementedKeysServer) ReclaimKey(context.Context, *KeyRequest) (*Void, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReclaimKey not implemented")
+}
+func (UnimplementedKeysServer) WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[KeyEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
 func (UnimplementedKeysServer) mustEmbedUnimplementedKeysServer() {}
 func (UnimplementedKeysServer) testEmbeddedByValue()              {}
 
@@ -103,7 +144,7 @@ func RegisterKeysServer(s grpc.ServiceRegistrar, srv KeysServer) {
 }
 
 func _Keys_GetKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Void)
+	in := new(GetKeyRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -115,7 +156,7 @@ func _Keys_GetKey_Handler(srv interface{}, ctx context.Context, dec func(interfa
 		FullMethod: Keys_GetKey_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(KeysServer).GetKey(ctx, req.(*Void))
+		return srv.(KeysServer).GetKey(ctx, req.(*GetKeyRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -138,6 +179,35 @@ func _Keys_ReleaseKey_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Keys_ReclaimKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysServer).ReclaimKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keys_ReclaimKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysServer).ReclaimKey(ctx, req.(*KeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keys_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KeysServer).WatchEvents(m, &grpc.GenericServerStream[WatchEventsRequest, KeyEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Keys_WatchEventsServer = grpc.ServerStreamingServer[KeyEvent]
+
 // Keys_ServiceDesc is the grpc.ServiceDesc for Keys service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,6 +223,730 @@ var Keys_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ReleaseKey",
 			Handler:    _Keys_ReleaseKey_Handler,
 		},
+		{
+			MethodName: "ReclaimKey",
+			Handler:    _Keys_ReclaimKey_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _Keys_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "keys-contract.proto",
+}
+
+const (
+	KeysAdmin_CreateNamespace_FullMethodName = "/keys.KeysAdmin/CreateNamespace"
+	KeysAdmin_ListNamespaces_FullMethodName  = "/keys.KeysAdmin/ListNamespaces"
+	KeysAdmin_RetireNamespace_FullMethodName = "/keys.KeysAdmin/RetireNamespace"
+	KeysAdmin_ForceReleaseKey_FullMethodName = "/keys.KeysAdmin/ForceReleaseKey"
+	KeysAdmin_BanKey_FullMethodName          = "/keys.KeysAdmin/BanKey"
+	KeysAdmin_QuarantineKey_FullMethodName   = "/keys.KeysAdmin/QuarantineKey"
+	KeysAdmin_LiftQuarantine_FullMethodName  = "/keys.KeysAdmin/LiftQuarantine"
+	KeysAdmin_LookupKey_FullMethodName       = "/keys.KeysAdmin/LookupKey"
+	KeysAdmin_ListKeys_FullMethodName        = "/keys.KeysAdmin/ListKeys"
+	KeysAdmin_GetStats_FullMethodName        = "/keys.KeysAdmin/GetStats"
+	KeysAdmin_SeedKeys_FullMethodName        = "/keys.KeysAdmin/SeedKeys"
+	KeysAdmin_CheckKeys_FullMethodName       = "/keys.KeysAdmin/CheckKeys"
+	KeysAdmin_AuditKeys_FullMethodName       = "/keys.KeysAdmin/AuditKeys"
+	KeysAdmin_Reconcile_FullMethodName       = "/keys.KeysAdmin/Reconcile"
+	KeysAdmin_Migrate_FullMethodName         = "/keys.KeysAdmin/Migrate"
+	KeysAdmin_ListJobs_FullMethodName        = "/keys.KeysAdmin/ListJobs"
+	KeysAdmin_TriggerJob_FullMethodName      = "/keys.KeysAdmin/TriggerJob"
+)
+
+// KeysAdminClient is the client API for KeysAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// KeysAdmin manages the keys service, e.g. its namespaces
+type KeysAdminClient interface {
+	CreateNamespace(ctx context.Context, in *Namespace, opts ...grpc.CallOption) (*Namespace, error)
+	ListNamespaces(ctx context.Context, in *Void, opts ...grpc.CallOption) (*NamespaceList, error)
+	RetireNamespace(ctx context.Context, in *NamespaceRequest, opts ...grpc.CallOption) (*Namespace, error)
+	ForceReleaseKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*Void, error)
+	BanKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*Void, error)
+	QuarantineKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*Void, error)
+	LiftQuarantine(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*Void, error)
+	LookupKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*KeyStatus, error)
+	ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*KeyList, error)
+	GetStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*Stats, error)
+	SeedKeys(ctx context.Context, in *SeedRequest, opts ...grpc.CallOption) (*SeedResponse, error)
+	CheckKeys(ctx context.Context, in *NamespaceRequest, opts ...grpc.CallOption) (*CheckReport, error)
+	AuditKeys(ctx context.Context, in *AuditRequest, opts ...grpc.CallOption) (*KeyAudit, error)
+	Reconcile(ctx context.Context, in *ReconcileRequest, opts ...grpc.CallOption) (*Reconciliation, error)
+	Migrate(ctx context.Context, in *MigrationRequest, opts ...grpc.CallOption) (*MigrationStatus, error)
+	ListJobs(ctx context.Context, in *Void, opts ...grpc.CallOption) (*JobList, error)
+	TriggerJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*ScheduledJob, error)
+}
+
+type keysAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewKeysAdminClient(cc grpc.ClientConnInterface) KeysAdminClient {
+	return &keysAdminClient{cc}
+}
+
+func (c *keysAdminClient) CreateNamespace(ctx context.Context, in *Namespace, opts ...grpc.CallOption) (*Namespace, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Namespace)
+	err := c.cc.Invoke(ctx, KeysAdmin_CreateNamespace_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) ListNamespaces(ctx context.Context, in *Void, opts ...grpc.CallOption) (*NamespaceList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NamespaceList)
+	err := c.cc.Invoke(ctx, KeysAdmin_ListNamespaces_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) RetireNamespace(ctx context.Context, in *NamespaceRequest, opts ...grpc.CallOption) (*Namespace, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Namespace)
+	err := c.cc.Invoke(ctx, KeysAdmin_RetireNamespace_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) ForceReleaseKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*Void, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Void)
+	err := c.cc.Invoke(ctx, KeysAdmin_ForceReleaseKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) BanKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*Void, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Void)
+	err := c.cc.Invoke(ctx, KeysAdmin_BanKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) QuarantineKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*Void, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Void)
+	err := c.cc.Invoke(ctx, KeysAdmin_QuarantineKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) LiftQuarantine(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*Void, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Void)
+	err := c.cc.Invoke(ctx, KeysAdmin_LiftQuarantine_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) LookupKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*KeyStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KeyStatus)
+	err := c.cc.Invoke(ctx, KeysAdmin_LookupKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*KeyList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KeyList)
+	err := c.cc.Invoke(ctx, KeysAdmin_ListKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) GetStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*Stats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Stats)
+	err := c.cc.Invoke(ctx, KeysAdmin_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) SeedKeys(ctx context.Context, in *SeedRequest, opts ...grpc.CallOption) (*SeedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SeedResponse)
+	err := c.cc.Invoke(ctx, KeysAdmin_SeedKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) CheckKeys(ctx context.Context, in *NamespaceRequest, opts ...grpc.CallOption) (*CheckReport, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckReport)
+	err := c.cc.Invoke(ctx, KeysAdmin_CheckKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) AuditKeys(ctx context.Context, in *AuditRequest, opts ...grpc.CallOption) (*KeyAudit, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KeyAudit)
+	err := c.cc.Invoke(ctx, KeysAdmin_AuditKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) Reconcile(ctx context.Context, in *ReconcileRequest, opts ...grpc.CallOption) (*Reconciliation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Reconciliation)
+	err := c.cc.Invoke(ctx, KeysAdmin_Reconcile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) Migrate(ctx context.Context, in *MigrationRequest, opts ...grpc.CallOption) (*MigrationStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MigrationStatus)
+	err := c.cc.Invoke(ctx, KeysAdmin_Migrate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) ListJobs(ctx context.Context, in *Void, opts ...grpc.CallOption) (*JobList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobList)
+	err := c.cc.Invoke(ctx, KeysAdmin_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keysAdminClient) TriggerJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*ScheduledJob, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScheduledJob)
+	err := c.cc.Invoke(ctx, KeysAdmin_TriggerJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// KeysAdminServer is the server API for KeysAdmin service.
+// All implementations must embed UnimplementedKeysAdminServer
+// for forward compatibility.
+//
+// KeysAdmin manages the keys service, e.g. its namespaces
+type KeysAdminServer interface {
+	CreateNamespace(context.Context, *Namespace) (*Namespace, error)
+	ListNamespaces(context.Context, *Void) (*NamespaceList, error)
+	RetireNamespace(context.Context, *NamespaceRequest) (*Namespace, error)
+	ForceReleaseKey(context.Context, *KeyRequest) (*Void, error)
+	BanKey(context.Context, *KeyRequest) (*Void, error)
+	QuarantineKey(context.Context, *KeyRequest) (*Void, error)
+	LiftQuarantine(context.Context, *KeyRequest) (*Void, error)
+	LookupKey(context.Context, *KeyRequest) (*KeyStatus, error)
+	ListKeys(context.Context, *ListKeysRequest) (*KeyList, error)
+	GetStats(context.Context, *StatsRequest) (*Stats, error)
+	SeedKeys(context.Context, *SeedRequest) (*SeedResponse, error)
+	CheckKeys(context.Context, *NamespaceRequest) (*CheckReport, error)
+	AuditKeys(context.Context, *AuditRequest) (*KeyAudit, error)
+	Reconcile(context.Context, *ReconcileRequest) (*Reconciliation, error)
+	Migrate(context.Context, *MigrationRequest) (*MigrationStatus, error)
+	ListJobs(context.Context, *Void) (*JobList, error)
+	TriggerJob(context.Context, *JobRequest) (*ScheduledJob, error)
+	mustEmbedUnimplementedKeysAdminServer()
+}
+
+// UnimplementedKeysAdminServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedKeysAdminServer struct{}
+
+func (UnimplementedKeysAdminServer) CreateNamespace(context.Context, *Namespace) (*Namespace, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateNamespace not implemented")
+}
+func (UnimplementedKeysAdminServer) ListNamespaces(context.Context, *Void) (*NamespaceList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNamespaces not implemented")
+}
+func (UnimplementedKeysAdminServer) RetireNamespace(context.Context, *NamespaceRequest) (*Namespace, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetireNamespace not implemented")
+}
+func (UnimplementedKeysAdminServer) ForceReleaseKey(context.Context, *KeyRequest) (*Void, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ForceReleaseKey not implemented")
+}
+func (UnimplementedKeysAdminServer) BanKey(context.Context, *KeyRequest) (*Void, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BanKey not implemented")
+}
+func (UnimplementedKeysAdminServer) QuarantineKey(context.Context, *KeyRequest) (*Void, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QuarantineKey not implemented")
+}
+func (UnimplementedKeysAdminServer) LiftQuarantine(context.Context, *KeyRequest) (*Void, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LiftQuarantine not implemented")
+}
+func (UnimplementedKeysAdminServer) LookupKey(context.Context, *KeyRequest) (*KeyStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupKey not implemented")
+}
+func (UnimplementedKeysAdminServer) ListKeys(context.Context, *ListKeysRequest) (*KeyList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListKeys not implemented")
+}
+func (UnimplementedKeysAdminServer) GetStats(context.Context, *StatsRequest) (*Stats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedKeysAdminServer) SeedKeys(context.Context, *SeedRequest) (*SeedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SeedKeys not implemented")
+}
+func (UnimplementedKeysAdminServer) CheckKeys(context.Context, *NamespaceRequest) (*CheckReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckKeys not implemented")
+}
+func (UnimplementedKeysAdminServer) AuditKeys(context.Context, *AuditRequest) (*KeyAudit, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AuditKeys not implemented")
+}
+func (UnimplementedKeysAdminServer) Reconcile(context.Context, *ReconcileRequest) (*Reconciliation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reconcile not implemented")
+}
+func (UnimplementedKeysAdminServer) Migrate(context.Context, *MigrationRequest) (*MigrationStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Migrate not implemented")
+}
+func (UnimplementedKeysAdminServer) ListJobs(context.Context, *Void) (*JobList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedKeysAdminServer) TriggerJob(context.Context, *JobRequest) (*ScheduledJob, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TriggerJob not implemented")
+}
+func (UnimplementedKeysAdminServer) mustEmbedUnimplementedKeysAdminServer() {}
+func (UnimplementedKeysAdminServer) testEmbeddedByValue()                   {}
+
+// UnsafeKeysAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to KeysAdminServer will
+// result in compilation errors.
+type UnsafeKeysAdminServer interface {
+	mustEmbedUnimplementedKeysAdminServer()
+}
+
+func RegisterKeysAdminServer(s grpc.ServiceRegistrar, srv KeysAdminServer) {
+	// If the following call pancis, it indicates UnimplementedKeysAdminServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&KeysAdmin_ServiceDesc, srv)
+}
+
+func _KeysAdmin_CreateNamespace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Namespace)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).CreateNamespace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_CreateNamespace_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).CreateNamespace(ctx, req.(*Namespace))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_ListNamespaces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Void)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).ListNamespaces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_ListNamespaces_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).ListNamespaces(ctx, req.(*Void))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_RetireNamespace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NamespaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).RetireNamespace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_RetireNamespace_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).RetireNamespace(ctx, req.(*NamespaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_ForceReleaseKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).ForceReleaseKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_ForceReleaseKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).ForceReleaseKey(ctx, req.(*KeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_BanKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).BanKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_BanKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).BanKey(ctx, req.(*KeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_QuarantineKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).QuarantineKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_QuarantineKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).QuarantineKey(ctx, req.(*KeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_LiftQuarantine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).LiftQuarantine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_LiftQuarantine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).LiftQuarantine(ctx, req.(*KeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_LookupKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).LookupKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_LookupKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).LookupKey(ctx, req.(*KeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_ListKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).ListKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_ListKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).ListKeys(ctx, req.(*ListKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).GetStats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_SeedKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SeedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).SeedKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_SeedKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).SeedKeys(ctx, req.(*SeedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_CheckKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NamespaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).CheckKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_CheckKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).CheckKeys(ctx, req.(*NamespaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_AuditKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).AuditKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_AuditKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).AuditKeys(ctx, req.(*AuditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_Reconcile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReconcileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).Reconcile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_Reconcile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).Reconcile(ctx, req.(*ReconcileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_Migrate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MigrationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).Migrate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_Migrate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).Migrate(ctx, req.(*MigrationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Void)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).ListJobs(ctx, req.(*Void))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeysAdmin_TriggerJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeysAdminServer).TriggerJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeysAdmin_TriggerJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeysAdminServer).TriggerJob(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// KeysAdmin_ServiceDesc is the grpc.ServiceDesc for KeysAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var KeysAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "keys.KeysAdmin",
+	HandlerType: (*KeysAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateNamespace",
+			Handler:    _KeysAdmin_CreateNamespace_Handler,
+		},
+		{
+			MethodName: "ListNamespaces",
+			Handler:    _KeysAdmin_ListNamespaces_Handler,
+		},
+		{
+			MethodName: "RetireNamespace",
+			Handler:    _KeysAdmin_RetireNamespace_Handler,
+		},
+		{
+			MethodName: "ForceReleaseKey",
+			Handler:    _KeysAdmin_ForceReleaseKey_Handler,
+		},
+		{
+			MethodName: "BanKey",
+			Handler:    _KeysAdmin_BanKey_Handler,
+		},
+		{
+			MethodName: "QuarantineKey",
+			Handler:    _KeysAdmin_QuarantineKey_Handler,
+		},
+		{
+			MethodName: "LiftQuarantine",
+			Handler:    _KeysAdmin_LiftQuarantine_Handler,
+		},
+		{
+			MethodName: "LookupKey",
+			Handler:    _KeysAdmin_LookupKey_Handler,
+		},
+		{
+			MethodName: "ListKeys",
+			Handler:    _KeysAdmin_ListKeys_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _KeysAdmin_GetStats_Handler,
+		},
+		{
+			MethodName: "SeedKeys",
+			Handler:    _KeysAdmin_SeedKeys_Handler,
+		},
+		{
+			MethodName: "CheckKeys",
+			Handler:    _KeysAdmin_CheckKeys_Handler,
+		},
+		{
+			MethodName: "AuditKeys",
+			Handler:    _KeysAdmin_AuditKeys_Handler,
+		},
+		{
+			MethodName: "Reconcile",
+			Handler:    _KeysAdmin_Reconcile_Handler,
+		},
+		{
+			MethodName: "Migrate",
+			Handler:    _KeysAdmin_Migrate_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _KeysAdmin_ListJobs_Handler,
+		},
+		{
+			MethodName: "TriggerJob",
+			Handler:    _KeysAdmin_TriggerJob_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "keys-contract.proto",
@@ -0,0 +1,943 @@
+package keys
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const KeysMigrationName = "keysMigration"
+
+// MigrationPhase tells which storage of a migration serves
+// the keys and whether writes are mirrored to the other
+type MigrationPhase string
+
+const (
+	// MigrationIdle serves the source only
+	MigrationIdle MigrationPhase = "idle"
+
+	// MigrationCopying serves the source, mirroring writes to the target
+	MigrationCopying MigrationPhase = "copying"
+
+	// MigrationCutOver serves the target, mirroring writes to the
+	// source so that the migration can still be rolled back
+	MigrationCutOver MigrationPhase = "cutover"
+
+	// MigrationDone serves the target only
+	MigrationDone MigrationPhase = "done"
+)
+
+var (
+	// ErrMigrationPhase is returned by migration
+	// steps not allowed in the current phase
+	ErrMigrationPhase = errors.New("migration step not allowed")
+
+	// ErrMigrationDiverged is returned when cutting over
+	// to a target holding other keys than the source
+	ErrMigrationDiverged = errors.New("migration target diverged from source")
+)
+
+// maxMirrorConflicts bounds the allocations
+// skipped on conflicts before giving up
+const maxMirrorConflicts = 10
+
+// MigrationStore keeps the phase of a migration,
+// shared by every replica of the service
+type MigrationStore interface {
+	Phase() (MigrationPhase, error)
+
+	// SetPhase saves the to phase when the saved one is from,
+	// failing with ErrMigrationPhase otherwise
+	SetPhase(from, to MigrationPhase) error
+}
+
+// setPhaseScript sets the KEYS[1] string to ARGV[2] when it
+// holds ARGV[1], missing meaning ARGV[3]; returns 1 when set,
+// otherwise the phase held
+const setPhaseScript = `
+local phase = redis.call('GET', KEYS[1]) or ARGV[3]
+if phase ~= ARGV[1] then
+  return phase
+end
+redis.call('SET', KEYS[1], ARGV[2])
+return 1`
+
+// ValkeyMigrationStore keeps the phase in a string
+type ValkeyMigrationStore struct {
+	// Client connects to the db, required
+	Client app.KeyValueDbClient
+}
+
+// Phase returns the saved phase, idle when none was saved
+func (v *ValkeyMigrationStore) Phase() (MigrationPhase, error) {
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := valkeyClient.Get(KeysMigrationName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get migration phase: %w", err)
+	}
+
+	if res.IsNil() {
+		return MigrationIdle, nil
+	}
+
+	return MigrationPhase(res.Value()), nil
+}
+
+// SetPhase saves the to phase when the saved one is from,
+// comparing and setting it in a script
+func (v *ValkeyMigrationStore) SetPhase(from, to MigrationPhase) error {
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return err
+	}
+
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", setPhaseScript, "1", KeysMigrationName, string(from), string(to), string(MigrationIdle),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save migration phase: %w", err)
+	}
+
+	if phase, ok := res.(string); ok {
+		return fmt.Errorf("%w: %s while %s", ErrMigrationPhase, to, phase)
+	}
+
+	return nil
+}
+
+// MigratingEntity moves live keys from a Source storage to a
+// Target one: once started, writes to the serving storage are
+// mirrored to the other one while the source keys are copied,
+// then verified before cutting over to the target. Replicas
+// follow the phase saved in the Store within Refresh
+type MigratingEntity struct {
+	Source app.KeyValueEntity
+	Target app.KeyValueEntity
+	Store  MigrationStore
+
+	// Refresh is how long a loaded phase is trusted, 5s when zero
+	Refresh time.Duration
+
+	// BatchSize keys are copied, or verified,
+	// at once, 1000 when zero
+	BatchSize int
+
+	mu       sync.Mutex
+	phase    MigrationPhase
+	loadedAt time.Time
+
+	copied, mirrored, mirrorFailures, conflicts atomic.Int64
+}
+
+// MigrationStats is a snapshot of a MigratingEntity
+type MigrationStats struct {
+	Phase          MigrationPhase
+	Copied         int64 // by this replica
+	Mirrored       int64
+	MirrorFailures int64
+	Conflicts      int64 // keys allocated by both storages at once
+}
+
+// MigrationReport compares the keys of both storages
+type MigrationReport struct {
+	Source StateCounts
+	Target StateCounts
+
+	Checked    int64 // source keys looked up in the target
+	Mismatched int64
+	Mismatches []ShortKey // the first ones
+}
+
+// Consistent tells whether both storages hold the same keys
+func (r MigrationReport) Consistent() bool {
+	return r.Source == r.Target && r.Mismatched == 0
+}
+
+// Phase returns the current phase, loaded
+// again once older than Refresh
+func (e *MigratingEntity) Phase() (MigrationPhase, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.phase != "" && time.Since(e.loadedAt) < cmp.Or(e.Refresh, 5*time.Second) {
+		return e.phase, nil
+	}
+
+	phase, err := e.Store.Phase()
+	if err != nil {
+		if e.phase != "" { // keep serving the last known phase
+			log.Printf("keys migration phase not refreshed: %v", err)
+			return e.phase, nil
+		}
+
+		return "", err
+	}
+
+	e.phase, e.loadedAt = phase, time.Now()
+	return phase, nil
+}
+
+// Stats returns the migration counters
+func (e *MigratingEntity) Stats() MigrationStats {
+	phase, _ := e.Phase()
+
+	return MigrationStats{
+		Phase:          phase,
+		Copied:         e.copied.Load(),
+		Mirrored:       e.mirrored.Load(),
+		MirrorFailures: e.mirrorFailures.Load(),
+		Conflicts:      e.conflicts.Load(),
+	}
+}
+
+// Start mirrors writes to the target and clears it, then
+// copies the source keys once every replica mirrors them;
+// the writes mirrored before the target is cleared are
+// copied again
+func (e *MigratingEntity) Start(ctx context.Context) (int64, error) {
+	restorer, ok := app.As[KeyRestorer](e.Target)
+	if !ok {
+		return 0, fmt.Errorf("migration target: %w", ErrInspectUnsupported)
+	}
+
+	if err := e.transition(MigrationIdle, MigrationCopying); err != nil {
+		return 0, err
+	}
+
+	if err := restorer.Clear(); err != nil {
+		if rollbackErr := e.transition(MigrationCopying, MigrationIdle); rollbackErr != nil {
+			return 0, errors.Join(fmt.Errorf("failed to clear the migration target: %w", err), rollbackErr)
+		}
+
+		return 0, fmt.Errorf("failed to clear the migration target: %w", err)
+	}
+
+	sleep(ctx, 2*cmp.Or(e.Refresh, 5*time.Second))
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("migration started, copy interrupted: %w", err)
+	}
+
+	return e.Copy(ctx)
+}
+
+// Copy copies the source keys the target doesn't know yet,
+// the keys known having been mirrored meanwhile; copying
+// again resumes an interrupted copy
+func (e *MigratingEntity) Copy(ctx context.Context) (int64, error) {
+	if phase, err := e.Phase(); err != nil {
+		return 0, err
+	} else if phase != MigrationCopying {
+		return 0, fmt.Errorf("%w: copying keys while %s", ErrMigrationPhase, phase)
+	}
+
+	restorer, ok := app.As[KeyRestorer](e.Target)
+	if !ok {
+		return 0, fmt.Errorf("migration target: %w", ErrInspectUnsupported)
+	}
+
+	// taken keys include the reserved, quarantined and banned
+	// ones, copied first so that the taken keys skip them
+	var copied int64
+	for _, state := range []KeyState{KeyBanned, KeyQuarantined, KeyReserved, KeyTaken, KeyAvailable} {
+		err := e.listSource(ctx, state, func(page []ShortKey) error {
+			restored, err := restorer.Restore(state, page, true)
+			copied += int64(len(restored))
+			e.copied.Add(int64(len(restored)))
+
+			if err != nil {
+				return fmt.Errorf("failed to copy %s keys: %w", state, err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return copied, err
+		}
+	}
+
+	return copied, nil
+}
+
+// Verify counts the keys of both storages and looks every
+// source key up in the target; keys found out of sync are
+// looked up again, their writes may have been in flight
+func (e *MigratingEntity) Verify(ctx context.Context) (MigrationReport, error) {
+	var report MigrationReport
+
+	sourceInspector, sourceOk := app.As[KeyInspector](e.Source)
+	targetInspector, targetOk := app.As[KeyInspector](e.Target)
+	if !sourceOk || !targetOk {
+		return report, ErrInspectUnsupported
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		var err error
+		if report.Source, err = countStates(e.Source); err != nil {
+			return report, fmt.Errorf("failed to count source keys: %w", err)
+		}
+
+		if report.Target, err = countStates(e.Target); err != nil {
+			return report, fmt.Errorf("failed to count target keys: %w", err)
+		}
+
+		if report.Source == report.Target {
+			break
+		}
+	}
+
+	for _, listed := range []KeyState{KeyAvailable, KeyTaken} {
+		err := e.listSource(ctx, listed, func(page []ShortKey) error {
+			for _, k := range page {
+				report.Checked++
+
+				for attempt := 0; ; attempt++ {
+					sourceState, targetState := listed, KeyState("")
+
+					var err error
+					if listed != KeyAvailable || attempt > 0 {
+						if sourceState, err = sourceInspector.State(k); err != nil {
+							return fmt.Errorf("failed to look source key up: %w", err)
+						}
+					}
+
+					if targetState, err = targetInspector.State(k); err != nil {
+						return fmt.Errorf("failed to look target key up: %w", err)
+					}
+
+					if sourceState == targetState {
+						break
+					}
+
+					if attempt > 0 {
+						report.Mismatched++
+						if len(report.Mismatches) < 10 {
+							report.Mismatches = append(report.Mismatches, k)
+						}
+						break
+					}
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// CutOver serves the target once verified to hold
+// the source keys, still mirroring writes to the source
+func (e *MigratingEntity) CutOver(ctx context.Context) (MigrationReport, error) {
+	if phase, err := e.Phase(); err != nil {
+		return MigrationReport{}, err
+	} else if phase != MigrationCopying {
+		return MigrationReport{}, fmt.Errorf("%w: cutting over while %s", ErrMigrationPhase, phase)
+	}
+
+	report, err := e.Verify(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	if !report.Consistent() {
+		return report, fmt.Errorf("%w: %d mismatched keys", ErrMigrationDiverged, report.Mismatched)
+	}
+
+	return report, e.transition(MigrationCopying, MigrationCutOver)
+}
+
+// Rollback serves the source again after a cut over,
+// or stops mirroring writes to the target while copying
+func (e *MigratingEntity) Rollback() (MigrationPhase, error) {
+	phase, err := e.Phase()
+	if err != nil {
+		return "", err
+	}
+
+	switch phase {
+	case MigrationCutOver:
+		return MigrationCopying, e.transition(phase, MigrationCopying)
+	case MigrationCopying:
+		return MigrationIdle, e.transition(phase, MigrationIdle)
+	}
+
+	return "", fmt.Errorf("%w: rolling back while %s", ErrMigrationPhase, phase)
+}
+
+// Finish stops mirroring writes to the source,
+// which can't be rolled back to anymore
+func (e *MigratingEntity) Finish() error {
+	return e.transition(MigrationCutOver, MigrationDone)
+}
+
+// transition saves the next phase when the saved one is
+// from, another replica may have moved it meanwhile
+func (e *MigratingEntity) transition(from, to MigrationPhase) error {
+	if err := e.Store.SetPhase(from, to); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.phase, e.loadedAt = to, time.Now()
+	e.mu.Unlock()
+
+	log.Printf("keys migration moved from %s to %s", from, to)
+	return nil
+}
+
+// listSource calls f with each page of the source keys in the state
+func (e *MigratingEntity) listSource(ctx context.Context, state KeyState, f func([]ShortKey) error) error {
+	lister, ok := app.As[KeyLister](e.Source)
+	if !ok {
+		return fmt.Errorf("migration source: %w", ErrInspectUnsupported)
+	}
+
+	cursor := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page, next, err := lister.ListKeys(state, cursor, cmp.Or(e.BatchSize, 1000))
+		if err != nil {
+			return fmt.Errorf("failed to list source %s keys: %w", state, err)
+		}
+
+		if err := f(page); err != nil {
+			return err
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func countStates(entity app.KeyValueEntity) (StateCounts, error) {
+	counter, ok := app.As[StateCounter](entity)
+	if !ok {
+		return StateCounts{}, ErrInspectUnsupported
+	}
+
+	stats, err := counter.CountStates(false)
+	return stats.StateCounts, err
+}
+
+// storages returns the storage serving the keys and
+// the one mirroring its writes, nil when none does
+func (e *MigratingEntity) storages() (app.KeyValueEntity, app.KeyValueEntity, error) {
+	phase, err := e.Phase()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get migration phase: %w", err)
+	}
+
+	switch phase {
+	case MigrationCopying:
+		return e.Source, e.Target, nil
+	case MigrationCutOver:
+		return e.Target, e.Source, nil
+	case MigrationDone:
+		return e.Target, nil, nil
+	default:
+		return e.Source, nil, nil
+	}
+}
+
+// serving returns the storage serving the keys
+func (e *MigratingEntity) serving() (app.KeyValueEntity, error) {
+	primary, _, err := e.storages()
+	return primary, err
+}
+
+// mirror applies a write to the mirror storage, failures
+// leave the storages out of sync until verified
+func (e *MigratingEntity) mirror(write string, f func() error) {
+	if err := f(); err != nil {
+		e.mirrorFailures.Add(1)
+		log.Printf("keys migration failed to mirror %s: %v", write, err)
+		return
+	}
+
+	e.mirrored.Add(1)
+}
+
+// mirrorTaken takes the key in the mirror storage too, failing with
+// ErrKeyTaken when it was taken there meanwhile, e.g. by a replica
+// serving the other storage while the phase moves
+func (e *MigratingEntity) mirrorTaken(mirror app.KeyValueEntity, key ShortKey, state KeyState) error {
+	reserver, ok := app.As[app.KeyValueReserver](mirror)
+	if !ok {
+		e.mirror("allocation", func() error { return ErrReserveUnsupported })
+		return nil
+	}
+
+	err := reserver.Reserve(&key)
+	if errors.Is(err, ErrKeyTaken) {
+		e.conflicts.Add(1)
+		log.Printf("keys migration skipped key %s taken by both storages", key)
+		return err
+	}
+
+	e.mirror("allocation", func() error {
+		if err != nil || state == KeyReserved {
+			return err
+		}
+
+		// Reserve marks the key reserved, allocations aren't
+		restorer, ok := app.As[KeyRestorer](mirror)
+		if !ok {
+			return nil
+		}
+
+		_, err := restorer.Restore(state, []ShortKey{key}, false)
+		return err
+	})
+
+	return nil
+}
+
+// releaseSkipped makes the keys skipped on conflicts available
+// again in the primary storage, whoever took them in the
+// mirror one keeping them
+func (e *MigratingEntity) releaseSkipped(primary app.KeyValueEntity, skipped []ShortKey) {
+	for _, key := range skipped {
+		if err := primary.Deallocate(&key); err != nil {
+			log.Printf("keys migration failed to release key %s skipped on a conflict: %v", key, err)
+		}
+	}
+}
+
+// Create persists a new key, in both storages while migrating
+func (e *MigratingEntity) Create(i interface{}) error {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return err
+	}
+
+	if err := primary.Create(i); err != nil {
+		return err
+	}
+
+	if mirror != nil {
+		e.mirror("creation", func() error {
+			_, err := createBatch(mirror, []interface{}{i})
+			return err
+		})
+	}
+
+	return nil
+}
+
+// CreateBatch persists many new keys, in both storages while migrating
+func (e *MigratingEntity) CreateBatch(items []interface{}) (int64, error) {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return 0, err
+	}
+
+	created, err := createBatch(primary, items)
+	if err != nil {
+		return created, err
+	}
+
+	if mirror != nil {
+		e.mirror("creations", func() error {
+			_, err := createBatch(mirror, items)
+			return err
+		})
+	}
+
+	return created, nil
+}
+
+// AllocateFirst allocates a key, taken in both storages while
+// migrating; keys taken by both at once are skipped, released
+// in the primary storage once allocated
+func (e *MigratingEntity) AllocateFirst() (interface{}, error) {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return nil, err
+	}
+
+	var skipped []ShortKey
+	defer func() { e.releaseSkipped(primary, skipped) }()
+
+	for range maxMirrorConflicts {
+		i, err := primary.AllocateFirst()
+		if err != nil || mirror == nil {
+			return i, err
+		}
+
+		key, ok := i.(ShortKey)
+		if !ok {
+			return nil, errors.New("could not convert allocated value into a key")
+		}
+
+		if err := e.mirrorTaken(mirror, key, KeyTaken); !errors.Is(err, ErrKeyTaken) {
+			return i, nil
+		}
+		skipped = append(skipped, key)
+	}
+
+	return nil, fmt.Errorf("failed to allocate a key taken by a single storage: %w", ErrKeyTaken)
+}
+
+// AllocateBatch allocates up to n keys, taken in both storages
+// while migrating; keys taken by both at once are skipped,
+// released in the primary storage
+func (e *MigratingEntity) AllocateBatch(n int) ([]interface{}, error) {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return nil, err
+	}
+
+	allocated, err := allocateBatch(primary, n)
+	if err != nil || mirror == nil {
+		return allocated, err
+	}
+
+	var skipped []ShortKey
+	defer func() { e.releaseSkipped(primary, skipped) }()
+
+	kept := allocated[:0]
+	for _, i := range allocated {
+		key, ok := i.(ShortKey)
+		if !ok {
+			return nil, errors.New("could not convert allocated value into a key")
+		}
+
+		if err := e.mirrorTaken(mirror, key, KeyTaken); errors.Is(err, ErrKeyTaken) {
+			skipped = append(skipped, key)
+			continue
+		}
+		kept = append(kept, i)
+	}
+
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("failed to allocate keys taken by a single storage: %w", ErrKeyTaken)
+	}
+
+	return kept, nil
+}
+
+// Reserve reserves a key, in both storages while migrating
+func (e *MigratingEntity) Reserve(i interface{}) error {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return err
+	}
+
+	reserver, ok := app.As[app.KeyValueReserver](primary)
+	if !ok {
+		return ErrReserveUnsupported
+	}
+
+	if err := reserver.Reserve(i); err != nil || mirror == nil {
+		return err
+	}
+
+	key, ok := i.(*ShortKey)
+	if !ok {
+		return errors.New("incompatible key type")
+	}
+
+	if err := e.mirrorTaken(mirror, *key, KeyReserved); err != nil {
+		return fmt.Errorf("failed to reserve the key: %w", err)
+	}
+
+	return nil
+}
+
+// Deallocate releases a key, in both storages while migrating;
+// the mirror storage may not know the key yet when copying
+func (e *MigratingEntity) Deallocate(i interface{}) error {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return err
+	}
+
+	if err := primary.Deallocate(i); err != nil || mirror == nil {
+		return err
+	}
+
+	e.mirror("release", func() error {
+		restorer, ok := app.As[KeyRestorer](mirror)
+		key, isKey := i.(*ShortKey)
+		if !ok || !isKey {
+			return mirror.Deallocate(i)
+		}
+
+		_, err := restorer.Restore(KeyAvailable, []ShortKey{*key}, false)
+		return err
+	})
+
+	return nil
+}
+
+// Ban bans a key, in both storages while migrating
+func (e *MigratingEntity) Ban(key ShortKey) error {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return err
+	}
+
+	banner, ok := app.As[KeyBanner](primary)
+	if !ok {
+		return ErrInspectUnsupported
+	}
+
+	if err := banner.Ban(key); err != nil || mirror == nil {
+		return err
+	}
+
+	e.mirror("ban", func() error {
+		banner, ok := app.As[KeyBanner](mirror)
+		if !ok {
+			return ErrInspectUnsupported
+		}
+
+		return banner.Ban(key)
+	})
+
+	return nil
+}
+
+// Quarantine quarantines a key, in both storages while
+// migrating; the mirror storage may not know the key yet
+func (e *MigratingEntity) Quarantine(key ShortKey) error {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return err
+	}
+
+	quarantiner, ok := app.As[KeyQuarantiner](primary)
+	if !ok {
+		return ErrInspectUnsupported
+	}
+
+	if err := quarantiner.Quarantine(key); err != nil || mirror == nil {
+		return err
+	}
+
+	e.mirror("quarantine", func() error {
+		restorer, ok := app.As[KeyRestorer](mirror)
+		if !ok {
+			return ErrInspectUnsupported
+		}
+
+		_, err := restorer.Restore(KeyQuarantined, []ShortKey{key}, false)
+		return err
+	})
+
+	return nil
+}
+
+// Lift lifts a key quarantine, in both storages while migrating
+func (e *MigratingEntity) Lift(key ShortKey) error {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return err
+	}
+
+	quarantiner, ok := app.As[KeyQuarantiner](primary)
+	if !ok {
+		return ErrInspectUnsupported
+	}
+
+	if err := quarantiner.Lift(key); err != nil || mirror == nil {
+		return err
+	}
+
+	e.mirror("lift", func() error {
+		restorer, ok := app.As[KeyRestorer](mirror)
+		if !ok {
+			return ErrInspectUnsupported
+		}
+
+		_, err := restorer.Restore(KeyAvailable, []ShortKey{key}, false)
+		return err
+	})
+
+	return nil
+}
+
+// Restore puts keys in a state, in both storages while migrating
+func (e *MigratingEntity) Restore(state KeyState, keys []ShortKey, keep bool) ([]ShortKey, error) {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return nil, err
+	}
+
+	restorer, ok := app.As[KeyRestorer](primary)
+	if !ok {
+		return nil, ErrInspectUnsupported
+	}
+
+	restored, err := restorer.Restore(state, keys, keep)
+	if err != nil || mirror == nil || len(restored) == 0 {
+		return restored, err
+	}
+
+	e.mirror("restore", func() error {
+		restorer, ok := app.As[KeyRestorer](mirror)
+		if !ok {
+			return ErrInspectUnsupported
+		}
+
+		_, err := restorer.Restore(state, restored, false)
+		return err
+	})
+
+	return restored, nil
+}
+
+// Clear removes every key, refused while migrating
+func (e *MigratingEntity) Clear() error {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return err
+	}
+
+	if mirror != nil {
+		return fmt.Errorf("%w: clearing keys while migrating", ErrMigrationPhase)
+	}
+
+	restorer, ok := app.As[KeyRestorer](primary)
+	if !ok {
+		return ErrInspectUnsupported
+	}
+
+	return restorer.Clear()
+}
+
+// Count counts the keys of the serving storage when supported
+func (e *MigratingEntity) Count() (int64, int64, error) {
+	primary, err := e.serving()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	counter, ok := app.As[app.KeyValueCounter](primary)
+	if !ok {
+		return 0, 0, errors.New("keys storage can't be counted")
+	}
+
+	return counter.Count()
+}
+
+// State looks up a key of the serving storage when supported
+func (e *MigratingEntity) State(key ShortKey) (KeyState, error) {
+	primary, err := e.serving()
+	if err != nil {
+		return "", err
+	}
+
+	inspector, ok := app.As[KeyInspector](primary)
+	if !ok {
+		return "", ErrInspectUnsupported
+	}
+
+	return inspector.State(key)
+}
+
+// Check checks the keys of the serving storage when supported
+func (e *MigratingEntity) Check() (ConsistencyReport, error) {
+	primary, err := e.serving()
+	if err != nil {
+		return ConsistencyReport{}, err
+	}
+
+	checker, ok := app.As[ConsistencyChecker](primary)
+	if !ok {
+		return ConsistencyReport{}, ErrInspectUnsupported
+	}
+
+	return checker.Check()
+}
+
+// CountStates counts the keys of the serving storage when supported
+func (e *MigratingEntity) CountStates(byLength bool) (PoolStats, error) {
+	primary, err := e.serving()
+	if err != nil {
+		return PoolStats{}, err
+	}
+
+	counter, ok := app.As[StateCounter](primary)
+	if !ok {
+		return PoolStats{}, ErrInspectUnsupported
+	}
+
+	return counter.CountStates(byLength)
+}
+
+// ListKeys lists the keys of the serving storage when supported
+func (e *MigratingEntity) ListKeys(state KeyState, cursor string, count int) ([]ShortKey, string, error) {
+	primary, err := e.serving()
+	if err != nil {
+		return nil, "", err
+	}
+
+	lister, ok := app.As[KeyLister](primary)
+	if !ok {
+		return nil, "", ErrInspectUnsupported
+	}
+
+	return lister.ListKeys(state, cursor, count)
+}
+
+// Members checks keys of the serving storage when supported
+func (e *MigratingEntity) Members(state KeyState, keys []ShortKey) ([]bool, error) {
+	primary, err := e.serving()
+	if err != nil {
+		return nil, err
+	}
+
+	repairer, ok := app.As[KeyRepairer](primary)
+	if !ok {
+		return nil, ErrInspectUnsupported
+	}
+
+	return repairer.Members(state, keys)
+}
+
+// Remove removes keys from a set, in both storages while migrating
+func (e *MigratingEntity) Remove(state KeyState, keys []ShortKey) error {
+	primary, mirror, err := e.storages()
+	if err != nil {
+		return err
+	}
+
+	repairer, ok := app.As[KeyRepairer](primary)
+	if !ok {
+		return ErrInspectUnsupported
+	}
+
+	if err := repairer.Remove(state, keys); err != nil || mirror == nil {
+		return err
+	}
+
+	e.mirror("removal", func() error {
+		repairer, ok := app.As[KeyRepairer](mirror)
+		if !ok {
+			return ErrInspectUnsupported
+		}
+
+		return repairer.Remove(state, keys)
+	})
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package keys
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type migrationStoreMock struct {
+	phase MigrationPhase
+}
+
+func (s *migrationStoreMock) Phase() (MigrationPhase, error) {
+	return s.phase, nil
+}
+
+func (s *migrationStoreMock) SetPhase(from, to MigrationPhase) error {
+	if s.phase != from {
+		return fmt.Errorf("%w: %s while %s", ErrMigrationPhase, to, s.phase)
+	}
+
+	s.phase = to
+	return nil
+}
+
+func allocatedKey(i interface{}) string {
+	k, _ := i.(ShortKey)
+	return string(k)
+}
+
+func newMigratingEntity(source, target map[string]KeyState, phase MigrationPhase) *MigratingEntity {
+	return &MigratingEntity{
+		Source:  newMemoryKeyValueEntityMock(source),
+		Target:  newMemoryKeyValueEntityMock(target),
+		Store:   &migrationStoreMock{phase: cmp.Or(phase, MigrationIdle)},
+		Refresh: time.Millisecond,
+	}
+}
+
+func TestMigratingEntity_GivenMigration(t *testing.T) {
+	ctx := context.Background()
+
+	e := newMigratingEntity(map[string]KeyState{
+		"aaaaaa": KeyAvailable,
+		"bbbbbb": KeyAvailable,
+		"cccccc": KeyAvailable,
+		"dddddd": KeyTaken,
+		"eeeeee": KeyReserved,
+		"ffffff": KeyBanned,
+		"gggggg": KeyQuarantined,
+	}, map[string]KeyState{"zzzzzz": KeyAvailable}, "")
+	source, target := e.Source.(*memoryKeyValueEntityMock), e.Target.(*memoryKeyValueEntityMock)
+
+	if copied, err := e.Start(ctx); err != nil || copied != 7 || target.state("gggggg") != KeyQuarantined {
+		t.Fatalf("Start() = %d, %v, target %v, want 7 keys copied", copied, err, target.sets)
+	}
+
+	if target.state("zzzzzz") != KeyUnknown {
+		t.Errorf("Start() kept %v, want the target cleared", target.sets)
+	}
+
+	k, err := e.AllocateFirst()
+	if err != nil || allocatedKey(k) != "aaaaaa" || target.state("aaaaaa") != KeyTaken {
+		t.Fatalf("AllocateFirst() = %v, %v, target %v, want aaaaaa taken in both storages", k, err, target.sets)
+	}
+
+	key := ShortKey("dddddd")
+	if err := e.Deallocate(&key); err != nil || target.state("dddddd") != KeyAvailable {
+		t.Fatalf("Deallocate() = %v, target %v, want dddddd available in both storages", err, target.sets)
+	}
+
+	if err := e.Lift(ShortKey("gggggg")); err != nil || target.state("gggggg") != KeyAvailable {
+		t.Fatalf("Lift() = %v, target %v, want gggggg available in both storages", err, target.sets)
+	}
+
+	report, err := e.CutOver(ctx)
+	if err != nil || !report.Consistent() || report.Checked != 7 {
+		t.Fatalf("CutOver() = %+v, %v, want 7 consistent keys", report, err)
+	}
+
+	if k, err := e.AllocateFirst(); err != nil || allocatedKey(k) != "bbbbbb" || source.state("bbbbbb") != KeyTaken {
+		t.Fatalf("AllocateFirst() = %v, %v, source %v, want bbbbbb taken in both storages", k, err, source.sets)
+	}
+
+	if phase, err := e.Rollback(); err != nil || phase != MigrationCopying {
+		t.Fatalf("Rollback() = %v, %v, want %v", phase, err, MigrationCopying)
+	}
+
+	if _, err := e.CutOver(ctx); err != nil {
+		t.Fatalf("CutOver() failed: %v", err)
+	}
+
+	if err := e.Finish(); err != nil {
+		t.Fatalf("Finish() failed: %v", err)
+	}
+
+	if k, err := e.AllocateFirst(); err != nil || allocatedKey(k) != "cccccc" || source.state("cccccc") != KeyAvailable {
+		t.Errorf("AllocateFirst() = %v, %v, source %v, want cccccc taken in the target only", k, err, source.sets)
+	}
+
+	if _, err := e.Rollback(); !errors.Is(err, ErrMigrationPhase) {
+		t.Errorf("Rollback() = %v, want %v once done", err, ErrMigrationPhase)
+	}
+
+	if stats := e.Stats(); stats.Phase != MigrationDone || stats.Mirrored != 4 || stats.MirrorFailures != 0 {
+		t.Errorf("Stats() = %+v, want 4 writes mirrored", stats)
+	}
+}
+
+func TestMigratingEntity_GivenConflict(t *testing.T) {
+	e := newMigratingEntity(
+		map[string]KeyState{"aaaaaa": KeyAvailable, "bbbbbb": KeyAvailable},
+		map[string]KeyState{"aaaaaa": KeyTaken, "bbbbbb": KeyAvailable},
+		MigrationCopying,
+	)
+
+	k, err := e.AllocateFirst()
+	if err != nil || allocatedKey(k) != "bbbbbb" {
+		t.Errorf("AllocateFirst() = %v, %v, want the key taken by the target skipped", k, err)
+	}
+
+	if _, err := e.AllocateFirst(); !errors.Is(err, ErrNoAvailableKeys) {
+		t.Errorf("AllocateFirst() = %v, want %v", err, ErrNoAvailableKeys)
+	}
+
+	if state := e.Source.(*memoryKeyValueEntityMock).state("aaaaaa"); state != KeyAvailable {
+		t.Errorf("State() = %v, want the skipped key available again in the source", state)
+	}
+
+	if stats := e.Stats(); stats.Conflicts != 2 {
+		t.Errorf("Stats() = %+v, want 2 conflicts", stats)
+	}
+}
+
+func TestMigratingEntity_GivenDivergedTarget(t *testing.T) {
+	e := newMigratingEntity(
+		map[string]KeyState{"aaaaaa": KeyAvailable, "bbbbbb": KeyTaken},
+		map[string]KeyState{"aaaaaa": KeyAvailable, "bbbbbb": KeyAvailable},
+		MigrationCopying,
+	)
+
+	report, err := e.CutOver(context.Background())
+	if !errors.Is(err, ErrMigrationDiverged) || report.Mismatched != 1 || string(report.Mismatches[0]) != "bbbbbb" {
+		t.Errorf("CutOver() = %+v, %v, want bbbbbb mismatched", report, err)
+	}
+
+	if phase, _ := e.Phase(); phase != MigrationCopying {
+		t.Errorf("Phase() = %v, want %v", phase, MigrationCopying)
+	}
+
+	if _, err := e.Copy(context.Background()); err != nil {
+		t.Fatalf("Copy() failed: %v", err)
+	}
+
+	if report, err := e.Verify(context.Background()); err == nil && report.Consistent() {
+		t.Errorf("Verify() = %+v, want copies to leave known keys alone", report)
+	}
+}
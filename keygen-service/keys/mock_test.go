@@ -0,0 +1,359 @@
+package keys
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	glideErrors "github.com/valkey-io/valkey-glide/go/api/errors"
+)
+
+// memoryKeyValueEntityMock keeps the members of each state set
+// in memory, taken including reserved, quarantined and banned keys
+// as with valkey, and allocates the available keys in order; sets
+// may be given anomalies, e.g. duplicated or malformed members
+type memoryKeyValueEntityMock struct {
+	mu   sync.Mutex
+	sets map[KeyState][]string
+
+	flaky       int           // allocations failing before any succeeds
+	lostReplies int           // deallocations losing their reply
+	latency     time.Duration // of each batch, as a db round trip
+
+	batches   int
+	listCount int // of the last listing
+}
+
+func newMemoryKeyValueEntityMock(states map[string]KeyState) *memoryKeyValueEntityMock {
+	e := &memoryKeyValueEntityMock{sets: map[KeyState][]string{}}
+	for _, k := range slices.Sorted(maps.Keys(states)) {
+		e.put(states[k], k)
+	}
+
+	return e
+}
+
+// generatedKeys gives n new available keys
+func generatedKeys(n int) map[string]KeyState {
+	states := map[string]KeyState{}
+	for len(states) < n {
+		k, _ := NextKey()
+		states[string(*k)] = KeyAvailable
+	}
+
+	return states
+}
+
+func (e *memoryKeyValueEntityMock) has(state KeyState, key string) bool {
+	return slices.Contains(e.sets[state], key)
+}
+
+func (e *memoryKeyValueEntityMock) add(state KeyState, key string) {
+	if !e.has(state, key) {
+		e.sets[state] = append(e.sets[state], key)
+	}
+}
+
+func (e *memoryKeyValueEntityMock) remove(state KeyState, key string) {
+	e.sets[state] = slices.DeleteFunc(e.sets[state], func(m string) bool { return m == key })
+}
+
+// put moves the key to the sets of the state
+func (e *memoryKeyValueEntityMock) put(state KeyState, key string) {
+	for s := range e.sets {
+		e.remove(s, key)
+	}
+
+	if state == KeyAvailable {
+		e.add(KeyAvailable, key)
+		return
+	}
+
+	e.add(KeyTaken, key)
+	if state != KeyTaken {
+		e.add(state, key)
+	}
+}
+
+func (e *memoryKeyValueEntityMock) known(key string) bool {
+	return e.has(KeyAvailable, key) || e.has(KeyTaken, key)
+}
+
+// count tells the available and taken keys
+func (e *memoryKeyValueEntityMock) count() (int, int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.sets[KeyAvailable]), len(e.sets[KeyTaken])
+}
+
+// state tells the state of the key, failures ignored
+func (e *memoryKeyValueEntityMock) state(key string) KeyState {
+	state, _ := e.State(ShortKey(key))
+	return state
+}
+
+func (e *memoryKeyValueEntityMock) Create(i interface{}) error {
+	k, ok := i.(*ShortKey)
+	if !ok {
+		return errors.New("incompatible key")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.known(string(*k)) {
+		return ErrKeyExists
+	}
+
+	e.add(KeyAvailable, string(*k))
+	return nil
+}
+
+func (e *memoryKeyValueEntityMock) CreateBatch(items []interface{}) (int64, error) {
+	time.Sleep(e.latency)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var created int64
+	for _, i := range items {
+		k, ok := i.(*ShortKey)
+		if !ok {
+			return created, errors.New("incompatible key")
+		}
+
+		if !e.known(string(*k)) {
+			e.add(KeyAvailable, string(*k))
+			created++
+		}
+	}
+
+	e.batches++
+	return created, nil
+}
+
+func (e *memoryKeyValueEntityMock) AllocateFirst() (interface{}, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.flaky > 0 {
+		e.flaky--
+		return nil, &glideErrors.ConnectionError{Msg: "connection refused"}
+	}
+
+	if len(e.sets[KeyAvailable]) == 0 {
+		return nil, ErrNoAvailableKeys
+	}
+
+	k := e.sets[KeyAvailable][0]
+	e.put(KeyTaken, k)
+
+	return ShortKey(k), nil
+}
+
+func (e *memoryKeyValueEntityMock) Reserve(i interface{}) error {
+	k, ok := i.(*ShortKey)
+	if !ok {
+		return errors.New("incompatible key")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.has(KeyTaken, string(*k)) {
+		return ErrKeyTaken
+	}
+
+	e.put(KeyReserved, string(*k))
+	return nil
+}
+
+func (e *memoryKeyValueEntityMock) Deallocate(i interface{}) error {
+	k, ok := i.(*ShortKey)
+	if !ok {
+		return errors.New("incompatible key")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch {
+	case e.has(KeyBanned, string(*k)):
+		return ErrKeyBanned
+	case e.has(KeyQuarantined, string(*k)):
+		return ErrKeyQuarantined
+	case !e.has(KeyTaken, string(*k)):
+		return ErrKeyNotFound
+	}
+
+	e.put(KeyAvailable, string(*k))
+
+	if e.lostReplies > 0 {
+		e.lostReplies--
+		return &glideErrors.ConnectionError{Msg: "connection reset"}
+	}
+
+	return nil
+}
+
+func (e *memoryKeyValueEntityMock) Count() (int64, int64, error) {
+	available, taken := e.count()
+	return int64(available), int64(taken), nil
+}
+
+func (e *memoryKeyValueEntityMock) State(key ShortKey) (KeyState, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, state := range []KeyState{KeyBanned, KeyQuarantined, KeyReserved, KeyTaken, KeyAvailable} {
+		if e.has(state, string(key)) {
+			return state, nil
+		}
+	}
+
+	return KeyUnknown, nil
+}
+
+func (e *memoryKeyValueEntityMock) Ban(key ShortKey) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.put(KeyBanned, string(key))
+	return nil
+}
+
+func (e *memoryKeyValueEntityMock) Quarantine(key ShortKey) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch {
+	case e.has(KeyBanned, string(key)):
+		return ErrKeyBanned
+	case e.has(KeyQuarantined, string(key)):
+		return nil
+	case e.has(KeyTaken, string(key)):
+		return ErrKeyTaken
+	}
+
+	e.put(KeyQuarantined, string(key))
+	return nil
+}
+
+func (e *memoryKeyValueEntityMock) Lift(key ShortKey) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.has(KeyQuarantined, string(key)) {
+		return ErrKeyNotFound
+	}
+
+	e.put(KeyAvailable, string(key))
+	return nil
+}
+
+func (e *memoryKeyValueEntityMock) Check() (ConsistencyReport, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	report := ConsistencyReport{Available: int64(len(e.sets[KeyAvailable])), Taken: int64(len(e.sets[KeyTaken]))}
+	for _, k := range e.sets[KeyAvailable] {
+		if e.has(KeyTaken, k) {
+			report.Duplicated++
+		}
+		if e.has(KeyBanned, k) {
+			report.BannedAvailable++
+		}
+	}
+
+	return report, nil
+}
+
+func (e *memoryKeyValueEntityMock) CountStates(bool) (PoolStats, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return PoolStats{StateCounts: StateCounts{
+		Available:   int64(len(e.sets[KeyAvailable])),
+		Taken:       int64(len(e.sets[KeyTaken])),
+		Reserved:    int64(len(e.sets[KeyReserved])),
+		Quarantined: int64(len(e.sets[KeyQuarantined])),
+		Banned:      int64(len(e.sets[KeyBanned])),
+	}}, nil
+}
+
+// ListKeys lists the set by pages of two, the
+// cursor being the first member of the next page
+func (e *memoryKeyValueEntityMock) ListKeys(state KeyState, cursor string, count int) ([]ShortKey, string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.listCount = count
+	members := slices.Sorted(slices.Values(e.sets[state]))
+
+	start := 0
+	if cursor != "" {
+		start, _ = slices.BinarySearch(members, cursor) // removed meanwhile or not
+	}
+
+	var page []ShortKey
+	for _, m := range members[start:min(start+2, len(members))] {
+		page = append(page, ShortKey(m))
+	}
+
+	if start+2 >= len(members) {
+		return page, "", nil
+	}
+
+	return page, members[start+2], nil
+}
+
+func (e *memoryKeyValueEntityMock) Members(state KeyState, keys []ShortKey) ([]bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	members := make([]bool, len(keys))
+	for i, k := range keys {
+		members[i] = e.has(state, string(k))
+	}
+
+	return members, nil
+}
+
+func (e *memoryKeyValueEntityMock) Remove(state KeyState, keys []ShortKey) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, k := range keys {
+		e.remove(state, string(k))
+	}
+
+	return nil
+}
+
+func (e *memoryKeyValueEntityMock) Restore(state KeyState, keys []ShortKey, keep bool) ([]ShortKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var restored []ShortKey
+	for _, k := range keys {
+		if keep && e.known(string(k)) {
+			continue
+		}
+
+		e.put(state, string(k))
+		restored = append(restored, k)
+	}
+
+	return restored, nil
+}
+
+func (e *memoryKeyValueEntityMock) Clear() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	clear(e.sets)
+	return nil
+}
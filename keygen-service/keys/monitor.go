@@ -0,0 +1,270 @@
+package keys
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"log"
+	"sync"
+	"time"
+)
+
+// KeySpaceMonitor tracks how much of the key space is
+// consumed, how often new keys collide with existing ones
+// and how fast keys are allocated, forecasting when keys
+// run out and optionally moving generation to longer keys
+type KeySpaceMonitor struct {
+	// EscalationThreshold is the collision rate, or fraction
+	// of consumed key space, that moves generation to the
+	// next key length; zero disables escalation
+	EscalationThreshold float64
+
+	// Smoothing weights the newest sample in the allocation
+	// rate estimate and TrendSmoothing in its trend, as in
+	// double exponential smoothing; 0.3 and 0.1 when zero
+	Smoothing      float64
+	TrendSmoothing float64
+
+	mu              sync.Mutex
+	length          int
+	creations       []bool
+	next            int
+	recorded        int
+	available       int64
+	allocated       int64
+	sampledAt       time.Time
+	rated           bool
+	allocationRate  float64
+	allocationTrend float64
+}
+
+// KeySpaceStats is a snapshot of a KeySpaceMonitor
+type KeySpaceStats struct {
+	KeyLength        int
+	Available        int64
+	Allocated        int64
+	ConsumedFraction float64
+	CollisionRate    float64
+	AllocationRate   float64 // keys per second
+	AllocationTrend  float64 // keys per second, per second
+	DaysToExhaustion float64 // negative while unknown
+}
+
+// NewKeySpaceMonitor returns a monitor generating MinKeyLength
+// keys, the collision rate is computed over the last window
+// creations
+func NewKeySpaceMonitor(window int, escalationThreshold float64) *KeySpaceMonitor {
+	return &KeySpaceMonitor{
+		EscalationThreshold: escalationThreshold,
+		length:              MinKeyLength,
+		creations:           make([]bool, max(window, 1)),
+	}
+}
+
+// RecordCreation registers a key creation attempt,
+// colliding when the key already existed; created
+// keys count as available until the next sample
+func (m *KeySpaceMonitor) RecordCreation(collided bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !collided {
+		m.available++
+	}
+
+	m.creations[m.next] = collided
+	m.next = (m.next + 1) % len(m.creations)
+	m.recorded = min(m.recorded+1, len(m.creations))
+
+	m.escalate()
+}
+
+// Sample refreshes the key counts and the allocation rate
+func (m *KeySpaceMonitor) Sample(counter app.KeyValueCounter, now time.Time) error {
+	available, allocated, err := counter.Count()
+	if err != nil {
+		return fmt.Errorf("failed to count keys: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.sampledAt.IsZero() && now.After(m.sampledAt) {
+		elapsed := now.Sub(m.sampledAt).Seconds()
+
+		// releases may shrink the allocated keys, that isn't demand
+		rate := max(float64(allocated-m.allocated)/elapsed, 0)
+
+		if !m.rated {
+			m.allocationRate, m.rated = rate, true
+		} else {
+			alpha, beta := cmp.Or(m.Smoothing, 0.3), cmp.Or(m.TrendSmoothing, 0.1)
+
+			previous := m.allocationRate
+			m.allocationRate = alpha*rate + (1-alpha)*(previous+m.allocationTrend*elapsed)
+			m.allocationTrend = beta*(m.allocationRate-previous)/elapsed + (1-beta)*m.allocationTrend
+		}
+	}
+
+	m.available, m.allocated, m.sampledAt = available, allocated, now
+
+	m.escalate()
+	return nil
+}
+
+// Watch should be launched in its own goroutine where
+// it samples the counted keys every interval
+func (m *KeySpaceMonitor) Watch(counter app.KeyValueCounter, interval time.Duration, ch chan error) {
+	for {
+		if err := m.Sample(counter, time.Now()); err != nil {
+			ch <- fmt.Errorf("failed to sample key space: %w", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// Stats returns the current key space figures
+func (m *KeySpaceMonitor) Stats() KeySpaceStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := KeySpaceStats{
+		KeyLength:        m.length,
+		Available:        m.available,
+		Allocated:        m.allocated,
+		ConsumedFraction: m.consumedFraction(),
+		CollisionRate:    m.collisionRate(),
+		AllocationRate:   m.allocationRate,
+		AllocationTrend:  m.allocationTrend,
+		DaysToExhaustion: -1,
+	}
+
+	if m.allocationRate > 0 {
+		remaining := float64(KeySpaceOffset(m.length+1)) - float64(m.allocated)
+		stats.DaysToExhaustion = remaining / m.allocationRate / (24 * time.Hour).Seconds()
+	}
+
+	return stats
+}
+
+// ForecastAllocations estimates how many keys will be
+// allocated within the horizon, following the allocation
+// rate and its trend
+func (m *KeySpaceMonitor) ForecastAllocations(horizon time.Duration) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := horizon.Seconds()
+
+	// the trend may take the rate to zero within the horizon
+	if m.allocationTrend < 0 && m.allocationRate+m.allocationTrend*h < 0 {
+		h = m.allocationRate / -m.allocationTrend
+	}
+
+	return max(m.allocationRate*h+m.allocationTrend*h*h/2, 0)
+}
+
+// Length returns the length of keys to generate
+func (m *KeySpaceMonitor) Length() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.length
+}
+
+// Generator returns a key generator following the
+// monitor length, built from a generator factory
+// such as NextKeyOfLength
+func (m *KeySpaceMonitor) Generator(factory func(length int) func() (*ShortKey, error)) func() (*ShortKey, error) {
+	return func() (*ShortKey, error) {
+		return factory(m.Length())()
+	}
+}
+
+func (m *KeySpaceMonitor) consumedFraction() float64 {
+	return float64(m.available+m.allocated) / float64(KeySpaceOffset(m.length+1))
+}
+
+func (m *KeySpaceMonitor) collisionRate() float64 {
+	if m.recorded == 0 {
+		return 0
+	}
+
+	collisions := 0
+	for _, collided := range m.creations[:m.recorded] {
+		if collided {
+			collisions++
+		}
+	}
+
+	return float64(collisions) / float64(m.recorded)
+}
+
+func (m *KeySpaceMonitor) escalate() {
+	if m.EscalationThreshold <= 0 || m.length >= MaxKeyLength {
+		return
+	}
+
+	fullWindow := m.recorded == len(m.creations)
+	if !(fullWindow && m.collisionRate() >= m.EscalationThreshold) && m.consumedFraction() < m.EscalationThreshold {
+		return
+	}
+
+	m.length++
+	m.next, m.recorded = 0, 0
+
+	log.Printf("keys generation escalated to %d characters keys", m.length)
+}
+
+// MonitoredEntity reports key creations of the
+// wrapped entity to a KeySpaceMonitor
+type MonitoredEntity struct {
+	app.KeyValueEntity
+
+	Monitor *KeySpaceMonitor
+}
+
+// Create persists a new key, recording collisions
+func (e *MonitoredEntity) Create(i interface{}) error {
+	err := e.KeyValueEntity.Create(i)
+	if err == nil || errors.Is(err, ErrKeyExists) {
+		e.Monitor.RecordCreation(err != nil)
+	}
+
+	return err
+}
+
+// CreateBatch persists many new keys, recording collisions,
+// one by one when the wrapped entity can't batch them
+func (e *MonitoredEntity) CreateBatch(items []interface{}) (int64, error) {
+	batchEntity, ok := app.As[app.KeyValueBatchEntity](e.KeyValueEntity)
+	if !ok {
+		var created int64
+		for _, i := range items {
+			if err := e.Create(i); err != nil && !errors.Is(err, ErrKeyExists) {
+				return created, err
+			} else if err == nil {
+				created++
+			}
+		}
+
+		return created, nil
+	}
+
+	created, err := batchEntity.CreateBatch(items)
+	if err == nil {
+		for n := range int64(len(items)) {
+			e.Monitor.RecordCreation(n >= created)
+		}
+	}
+
+	return created, err
+}
+
+// Unwrap returns the wrapped entity, exposing
+// the optional interfaces it implements
+func (e *MonitoredEntity) Unwrap() app.KeyValueEntity {
+	return e.KeyValueEntity
+}
@@ -0,0 +1,158 @@
+package keys
+
+import (
+	"errors"
+	"keygen-service/app"
+	"strings"
+	"testing"
+	"time"
+)
+
+type keyValueCounterMock struct {
+	available, allocated int64
+	err                  error
+}
+
+func (c *keyValueCounterMock) Count() (int64, int64, error) {
+	return c.available, c.allocated, c.err
+}
+
+func TestKeySpaceMonitor_GivenCollisions(t *testing.T) {
+	monitor := NewKeySpaceMonitor(4, 0)
+
+	for _, collided := range []bool{true, false, false, false, true} {
+		monitor.RecordCreation(collided)
+	}
+
+	// the first collision left the window
+	if got, want := monitor.Stats().CollisionRate, 0.25; got != want {
+		t.Errorf("Stats().CollisionRate = %v, want %v", got, want)
+	}
+}
+
+func TestKeySpaceMonitor_GivenAllocations(t *testing.T) {
+	monitor := NewKeySpaceMonitor(1, 0)
+	counter := &keyValueCounterMock{available: 100, allocated: 0}
+	now := time.Now()
+
+	if got := monitor.Stats().DaysToExhaustion; got >= 0 {
+		t.Errorf("Stats().DaysToExhaustion = %v before allocations, want negative", got)
+	}
+
+	if err := monitor.Sample(counter, now); err != nil {
+		t.Fatalf("Sample() failed: %v", err)
+	}
+
+	counter.allocated = 10
+	if err := monitor.Sample(counter, now.Add(time.Second)); err != nil {
+		t.Fatalf("Sample() failed: %v", err)
+	}
+
+	got := monitor.Stats()
+
+	if got.AllocationRate != 10 {
+		t.Errorf("Stats().AllocationRate = %v, want %v", got.AllocationRate, 10)
+	}
+
+	wantFraction := 110 / float64(KeySpaceSize(MinKeyLength))
+	if got.ConsumedFraction != wantFraction {
+		t.Errorf("Stats().ConsumedFraction = %v, want %v", got.ConsumedFraction, wantFraction)
+	}
+
+	wantDays := (float64(KeySpaceSize(MinKeyLength)) - 10) / 10 / (24 * time.Hour).Seconds()
+	if got.DaysToExhaustion != wantDays {
+		t.Errorf("Stats().DaysToExhaustion = %v, want %v", got.DaysToExhaustion, wantDays)
+	}
+}
+
+func TestKeySpaceMonitor_GivenFailingCounter(t *testing.T) {
+	monitor := NewKeySpaceMonitor(1, 0)
+
+	err := monitor.Sample(&keyValueCounterMock{err: errors.New("failing counter")}, time.Now())
+	if err == nil {
+		t.Fatal("Sample() = nil, want an error")
+	}
+
+	want := "failed to count keys"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("Sample() = %v, want %v", err, want)
+	}
+}
+
+func TestKeySpaceMonitor_GivenThresholdCrossed(t *testing.T) {
+	cases := []struct {
+		name      string
+		threshold float64
+		want      int
+	}{
+		{"escalation disabled", 0, MinKeyLength},
+		{"threshold not crossed", 0.9, MinKeyLength},
+		{"threshold crossed", 0.5, MinKeyLength + 1},
+	}
+
+	for _, c := range cases {
+		monitor := NewKeySpaceMonitor(2, c.threshold)
+
+		monitor.RecordCreation(true)
+		monitor.RecordCreation(false)
+
+		if got := monitor.Length(); got != c.want {
+			t.Errorf("Length() with %s = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestKeySpaceMonitor_GivenLongestKeys(t *testing.T) {
+	monitor := NewKeySpaceMonitor(1, 0.5)
+
+	for range MaxKeyLength - MinKeyLength + 1 {
+		monitor.RecordCreation(true)
+	}
+
+	if got := monitor.Length(); got != MaxKeyLength {
+		t.Errorf("Length() = %v, want %v", got, MaxKeyLength)
+	}
+
+	key, err := monitor.Generator(NextKeyOfLength)()
+	if err != nil {
+		t.Fatalf("Generator()() failed: %v", err)
+	}
+
+	if len(*key) != MaxKeyLength {
+		t.Errorf("Generator()() = %s, want a key with length %v", *key, MaxKeyLength)
+	}
+}
+
+func TestMonitoredEntity_GivenCollidingCreation(t *testing.T) {
+	monitor := NewKeySpaceMonitor(2, 0)
+	entity := &MonitoredEntity{KeyValueEntity: newMemoryKeyValueEntityMock(nil), Monitor: monitor}
+
+	key, _ := NextKey()
+	_ = entity.Create(key)
+	_ = entity.Create(key)
+
+	if got, want := monitor.Stats().CollisionRate, 0.5; got != want {
+		t.Errorf("Stats().CollisionRate = %v, want %v", got, want)
+	}
+}
+
+func TestMonitoredEntity_GivenOptionalInterfaces(t *testing.T) {
+	inner := newMemoryKeyValueEntityMock(nil)
+	lister := struct {
+		app.KeyValueEntity
+		KeyLister
+	}{inner, inner}
+	entity := &MonitoredEntity{KeyValueEntity: lister, Monitor: NewKeySpaceMonitor(1, 0)}
+
+	if got, ok := app.As[KeyLister](entity); !ok || got != lister {
+		t.Errorf("As[KeyLister]() = %v, %v, want the wrapped entity", got, ok)
+	}
+
+	if got, ok := app.As[app.KeyValueBatchEntity](entity); !ok || got != entity {
+		t.Errorf("As[KeyValueBatchEntity]() = %v, %v, want the monitored entity", got, ok)
+	}
+
+	if _, ok := app.As[KeyRepairer](entity); ok {
+		t.Errorf("As[KeyRepairer]() succeeded, want no repairer")
+	}
+}
@@ -0,0 +1,193 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"regexp"
+	"sync"
+)
+
+var (
+	// ErrNamespaceNotFound is returned for unknown namespaces
+	ErrNamespaceNotFound = errors.New("namespace not found")
+
+	// ErrNamespaceExists is returned when creating
+	// a namespace that is already known
+	ErrNamespaceExists = errors.New("namespace already exists")
+
+	// ErrNamespaceRetired is returned when allocating
+	// keys of a retired namespace
+	ErrNamespaceRetired = errors.New("namespace retired")
+
+	// ErrInvalidNamespace is returned when creating a
+	// namespace with an invalid name or policies
+	ErrInvalidNamespace = errors.New("invalid namespace")
+)
+
+// DefaultNamespaceMinAvailable keys are kept available
+// for namespaces created without MinAvailable
+const DefaultNamespaceMinAvailable = 1000
+
+// namespaceName allows domain-like names
+var namespaceName = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{0,62}$`)
+
+// KeyNamespace is an independent pool of keys, e.g. of a
+// branded domain; the same key may exist in two namespaces
+type KeyNamespace struct {
+	Name string
+
+	// MinAvailable keys are kept available by generation,
+	// DefaultNamespaceMinAvailable when created with zero
+	MinAvailable int64
+
+	// KeyLength of generated keys, MinKeyLength when zero
+	KeyLength int
+
+	// Retired namespaces serve no more keys
+	Retired bool
+}
+
+// Validate checks the namespace name and policies
+func (n KeyNamespace) Validate() error {
+	if !namespaceName.MatchString(n.Name) {
+		return fmt.Errorf("%w name %q: lowercase letters, digits, dots and dashes only", ErrInvalidNamespace, n.Name)
+	}
+
+	if n.MinAvailable < 0 {
+		return fmt.Errorf("%w min available %d", ErrInvalidNamespace, n.MinAvailable)
+	}
+
+	if n.KeyLength != 0 && (n.KeyLength < MinKeyLength || n.KeyLength > MaxKeyLength) {
+		return fmt.Errorf("%w key length %d", ErrInvalidNamespace, n.KeyLength)
+	}
+
+	return nil
+}
+
+// NamespaceRegistry keeps the namespaces configuration
+type NamespaceRegistry interface {
+	// Create saves a new namespace, failing
+	// with ErrNamespaceExists when known
+	Create(KeyNamespace) error
+
+	// Get returns the namespace or ErrNamespaceNotFound
+	Get(name string) (KeyNamespace, error)
+
+	// List returns every namespace, retired ones included
+	List() ([]KeyNamespace, error)
+
+	// Update saves a known namespace
+	Update(KeyNamespace) error
+}
+
+// Namespaces serves the keys of each namespace, the
+// default namespace, named "", being always there
+type Namespaces struct {
+	Registry NamespaceRegistry
+
+	// Storage returns the keys storage of a namespace
+	Storage func(namespace string) app.KeyValueEntity
+
+	// Default allocates keys of the default namespace
+	Default *Allocator
+
+	// Owners, when set, returns the ownership
+	// records of a namespace
+	Owners func(namespace string) Ownership
+
+	// Audit, when set, returns the audit
+	// log of a namespace
+	Audit func(namespace string) AuditLog
+
+	mu         sync.Mutex
+	allocators map[string]*Allocator
+}
+
+// Create registers a new namespace
+func (n *Namespaces) Create(namespace KeyNamespace) (KeyNamespace, error) {
+	if err := namespace.Validate(); err != nil {
+		return KeyNamespace{}, err
+	}
+
+	namespace.Retired = false
+	if namespace.MinAvailable == 0 {
+		namespace.MinAvailable = DefaultNamespaceMinAvailable
+	}
+
+	if err := n.Registry.Create(namespace); err != nil {
+		return KeyNamespace{}, fmt.Errorf("failed to create namespace %s: %w", namespace.Name, err)
+	}
+
+	return namespace, nil
+}
+
+// List returns the registered namespaces
+func (n *Namespaces) List() ([]KeyNamespace, error) {
+	namespaces, err := n.Registry.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	return namespaces, nil
+}
+
+// Retire stops serving keys of a namespace,
+// its keys may still be released
+func (n *Namespaces) Retire(name string) (KeyNamespace, error) {
+	namespace, err := n.Registry.Get(name)
+	if err != nil {
+		return KeyNamespace{}, fmt.Errorf("failed to retire namespace %s: %w", name, err)
+	}
+
+	namespace.Retired = true
+	if err := n.Registry.Update(namespace); err != nil {
+		return KeyNamespace{}, fmt.Errorf("failed to retire namespace %s: %w", name, err)
+	}
+
+	return namespace, nil
+}
+
+// Allocator returns the allocator of a namespace, failing
+// for unknown namespaces or, when allocating, retired ones
+func (n *Namespaces) Allocator(name string, allocating bool) (*Allocator, error) {
+	if name == "" {
+		return n.Default, nil
+	}
+
+	namespace, err := n.Registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if allocating && namespace.Retired {
+		return nil, fmt.Errorf("%w: %s", ErrNamespaceRetired, name)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.allocators == nil {
+		n.allocators = map[string]*Allocator{}
+	}
+
+	allocator, ok := n.allocators[name]
+	if !ok {
+		allocator = NewAllocator(n.Storage(name), nil)
+		allocator.Backoff = n.Default.Backoff
+		allocator.Events, allocator.Namespace = n.Default.Events, name
+		if n.Default.Replenishment != nil {
+			allocator.Replenishment = n.Default.Replenishment.sibling(name)
+			allocator.MaxWait = n.Default.MaxWait
+		}
+		if n.Owners != nil {
+			allocator.Owners, allocator.AllowUnowned = n.Owners(name), n.Default.AllowUnowned
+		}
+		if n.Audit != nil {
+			allocator.Audit = n.Audit(name)
+		}
+		n.allocators[name] = allocator
+	}
+
+	return allocator, nil
+}
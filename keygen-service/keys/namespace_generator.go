@@ -0,0 +1,144 @@
+package keys
+
+import (
+	"context"
+	"fmt"
+	"keygen-service/app"
+	"time"
+)
+
+// StockPlanner keeps MinAvailable keys available
+// in a storage able to count them
+type StockPlanner struct {
+	Counter      app.KeyValueCounter
+	MinAvailable int64
+}
+
+// Deficit returns how many keys are missing, none
+// while the storage can't be counted
+func (s *StockPlanner) Deficit() int64 {
+	available, _, err := s.Counter.Count()
+	if err != nil {
+		return 0
+	}
+
+	return max(s.MinAvailable-available, 0)
+}
+
+// NamespaceGenerators runs a GeneratorPool per active
+// namespace, keeping its MinAvailable keys available;
+// pools follow the registry, checked every Interval
+type NamespaceGenerators struct {
+	Namespaces *Namespaces
+
+	// Pool returns a pool configured but for its keys
+	// and supply, set from the namespace
+	Pool func() *GeneratorPool
+
+	// Generator returns the key generator of a namespace
+	Generator func(namespace KeyNamespace) func() (*ShortKey, error)
+
+	Interval time.Duration
+}
+
+// namespaceGeneration is a running namespace pool
+type namespaceGeneration struct {
+	namespace KeyNamespace
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// Run should be launched in its own goroutine where it
+// generates keys of the namespaces until ctx is done,
+// when it stops every pool and closes the channel
+func (g *NamespaceGenerators) Run(ctx context.Context, ch chan error) {
+	defer close(ch)
+
+	running := map[string]*namespaceGeneration{}
+	stop := func(name string) {
+		running[name].cancel()
+		<-running[name].done
+		delete(running, name)
+	}
+
+	ticker := time.NewTicker(max(g.Interval, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		namespaces, err := g.Namespaces.List()
+		if err != nil {
+			select {
+			case ch <- err:
+			case <-ctx.Done():
+			}
+		}
+
+		active := map[string]bool{}
+		for _, namespace := range namespaces {
+			if namespace.Retired || namespace.MinAvailable == 0 {
+				continue
+			}
+			active[namespace.Name] = true
+
+			if r, ok := running[namespace.Name]; ok && r.namespace == namespace {
+				continue
+			} else if ok { // policies changed
+				stop(namespace.Name)
+			}
+
+			running[namespace.Name] = g.start(ctx, namespace, ch)
+		}
+
+		for name := range running {
+			if err == nil && !active[name] {
+				stop(name)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			for name := range running {
+				stop(name)
+			}
+
+			return
+		}
+	}
+}
+
+// start launches the namespace pool, forwarding its errors
+func (g *NamespaceGenerators) start(ctx context.Context, namespace KeyNamespace, ch chan error) *namespaceGeneration {
+	storage := g.Namespaces.Storage(namespace.Name)
+
+	pool := g.Pool()
+	pool.Keys = storage
+	pool.Namespace = namespace.Name
+	if g.Namespaces.Default != nil && g.Namespaces.Default.Replenishment != nil {
+		if allocator, err := g.Namespaces.Allocator(namespace.Name, false); err == nil {
+			pool.Replenishment = allocator.Replenishment
+		}
+	}
+	if counter, ok := app.As[app.KeyValueCounter](storage); ok {
+		pool.Supply = &StockPlanner{Counter: counter, MinAvailable: namespace.MinAvailable}
+	}
+
+	poolCtx, cancel := context.WithCancel(ctx)
+	r := &namespaceGeneration{namespace: namespace, cancel: cancel, done: make(chan struct{})}
+
+	poolCh := make(chan error)
+	go pool.GenerateKeys(poolCtx, g.Generator(namespace), poolCh)
+
+	go func() {
+		defer close(r.done)
+
+		for e := range poolCh {
+			select {
+			case ch <- fmt.Errorf("namespace %s: %w", namespace.Name, e):
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return r
+}
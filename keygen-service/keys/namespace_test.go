@@ -0,0 +1,274 @@
+package keys
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"keygen-service/app"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// namespaceRegistryMock keeps namespaces in memory
+type namespaceRegistryMock struct {
+	mu         sync.Mutex
+	namespaces map[string]KeyNamespace
+}
+
+func (r *namespaceRegistryMock) Create(namespace KeyNamespace) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.namespaces == nil {
+		r.namespaces = map[string]KeyNamespace{}
+	}
+
+	if _, ok := r.namespaces[namespace.Name]; ok {
+		return ErrNamespaceExists
+	}
+
+	r.namespaces[namespace.Name] = namespace
+	return nil
+}
+
+func (r *namespaceRegistryMock) Get(name string) (KeyNamespace, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	namespace, ok := r.namespaces[name]
+	if !ok {
+		return KeyNamespace{}, ErrNamespaceNotFound
+	}
+
+	return namespace, nil
+}
+
+func (r *namespaceRegistryMock) List() ([]KeyNamespace, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var namespaces []KeyNamespace
+	for _, namespace := range r.namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+
+	return namespaces, nil
+}
+
+func (r *namespaceRegistryMock) Update(namespace KeyNamespace) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.namespaces[namespace.Name] = namespace
+	return nil
+}
+
+// newTestNamespaces serves namespaces with a storage
+// holding the same keys, as given, in every namespace
+func newTestNamespaces(available ...ShortKey) *Namespaces {
+	var mu sync.Mutex
+	storages := map[string]*memoryKeyValueEntityMock{}
+
+	return &Namespaces{
+		Registry: &namespaceRegistryMock{},
+		Storage: func(namespace string) app.KeyValueEntity {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if _, ok := storages[namespace]; !ok {
+				storages[namespace] = newMemoryKeyValueEntityMock(nil)
+				for _, k := range available {
+					_ = storages[namespace].Create(&k)
+				}
+			}
+
+			return storages[namespace]
+		},
+		Default: NewAllocator(newMemoryKeyValueEntityMock(generatedKeys(1)), nil),
+	}
+}
+
+func TestNamespaces_GivenNewNamespace(t *testing.T) {
+	namespaces := newTestNamespaces()
+
+	got, err := namespaces.Create(KeyNamespace{Name: "brand.example.com", KeyLength: MaxKeyLength})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	if got.MinAvailable != DefaultNamespaceMinAvailable {
+		t.Errorf("Create() = %+v, want %v min available", got, DefaultNamespaceMinAvailable)
+	}
+
+	if _, err := namespaces.Create(KeyNamespace{Name: "brand.example.com"}); !errors.Is(err, ErrNamespaceExists) {
+		t.Errorf("Create() = %v, want %v", err, ErrNamespaceExists)
+	}
+
+	for _, invalid := range []KeyNamespace{{Name: ""}, {Name: "Brand:1"}, {Name: "brand", KeyLength: 3}, {Name: "brand", MinAvailable: -1}} {
+		if _, err := namespaces.Create(invalid); err == nil {
+			t.Errorf("Create(%+v) succeeded, want a validation error", invalid)
+		}
+	}
+
+	list, err := namespaces.List()
+	if err != nil || len(list) != 1 {
+		t.Errorf("List() = %v, %v, want the created namespace", list, err)
+	}
+}
+
+func TestNamespaces_GivenRetiredNamespace(t *testing.T) {
+	namespaces := newTestNamespaces(ShortKey("testk1"))
+	if _, err := namespaces.Create(KeyNamespace{Name: "brand"}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	allocator, err := namespaces.Allocator("brand", true)
+	if err != nil {
+		t.Fatalf("Allocator() failed: %v", err)
+	}
+
+	k, err := allocator.Allocate(context.Background())
+	if err != nil {
+		t.Fatalf("Allocate() failed: %v", err)
+	}
+
+	if got, err := namespaces.Retire("brand"); err != nil || !got.Retired {
+		t.Fatalf("Retire() = %+v, %v, want a retired namespace", got, err)
+	}
+
+	if _, err := namespaces.Allocator("brand", true); !errors.Is(err, ErrNamespaceRetired) {
+		t.Errorf("Allocator() = %v, want %v", err, ErrNamespaceRetired)
+	}
+
+	allocator, err = namespaces.Allocator("brand", false)
+	if err != nil {
+		t.Fatalf("Allocator() failed: %v", err)
+	}
+
+	if err := allocator.Release(context.Background(), k); err != nil {
+		t.Errorf("Release() = %v, want keys of retired namespaces released", err)
+	}
+
+	if _, err := namespaces.Retire("unknown"); !errors.Is(err, ErrNamespaceNotFound) {
+		t.Errorf("Retire() = %v, want %v", err, ErrNamespaceNotFound)
+	}
+}
+
+func TestRPCHandler_GivenNamespaces(t *testing.T) {
+	namespaces := newTestNamespaces(ShortKey("testk1"))
+	handler := &RPCHandler{Allocator: namespaces.Default, Namespaces: namespaces}
+	admin := &AdminRPCHandler{Namespaces: namespaces}
+
+	for _, name := range []string{"brand1", "brand2"} {
+		if _, err := admin.CreateNamespace(context.Background(), &Namespace{Name: name}); err != nil {
+			t.Fatalf("CreateNamespace() failed: %v", err)
+		}
+	}
+
+	// the same key lives independently in each namespace
+	for _, name := range []string{"brand1", "brand2"} {
+		res, err := handler.GetKey(context.Background(), &GetKeyRequest{Namespace: name})
+		if err != nil {
+			t.Fatalf("GetKey() failed: %v", err)
+		}
+
+		if string(res.Key) != "testk1" {
+			t.Errorf("GetKey() = %s, want testk1 in %s", res.Key, name)
+		}
+	}
+
+	if _, err := handler.GetKey(context.Background(), &GetKeyRequest{Namespace: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetKey() = %v, want %v", err, codes.NotFound)
+	}
+
+	if _, err := admin.CreateNamespace(context.Background(), &Namespace{Name: "Brand!"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateNamespace() = %v, want %v", err, codes.InvalidArgument)
+	}
+
+	if _, err := admin.CreateNamespace(context.Background(), &Namespace{Name: "brand1"}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("CreateNamespace() = %v, want %v", err, codes.AlreadyExists)
+	}
+
+	if _, err := admin.RetireNamespace(context.Background(), &NamespaceRequest{Name: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("RetireNamespace() = %v, want %v", err, codes.NotFound)
+	}
+
+	if _, err := admin.RetireNamespace(context.Background(), &NamespaceRequest{Name: "brand1"}); err != nil {
+		t.Fatalf("RetireNamespace() failed: %v", err)
+	}
+
+	list, err := admin.ListNamespaces(context.Background(), &Void{})
+	if err != nil || len(list.Namespaces) != 2 {
+		t.Fatalf("ListNamespaces() = %v, %v, want 2 namespaces", list, err)
+	}
+
+	if _, err := handler.ReleaseKey(context.Background(), &KeyRequest{Key: []byte("testk1"), Namespace: "brand1"}); err != nil {
+		t.Errorf("ReleaseKey() = %v, want keys of retired namespaces released", err)
+	}
+}
+
+func TestNamespaceGenerators_GivenNamespaces(t *testing.T) {
+	var mu sync.Mutex
+	storages := map[string]*memoryKeyValueEntityMock{}
+
+	namespaces := &Namespaces{
+		Registry: &namespaceRegistryMock{},
+		Storage: func(namespace string) app.KeyValueEntity {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if _, ok := storages[namespace]; !ok {
+				storages[namespace] = newMemoryKeyValueEntityMock(nil)
+			}
+
+			return storages[namespace]
+		},
+	}
+
+	for _, namespace := range []KeyNamespace{{Name: "brand1", MinAvailable: 20}, {Name: "brand2", MinAvailable: 30, KeyLength: MaxKeyLength}, {Name: "retired"}} {
+		if _, err := namespaces.Create(namespace); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+	}
+
+	if _, err := namespaces.Retire("retired"); err != nil {
+		t.Fatalf("Retire() failed: %v", err)
+	}
+
+	generators := &NamespaceGenerators{
+		Namespaces: namespaces,
+		Pool:       func() *GeneratorPool { return &GeneratorPool{BatchSize: 5, Buffer: 5} },
+		Generator: func(namespace KeyNamespace) func() (*ShortKey, error) {
+			return NextKeyOfLength(cmp.Or(namespace.KeyLength, MinKeyLength))
+		},
+		Interval: time.Millisecond,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan error)
+	go generators.Run(ctx, ch)
+
+	for e := range ch {
+		t.Errorf("Run() sent %v, want no errors", e)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for name, want := range map[string]int{"brand1": 20, "brand2": 30} {
+		if got, _ := storages[name].count(); got != want {
+			t.Errorf("Run() created %v keys in %s, want %v", got, name, want)
+		}
+	}
+
+	if _, ok := storages["retired"]; ok {
+		t.Error("Run() generated keys of a retired namespace, want none")
+	}
+}
@@ -0,0 +1,196 @@
+package keys
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+)
+
+const KeysOwnersName = "keysOwners"
+
+// ErrNotOwner is returned when releasing a key
+// without the token of its allocation
+var ErrNotOwner = errors.New("key not owned by the caller")
+
+// Ownership keeps the token of each allocated key,
+// proving who allocated it
+type Ownership interface {
+	// Own records the token of an allocated key
+	Own(key ShortKey, token string) error
+
+	// Owner returns the token of an allocated
+	// key, empty when none was recorded
+	Owner(key ShortKey) (string, error)
+
+	// Disown forgets the token of a released key when it is
+	// the given one, failing with ErrNotOwner when another one
+	// is recorded; returns whether the token was recorded
+	Disown(key ShortKey, token string) (bool, error)
+}
+
+// BatchOwnership is implemented by ownerships able
+// to handle the tokens of many keys at once
+type BatchOwnership interface {
+	// Owners returns the tokens of the keys,
+	// empty for keys without token
+	Owners(keys []ShortKey) ([]string, error)
+
+	// OwnAll records the tokens of the keys
+	OwnAll(tokens map[string]string) error
+
+	// Clear forgets every token
+	Clear() error
+}
+
+// NewOwnershipToken returns an unguessable token
+func NewOwnershipToken() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate ownership token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// disownScript deletes the ARGV[1] field of the KEYS[1] hash
+// when its value is ARGV[2]; returns 1 when deleted, 0 when
+// missing and -1 when holding another value
+const disownScript = `
+local owner = redis.call('HGET', KEYS[1], ARGV[1])
+if not owner then
+  return 0
+end
+if owner ~= ARGV[2] then
+  return -1
+end
+redis.call('HDEL', KEYS[1], ARGV[1])
+return 1`
+
+// ValkeyOwnership keeps the tokens in
+// a hash of the allocated keys
+type ValkeyOwnership struct {
+	// Client connects to the db, required
+	Client app.KeyValueDbClient
+
+	// Namespace of the keys, the default one when empty
+	Namespace string
+}
+
+// Own records the token of an allocated key
+func (v *ValkeyOwnership) Own(key ShortKey, token string) error {
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return err
+	}
+
+	if _, err := valkeyClient.HSet(v.ownersName(), map[string]string{string(key): token}); err != nil {
+		return fmt.Errorf("failed to record key owner: %w", err)
+	}
+
+	return nil
+}
+
+// Owner returns the token of an allocated key
+func (v *ValkeyOwnership) Owner(key ShortKey) (string, error) {
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := valkeyClient.HGet(v.ownersName(), string(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to get key owner: %w", err)
+	}
+
+	return res.Value(), nil
+}
+
+// Disown forgets the token of a released key when it is
+// the given one, comparing and deleting it in a script
+func (v *ValkeyOwnership) Disown(key ShortKey, token string) (bool, error) {
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := valkeyClient.CustomCommand([]string{"EVAL", disownScript, "1", v.ownersName(), string(key), token})
+	if err != nil {
+		return false, fmt.Errorf("failed to forget key owner: %w", err)
+	}
+
+	switch res, _ := res.(int64); res {
+	case 1:
+		return true, nil
+	case -1:
+		return false, fmt.Errorf("%w: %s", ErrNotOwner, key)
+	default:
+		return false, nil
+	}
+}
+
+// Owners returns the tokens of the keys at once
+func (v *ValkeyOwnership) Owners(keys []ShortKey) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, len(keys))
+	for i, k := range keys {
+		fields[i] = string(k)
+	}
+
+	res, err := valkeyClient.HMGet(v.ownersName(), fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keys owners: %w", err)
+	}
+
+	tokens := make([]string, len(res))
+	for i, r := range res {
+		tokens[i] = r.Value()
+	}
+
+	return tokens, nil
+}
+
+// OwnAll records the tokens of the keys at once
+func (v *ValkeyOwnership) OwnAll(tokens map[string]string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return err
+	}
+
+	if _, err := valkeyClient.HSet(v.ownersName(), tokens); err != nil {
+		return fmt.Errorf("failed to record keys owners: %w", err)
+	}
+
+	return nil
+}
+
+// Clear forgets every token
+func (v *ValkeyOwnership) Clear() error {
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return err
+	}
+
+	if _, err := valkeyClient.Del([]string{v.ownersName()}); err != nil {
+		return fmt.Errorf("failed to clear keys owners: %w", err)
+	}
+
+	return nil
+}
+
+func (v *ValkeyOwnership) ownersName() string {
+	return namespacedName(KeysOwnersName, v.Namespace)
+}
@@ -0,0 +1,263 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ownershipMock keeps the tokens in memory
+type ownershipMock struct {
+	mu     sync.Mutex
+	owners map[string]string
+	err    error
+}
+
+func (o *ownershipMock) Own(key ShortKey, token string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.err != nil {
+		return o.err
+	}
+
+	if o.owners == nil {
+		o.owners = map[string]string{}
+	}
+
+	o.owners[string(key)] = token
+	return nil
+}
+
+func (o *ownershipMock) Owner(key ShortKey) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.owners[string(key)], nil
+}
+
+func (o *ownershipMock) Disown(key ShortKey, token string) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	owner, ok := o.owners[string(key)]
+	if ok && owner != token {
+		return false, fmt.Errorf("%w: %s", ErrNotOwner, key)
+	}
+
+	delete(o.owners, string(key))
+	return ok, nil
+}
+
+func TestAllocator_GivenOwners(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(2))
+	owners := &ownershipMock{}
+	allocator := NewAllocator(entity, nil)
+	allocator.Owners = owners
+
+	key, token, err := allocator.AllocateOwned(context.Background())
+	if err != nil || token == "" {
+		t.Fatalf("AllocateOwned() = %s, %q, %v, want a key and its token", key, token, err)
+	}
+
+	for _, forged := range []string{"", "forged"} {
+		if err := allocator.ReleaseOwned(context.Background(), key, forged); !errors.Is(err, ErrNotOwner) {
+			t.Errorf("ReleaseOwned() = %v, want %v", err, ErrNotOwner)
+		}
+	}
+
+	if err := allocator.ReleaseOwned(context.Background(), key, token); err != nil {
+		t.Fatalf("ReleaseOwned() failed: %v", err)
+	}
+
+	if owner, _ := owners.Owner(key); owner != "" {
+		t.Errorf("ReleaseOwned() kept owner %q, want the token forgotten", owner)
+	}
+
+	if err := allocator.ReleaseOwned(context.Background(), key, token); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("ReleaseOwned() = %v, want the token of a released key rejected", err)
+	}
+
+	// keys allocated before ownership only get released by force
+	legacy, err := allocator.Allocate(context.Background())
+	if err != nil {
+		t.Fatalf("Allocate() failed: %v", err)
+	}
+
+	if err := allocator.ReleaseOwned(context.Background(), legacy, ""); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("ReleaseOwned() = %v, want %v", err, ErrNotOwner)
+	}
+
+	if err := allocator.Release(context.Background(), legacy); err != nil {
+		t.Errorf("Release() = %v, want the key released by force", err)
+	}
+
+	if stats := allocator.Stats(); stats.Denied != 4 || stats.Released != 2 {
+		t.Errorf("Stats() = %+v, want 4 denied and 2 released", stats)
+	}
+}
+
+func TestAllocator_GivenUnownedKeysAllowed(t *testing.T) {
+	allocator := NewAllocator(newMemoryKeyValueEntityMock(generatedKeys(2)), nil)
+	allocator.Owners, allocator.AllowUnowned = &ownershipMock{}, true
+
+	legacy, err := allocator.Allocate(context.Background())
+	if err != nil {
+		t.Fatalf("Allocate() failed: %v", err)
+	}
+
+	if err := allocator.ReleaseOwned(context.Background(), legacy, ""); err != nil {
+		t.Errorf("ReleaseOwned() = %v, want keys allocated before ownership released", err)
+	}
+
+	key, _, err := allocator.AllocateOwned(context.Background())
+	if err != nil {
+		t.Fatalf("AllocateOwned() failed: %v", err)
+	}
+
+	if err := allocator.ReleaseOwned(context.Background(), key, "forged"); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("ReleaseOwned() = %v, want %v for owned keys", err, ErrNotOwner)
+	}
+}
+
+func TestAllocator_GivenFailingOwners(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(1))
+	allocator := NewAllocator(entity, nil)
+	allocator.Owners = &ownershipMock{err: errors.New("incompatible owner")}
+
+	if _, _, err := allocator.AllocateOwned(context.Background()); err == nil {
+		t.Fatal("AllocateOwned() succeeded, want the owner failure")
+	}
+
+	if available, taken := entity.count(); available != 1 || taken != 0 {
+		t.Errorf("AllocateOwned() left %v available and %v taken keys, want the key released", available, taken)
+	}
+}
+
+// reallocatingKeyValueEntityMock allocates each deallocated
+// key again at once, to another owner
+type reallocatingKeyValueEntityMock struct {
+	*memoryKeyValueEntityMock
+
+	owners *ownershipMock
+}
+
+func (e *reallocatingKeyValueEntityMock) Deallocate(i interface{}) error {
+	if err := e.memoryKeyValueEntityMock.Deallocate(i); err != nil {
+		return err
+	}
+
+	k, _ := i.(*ShortKey)
+	if err := e.Reserve(k); err != nil {
+		return err
+	}
+
+	return e.owners.Own(*k, "next")
+}
+
+func TestAllocator_GivenKeyAllocatedAgainOnRelease(t *testing.T) {
+	owners := &ownershipMock{}
+	allocator := NewAllocator(&reallocatingKeyValueEntityMock{newMemoryKeyValueEntityMock(generatedKeys(2)), owners}, nil)
+	allocator.Owners = owners
+
+	for name, release := range map[string]func(ShortKey, string) error{
+		"ReleaseOwned": func(key ShortKey, token string) error {
+			return allocator.ReleaseOwned(context.Background(), key, token)
+		},
+		"Release": func(key ShortKey, _ string) error { return allocator.Release(context.Background(), key) },
+	} {
+		key, token, err := allocator.AllocateOwned(context.Background())
+		if err != nil {
+			t.Fatalf("AllocateOwned() failed: %v", err)
+		}
+
+		if err := release(key, token); err != nil {
+			t.Fatalf("%s() failed: %v", name, err)
+		}
+
+		if owner, _ := owners.Owner(key); owner != "next" {
+			t.Errorf("%s() left owner %q, want the next owner kept", name, owner)
+		}
+	}
+}
+
+func TestRPCHandler_GivenOwnershipTokens(t *testing.T) {
+	allocator := NewAllocator(newMemoryKeyValueEntityMock(generatedKeys(1)), nil)
+	allocator.Owners = &ownershipMock{}
+	namespaces := &Namespaces{Registry: &namespaceRegistryMock{}, Default: allocator}
+
+	handler := &RPCHandler{Allocator: allocator, Namespaces: namespaces}
+	admin := &AdminRPCHandler{Namespaces: namespaces}
+
+	res, err := handler.GetKey(context.Background(), &GetKeyRequest{})
+	if err != nil || res.Token == "" {
+		t.Fatalf("GetKey() = %v, %v, want a key and its token", res, err)
+	}
+
+	_, err = handler.ReleaseKey(context.Background(), &KeyRequest{Key: res.Key, Token: "forged"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ReleaseKey() = %v, want %v", err, codes.PermissionDenied)
+	}
+
+	if _, err := admin.ForceReleaseKey(context.Background(), &KeyRequest{Key: res.Key}); err != nil {
+		t.Errorf("ForceReleaseKey() = %v, want the key released", err)
+	}
+
+	res, err = handler.GetKey(context.Background(), &GetKeyRequest{})
+	if err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+
+	if _, err := handler.ReleaseKey(context.Background(), &KeyRequest{Key: res.Key, Token: res.Token}); err != nil {
+		t.Errorf("ReleaseKey() = %v, want the key released with its token", err)
+	}
+}
+
+func TestRPCHandler_GivenReclaimedKeys(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(1))
+	allocator := NewAllocator(entity, nil)
+	allocator.Owners = &ownershipMock{}
+	handler := &RPCHandler{Allocator: allocator}
+
+	expired, err := handler.GetKey(context.Background(), &GetKeyRequest{})
+	if err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+
+	// reclaiming twice, e.g. a message delivered again
+	for range 2 {
+		if _, err := handler.ReclaimKey(context.Background(), &KeyRequest{Key: expired.Key, Token: expired.Token}); err != nil {
+			t.Errorf("ReclaimKey() = %v, want the key reclaimed", err)
+		}
+	}
+
+	res, err := handler.GetKey(context.Background(), &GetKeyRequest{})
+	if err != nil {
+		t.Fatalf("GetKey() failed: %v", err)
+	}
+
+	if _, err := handler.ReclaimKey(context.Background(), &KeyRequest{Key: expired.Key, Token: expired.Token}); err != nil {
+		t.Errorf("ReclaimKey() = %v, want the expired allocation reclaimed already", err)
+	}
+
+	if state := entity.state(string(res.Key)); state != KeyTaken {
+		t.Errorf("ReclaimKey() left the reallocated key %v, want it %v", state, KeyTaken)
+	}
+
+	if _, err := handler.ReclaimKey(context.Background(), &KeyRequest{Key: res.Key}); err != nil {
+		t.Errorf("ReclaimKey() = %v, want the key reclaimed without its token", err)
+	}
+
+	if state := entity.state(string(res.Key)); state != KeyAvailable {
+		t.Errorf("ReclaimKey() left the key %v, want it %v", state, KeyAvailable)
+	}
+
+	if _, err := handler.ReclaimKey(context.Background(), &KeyRequest{Key: []byte("!")}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ReclaimKey() = %v, want %v", err, codes.InvalidArgument)
+	}
+}
@@ -0,0 +1,228 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PrefetchBuffer serves keys allocated ahead of time from
+// memory; once fewer than Threshold keys are left it
+// allocates a batch in the background, up to Size keys
+type PrefetchBuffer struct {
+	// Keys allocates the buffered keys, required
+	Keys app.KeyValueEntity
+
+	Size      int
+	Threshold int
+
+	// Interval spaces refills retried after failures
+	Interval time.Duration
+
+	mu      sync.Mutex
+	keys    []ShortKey
+	stopped bool
+	refill  chan struct{}
+
+	served, missed, refills, returned atomic.Int64
+}
+
+// PrefetchStats is a snapshot of a PrefetchBuffer
+type PrefetchStats struct {
+	Buffered int
+	Served   int64 // keys served from memory
+	Missed   int64 // keys allocated on demand, the buffer being empty
+	Refills  int64
+	Returned int64 // keys made available again on stop
+}
+
+// NewPrefetchBuffer returns a buffer of up to size keys
+// refilled below threshold keys, retrying every second
+func NewPrefetchBuffer(keys app.KeyValueEntity, size, threshold int) *PrefetchBuffer {
+	return &PrefetchBuffer{
+		Keys:      keys,
+		Size:      size,
+		Threshold: min(threshold, size),
+		Interval:  time.Second,
+		refill:    make(chan struct{}, 1),
+	}
+}
+
+// AllocateFirst serves a buffered key, or allocates
+// one from storage when the buffer is empty
+func (b *PrefetchBuffer) AllocateFirst() (interface{}, error) {
+	b.mu.Lock()
+	if n := len(b.keys); n > 0 {
+		k := b.keys[n-1]
+		b.keys = b.keys[:n-1]
+		if n-1 < b.Threshold {
+			b.requestRefill()
+		}
+		b.mu.Unlock()
+
+		b.served.Add(1)
+		return k, nil
+	}
+	b.requestRefill()
+	b.mu.Unlock()
+
+	entity, err := b.entity()
+	if err != nil {
+		return nil, err
+	}
+
+	b.missed.Add(1)
+	return entity.AllocateFirst()
+}
+
+// Run should be launched in its own goroutine where it
+// fills the buffer until ctx is done, when it makes the
+// buffered keys available again and closes the channel
+func (b *PrefetchBuffer) Run(ctx context.Context, ch chan error) {
+	defer close(ch)
+
+	ticker := time.NewTicker(max(b.Interval, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		if err := b.fill(); err != nil {
+			select {
+			case ch <- err:
+			case <-ctx.Done():
+			}
+		}
+
+		select {
+		case <-b.refill:
+		case <-ticker.C:
+		case <-ctx.Done():
+			b.drain(ch)
+			return
+		}
+	}
+}
+
+// Stats returns the buffer counters
+func (b *PrefetchBuffer) Stats() PrefetchStats {
+	b.mu.Lock()
+	buffered := len(b.keys)
+	b.mu.Unlock()
+
+	return PrefetchStats{
+		Buffered: buffered,
+		Served:   b.served.Load(),
+		Missed:   b.missed.Load(),
+		Refills:  b.refills.Load(),
+		Returned: b.returned.Load(),
+	}
+}
+
+// fill tops the buffer up when below threshold
+func (b *PrefetchBuffer) fill() error {
+	b.mu.Lock()
+	missing := b.Size - len(b.keys)
+	if b.stopped || len(b.keys) >= b.Threshold || missing <= 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	b.mu.Unlock()
+
+	entity, err := b.entity()
+	if err != nil {
+		return err
+	}
+
+	allocated, err := allocateBatch(entity, missing)
+	if err != nil {
+		return fmt.Errorf("failed to refill keys buffer: %w", err)
+	}
+
+	var batch []ShortKey
+	for _, i := range allocated {
+		k, ok := i.(ShortKey)
+		if !ok {
+			return errors.New("could not convert allocated value into a key")
+		}
+		batch = append(batch, k)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.keys = append(b.keys, batch...)
+	b.refills.Add(1)
+
+	return nil
+}
+
+// drain stops refills and deallocates the buffered keys
+func (b *PrefetchBuffer) drain(ch chan error) {
+	b.mu.Lock()
+	buffered := b.keys
+	b.keys, b.stopped = nil, true
+	b.mu.Unlock()
+
+	entity, err := b.entity()
+	if err != nil {
+		ch <- fmt.Errorf("failed to return %d buffered keys: %w", len(buffered), err)
+		return
+	}
+
+	for _, k := range buffered {
+		if err := entity.Deallocate(&k); err != nil {
+			ch <- fmt.Errorf("failed to return key %s: %w", k, err)
+			continue
+		}
+
+		b.returned.Add(1)
+	}
+}
+
+// requestRefill wakes Run up, b.mu must be held
+func (b *PrefetchBuffer) requestRefill() {
+	if b.stopped {
+		return
+	}
+
+	select {
+	case b.refill <- struct{}{}:
+	default: // a refill is already requested
+	}
+}
+
+func (b *PrefetchBuffer) entity() (app.KeyValueEntity, error) {
+	if b.Keys == nil {
+		return nil, ErrNoKeysStorage
+	}
+
+	return b.Keys, nil
+}
+
+// allocateBatch allocates up to n keys in a single batch
+// when the entity supports it, otherwise one by one
+// until the first failure
+func allocateBatch(entity app.KeyValueEntity, n int) ([]interface{}, error) {
+	if batchAllocator, ok := app.As[app.KeyValueBatchAllocator](entity); ok {
+		return batchAllocator.AllocateBatch(n)
+	}
+
+	var allocated []interface{}
+	for range n {
+		i, err := entity.AllocateFirst()
+		if err != nil {
+			if len(allocated) > 0 {
+				break
+			}
+
+			return nil, err
+		}
+
+		allocated = append(allocated, i)
+	}
+
+	return allocated, nil
+}
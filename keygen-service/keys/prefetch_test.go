@@ -0,0 +1,103 @@
+package keys
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrefetchBuffer_GivenAvailableKeys(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(100))
+	buffer := NewPrefetchBuffer(entity, 10, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan error)
+	go buffer.Run(ctx, ch)
+
+	if !waitForBuffered(buffer, 10) {
+		t.Fatalf("Stats() = %+v, want 10 buffered keys", buffer.Stats())
+	}
+
+	served := map[string]bool{}
+	for range 20 {
+		i, err := buffer.AllocateFirst()
+		if err != nil {
+			t.Fatalf("AllocateFirst() failed: %v", err)
+		}
+
+		k := i.(ShortKey)
+		if served[string(k)] {
+			t.Errorf("AllocateFirst() = %s twice, want unique keys", k)
+		}
+		served[string(k)] = true
+
+		time.Sleep(time.Millisecond) // let refills happen
+	}
+
+	cancel()
+	for e := range ch {
+		t.Errorf("Run() sent %v, want no errors", e)
+	}
+
+	if available, taken := entity.count(); taken != 20 || available != 80 {
+		t.Errorf("Run() left %v taken and %v available keys, want 20 and 80", taken, available)
+	}
+
+	stats := buffer.Stats()
+	if stats.Buffered != 0 || stats.Served == 0 || stats.Returned == 0 {
+		t.Errorf("Stats() = %+v, want keys served and returned", stats)
+	}
+}
+
+func TestPrefetchBuffer_GivenEmptyBuffer(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(1))
+	buffer := NewPrefetchBuffer(entity, 10, 5)
+
+	i, err := buffer.AllocateFirst()
+	if err != nil {
+		t.Fatalf("AllocateFirst() failed: %v", err)
+	}
+
+	if _, ok := i.(ShortKey); !ok {
+		t.Errorf("AllocateFirst() = %v, want a key", i)
+	}
+
+	if stats := buffer.Stats(); stats.Missed != 1 {
+		t.Errorf("Stats() = %+v, want a missed key", stats)
+	}
+}
+
+func TestPrefetchBuffer_GivenNoAvailableKeys(t *testing.T) {
+	buffer := NewPrefetchBuffer(newMemoryKeyValueEntityMock(nil), 10, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan error)
+	go buffer.Run(ctx, ch)
+
+	select {
+	case e := <-ch:
+		want := "failed to refill keys buffer"
+		if !strings.Contains(e.Error(), want) {
+			t.Errorf("Run() sent %v, want containing %v", e, want)
+		}
+	case <-time.After(time.Second):
+		t.Error("Run() timed out, want it to send a error")
+	}
+
+	if _, err := buffer.AllocateFirst(); err == nil {
+		t.Error("AllocateFirst() succeeded, want a error without available keys")
+	}
+}
+
+func waitForBuffered(buffer *PrefetchBuffer, n int) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if buffer.Stats().Buffered == n {
+			return true
+		}
+	}
+
+	return false
+}
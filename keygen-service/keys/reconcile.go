@@ -0,0 +1,405 @@
+package keys
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// KeyRepairer is implemented by storages keeping their keys in
+// sets, whose members are checked and removed as they are,
+// malformed ones included
+type KeyRepairer interface {
+	// Members tells which keys are members of the set of the state
+	Members(state KeyState, keys []ShortKey) ([]bool, error)
+
+	// Remove removes the keys from the set of the state
+	Remove(state KeyState, keys []ShortKey) error
+}
+
+// ReconcileReport counts the keys scanned and the anomalies
+// found among them; a key may be counted twice when the
+// sets change during the scan
+type ReconcileReport struct {
+	Scanned int64
+
+	Duplicated      int64 // both available and taken
+	Malformed       int64 // invalid per NewKeyFromBytes
+	BannedAvailable int64 // banned yet available
+	InUseNotTaken   int64 // in use, yet available or unknown
+
+	// Unused keys are taken, yet neither in use, reserved,
+	// quarantined nor banned; reported only, the list of
+	// keys in use may be outdated
+	Unused int64
+
+	Repaired int64
+	Examples []string // the first anomalies, as "kind key"
+}
+
+// Anomalies counts the anomalies worth a repair
+func (r ReconcileReport) Anomalies() int64 {
+	return r.Duplicated + r.Malformed + r.BannedAvailable + r.InUseNotTaken
+}
+
+func (r *ReconcileReport) found(kind string, key ShortKey) {
+	if len(r.Examples) < 10 {
+		r.Examples = append(r.Examples, fmt.Sprintf("%s %q", kind, key))
+	}
+}
+
+// Reconciler scans the available and taken keys of a storage
+// page by page, reporting anomalies and repairing them when
+// asked to: duplicated, malformed and banned available keys
+// are removed from the available ones, keys in use made taken
+type Reconciler struct {
+	// Repair fixes the anomalies found, only reported otherwise
+	Repair bool
+
+	// InUse, when set, holds the keys in use,
+	// e.g. the hashes of the short links
+	InUse map[string]bool
+
+	// BatchSize keys are scanned at once, 1000 when zero
+	BatchSize int
+
+	// Pause between pages spares the storage
+	Pause time.Duration
+}
+
+// Run reconciles the keys of the storage
+func (r *Reconciler) Run(ctx context.Context, entity app.KeyValueEntity) (ReconcileReport, error) {
+	var report ReconcileReport
+
+	lister, listing := app.As[KeyLister](entity)
+	repairer, repairing := app.As[KeyRepairer](entity)
+	if !listing || !repairing {
+		return report, ErrInspectUnsupported
+	}
+
+	err := r.scan(ctx, lister, KeyAvailable, func(page []ShortKey) error {
+		valid, err := r.removeMalformed(repairer, KeyAvailable, page, &report)
+		if err != nil || len(valid) == 0 {
+			return err
+		}
+
+		banned, err := repairer.Members(KeyBanned, valid)
+		if err != nil {
+			return fmt.Errorf("failed to check banned keys: %w", err)
+		}
+
+		taken, err := repairer.Members(KeyTaken, valid)
+		if err != nil {
+			return fmt.Errorf("failed to check taken keys: %w", err)
+		}
+
+		var anomalies []ShortKey
+		for i, k := range valid {
+			switch {
+			case banned[i]:
+				report.BannedAvailable++
+				report.found("banned available", k)
+			case taken[i]:
+				report.Duplicated++
+				report.found("duplicated", k)
+			default:
+				continue
+			}
+			anomalies = append(anomalies, k)
+		}
+
+		return r.remove(repairer, KeyAvailable, anomalies, &report)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	err = r.scan(ctx, lister, KeyTaken, func(page []ShortKey) error {
+		valid, err := r.removeMalformed(repairer, KeyTaken, page, &report)
+		if err != nil || r.InUse == nil {
+			return err
+		}
+
+		var unused []ShortKey
+		for _, k := range valid {
+			if !r.InUse[string(k)] {
+				unused = append(unused, k)
+			}
+		}
+
+		if len(unused) == 0 {
+			return nil
+		}
+
+		reserved, err := repairer.Members(KeyReserved, unused)
+		if err != nil {
+			return fmt.Errorf("failed to check reserved keys: %w", err)
+		}
+
+		banned, err := repairer.Members(KeyBanned, unused)
+		if err != nil {
+			return fmt.Errorf("failed to check banned keys: %w", err)
+		}
+
+		quarantined, err := repairer.Members(KeyQuarantined, unused)
+		if err != nil {
+			return fmt.Errorf("failed to check quarantined keys: %w", err)
+		}
+
+		for i := range unused {
+			if !reserved[i] && !banned[i] && !quarantined[i] {
+				report.Unused++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if r.InUse != nil {
+		err = r.reconcileInUse(ctx, entity, repairer, &report)
+	}
+
+	return report, err
+}
+
+// reconcileInUse makes taken the keys in use that aren't,
+// be they available or unknown to the storage
+func (r *Reconciler) reconcileInUse(ctx context.Context, entity app.KeyValueEntity, repairer KeyRepairer, report *ReconcileReport) error {
+	inUse := make([]ShortKey, 0, len(r.InUse))
+	for k := range r.InUse {
+		inUse = append(inUse, ShortKey(k))
+	}
+	slices.SortFunc(inUse, func(a, b ShortKey) int { return slices.Compare(a, b) })
+
+	for batch := range slices.Chunk(inUse, cmp.Or(r.BatchSize, 1000)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		taken, err := repairer.Members(KeyTaken, batch)
+		if err != nil {
+			return fmt.Errorf("failed to check taken keys: %w", err)
+		}
+
+		var notTaken []ShortKey
+		for i, k := range batch {
+			if !taken[i] {
+				report.InUseNotTaken++
+				report.found("in use not taken", k)
+				notTaken = append(notTaken, k)
+			}
+		}
+
+		if r.Repair && len(notTaken) > 0 {
+			restorer, ok := app.As[KeyRestorer](entity)
+			if !ok {
+				return fmt.Errorf("failed to take keys in use: %w", ErrInspectUnsupported)
+			}
+
+			restored, err := restorer.Restore(KeyTaken, notTaken, false)
+			report.Repaired += int64(len(restored))
+			if err != nil {
+				return fmt.Errorf("failed to take keys in use: %w", err)
+			}
+		}
+
+		sleep(ctx, r.Pause)
+	}
+
+	return nil
+}
+
+// removeMalformed reports the malformed keys of the page,
+// removed when repairing, and returns the valid ones
+func (r *Reconciler) removeMalformed(repairer KeyRepairer, state KeyState, page []ShortKey, report *ReconcileReport) ([]ShortKey, error) {
+	report.Scanned += int64(len(page))
+
+	var valid, malformed []ShortKey
+	for _, k := range page {
+		if _, err := NewKeyFromBytes(k); err != nil {
+			report.Malformed++
+			report.found("malformed", k)
+			malformed = append(malformed, k)
+			continue
+		}
+
+		valid = append(valid, k)
+	}
+
+	return valid, r.remove(repairer, state, malformed, report)
+}
+
+// remove removes the keys from the state set when repairing
+func (r *Reconciler) remove(repairer KeyRepairer, state KeyState, keys []ShortKey, report *ReconcileReport) error {
+	if !r.Repair || len(keys) == 0 {
+		return nil
+	}
+
+	if err := repairer.Remove(state, keys); err != nil {
+		return fmt.Errorf("failed to remove %s keys: %w", state, err)
+	}
+
+	report.Repaired += int64(len(keys))
+	return nil
+}
+
+// scan calls f with each page of the keys in the state
+func (r *Reconciler) scan(ctx context.Context, lister KeyLister, state KeyState, f func([]ShortKey) error) error {
+	cursor := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page, next, err := lister.ListKeys(state, cursor, cmp.Or(r.BatchSize, 1000))
+		if err != nil {
+			return fmt.Errorf("failed to scan %s keys: %w", state, err)
+		}
+
+		if err := f(page); err != nil {
+			return err
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+
+		sleep(ctx, r.Pause)
+	}
+}
+
+// Reconcile reconciles the keys of the storage, see Reconciler
+func (a *Allocator) Reconcile(ctx context.Context, r *Reconciler) (ReconcileReport, error) {
+	entity, err := a.entity()
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+
+	return r.Run(ctx, entity)
+}
+
+// ReconcileJob reconciles the keys of every
+// namespace, the default one first, on each Run
+type ReconcileJob struct {
+	Namespaces *Namespaces
+	Reconciler *Reconciler
+
+	mu      sync.Mutex
+	reports map[string]ReconcileReport
+	ranAt   time.Time
+}
+
+// ReconcileJobStats is a snapshot of a ReconcileJob
+type ReconcileJobStats struct {
+	RanAt   time.Time
+	Repair  bool
+	Reports map[string]ReconcileReport // by namespace
+}
+
+// Run reconciles the keys of every namespace once, going on
+// after failing namespaces, whose failures are returned
+func (j *ReconcileJob) Run(ctx context.Context) error {
+	var errs []error
+
+	names := []string{""}
+	if namespaces, err := j.Namespaces.List(); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, namespace := range namespaces {
+			names = append(names, namespace.Name)
+		}
+	}
+
+	reports := map[string]ReconcileReport{}
+	for _, name := range names {
+		allocator, err := j.Namespaces.Allocator(name, false)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("namespace %q: %w", name, err))
+			continue
+		}
+
+		report, err := allocator.Reconcile(ctx, j.Reconciler)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to reconcile keys of namespace %q: %w", name, err))
+			continue
+		}
+		reports[name] = report
+
+		if report.Anomalies() > 0 {
+			log.Printf("keys reconciliation of namespace %q found %d anomalies, repaired %d: %v",
+				name, report.Anomalies(), report.Repaired, report.Examples)
+		}
+	}
+
+	j.mu.Lock()
+	j.reports, j.ranAt = reports, time.Now()
+	j.mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// Stats returns the reports of the last reconciliation
+func (j *ReconcileJob) Stats() ReconcileJobStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return ReconcileJobStats{RanAt: j.ranAt, Repair: j.Reconciler.Repair, Reports: j.reports}
+}
+
+// Members tells which keys are members of the set of the state
+func (k *Valkey) Members(state KeyState, keys []ShortKey) ([]bool, error) {
+	set, err := k.stateSetName(state)
+	if err != nil {
+		return nil, err
+	}
+
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := valkeyClient.SMIsMember(set, shortKeyStrings(keys))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s keys: %w", state, err)
+	}
+
+	return members, nil
+}
+
+// Remove removes the keys from the set of the state
+func (k *Valkey) Remove(state KeyState, keys []ShortKey) error {
+	set, err := k.stateSetName(state)
+	if err != nil {
+		return err
+	}
+
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	if _, err := valkeyClient.SRem(set, shortKeyStrings(keys)); err != nil {
+		return fmt.Errorf("failed to remove %s keys: %w", state, err)
+	}
+
+	return nil
+}
+
+func shortKeyStrings(keys []ShortKey) []string {
+	members := make([]string, len(keys))
+	for i, k := range keys {
+		members[i] = string(k)
+	}
+
+	return members
+}
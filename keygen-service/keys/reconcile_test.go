@@ -0,0 +1,119 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"keygen-service/app"
+	"keygen-service/jobs"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newAnomalousKeyValueEntityMock gives sets with duplicated,
+// malformed, banned yet available and dangling members
+func newAnomalousKeyValueEntityMock() *memoryKeyValueEntityMock {
+	return &memoryKeyValueEntityMock{sets: map[KeyState][]string{
+		KeyAvailable: {"aaaaaa", "bbbbbb", "cccccc", "dd/ddd", "eeeeee"},
+		KeyTaken:     {"bbbbbb", "ffffff", "gggggg", "hhhhhh", "eeeeee", "x"},
+		KeyReserved:  {"gggggg"},
+		KeyBanned:    {"eeeeee"},
+	}}
+}
+
+func TestReconciler_GivenAnomalies(t *testing.T) {
+	entity := newAnomalousKeyValueEntityMock()
+
+	reconciler := &Reconciler{InUse: map[string]bool{"aaaaaa": true, "ffffff": true, "iiiiii": true}}
+	report, err := reconciler.Run(context.Background(), entity)
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+
+	want := ReconcileReport{Scanned: 11, Duplicated: 1, Malformed: 2, BannedAvailable: 1, InUseNotTaken: 2, Unused: 2}
+	report.Examples = nil
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Run() = %+v, want %+v", report, want)
+	}
+
+	if len(entity.sets[KeyAvailable]) != 5 {
+		t.Errorf("Run() changed %v, want a dry run", entity.sets)
+	}
+
+	reconciler.Repair = true
+	if report, err := reconciler.Run(context.Background(), entity); err != nil || report.Repaired != 6 {
+		t.Fatalf("Run() = %+v, %v, want 6 keys repaired", report, err)
+	}
+
+	if got := entity.sets[KeyAvailable]; !slices.Equal(got, []string{"cccccc"}) {
+		t.Errorf("Run() left %v available, want cccccc only", got)
+	}
+
+	if got := entity.sets[KeyTaken]; !slices.Contains(got, "aaaaaa") || !slices.Contains(got, "iiiiii") || slices.Contains(got, "x") {
+		t.Errorf("Run() left %v taken, want the keys in use taken and x removed", got)
+	}
+
+	if report, err := reconciler.Run(context.Background(), entity); err != nil || report.Anomalies() != 0 {
+		t.Errorf("Run() = %+v, %v, want no anomaly left", report, err)
+	}
+}
+
+func TestReconciler_GivenUnrepairableStorage(t *testing.T) {
+	_, err := (&Reconciler{}).Run(context.Background(), struct{ app.KeyValueEntity }{newMemoryKeyValueEntityMock(nil)})
+	if !errors.Is(err, ErrInspectUnsupported) {
+		t.Errorf("Run() = %v, want %v", err, ErrInspectUnsupported)
+	}
+}
+
+func TestAdminRPCHandler_GivenJobs(t *testing.T) {
+	reconcile := &ReconcileJob{
+		Namespaces: &Namespaces{Registry: &namespaceRegistryMock{}, Default: NewAllocator(newAnomalousKeyValueEntityMock(), nil)},
+		Reconciler: &Reconciler{},
+	}
+
+	scheduler := &jobs.Scheduler{}
+	if err := scheduler.Add(jobs.Job{Name: "reconcile", Schedule: jobs.Every(time.Hour), Run: reconcile.Run}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	handler := &AdminRPCHandler{Jobs: scheduler}
+	ctx := context.Background()
+
+	job, err := handler.TriggerJob(ctx, &JobRequest{Name: "reconcile"})
+	if err != nil {
+		t.Fatalf("TriggerJob() failed: %v", err)
+	}
+
+	if !job.GetRunning() || job.GetSchedule() != "@every 1h0m0s" {
+		t.Errorf("TriggerJob() = %v, want the job running", job)
+	}
+
+	for scheduler.List()[0].Running {
+		time.Sleep(time.Millisecond)
+	}
+
+	if report := reconcile.Stats().Reports[""]; report.Duplicated != 1 {
+		t.Errorf("Stats() = %+v, want the default namespace reconciled", reconcile.Stats())
+	}
+
+	list, err := handler.ListJobs(ctx, &Void{})
+	if err != nil {
+		t.Fatalf("ListJobs() failed: %v", err)
+	}
+
+	if len(list.GetJobs()) != 1 || list.GetJobs()[0].GetRuns() != 1 || list.GetJobs()[0].GetLastRun() == "" || list.GetJobs()[0].GetNextRun() != "" {
+		t.Errorf("ListJobs() = %v, want the run recorded", list)
+	}
+
+	if _, err := handler.TriggerJob(ctx, &JobRequest{Name: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("TriggerJob() = %v, want %v", err, codes.NotFound)
+	}
+
+	if _, err := (&AdminRPCHandler{}).ListJobs(ctx, &Void{}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ListJobs() = %v, want %v without jobs", err, codes.FailedPrecondition)
+	}
+}
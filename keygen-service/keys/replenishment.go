@@ -0,0 +1,364 @@
+package keys
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"keygen-service/app"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valkey-io/valkey-glide/go/api/options"
+)
+
+const KeysReplenishmentName = "keysReplenishment"
+
+// ReplenishmentSignal tells the replicas about the keys of a namespace
+type ReplenishmentSignal string
+
+const (
+	SignalDemand  ReplenishmentSignal = "demand"  // allocations wait for keys
+	SignalCreated ReplenishmentSignal = "created" // keys are available again
+)
+
+// NamespaceSignal is a signal received for a namespace
+type NamespaceSignal struct {
+	Namespace string
+	Signal    ReplenishmentSignal
+}
+
+// ReplenishmentSignals carries the signals between the replicas
+type ReplenishmentSignals interface {
+	// Send sends the signal of the namespace to the other replicas
+	Send(namespace string, signal ReplenishmentSignal) error
+
+	// Receive waits for the signals of the other replicas sent to
+	// the namespaces after their cursor, those to come for an empty
+	// one, moving the cursors past the signals returned
+	Receive(cursors map[string]string) ([]NamespaceSignal, error)
+}
+
+// Replenishment connects the allocations waiting for keys of a
+// namespace with its generator: waiting allocations wake the
+// generator, which wakes them once it created keys; through
+// Replenishments, across the replicas
+type Replenishment struct {
+	mu       sync.Mutex
+	created  chan struct{} // closed on the next creation
+	demand   chan struct{}
+	waiting  atomic.Int64
+	demanded atomic.Bool // by other replicas, until keys are created
+
+	namespace string
+	hub       *Replenishments
+}
+
+// NewReplenishment returns a Replenishment nobody waits on,
+// its signals staying on the replica
+func NewReplenishment() *Replenishment {
+	return &Replenishment{created: make(chan struct{}), demand: make(chan struct{}, 1)}
+}
+
+// Created wakes the allocations waiting for keys, once keys
+// are created or released, on every replica
+func (r *Replenishment) Created() {
+	if r.hub != nil && r.demanded.Load() {
+		r.hub.send(r.namespace, SignalCreated)
+	}
+
+	r.wakeAllocations()
+}
+
+// Demanded is signalled when allocations start waiting,
+// for the generator to write keys without pausing
+func (r *Replenishment) Demanded() <-chan struct{} {
+	return r.demand
+}
+
+// Waiting returns how many allocations wait for keys,
+// those of the other replicas counting as one
+func (r *Replenishment) Waiting() int64 {
+	waiting := r.waiting.Load()
+	if r.demanded.Load() {
+		waiting++
+	}
+
+	return waiting
+}
+
+// sibling returns the Replenishment of another namespace,
+// sharing the signals of this one
+func (r *Replenishment) sibling(namespace string) *Replenishment {
+	if r.hub == nil {
+		return NewReplenishment()
+	}
+
+	return r.hub.Of(namespace)
+}
+
+// next returns a channel closed once keys are created
+func (r *Replenishment) next() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.created
+}
+
+// wake signals the generator, on every replica
+func (r *Replenishment) wake() {
+	if r.hub != nil {
+		r.hub.send(r.namespace, SignalDemand)
+	}
+
+	r.wakeGenerator()
+}
+
+// receive handles a signal of another replica
+func (r *Replenishment) receive(signal ReplenishmentSignal) {
+	switch signal {
+	case SignalDemand:
+		r.demanded.Store(true)
+		r.wakeGenerator()
+	case SignalCreated:
+		r.wakeAllocations()
+	}
+}
+
+// wakeAllocations wakes the allocations waiting on the replica
+func (r *Replenishment) wakeAllocations() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.demanded.Store(false) // allocations still waiting demand again
+	close(r.created)
+	r.created = make(chan struct{})
+}
+
+// wakeGenerator signals the generator of the replica, without blocking
+func (r *Replenishment) wakeGenerator() {
+	select {
+	case r.demand <- struct{}{}:
+	default: // already signalled
+	}
+}
+
+// Replenishments keeps the Replenishment of each namespace
+// of the replica, sharing their signals with the other
+// replicas through Signals when set
+type Replenishments struct {
+	Signals ReplenishmentSignals
+
+	// Backoff spaces the receptions failing
+	Backoff Backoff
+
+	mu         sync.Mutex
+	namespaces map[string]*Replenishment
+
+	sent, received, failures atomic.Int64
+}
+
+// ReplenishmentStats is a snapshot of Replenishments
+type ReplenishmentStats struct {
+	Sent     int64
+	Received int64
+	Failures int64 // signals not sent and failed receptions
+}
+
+// Of returns the Replenishment of the namespace,
+// the default one when empty
+func (r *Replenishments) Of(namespace string) *Replenishment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.namespaces == nil {
+		r.namespaces = map[string]*Replenishment{}
+	}
+
+	replenishment, ok := r.namespaces[namespace]
+	if !ok {
+		replenishment = NewReplenishment()
+		replenishment.namespace, replenishment.hub = namespace, r
+		r.namespaces[namespace] = replenishment
+	}
+
+	return replenishment
+}
+
+// Run should be launched in its own goroutine where it passes
+// the signals of the other replicas to the namespaces until ctx
+// is done, then closes the channel
+func (r *Replenishments) Run(ctx context.Context, ch chan error) {
+	defer close(ch)
+
+	if r.Signals == nil {
+		return
+	}
+
+	cursors := map[string]string{}
+	for attempt := 0; ctx.Err() == nil; {
+		r.mu.Lock()
+		for namespace := range r.namespaces {
+			if _, ok := cursors[namespace]; !ok {
+				cursors[namespace] = "" // the signals to come
+			}
+		}
+		r.mu.Unlock()
+
+		signals, err := r.Signals.Receive(cursors)
+		if err != nil {
+			r.failures.Add(1)
+			select {
+			case ch <- fmt.Errorf("failed to receive keys replenishment signals: %w", err):
+			case <-ctx.Done():
+			}
+
+			sleep(ctx, r.Backoff.Delay(attempt))
+			attempt++
+			continue
+		}
+		attempt = 0
+
+		for _, signal := range signals {
+			r.received.Add(1)
+			r.Of(signal.Namespace).receive(signal.Signal)
+		}
+	}
+}
+
+// Stats returns the signals counters
+func (r *Replenishments) Stats() ReplenishmentStats {
+	return ReplenishmentStats{Sent: r.sent.Load(), Received: r.received.Load(), Failures: r.failures.Load()}
+}
+
+// send shares the signal, its failure leaving the other
+// replicas to notice the keys once their wait is over
+func (r *Replenishments) send(namespace string, signal ReplenishmentSignal) {
+	if r.Signals == nil {
+		return
+	}
+
+	if err := r.Signals.Send(namespace, signal); err != nil {
+		r.failures.Add(1)
+		return
+	}
+	r.sent.Add(1)
+}
+
+// ValkeyReplenishmentSignals carries the signals in a stream of
+// each namespace, read by every replica, skipping its own signals
+type ValkeyReplenishmentSignals struct {
+	// Client connects to the db to send signals, required
+	Client app.KeyValueDbClient
+
+	// Listener connects to the db to wait for signals, a
+	// connection of its own as waiting blocks it; required
+	Listener app.KeyValueDbClient
+
+	// Replica names the signals sent, required
+	Replica string
+
+	// Block bounds each wait for signals, 1s when zero
+	Block time.Duration
+
+	// MaxLen of signals kept per namespace, 100 when zero
+	MaxLen int64
+}
+
+// Send appends the signal to the stream of the namespace
+func (v *ValkeyReplenishmentSignals) Send(namespace string, signal ReplenishmentSignal) error {
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return err
+	}
+
+	opts := options.NewXAddOptions().SetTrimOptions(options.NewXTrimOptionsWithMaxLen(cmp.Or(v.MaxLen, 100)).SetNearlyExactTrimming())
+	fields := [][]string{{"signal", string(signal)}, {"replica", v.Replica}}
+	if _, err := valkeyClient.XAddWithOptions(namespacedName(KeysReplenishmentName, namespace), fields, *opts); err != nil {
+		return fmt.Errorf("failed to send keys %s signal: %w", signal, err)
+	}
+
+	return nil
+}
+
+// Receive reads the streams of the namespaces, waiting up to
+// Block for signals; empty cursors start after the latest signal
+func (v *ValkeyReplenishmentSignals) Receive(cursors map[string]string) ([]NamespaceSignal, error) {
+	valkeyClient, err := valkeyConn(v.Listener)
+	if err != nil {
+		return nil, err
+	}
+
+	streams := make(map[string]string, len(cursors))
+	namespaces := make(map[string]string, len(cursors))
+	for namespace, cursor := range cursors {
+		name := namespacedName(KeysReplenishmentName, namespace)
+		if cursor == "" {
+			entries, err := valkeyClient.XRevRangeWithOptions(name, options.NewInfiniteStreamBoundary(options.PositiveInfinity),
+				options.NewInfiniteStreamBoundary(options.NegativeInfinity), *options.NewXRangeOptions().SetCount(1))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read keys replenishment signals: %w", err)
+			}
+
+			cursor = "0-0"
+			if len(entries) > 0 {
+				cursor = entries[0].StreamId
+			}
+			cursors[namespace] = cursor
+		}
+
+		streams[name], namespaces[name] = cursor, namespace
+	}
+
+	if len(streams) == 0 {
+		time.Sleep(cmp.Or(v.Block, time.Second))
+		return nil, nil
+	}
+
+	res, err := valkeyClient.XReadWithOptions(streams, *options.NewXReadOptions().SetBlock(cmp.Or(v.Block, time.Second).Milliseconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for keys replenishment signals: %w", err)
+	}
+
+	var signals []NamespaceSignal
+	for name, entries := range res {
+		namespace := namespaces[name]
+		for _, id := range slices.SortedFunc(maps.Keys(entries), compareStreamIDs) {
+			cursors[namespace] = id
+
+			var signal NamespaceSignal
+			own := false
+			for _, field := range entries[id] {
+				switch {
+				case len(field) != 2:
+				case field[0] == "signal":
+					signal = NamespaceSignal{Namespace: namespace, Signal: ReplenishmentSignal(field[1])}
+				case field[0] == "replica":
+					own = field[1] == v.Replica
+				}
+			}
+
+			if signal.Signal != "" && !own {
+				signals = append(signals, signal)
+			}
+		}
+	}
+
+	return signals, nil
+}
+
+type waitKey struct{}
+
+// WithWait makes the allocations of ctx wait, while no key is
+// available, for the generator to create some until ctx is done
+func WithWait(ctx context.Context) context.Context {
+	return context.WithValue(ctx, waitKey{}, true)
+}
+
+// waits tells whether the allocations of ctx wait for keys
+func waits(ctx context.Context) bool {
+	wait, _ := ctx.Value(waitKey{}).(bool)
+	return wait
+}
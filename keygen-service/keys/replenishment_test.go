@@ -0,0 +1,268 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"keygen-service/app"
+)
+
+// allocateAsync allocates in the background, the
+// result sent once allocated
+func allocateAsync(ctx context.Context, allocator *Allocator) chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := allocator.Allocate(ctx)
+		done <- err
+	}()
+
+	return done
+}
+
+func TestAllocator_GivenWait(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(nil)
+
+	allocator := NewAllocator(entity, nil)
+	allocator.Replenishment = NewReplenishment()
+
+	if _, err := allocator.Allocate(context.Background()); !errors.Is(err, ErrNoAvailableKeys) {
+		t.Errorf("Allocate() = %v, want %v without waiting", err, ErrNoAvailableKeys)
+	}
+
+	ctx, cancel := context.WithTimeout(WithWait(context.Background()), 5*time.Second)
+	defer cancel()
+	done := allocateAsync(ctx, allocator)
+
+	<-allocator.Replenishment.Demanded() // the generator woken
+	if waiting := allocator.Replenishment.Waiting(); waiting != 1 {
+		t.Errorf("Waiting() = %d, want 1", waiting)
+	}
+
+	k, _ := NextKey()
+	_ = entity.Create(k)
+	allocator.Replenishment.Created()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Allocate() failed: %v", err)
+	}
+
+	if stats := allocator.Stats(); stats.Allocated != 1 || stats.Waits != 1 || stats.WaitTimeouts != 0 {
+		t.Errorf("Stats() = %+v, want an allocation after waiting", stats)
+	}
+
+	if waiting := allocator.Replenishment.Waiting(); waiting != 0 {
+		t.Errorf("Waiting() = %d, want 0 once allocated", waiting)
+	}
+}
+
+func TestAllocator_GivenWaitTimeout(t *testing.T) {
+	allocator := NewAllocator(newMemoryKeyValueEntityMock(nil), nil)
+	allocator.Replenishment, allocator.MaxWait = NewReplenishment(), 20*time.Millisecond
+
+	start := time.Now()
+	_, err := allocator.Allocate(WithWait(context.Background()))
+	if !errors.Is(err, ErrNoAvailableKeys) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Allocate() = %v, want %v once MaxWait passed", err, ErrNoAvailableKeys)
+	}
+
+	if elapsed := time.Since(start); elapsed < allocator.MaxWait {
+		t.Errorf("Allocate() returned after %v, want %v at least", elapsed, allocator.MaxWait)
+	}
+
+	if stats := allocator.Stats(); stats.Waits != 1 || stats.WaitTimeouts != 1 {
+		t.Errorf("Stats() = %+v, want a wait timed out", stats)
+	}
+}
+
+func TestAllocator_GivenKeysCreatedElsewhere(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(nil)
+	bus := &replenishmentBusMock{}
+	waiting := &Replenishments{Signals: &replicaSignalsMock{bus: bus, replica: "waiting"}}
+	leading := &Replenishments{Signals: &replicaSignalsMock{bus: bus, replica: "leading"}}
+
+	allocator := NewAllocator(entity, nil)
+	allocator.Replenishment = waiting.Of("brand")
+	leader := NewAllocator(entity, nil)
+	leader.Replenishment = leading.Of("brand")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, replenishments := range []*Replenishments{waiting, leading} {
+		ch := make(chan error)
+		go replenishments.Run(ctx, ch)
+		go func() {
+			for err := range ch {
+				t.Errorf("Run() sent %v, want none", err)
+			}
+		}()
+	}
+
+	done := allocateAsync(WithWait(ctx), allocator)
+
+	<-leader.Replenishment.Demanded() // the generator of the other replica woken
+	if waiting := leader.Replenishment.Waiting(); waiting != 1 {
+		t.Errorf("Waiting() = %d, want the other replica waiting", waiting)
+	}
+
+	k, _ := NextKey()
+	_ = entity.Create(k)
+	leader.Replenishment.Created()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Allocate() = %v, want the key created by the other replica", err)
+	}
+
+	// the allocated key released on the other replica
+	done = allocateAsync(WithWait(ctx), allocator)
+	for leader.Replenishment.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := leader.Release(ctx, *k); err != nil {
+		t.Fatalf("Release() failed: %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("Allocate() = %v, want the key released by the other replica", err)
+	}
+
+	if stats := leading.Stats(); stats.Sent != 2 || stats.Received != 2 || stats.Failures != 0 {
+		t.Errorf("Stats() = %+v, want 2 signals sent and 2 received", stats)
+	}
+}
+
+func TestGeneratorPool_GivenWaitingAllocations(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(nil)
+	replenishment := NewReplenishment()
+
+	pool := &GeneratorPool{
+		BatchSize:     10,
+		Interval:      time.Hour,
+		Keys:          entity,
+		Supply:        &StockPlanner{Counter: countedKeysMock(1000)}, // stale counts, no deficit
+		Replenishment: replenishment,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan error)
+	go pool.GenerateKeys(ctx, NextKey, ch)
+
+	allocator := NewAllocator(entity, nil)
+	allocator.Replenishment = replenishment
+
+	waitCtx, cancelWait := context.WithTimeout(WithWait(context.Background()), 5*time.Second)
+	defer cancelWait()
+
+	for range 2 {
+		if _, err := allocator.Allocate(waitCtx); err != nil {
+			t.Fatalf("Allocate() failed: %v", err)
+		}
+	}
+
+	cancel()
+	for range ch {
+	}
+
+	if created := pool.Stats().Created; created == 0 {
+		t.Errorf("Stats() = %+v, want keys created for the waiting allocations", pool.Stats())
+	}
+}
+
+// countedKeysMock counts a fixed number of available keys
+type countedKeysMock int64
+
+func (c countedKeysMock) Count() (int64, int64, error) {
+	return int64(c), 0, nil
+}
+
+func TestGeneratorPool_GivenFailingStorageAndWaitingAllocations(t *testing.T) {
+	entity := &failingCreationMock{KeyValueEntity: newMemoryKeyValueEntityMock(nil)}
+	replenishment := NewReplenishment()
+	replenishment.waiting.Add(1)
+
+	pool := &GeneratorPool{
+		BatchSize:     1,
+		Interval:      time.Hour,
+		Keys:          entity,
+		Backoff:       Backoff{Initial: 20 * time.Millisecond, Max: 20 * time.Millisecond},
+		Replenishment: replenishment,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan error)
+	go pool.GenerateKeys(ctx, NextKey, ch)
+	for range ch {
+	}
+
+	if attempts := entity.attempts.Load(); attempts == 0 || attempts > 10 {
+		t.Errorf("GenerateKeys() tried %d writes in 100ms, want a backoff after each failed batch", attempts)
+	}
+}
+
+// failingCreationMock fails every creation
+type failingCreationMock struct {
+	app.KeyValueEntity
+	attempts atomic.Int64
+}
+
+func (e *failingCreationMock) Create(interface{}) error {
+	e.attempts.Add(1)
+	return errors.New("read only replica")
+}
+
+// replenishmentBusMock keeps the signals of every replica in memory
+type replenishmentBusMock struct {
+	mu      sync.Mutex
+	signals []replicaSignal
+}
+
+type replicaSignal struct {
+	NamespaceSignal
+	replica string
+}
+
+// replicaSignalsMock sends and receives the signals of a replica
+type replicaSignalsMock struct {
+	bus     *replenishmentBusMock
+	replica string
+}
+
+func (r *replicaSignalsMock) Send(namespace string, signal ReplenishmentSignal) error {
+	r.bus.mu.Lock()
+	defer r.bus.mu.Unlock()
+
+	r.bus.signals = append(r.bus.signals, replicaSignal{NamespaceSignal{namespace, signal}, r.replica})
+	return nil
+}
+
+func (r *replicaSignalsMock) Receive(cursors map[string]string) ([]NamespaceSignal, error) {
+	r.bus.mu.Lock()
+	defer r.bus.mu.Unlock()
+
+	var signals []NamespaceSignal
+	for namespace, cursor := range cursors {
+		next, _ := strconv.Atoi(cursor) // from the first signal when empty
+
+		for _, signal := range r.bus.signals[next:] {
+			if signal.Namespace == namespace && signal.replica != r.replica {
+				signals = append(signals, signal.NamespaceSignal)
+			}
+		}
+		cursors[namespace] = strconv.Itoa(len(r.bus.signals))
+	}
+
+	if len(signals) == 0 {
+		r.bus.mu.Unlock()
+		time.Sleep(time.Millisecond) // waiting for signals
+		r.bus.mu.Lock()
+	}
+
+	return signals, nil
+}
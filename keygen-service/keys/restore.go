@@ -0,0 +1,244 @@
+package keys
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// KeyRestorer is implemented by storages able to put
+// keys back in a given state, e.g. importing a snapshot
+type KeyRestorer interface {
+	// Restore puts the keys in the state, leaving alone the
+	// keys known by the storage when keep is set, and returns
+	// the keys put
+	Restore(state KeyState, keys []ShortKey, keep bool) ([]ShortKey, error)
+
+	// Clear removes every key of the storage
+	Clear() error
+}
+
+// restoreScript moves the keys in ARGV[3:] to the sets of the
+// ARGV[1] state among KEYS, available, taken, reserved, banned
+// and quarantined, skipping known keys when ARGV[2] is 1;
+// returns the keys moved
+const restoreScript = `
+local restored = {}
+for i = 3, #ARGV do
+  local key = ARGV[i]
+  if ARGV[2] ~= '1' or (redis.call('SISMEMBER', KEYS[1], key) == 0 and redis.call('SISMEMBER', KEYS[2], key) == 0) then
+    for _, set in ipairs(KEYS) do
+      redis.call('SREM', set, key)
+    end
+    if ARGV[1] == 'available' then
+      redis.call('SADD', KEYS[1], key)
+    else
+      redis.call('SADD', KEYS[2], key)
+      if ARGV[1] == 'reserved' then
+        redis.call('SADD', KEYS[3], key)
+      elseif ARGV[1] == 'banned' then
+        redis.call('SADD', KEYS[4], key)
+      elseif ARGV[1] == 'quarantined' then
+        redis.call('SADD', KEYS[5], key)
+      end
+    end
+    restored[#restored + 1] = key
+  end
+end
+return restored`
+
+// Restore puts the keys in the sets of the state at once
+func (k *Valkey) Restore(state KeyState, keys []ShortKey, keep bool) ([]ShortKey, error) {
+	if err := restorable(state); err != nil {
+		return nil, err
+	}
+
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	keepKnown := "0"
+	if keep {
+		keepKnown = "1"
+	}
+
+	args := []string{
+		"EVAL", restoreScript, "5",
+		k.keysName(), k.takenKeysName(), k.reservedKeysName(), k.bannedKeysName(), k.quarantinedKeysName(),
+		string(state), keepKnown,
+	}
+
+	for _, key := range keys {
+		args = append(args, string(key))
+	}
+
+	res, err := valkeyClient.CustomCommand(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore keys: %w", err)
+	}
+
+	members, err := setMembers(res)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := make([]ShortKey, len(members))
+	for i, m := range members {
+		restored[i] = ShortKey(m)
+	}
+
+	return restored, nil
+}
+
+// Clear removes every set of the keys
+func (k *Valkey) Clear() error {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	names := []string{k.keysName(), k.takenKeysName(), k.reservedKeysName(), k.bannedKeysName(), k.quarantinedKeysName()}
+	if _, err := valkeyClient.Del(names); err != nil {
+		return fmt.Errorf("failed to clear keys: %w", err)
+	}
+
+	return nil
+}
+
+// Restore puts the keys in the state, with a
+// command per bitmap segment
+func (k *ValkeyBitmap) Restore(state KeyState, keys []ShortKey, keep bool) ([]ShortKey, error) {
+	if err := restorable(state); err != nil {
+		return nil, err
+	}
+
+	segments := map[uint64][]ShortKey{}
+	for _, key := range keys {
+		segment, _ := bitmapPosition(&key)
+		segments[segment] = append(segments[segment], key)
+	}
+
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	available := int64(0)
+	if state == KeyAvailable {
+		available = 1
+	}
+
+	var restored []ShortKey
+	for segment, segmentKeys := range segments {
+		offsets := make([]int64, len(segmentKeys))
+		for n, key := range segmentKeys {
+			_, offsets[n] = bitmapPosition(&key)
+		}
+
+		if keep {
+			known := make([]bool, len(offsets))
+			for _, bitmap := range []string{k.keysName(), k.takenKeysName()} {
+				bits, err := bitField(valkeyClient, "BITFIELD_RO", bitmapSegmentName(bitmap, segment), "GET", offsets)
+				if err != nil {
+					return restored, fmt.Errorf("failed to check keys: %w", err)
+				}
+
+				for n, bit := range bits {
+					known[n] = known[n] || bit == 1
+				}
+			}
+
+			var unknownKeys []ShortKey
+			var unknownOffsets []int64
+			for n, key := range segmentKeys {
+				if !known[n] {
+					unknownKeys, unknownOffsets = append(unknownKeys, key), append(unknownOffsets, offsets[n])
+				}
+			}
+
+			if len(unknownKeys) == 0 {
+				continue
+			}
+			segmentKeys, offsets = unknownKeys, unknownOffsets
+		}
+
+		if err := k.indexSegment(valkeyClient, segment); err != nil {
+			return restored, err
+		}
+
+		if err := setBits(valkeyClient, bitmapSegmentName(k.keysName(), segment), offsets, available); err != nil {
+			return restored, fmt.Errorf("failed to restore keys: %w", err)
+		}
+
+		if err := setBits(valkeyClient, bitmapSegmentName(k.takenKeysName(), segment), offsets, 1-available); err != nil {
+			return restored, fmt.Errorf("failed to restore keys: %w", err)
+		}
+
+		if state == KeyAvailable {
+			if _, err := valkeyClient.SAdd(k.segmentsName(), []string{strconv.FormatUint(segment, 10)}); err != nil {
+				return restored, fmt.Errorf("failed to register key segment: %w", err)
+			}
+		}
+
+		members := make([]string, len(segmentKeys))
+		for n, key := range segmentKeys {
+			members[n] = string(key)
+		}
+
+		for _, set := range []struct {
+			name  string
+			state KeyState
+		}{
+			{k.reservedKeysName(), KeyReserved},
+			{k.bannedKeysName(), KeyBanned},
+			{k.quarantinedKeysName(), KeyQuarantined},
+		} {
+			if state == set.state {
+				_, err = valkeyClient.SAdd(set.name, members)
+			} else {
+				_, err = valkeyClient.SRem(set.name, members)
+			}
+
+			if err != nil {
+				return restored, fmt.Errorf("failed to restore %s keys: %w", set.state, err)
+			}
+		}
+
+		restored = append(restored, segmentKeys...)
+	}
+
+	return restored, nil
+}
+
+// Clear removes every bitmap segment and set of the keys
+func (k *ValkeyBitmap) Clear() error {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	names := []string{k.segmentsName(), k.indexName(), k.reservedKeysName(), k.bannedKeysName(), k.quarantinedKeysName()}
+	for _, bitmap := range []string{k.keysName(), k.takenKeysName()} {
+		segments, err := scanNames(valkeyClient, bitmap+":*")
+		if err != nil {
+			return fmt.Errorf("failed to list %s segments: %w", bitmap, err)
+		}
+
+		names = append(names, segments...)
+	}
+
+	if _, err := valkeyClient.Del(names); err != nil {
+		return fmt.Errorf("failed to clear keys: %w", err)
+	}
+
+	return nil
+}
+
+func restorable(state KeyState) error {
+	switch state {
+	case KeyAvailable, KeyTaken, KeyReserved, KeyQuarantined, KeyBanned:
+		return nil
+	}
+
+	return fmt.Errorf("can't restore %q keys", state)
+}
@@ -0,0 +1,283 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+)
+
+const (
+	ReservedKeysName    = "reservedKeys"
+	BannedKeysName      = "bannedKeys"
+	QuarantinedKeysName = "quarantinedKeys"
+)
+
+// KeyState names where a key is in its lifecycle
+type KeyState string
+
+const (
+	// KeyUnknown keys were never created by the storage
+	KeyUnknown KeyState = "unknown"
+
+	KeyAvailable KeyState = "available"
+	KeyTaken     KeyState = "taken"
+
+	// KeyReserved keys were taken by Reserve, e.g. custom keys
+	KeyReserved KeyState = "reserved"
+
+	// KeyQuarantined keys are held out of the pool until
+	// their quarantine is lifted, e.g. while under review
+	KeyQuarantined KeyState = "quarantined"
+
+	// KeyBanned keys are never served again
+	KeyBanned KeyState = "banned"
+)
+
+var (
+	// ErrKeyBanned is returned when releasing a banned key
+	ErrKeyBanned = errors.New("key banned")
+
+	// ErrKeyQuarantined is returned when releasing a quarantined key
+	ErrKeyQuarantined = errors.New("key quarantined")
+
+	// ErrInspectUnsupported is returned when inspecting
+	// keys on a storage unable to do so
+	ErrInspectUnsupported = errors.New("keys storage can't inspect keys")
+)
+
+// KeyInspector is implemented by storages
+// able to tell the state of a key
+type KeyInspector interface {
+	State(key ShortKey) (KeyState, error)
+}
+
+// KeyBanner is implemented by storages able to ban keys,
+// taking them out of the pool whatever their state
+type KeyBanner interface {
+	Ban(key ShortKey) error
+}
+
+// KeyQuarantiner is implemented by storages able to hold
+// keys out of the pool for a while; only keys nobody holds
+// are quarantined, lifting the quarantine makes them available
+type KeyQuarantiner interface {
+	Quarantine(key ShortKey) error
+	Lift(key ShortKey) error
+}
+
+// ConsistencyReport counts the keys of a storage and
+// the anomalies found among them
+type ConsistencyReport struct {
+	Available int64
+	Taken     int64
+
+	Duplicated      int64 // both available and taken
+	BannedAvailable int64 // banned yet available
+}
+
+// ConsistencyChecker is implemented by storages
+// able to check their keys
+type ConsistencyChecker interface {
+	Check() (ConsistencyReport, error)
+}
+
+// State returns the state of the key in the storage
+func (a *Allocator) State(ctx context.Context, key ShortKey) (KeyState, error) {
+	if _, err := NewKeyFromBytes(key); err != nil {
+		return "", err
+	}
+
+	var state KeyState
+	err := a.retry(ctx, func(entity app.KeyValueEntity) error {
+		inspector, ok := app.As[KeyInspector](entity)
+		if !ok {
+			return ErrInspectUnsupported
+		}
+
+		s, err := inspector.State(key)
+		state = s
+
+		return err
+	})
+
+	return state, err
+}
+
+// Ban takes the key out of the pool for good,
+// forgetting its owner
+func (a *Allocator) Ban(ctx context.Context, key ShortKey) error {
+	if _, err := NewKeyFromBytes(key); err != nil {
+		return err
+	}
+
+	owner, err := a.owner(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	err = a.retry(ctx, func(entity app.KeyValueEntity) error {
+		banner, ok := app.As[KeyBanner](entity)
+		if !ok {
+			return ErrInspectUnsupported
+		}
+
+		return banner.Ban(key)
+	})
+	if err != nil {
+		return err
+	}
+	a.audit(ctx, key, AuditBanned, KeyBanned)
+	a.publish(ctx, EventBanned, key, 1)
+
+	if err := a.disown(ctx, key, owner); err != nil {
+		return fmt.Errorf("key banned but its owner kept: %w", err)
+	}
+
+	return nil
+}
+
+// Quarantine holds the key out of the pool until the
+// quarantine is lifted, unless someone holds the key
+func (a *Allocator) Quarantine(ctx context.Context, key ShortKey) error {
+	if _, err := NewKeyFromBytes(key); err != nil {
+		return err
+	}
+
+	err := a.retry(ctx, func(entity app.KeyValueEntity) error {
+		quarantiner, ok := app.As[KeyQuarantiner](entity)
+		if !ok {
+			return ErrInspectUnsupported
+		}
+
+		return quarantiner.Quarantine(key)
+	})
+	if err != nil {
+		return err
+	}
+	a.audit(ctx, key, AuditQuarantined, KeyQuarantined)
+	a.publish(ctx, EventQuarantined, key, 1)
+
+	return nil
+}
+
+// LiftQuarantine makes the quarantined key available again
+func (a *Allocator) LiftQuarantine(ctx context.Context, key ShortKey) error {
+	if _, err := NewKeyFromBytes(key); err != nil {
+		return err
+	}
+
+	// lifts are atomic, a key not quarantined after a
+	// transient failure was lifted by the failed attempt
+	failed := false
+	err := a.retry(ctx, func(entity app.KeyValueEntity) error {
+		quarantiner, ok := app.As[KeyQuarantiner](entity)
+		if !ok {
+			return ErrInspectUnsupported
+		}
+
+		err := quarantiner.Lift(key)
+		if failed && errors.Is(err, ErrKeyNotFound) {
+			return nil
+		}
+		failed = err != nil
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	a.audit(ctx, key, AuditQuarantineLifted, KeyAvailable)
+	a.publish(ctx, EventQuarantineLifted, key, 1)
+
+	return nil
+}
+
+// Check checks the keys of the storage
+func (a *Allocator) Check(ctx context.Context) (ConsistencyReport, error) {
+	var report ConsistencyReport
+	err := a.retry(ctx, func(entity app.KeyValueEntity) error {
+		checker, ok := app.As[ConsistencyChecker](entity)
+		if !ok {
+			return ErrInspectUnsupported
+		}
+
+		r, err := checker.Check()
+		report = r
+
+		return err
+	})
+
+	return report, err
+}
+
+// Seed creates n new keys out of the generator, see SeedKeys
+func (a *Allocator) Seed(ctx context.Context, generator func() (*ShortKey, error), n int64) (int64, error) {
+	entity, err := a.entity()
+	if err != nil {
+		return 0, err
+	}
+
+	created, err := SeedKeys(ctx, entity, generator, n)
+	if created > 0 {
+		a.publish(ctx, EventCreated, nil, created)
+	}
+
+	return created, err
+}
+
+// SeedKeys creates n new keys out of the generator, in batches
+// when the storage supports them, returning how many were
+// created; generated keys that already exist don't count,
+// seeding stops once a whole batch already existed
+func SeedKeys(ctx context.Context, entity app.KeyValueEntity, generator func() (*ShortKey, error), n int64) (int64, error) {
+	const batchSize = 1000
+
+	var created int64
+	for created < n {
+		if err := ctx.Err(); err != nil {
+			return created, err
+		}
+
+		batch := make([]interface{}, 0, min(batchSize, n-created))
+		for range cap(batch) {
+			k, err := generator()
+			if err != nil {
+				return created, fmt.Errorf("failed to generate key: %w", err)
+			}
+			batch = append(batch, k)
+		}
+
+		c, err := createBatch(entity, batch)
+		created += c
+		if err != nil {
+			return created, err
+		}
+
+		if c == 0 {
+			return created, fmt.Errorf("failed to seed keys, key space saturated: %w", ErrKeyExists)
+		}
+	}
+
+	return created, nil
+}
+
+// createBatch creates the keys in a batch when
+// supported, one by one otherwise
+func createBatch(entity app.KeyValueEntity, items []interface{}) (int64, error) {
+	if batchEntity, ok := app.As[app.KeyValueBatchEntity](entity); ok {
+		return batchEntity.CreateBatch(items)
+	}
+
+	var created int64
+	for _, i := range items {
+		if err := entity.Create(i); errors.Is(err, ErrKeyExists) {
+			continue
+		} else if err != nil {
+			return created, err
+		}
+		created++
+	}
+
+	return created, nil
+}
@@ -0,0 +1,221 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"keygen-service/app"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAllocator_GivenBannedKey(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(2))
+	owners := &ownershipMock{}
+	allocator := NewAllocator(entity, nil)
+	allocator.Owners = owners
+
+	key, _, err := allocator.AllocateOwned(context.Background())
+	if err != nil {
+		t.Fatalf("AllocateOwned() failed: %v", err)
+	}
+
+	if err := allocator.Ban(context.Background(), key); err != nil {
+		t.Fatalf("Ban() failed: %v", err)
+	}
+
+	if state, err := allocator.State(context.Background(), key); state != KeyBanned || err != nil {
+		t.Errorf("State() = %v, %v, want %v", state, err, KeyBanned)
+	}
+
+	if owner, _ := owners.Owner(key); owner != "" {
+		t.Errorf("Ban() kept owner %q, want the token forgotten", owner)
+	}
+
+	if err := allocator.Release(context.Background(), key); !errors.Is(err, ErrKeyBanned) {
+		t.Errorf("Release() = %v, want %v", err, ErrKeyBanned)
+	}
+
+	available := ShortKey(entity.sets[KeyAvailable][0])
+	if state, err := allocator.State(context.Background(), available); state != KeyAvailable || err != nil {
+		t.Errorf("State() = %v, %v, want %v", state, err, KeyAvailable)
+	}
+
+	unknown := ShortKey("AAAAAA")
+	if state, err := allocator.State(context.Background(), unknown); state != KeyUnknown || err != nil {
+		t.Errorf("State() = %v, %v, want %v", state, err, KeyUnknown)
+	}
+
+	if err := allocator.Reserve(context.Background(), unknown); err != nil {
+		t.Fatalf("Reserve() failed: %v", err)
+	}
+
+	if state, err := allocator.State(context.Background(), unknown); state != KeyReserved || err != nil {
+		t.Errorf("State() = %v, %v, want %v", state, err, KeyReserved)
+	}
+
+	report, err := allocator.Check(context.Background())
+	want := ConsistencyReport{Available: 1, Taken: 2}
+	if report != want || err != nil {
+		t.Errorf("Check() = %+v, %v, want %+v", report, err, want)
+	}
+}
+
+func TestAllocator_GivenQuarantinedKey(t *testing.T) {
+	ctx := context.Background()
+	allocator := NewAllocator(newMemoryKeyValueEntityMock(map[string]KeyState{
+		"aaaaaa": KeyAvailable,
+		"bbbbbb": KeyTaken,
+		"cccccc": KeyBanned,
+	}), nil)
+	key := ShortKey("aaaaaa")
+
+	if err := allocator.Quarantine(ctx, key); err != nil {
+		t.Fatalf("Quarantine() failed: %v", err)
+	}
+
+	if state, err := allocator.State(ctx, key); state != KeyQuarantined || err != nil {
+		t.Errorf("State() = %v, %v, want %v", state, err, KeyQuarantined)
+	}
+
+	if _, err := allocator.Allocate(ctx); !errors.Is(err, ErrNoAvailableKeys) {
+		t.Errorf("Allocate() = %v, want %v", err, ErrNoAvailableKeys)
+	}
+
+	if err := allocator.Release(ctx, key); !errors.Is(err, ErrKeyQuarantined) {
+		t.Errorf("Release() = %v, want %v", err, ErrKeyQuarantined)
+	}
+
+	stats, err := allocator.CountStates(ctx, false)
+	want := StateCounts{Taken: 3, Quarantined: 1, Banned: 1}
+	if stats.StateCounts != want || err != nil {
+		t.Errorf("CountStates() = %+v, %v, want %+v", stats, err, want)
+	}
+
+	for _, tt := range []struct {
+		key  ShortKey
+		want error
+	}{{ShortKey("bbbbbb"), ErrKeyTaken}, {ShortKey("cccccc"), ErrKeyBanned}} {
+		if err := allocator.Quarantine(ctx, tt.key); !errors.Is(err, tt.want) {
+			t.Errorf("Quarantine(%s) = %v, want %v", tt.key, err, tt.want)
+		}
+	}
+
+	if err := allocator.LiftQuarantine(ctx, key); err != nil {
+		t.Fatalf("LiftQuarantine() failed: %v", err)
+	}
+
+	if k, err := allocator.Allocate(ctx); err != nil || string(k) != "aaaaaa" {
+		t.Errorf("Allocate() = %s, %v, want the lifted key", k, err)
+	}
+
+	if err := allocator.LiftQuarantine(ctx, key); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("LiftQuarantine() = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestAllocator_GivenUninspectableStorage(t *testing.T) {
+	allocator := NewAllocator(struct{ app.KeyValueEntity }{newMemoryKeyValueEntityMock(generatedKeys(1))}, nil)
+	key := ShortKey("AAAAAA")
+
+	if _, err := allocator.State(context.Background(), key); !errors.Is(err, ErrInspectUnsupported) {
+		t.Errorf("State() = %v, want %v", err, ErrInspectUnsupported)
+	}
+
+	if err := allocator.Ban(context.Background(), key); !errors.Is(err, ErrInspectUnsupported) {
+		t.Errorf("Ban() = %v, want %v", err, ErrInspectUnsupported)
+	}
+
+	if stats := allocator.Stats(); stats.Failures != 2 {
+		t.Errorf("Stats() = %+v, want 2 failures without retries", stats)
+	}
+}
+
+func TestSeedKeys_GivenGenerator(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(nil)
+
+	created, err := SeedKeys(context.Background(), entity, NextKey, 2500)
+	if created != 2500 || err != nil {
+		t.Fatalf("SeedKeys() = %v, %v, want 2500 keys", created, err)
+	}
+
+	if keys, _ := entity.count(); keys != 2500 || entity.batches != 3 {
+		t.Errorf("SeedKeys() created %v keys in %v batches, want 2500 in 3", keys, entity.batches)
+	}
+
+	same := func() (*ShortKey, error) {
+		k := ShortKey("AAAAAA")
+		return &k, nil
+	}
+
+	created, err = SeedKeys(context.Background(), entity, same, 10)
+	if created != 1 || !errors.Is(err, ErrKeyExists) {
+		t.Errorf("SeedKeys() = %v, %v, want 1 key and %v", created, err, ErrKeyExists)
+	}
+}
+
+func TestAdminRPCHandler_GivenKeysMaintenance(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(1))
+	allocator := NewAllocator(entity, nil)
+	admin := &AdminRPCHandler{Namespaces: &Namespaces{Registry: &namespaceRegistryMock{}, Default: allocator}}
+
+	key := ShortKey(entity.sets[KeyAvailable][0])
+	if _, err := admin.BanKey(context.Background(), &KeyRequest{Key: key}); err != nil {
+		t.Fatalf("BanKey() failed: %v", err)
+	}
+
+	res, err := admin.LookupKey(context.Background(), &KeyRequest{Key: key})
+	if err != nil || res.GetState() != string(KeyBanned) {
+		t.Errorf("LookupKey() = %v, %v, want %v", res, err, KeyBanned)
+	}
+
+	if _, err := admin.LookupKey(context.Background(), &KeyRequest{Key: []byte("?")}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("LookupKey() = %v, want %v", err, codes.InvalidArgument)
+	}
+
+	if _, err := admin.CheckKeys(context.Background(), &NamespaceRequest{Name: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("CheckKeys() = %v, want %v", err, codes.NotFound)
+	}
+
+	stats, err := admin.GetStats(context.Background(), &StatsRequest{})
+	if err != nil || stats.GetAvailable() != 0 || stats.GetTaken() != 1 {
+		t.Errorf("GetStats() = %v, %v, want 0 available and 1 taken", stats, err)
+	}
+
+	if _, err := admin.SeedKeys(context.Background(), &SeedRequest{Count: 0}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("SeedKeys() = %v, want %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestAdminRPCHandler_GivenQuarantine(t *testing.T) {
+	allocator := NewAllocator(newMemoryKeyValueEntityMock(map[string]KeyState{"aaaaaa": KeyAvailable, "bbbbbb": KeyTaken}), nil)
+	admin := &AdminRPCHandler{Namespaces: &Namespaces{Registry: &namespaceRegistryMock{}, Default: allocator}}
+	ctx := context.Background()
+
+	if _, err := admin.QuarantineKey(ctx, &KeyRequest{Key: []byte("aaaaaa")}); err != nil {
+		t.Fatalf("QuarantineKey() failed: %v", err)
+	}
+
+	stats, err := admin.GetStats(ctx, &StatsRequest{})
+	if err != nil || stats.GetQuarantined() != 1 || stats.GetBanned() != 0 {
+		t.Errorf("GetStats() = %v, %v, want 1 quarantined key", stats, err)
+	}
+
+	if _, err := admin.ForceReleaseKey(ctx, &KeyRequest{Key: []byte("aaaaaa")}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ForceReleaseKey() = %v, want %v", err, codes.FailedPrecondition)
+	}
+
+	if _, err := admin.QuarantineKey(ctx, &KeyRequest{Key: []byte("bbbbbb")}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("QuarantineKey() = %v, want %v for a taken key", err, codes.FailedPrecondition)
+	}
+
+	if _, err := admin.LiftQuarantine(ctx, &KeyRequest{Key: []byte("aaaaaa")}); err != nil {
+		t.Fatalf("LiftQuarantine() failed: %v", err)
+	}
+
+	if res, err := admin.LookupKey(ctx, &KeyRequest{Key: []byte("aaaaaa")}); err != nil || res.GetState() != string(KeyAvailable) {
+		t.Errorf("LookupKey() = %v, %v, want %v", res, err, KeyAvailable)
+	}
+}
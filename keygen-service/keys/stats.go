@@ -0,0 +1,192 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"strings"
+
+	"github.com/valkey-io/valkey-glide/go/api"
+	"github.com/valkey-io/valkey-glide/go/api/options"
+)
+
+const (
+	// DefaultListCount keys are listed per page by default
+	DefaultListCount = 100
+
+	// MaxListCount keys are listed per page at most
+	MaxListCount = 1000
+)
+
+// ErrInvalidListing is returned when listing keys
+// of an unknown state or with a foreign cursor
+var ErrInvalidListing = errors.New("invalid keys listing")
+
+// StateCounts counts the keys of each state; taken keys
+// include the reserved, quarantined and banned ones
+type StateCounts struct {
+	Available   int64
+	Taken       int64
+	Reserved    int64
+	Quarantined int64
+	Banned      int64
+}
+
+// PoolStats counts the keys of a storage,
+// by key length when asked to
+type PoolStats struct {
+	StateCounts
+
+	Lengths map[int]StateCounts
+}
+
+// StateCounter is implemented by storages able to
+// count their keys of each state; counting by
+// length may scan the whole storage
+type StateCounter interface {
+	CountStates(byLength bool) (PoolStats, error)
+}
+
+// KeyLister is implemented by storages able to list their
+// keys of a state by pages; the cursor is empty for the first
+// page and once done, pages may hold more or less than count
+type KeyLister interface {
+	ListKeys(state KeyState, cursor string, count int) ([]ShortKey, string, error)
+}
+
+// CountStates counts the keys of the storage, only
+// available and taken ones unless it can tell more
+func (a *Allocator) CountStates(ctx context.Context, byLength bool) (PoolStats, error) {
+	var stats PoolStats
+	err := a.retry(ctx, func(entity app.KeyValueEntity) error {
+		if counter, ok := app.As[StateCounter](entity); ok {
+			s, err := counter.CountStates(byLength)
+			if !errors.Is(err, ErrInspectUnsupported) {
+				stats = s
+				return err
+			}
+		}
+
+		counter, ok := app.As[app.KeyValueCounter](entity)
+		if !ok || byLength {
+			return ErrInspectUnsupported
+		}
+
+		available, taken, err := counter.Count()
+		stats = PoolStats{StateCounts: StateCounts{Available: available, Taken: taken}}
+
+		return err
+	})
+
+	return stats, err
+}
+
+// ListKeys returns a page of the keys in the given state, or
+// of every key, the available ones first, when state is empty
+func (a *Allocator) ListKeys(ctx context.Context, state KeyState, cursor string, count int) ([]ShortKey, string, error) {
+	if count <= 0 {
+		count = DefaultListCount
+	}
+	count = min(count, MaxListCount)
+
+	if state != "" {
+		return a.listKeys(ctx, state, cursor, count)
+	}
+
+	// the cursor of every key is the cursor
+	// of the state being listed, prefixed
+	state, stateCursor := KeyAvailable, cursor
+	if s, c, ok := strings.Cut(cursor, ":"); ok {
+		state, stateCursor = KeyState(s), c
+	}
+
+	if state != KeyAvailable && state != KeyTaken {
+		return nil, "", fmt.Errorf("%w: cursor %q", ErrInvalidListing, cursor)
+	}
+
+	page, next, err := a.listKeys(ctx, state, stateCursor, count)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case next != "":
+		next = string(state) + ":" + next
+	case state == KeyAvailable:
+		next = string(KeyTaken) + ":"
+	}
+
+	return page, next, nil
+}
+
+func (a *Allocator) listKeys(ctx context.Context, state KeyState, cursor string, count int) ([]ShortKey, string, error) {
+	switch state {
+	case KeyAvailable, KeyTaken, KeyReserved, KeyQuarantined, KeyBanned:
+	default:
+		return nil, "", fmt.Errorf("%w: state %q", ErrInvalidListing, state)
+	}
+
+	var page []ShortKey
+	var next string
+	err := a.retry(ctx, func(entity app.KeyValueEntity) error {
+		lister, ok := app.As[KeyLister](entity)
+		if !ok {
+			return ErrInspectUnsupported
+		}
+
+		p, n, err := lister.ListKeys(state, cursor, count)
+		page, next = p, n
+
+		return err
+	})
+
+	return page, next, err
+}
+
+// scanSet returns a page of the set members,
+// an empty cursor starting and ending the scan
+func scanSet(valkeyClient api.GlideClientCommands, set, cursor string, count int) ([]ShortKey, string, error) {
+	if cursor == "" {
+		cursor = "0"
+	}
+
+	next, members, err := valkeyClient.SScanWithOptions(set, cursor, *options.NewBaseScanOptions().SetCount(int64(count)))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to scan %s: %w", set, err)
+	}
+
+	page := make([]ShortKey, len(members))
+	for i, m := range members {
+		page[i] = ShortKey(m)
+	}
+
+	if next == "0" {
+		next = ""
+	}
+
+	return page, next, nil
+}
+
+// countSetLengths adds the set members
+// to the counts of their length
+func countSetLengths(valkeyClient api.GlideClientCommands, set string, lengths map[int]StateCounts, add func(*StateCounts)) error {
+	cursor := ""
+	for {
+		page, next, err := scanSet(valkeyClient, set, cursor, 1000)
+		if err != nil {
+			return err
+		}
+
+		for _, k := range page {
+			counts := lengths[len(k)]
+			add(&counts)
+			lengths[len(k)] = counts
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,86 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"keygen-service/app"
+)
+
+func TestAllocator_GivenKeysListing(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(5))
+	allocator := NewAllocator(entity, nil)
+
+	for range 2 {
+		if _, err := allocator.Allocate(context.Background()); err != nil {
+			t.Fatalf("Allocate() failed: %v", err)
+		}
+	}
+
+	var listed []ShortKey
+	var pages int
+	for cursor := ""; pages == 0 || cursor != ""; pages++ {
+		page, next, err := allocator.ListKeys(context.Background(), "", cursor, 0)
+		if err != nil {
+			t.Fatalf("ListKeys() failed: %v", err)
+		}
+
+		listed, cursor = append(listed, page...), next
+	}
+
+	if len(listed) != 5 || pages != 3 {
+		t.Errorf("ListKeys() listed %v keys in %v pages, want 5 keys in 3", len(listed), pages)
+	}
+
+	taken, _, err := allocator.ListKeys(context.Background(), KeyTaken, "", 0)
+	if err != nil || len(taken) != 2 {
+		t.Errorf("ListKeys() = %v, %v, want the 2 taken keys", taken, err)
+	}
+
+	for _, tt := range []struct {
+		state  KeyState
+		cursor string
+	}{{"expired", ""}, {"", "banned:1"}} {
+		if _, _, err := allocator.ListKeys(context.Background(), tt.state, tt.cursor, 0); !errors.Is(err, ErrInvalidListing) {
+			t.Errorf("ListKeys(%q, %q) = %v, want %v", tt.state, tt.cursor, err, ErrInvalidListing)
+		}
+	}
+}
+
+func TestAllocator_GivenLargePages(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(map[string]KeyState{"aaaaaa": KeyQuarantined})
+	allocator := NewAllocator(entity, nil)
+
+	page, _, err := allocator.ListKeys(context.Background(), KeyQuarantined, "", 1_000_000)
+	if err != nil || len(page) != 1 {
+		t.Errorf("ListKeys() = %v, %v, want the quarantined key", page, err)
+	}
+
+	if entity.listCount != MaxListCount {
+		t.Errorf("ListKeys() listed %d keys per page, want %d", entity.listCount, MaxListCount)
+	}
+}
+
+func TestAllocator_GivenCountingStorage(t *testing.T) {
+	entity := newMemoryKeyValueEntityMock(generatedKeys(3))
+	counting := struct {
+		app.KeyValueEntity
+		app.KeyValueCounter
+	}{entity, entity}
+	allocator := NewAllocator(&MonitoredEntity{KeyValueEntity: counting, Monitor: &KeySpaceMonitor{}}, nil)
+
+	if _, err := allocator.Allocate(context.Background()); err != nil {
+		t.Fatalf("Allocate() failed: %v", err)
+	}
+
+	stats, err := allocator.CountStates(context.Background(), false)
+	want := StateCounts{Available: 2, Taken: 1}
+	if stats.StateCounts != want || err != nil {
+		t.Errorf("CountStates() = %+v, %v, want %+v", stats, err, want)
+	}
+
+	if _, err := allocator.CountStates(context.Background(), true); !errors.Is(err, ErrInspectUnsupported) {
+		t.Errorf("CountStates() = %v, want %v by length", err, ErrInspectUnsupported)
+	}
+}
@@ -0,0 +1,960 @@
+package keys
+
+import (
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"math/big"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/valkey-io/valkey-glide/go/api"
+)
+
+const (
+	KeysBitmapName         = "keysBitmap"
+	TakenKeysBitmapName    = "takenKeysBitmap"
+	KeysBitmapSegmentsName = "keysBitmapSegments"
+	KeysBitmapIndexName    = "keysBitmapIndex"
+
+	// BitmapSegmentSize is the number of keys tracked
+	// by each bitmap; keys of a length sharing all but
+	// their last four characters fall into the same segment
+	BitmapSegmentSize = 1 << 24
+)
+
+// bitmapIndexComplete marks the index of bitmap segments
+// holding every segment, including those written before
+// segments were indexed
+const bitmapIndexComplete = "complete"
+
+// createBitScript marks the key of KEYS[1] and KEYS[2] at
+// offset ARGV[1] as available unless known, registering
+// the ARGV[2] segment in the KEYS[3] segments with available
+// keys and the KEYS[4] index; returns 1 when created
+const createBitScript = `
+if redis.call('GETBIT', KEYS[1], ARGV[1]) == 1 then
+  return 0
+end
+if redis.call('SETBIT', KEYS[2], ARGV[1], 1) == 1 then
+  return 0
+end
+redis.call('SADD', KEYS[3], ARGV[2])
+redis.call('SADD', KEYS[4], ARGV[2])
+return 1`
+
+// createBatchBitScript marks the keys of KEYS[1] and KEYS[2]
+// at offsets ARGV[2] onwards as available unless known,
+// registering the ARGV[1] segment in the KEYS[3] segments with
+// available keys and the KEYS[4] index; returns how many were
+// created
+const createBatchBitScript = `
+local created = 0
+for i = 2, #ARGV do
+  if redis.call('GETBIT', KEYS[1], ARGV[i]) == 0 and redis.call('SETBIT', KEYS[2], ARGV[i], 1) == 0 then
+    created = created + 1
+  end
+end
+if created > 0 then
+  redis.call('SADD', KEYS[3], ARGV[1])
+  redis.call('SADD', KEYS[4], ARGV[1])
+end
+return created`
+
+// allocateBitScript moves the first available key of the KEYS[1]
+// bitmap from byte ARGV[1], wrapping around, to the KEYS[2] taken
+// one; returns its offset, or -1 once the bitmap is exhausted,
+// dropping the ARGV[2] segment from the KEYS[3] segments with
+// available keys
+const allocateBitScript = `
+local offset = -1
+local size = redis.call('STRLEN', KEYS[1])
+if size > 0 then
+  local start = tonumber(ARGV[1]) % size
+  offset = redis.call('BITPOS', KEYS[1], 1, start)
+  if offset < 0 then
+    offset = redis.call('BITPOS', KEYS[1], 1, 0, start)
+  end
+end
+if offset < 0 then
+  redis.call('SREM', KEYS[3], ARGV[2])
+  return -1
+end
+redis.call('SETBIT', KEYS[1], offset, 0)
+redis.call('SETBIT', KEYS[2], offset, 1)
+return offset`
+
+// reserveBitScript moves the ARGV[2] key at offset ARGV[1]
+// to the KEYS[2] taken bitmap and the KEYS[3] reserved set,
+// out of the KEYS[1] available bitmap; returns 0 when
+// already taken
+const reserveBitScript = `
+if redis.call('SETBIT', KEYS[2], ARGV[1], 1) == 1 then
+  return 0
+end
+redis.call('SETBIT', KEYS[1], ARGV[1], 0)
+redis.call('SADD', KEYS[3], ARGV[2])
+return 1`
+
+// deallocateBitScript moves the ARGV[2] key at offset ARGV[1]
+// from the KEYS[2] taken bitmap back to the KEYS[1] available
+// one, out of the KEYS[3] reserved set, registering the ARGV[3]
+// segment in the KEYS[5] segments with available keys; returns
+// -1 when in the KEYS[4] banned set, -2 when in the KEYS[6]
+// quarantined set and 0 when not taken
+const deallocateBitScript = `
+if redis.call('SISMEMBER', KEYS[4], ARGV[2]) == 1 then
+  return -1
+end
+if redis.call('SISMEMBER', KEYS[6], ARGV[2]) == 1 then
+  return -2
+end
+if redis.call('SETBIT', KEYS[2], ARGV[1], 0) == 0 then
+  return 0
+end
+redis.call('SETBIT', KEYS[1], ARGV[1], 1)
+redis.call('SADD', KEYS[5], ARGV[3])
+redis.call('SREM', KEYS[3], ARGV[2])
+return 1`
+
+// quarantineBitScript moves the ARGV[2] key at offset ARGV[1]
+// to the KEYS[2] taken bitmap and the KEYS[4] quarantined set,
+// out of the KEYS[1] available bitmap; returns 1 when
+// quarantined, already or now, -1 when in the KEYS[3] banned
+// set and 0 when taken
+const quarantineBitScript = `
+if redis.call('SISMEMBER', KEYS[4], ARGV[2]) == 1 then
+  return 1
+end
+if redis.call('SISMEMBER', KEYS[3], ARGV[2]) == 1 then
+  return -1
+end
+if redis.call('SETBIT', KEYS[2], ARGV[1], 1) == 1 then
+  return 0
+end
+redis.call('SETBIT', KEYS[1], ARGV[1], 0)
+redis.call('SADD', KEYS[4], ARGV[2])
+return 1`
+
+// banBitScript moves the ARGV[2] key at offset ARGV[1] to the
+// KEYS[2] taken bitmap and the KEYS[3] banned set, out of the
+// KEYS[1] available bitmap and the KEYS[4] reserved and KEYS[5]
+// quarantined sets, whatever its state, indexing the ARGV[3]
+// segment in KEYS[6]
+const banBitScript = `
+redis.call('SADD', KEYS[3], ARGV[2])
+redis.call('SADD', KEYS[6], ARGV[3])
+redis.call('SETBIT', KEYS[2], ARGV[1], 1)
+redis.call('SETBIT', KEYS[1], ARGV[1], 0)
+redis.call('SREM', KEYS[4], ARGV[2])
+redis.call('SREM', KEYS[5], ARGV[2])
+return 1`
+
+// liftBitScript moves the ARGV[2] key at offset ARGV[1] out of
+// the KEYS[3] quarantined set and from the KEYS[2] taken bitmap
+// back to the KEYS[1] available one, registering the ARGV[3]
+// segment in the KEYS[4] segments with available keys; returns
+// 0 when not quarantined
+const liftBitScript = `
+if redis.call('SREM', KEYS[3], ARGV[2]) == 0 then
+  return 0
+end
+redis.call('SETBIT', KEYS[2], ARGV[1], 0)
+redis.call('SETBIT', KEYS[1], ARGV[1], 1)
+redis.call('SADD', KEYS[4], ARGV[3])
+return 1`
+
+// ValkeyBitmap stores keys as bits indexed by ShortKey.Index,
+// one bit in an available bitmap and one in a taken bitmap;
+// the key space is split into segments because valkey strings
+// are limited to 512MB; bitmaps only save memory when their
+// keys are dense, so generate keys with NextKeyInSegments;
+// keys of every length share the same segments sequence,
+// the segments written being registered in an index
+type ValkeyBitmap struct {
+	// Client connects to the db, required
+	Client app.KeyValueDbClient
+
+	// Namespace of the keys, the default one when empty
+	Namespace string
+}
+
+// Create marks a new key as available
+func (k *ValkeyBitmap) Create(i interface{}) error {
+	newKey, ok := i.(*ShortKey)
+	if !ok {
+		return fmt.Errorf("i is not a valid shortkey")
+	}
+
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	segment, offset := bitmapPosition(newKey)
+
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", createBitScript, "4",
+		bitmapSegmentName(k.takenKeysName(), segment), bitmapSegmentName(k.keysName(), segment),
+		k.segmentsName(), k.indexName(),
+		strconv.FormatInt(offset, 10), strconv.FormatUint(segment, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to push to db: %w", err)
+	}
+
+	if created, _ := res.(int64); created != 1 {
+		return fmt.Errorf("failed to push to db: %w", ErrKeyExists)
+	}
+
+	return nil
+}
+
+// CreateBatch marks many new keys as available, with
+// a script per bitmap segment, returns how many
+// weren't there yet
+func (k *ValkeyBitmap) CreateBatch(items []interface{}) (int64, error) {
+	segments := map[uint64][]int64{}
+	for n, i := range items {
+		newKey, ok := i.(*ShortKey)
+		if !ok {
+			return 0, fmt.Errorf("item %d is not a valid shortkey", n)
+		}
+
+		segment, offset := bitmapPosition(newKey)
+		segments[segment] = append(segments[segment], offset)
+	}
+
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return 0, err
+	}
+
+	var created int64
+	for segment, offsets := range segments {
+		args := []string{
+			"EVAL", createBatchBitScript, "4",
+			bitmapSegmentName(k.takenKeysName(), segment), bitmapSegmentName(k.keysName(), segment),
+			k.segmentsName(), k.indexName(),
+			strconv.FormatUint(segment, 10),
+		}
+		for _, offset := range offsets {
+			args = append(args, strconv.FormatInt(offset, 10))
+		}
+
+		res, err := valkeyClient.CustomCommand(args)
+		if err != nil {
+			return created, fmt.Errorf("failed to push to db: %w", err)
+		}
+
+		n, ok := res.(int64)
+		if !ok {
+			return created, fmt.Errorf("incompatible result type: %v", res)
+		}
+		created += n
+	}
+
+	return created, nil
+}
+
+// bitmapAllocationAttempts bounds the exhausted segments
+// dropped by an allocation before it gives up
+const bitmapAllocationAttempts = 16
+
+// AllocateFirst picks a random available key,
+// marks it as taken and returns that key
+func (k *ValkeyBitmap) AllocateFirst() (interface{}, error) {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	for range bitmapAllocationAttempts {
+		res, err := valkeyClient.SRandMember(k.segmentsName())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get a key segment: %w", err)
+		}
+
+		if res.Value() == "" {
+			return nil, ErrNoAvailableKeys
+		}
+
+		segment, err := strconv.ParseUint(res.Value(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key segment %q: %w", res.Value(), err)
+		}
+
+		// #nosec G404 -- only spreads concurrent allocations
+		start := rand.Uint64N(BitmapSegmentSize / 8)
+
+		moved, err := valkeyClient.CustomCommand([]string{
+			"EVAL", allocateBitScript, "3",
+			bitmapSegmentName(k.keysName(), segment), bitmapSegmentName(k.takenKeysName(), segment),
+			k.segmentsName(),
+			strconv.FormatUint(start, 10), res.Value(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get a key: %w", err)
+		}
+
+		offset, ok := moved.(int64)
+		if !ok {
+			return nil, fmt.Errorf("incompatible result type: %v", moved)
+		}
+
+		if offset < 0 { // exhausted and dropped, look elsewhere
+			continue
+		}
+
+		movedKey, err := NewKeyFromIndex(segment*BitmapSegmentSize + uint64(offset))
+		if err != nil {
+			return nil, fmt.Errorf("incompatible result type: %w", err)
+		}
+
+		return *movedKey, nil
+	}
+
+	return nil, ErrNoAvailableKeys
+}
+
+// Reserve allocates the given key unless allocated
+func (k *ValkeyBitmap) Reserve(i interface{}) error {
+	key, ok := i.(*ShortKey)
+	if !ok {
+		return errors.New("incompatible key type")
+	}
+
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	segment, offset := bitmapPosition(key)
+
+	if err := k.indexSegment(valkeyClient, segment); err != nil {
+		return err
+	}
+
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", reserveBitScript, "3",
+		bitmapSegmentName(k.keysName(), segment), bitmapSegmentName(k.takenKeysName(), segment),
+		k.reservedKeysName(),
+		strconv.FormatInt(offset, 10), string(*key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reserve the key: %w", err)
+	}
+
+	if reserved, _ := res.(int64); reserved != 1 {
+		return fmt.Errorf("failed to reserve the key: %w", ErrKeyTaken)
+	}
+
+	return nil
+}
+
+// Deallocate makes the given key available again
+func (k *ValkeyBitmap) Deallocate(i interface{}) error {
+	key, ok := i.(*ShortKey)
+	if !ok {
+		return errors.New("incompatible key type")
+	}
+
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	segment, offset := bitmapPosition(key)
+
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", deallocateBitScript, "6",
+		bitmapSegmentName(k.keysName(), segment), bitmapSegmentName(k.takenKeysName(), segment),
+		k.reservedKeysName(), k.bannedKeysName(), k.segmentsName(), k.quarantinedKeysName(),
+		strconv.FormatInt(offset, 10), string(*key), strconv.FormatUint(segment, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to deallocate the key: %w", err)
+	}
+
+	return deallocated(res)
+}
+
+// State returns the state of the given key, banned,
+// quarantined and reserved keys being kept in sets
+func (k *ValkeyBitmap) State(key ShortKey) (KeyState, error) {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return "", err
+	}
+
+	// banned, quarantined and reserved keys are
+	// taken too, so they are looked up first
+	for _, set := range []struct {
+		name  string
+		state KeyState
+	}{
+		{k.bannedKeysName(), KeyBanned},
+		{k.quarantinedKeysName(), KeyQuarantined},
+		{k.reservedKeysName(), KeyReserved},
+	} {
+		found, err := valkeyClient.SIsMember(set.name, string(key))
+		if err != nil {
+			return "", fmt.Errorf("failed to look up the key: %w", err)
+		}
+
+		if found {
+			return set.state, nil
+		}
+	}
+
+	segment, offset := bitmapPosition(&key)
+
+	for _, bitmap := range []struct {
+		name  string
+		state KeyState
+	}{
+		{k.takenKeysName(), KeyTaken},
+		{k.keysName(), KeyAvailable},
+	} {
+		bit, err := valkeyClient.GetBit(bitmapSegmentName(bitmap.name, segment), offset)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up the key: %w", err)
+		}
+
+		if bit == 1 {
+			return bitmap.state, nil
+		}
+	}
+
+	return KeyUnknown, nil
+}
+
+// Ban marks the given key as taken for good, whatever its state
+func (k *ValkeyBitmap) Ban(key ShortKey) error {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	segment, offset := bitmapPosition(&key)
+
+	if _, err := valkeyClient.CustomCommand([]string{
+		"EVAL", banBitScript, "6",
+		bitmapSegmentName(k.keysName(), segment), bitmapSegmentName(k.takenKeysName(), segment),
+		k.bannedKeysName(), k.reservedKeysName(), k.quarantinedKeysName(), k.indexName(),
+		strconv.FormatInt(offset, 10), string(key), strconv.FormatUint(segment, 10),
+	}); err != nil {
+		return fmt.Errorf("failed to ban the key: %w", err)
+	}
+
+	return nil
+}
+
+// Quarantine marks the given key, unless taken, as
+// taken and keeps it in the quarantined set
+func (k *ValkeyBitmap) Quarantine(key ShortKey) error {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	segment, offset := bitmapPosition(&key)
+
+	if err := k.indexSegment(valkeyClient, segment); err != nil {
+		return err
+	}
+
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", quarantineBitScript, "4",
+		bitmapSegmentName(k.keysName(), segment), bitmapSegmentName(k.takenKeysName(), segment),
+		k.bannedKeysName(), k.quarantinedKeysName(),
+		strconv.FormatInt(offset, 10), string(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to quarantine the key: %w", err)
+	}
+
+	return quarantined(res)
+}
+
+// Lift makes the given quarantined key available again
+func (k *ValkeyBitmap) Lift(key ShortKey) error {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return err
+	}
+
+	segment, offset := bitmapPosition(&key)
+
+	res, err := valkeyClient.CustomCommand([]string{
+		"EVAL", liftBitScript, "4",
+		bitmapSegmentName(k.keysName(), segment), bitmapSegmentName(k.takenKeysName(), segment),
+		k.quarantinedKeysName(), k.segmentsName(),
+		strconv.FormatInt(offset, 10), string(key), strconv.FormatUint(segment, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to lift the key quarantine: %w", err)
+	}
+
+	return lifted(res)
+}
+
+// Count returns how many keys are available
+// and how many are allocated
+func (k *ValkeyBitmap) Count() (int64, int64, error) {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	segments, err := k.segments(valkeyClient)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var counts [2]int64
+	for i, bitmap := range []string{k.keysName(), k.takenKeysName()} {
+		for _, segment := range segments {
+			count, err := valkeyClient.BitCount(bitmapSegmentName(bitmap, segment))
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to count %s bits: %w", bitmap, err)
+			}
+
+			counts[i] += count
+		}
+	}
+
+	return counts[0], counts[1], nil
+}
+
+// CountStates counts the bits of each bitmap segment,
+// segments holding keys of a single length, and the
+// members of the reserved, quarantined and banned sets
+func (k *ValkeyBitmap) CountStates(byLength bool) (PoolStats, error) {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return PoolStats{}, err
+	}
+
+	stats := PoolStats{Lengths: map[int]StateCounts{}}
+	bitmaps := []struct {
+		name string
+		add  func(*StateCounts, int64)
+	}{
+		{k.keysName(), func(c *StateCounts, n int64) { c.Available += n }},
+		{k.takenKeysName(), func(c *StateCounts, n int64) { c.Taken += n }},
+	}
+
+	segments, err := k.segments(valkeyClient)
+	if err != nil {
+		return PoolStats{}, err
+	}
+
+	for _, bitmap := range bitmaps {
+		for _, segment := range segments {
+			count, err := valkeyClient.BitCount(bitmapSegmentName(bitmap.name, segment))
+			if err != nil {
+				return PoolStats{}, fmt.Errorf("failed to count %s bits: %w", bitmap.name, err)
+			}
+
+			length := keyLengthAt(segment * BitmapSegmentSize)
+			counts := stats.Lengths[length]
+			bitmap.add(&counts, count)
+			stats.Lengths[length] = counts
+
+			bitmap.add(&stats.StateCounts, count)
+		}
+	}
+
+	if stats.Reserved, err = valkeyClient.SCard(k.reservedKeysName()); err != nil {
+		return PoolStats{}, fmt.Errorf("failed to count reserved keys: %w", err)
+	}
+
+	if stats.Banned, err = valkeyClient.SCard(k.bannedKeysName()); err != nil {
+		return PoolStats{}, fmt.Errorf("failed to count banned keys: %w", err)
+	}
+
+	if stats.Quarantined, err = valkeyClient.SCard(k.quarantinedKeysName()); err != nil {
+		return PoolStats{}, fmt.Errorf("failed to count quarantined keys: %w", err)
+	}
+
+	if !byLength {
+		stats.Lengths = nil
+		return stats, nil
+	}
+
+	if err := countSetLengths(valkeyClient, k.reservedKeysName(), stats.Lengths, func(c *StateCounts) { c.Reserved++ }); err != nil {
+		return PoolStats{}, err
+	}
+
+	if err := countSetLengths(valkeyClient, k.bannedKeysName(), stats.Lengths, func(c *StateCounts) { c.Banned++ }); err != nil {
+		return PoolStats{}, err
+	}
+
+	if err := countSetLengths(valkeyClient, k.quarantinedKeysName(), stats.Lengths, func(c *StateCounts) { c.Quarantined++ }); err != nil {
+		return PoolStats{}, err
+	}
+
+	return stats, nil
+}
+
+// ListKeys returns a page of the keys of the given state, in
+// key space order for bitmaps, the cursor being the index of
+// the next key to look at
+func (k *ValkeyBitmap) ListKeys(state KeyState, cursor string, count int) ([]ShortKey, string, error) {
+	valkeyClient, err := valkeyConn(k.Client)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var bitmap string
+	switch state {
+	case KeyAvailable:
+		bitmap = k.keysName()
+	case KeyTaken:
+		bitmap = k.takenKeysName()
+	case KeyReserved:
+		return scanSet(valkeyClient, k.reservedKeysName(), cursor, count)
+	case KeyBanned:
+		return scanSet(valkeyClient, k.bannedKeysName(), cursor, count)
+	case KeyQuarantined:
+		return scanSet(valkeyClient, k.quarantinedKeysName(), cursor, count)
+	default:
+		return nil, "", fmt.Errorf("%w: state %q", ErrInvalidListing, state)
+	}
+
+	var start uint64
+	if cursor != "" {
+		if start, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, "", fmt.Errorf("%w: cursor %q", ErrInvalidListing, cursor)
+		}
+	}
+
+	segments, err := k.segments(valkeyClient)
+	if err != nil {
+		return nil, "", err
+	}
+
+	const chunk = 4096 // bytes read per round trip
+
+	var page []ShortKey
+	for _, segment := range segments {
+		if (segment+1)*BitmapSegmentSize <= start {
+			continue
+		}
+
+		offset := int64(0)
+		if segment*BitmapSegmentSize < start {
+			offset = int64(start - segment*BitmapSegmentSize) // #nosec G115 -- bounded by BitmapSegmentSize
+		}
+
+		for offset < BitmapSegmentSize {
+			byteOffset := int(offset / 8)
+			bits, err := valkeyClient.GetRange(bitmapSegmentName(bitmap, segment), byteOffset, byteOffset+chunk-1)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to read %s bits: %w", bitmap, err)
+			}
+
+			if bits == "" {
+				break
+			}
+
+			for bit := offset; bit < int64(byteOffset+len(bits))*8; bit++ {
+				if bits[bit/8-int64(byteOffset)]&(0x80>>(bit%8)) == 0 {
+					continue
+				}
+
+				key, err := NewKeyFromIndex(segment*BitmapSegmentSize + uint64(bit))
+				if err != nil {
+					return nil, "", fmt.Errorf("incompatible bitmap position: %w", err)
+				}
+
+				page = append(page, *key)
+				if len(page) == count {
+					return page, strconv.FormatUint(key.Index()+1, 10), nil
+				}
+			}
+
+			offset = int64(byteOffset+len(bits)) * 8
+		}
+	}
+
+	return page, "", nil
+}
+
+// segments returns the sorted segments written in either
+// bitmap, from their index; segments written before they
+// were indexed are found by scanning the db once
+func (k *ValkeyBitmap) segments(valkeyClient api.GlideClientCommands) ([]uint64, error) {
+	members, err := valkeyClient.SMembers(k.indexName())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list key segments: %w", err)
+	}
+
+	if _, complete := members[bitmapIndexComplete]; !complete {
+		if err := k.indexSegments(valkeyClient); err != nil {
+			return nil, err
+		}
+
+		if members, err = valkeyClient.SMembers(k.indexName()); err != nil {
+			return nil, fmt.Errorf("failed to list key segments: %w", err)
+		}
+	}
+
+	segments := make([]uint64, 0, len(members))
+	for member := range members {
+		segment, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			continue // the completion mark
+		}
+		segments = append(segments, segment)
+	}
+
+	slices.Sort(segments)
+	return segments, nil
+}
+
+// indexSegments scans the db for the segments of both
+// bitmaps, registering them in the index, then marked
+// complete
+func (k *ValkeyBitmap) indexSegments(valkeyClient api.GlideClientCommands) error {
+	members := []string{bitmapIndexComplete}
+	for _, bitmap := range []string{k.keysName(), k.takenKeysName()} {
+		names, err := scanNames(valkeyClient, bitmap+":*")
+		if err != nil {
+			return fmt.Errorf("failed to list %s segments: %w", bitmap, err)
+		}
+
+		for _, name := range names {
+			segment := strings.TrimPrefix(name, bitmap+":")
+			if _, err := strconv.ParseUint(segment, 10, 64); err != nil {
+				continue // another bitmap sharing the prefix
+			}
+			members = append(members, segment)
+		}
+	}
+
+	if _, err := valkeyClient.SAdd(k.indexName(), members); err != nil {
+		return fmt.Errorf("failed to index key segments: %w", err)
+	}
+
+	return nil
+}
+
+// indexSegment registers the segment in the
+// index, before any of its bits is written
+func (k *ValkeyBitmap) indexSegment(valkeyClient api.GlideClientCommands, segment uint64) error {
+	if _, err := valkeyClient.SAdd(k.indexName(), []string{strconv.FormatUint(segment, 10)}); err != nil {
+		return fmt.Errorf("failed to index key segment: %w", err)
+	}
+
+	return nil
+}
+
+// keyLengthAt returns the length of the
+// key at the given key space position
+func keyLengthAt(index uint64) int {
+	for length := MinKeyLength; length < MaxKeyLength; length++ {
+		if index < KeySpaceOffset(length+1) {
+			return length
+		}
+	}
+
+	return MaxKeyLength
+}
+
+// NextKeyInSegments returns a generator of random keys with the
+// given length spread over a number of segments of their key space,
+// drawn at random once, keeping ValkeyBitmap segments dense while
+// the keys prefixes stay unpredictable
+func NextKeyInSegments(length int, segments uint64) (func() (*ShortKey, error), error) {
+	first := KeySpaceOffset(length) / BitmapSegmentSize
+	total := max(KeySpaceSize(length)/BitmapSegmentSize, 1)
+
+	drawn := map[uint64]bool{}
+	for uint64(len(drawn)) < min(segments, total) {
+		n, err := randomUint64N(total)
+		if err != nil {
+			return nil, err
+		}
+		drawn[first+n] = true
+	}
+
+	picked := make([]uint64, 0, len(drawn))
+	for segment := range drawn {
+		picked = append(picked, segment)
+	}
+
+	return func() (*ShortKey, error) {
+		n, err := randomUint64N(uint64(len(picked)) * min(BitmapSegmentSize, KeySpaceSize(length)))
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := NewKeyFromIndex(picked[n/BitmapSegmentSize]*BitmapSegmentSize + n%BitmapSegmentSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed key validation: %w", err)
+		}
+
+		return key, nil
+	}, nil
+}
+
+// SegmentedKeys returns a factory of generators of keys of a
+// length, each length keeping the segments drawn by its first
+// generator, see NextKeyInSegments
+func SegmentedKeys(segments uint64) func(length int) func() (*ShortKey, error) {
+	var mu sync.Mutex
+	generators := map[int]func() (*ShortKey, error){}
+
+	return func(length int) func() (*ShortKey, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if generator, ok := generators[length]; ok {
+			return generator
+		}
+
+		generator, err := NextKeyInSegments(length, segments)
+		if err != nil { // tried again with the next key
+			return func() (*ShortKey, error) { return nil, err }
+		}
+		generators[length] = generator
+
+		return generator
+	}
+}
+
+// randomUint64N returns a cryptographically random number in [0, n)
+func randomUint64N(n uint64) (uint64, error) {
+	v, err := crand.Int(crand.Reader, new(big.Int).SetUint64(n))
+	if err != nil { // this should never happen
+		return 0, fmt.Errorf("failed to create random number: %w", err)
+	}
+
+	return v.Uint64(), nil
+}
+
+// bitField runs a GET, or a SET to 1, of each
+// single bit offset in a BITFIELD command
+func bitField(valkeyClient api.GlideClientCommands, command, bitmap, operation string, offsets []int64) ([]int64, error) {
+	args := []string{command, bitmap}
+	for _, offset := range offsets {
+		args = append(args, operation, "u1", strconv.FormatInt(offset, 10))
+		if operation == "SET" {
+			args = append(args, "1")
+		}
+	}
+
+	res, err := valkeyClient.CustomCommand(args)
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := res.([]interface{})
+	if !ok || len(values) != len(offsets) {
+		return nil, fmt.Errorf("incompatible bitfield result: %v", res)
+	}
+
+	bits := make([]int64, len(values))
+	for n, v := range values {
+		if bits[n], ok = v.(int64); !ok {
+			return nil, fmt.Errorf("incompatible bitfield value: %v", v)
+		}
+	}
+
+	return bits, nil
+}
+
+// setBits sets each single bit offset of the
+// bitmap to the value in a BITFIELD command
+func setBits(valkeyClient api.GlideClientCommands, bitmap string, offsets []int64, value int64) error {
+	args := []string{"BITFIELD", bitmap}
+	for _, offset := range offsets {
+		args = append(args, "SET", "u1", strconv.FormatInt(offset, 10), strconv.FormatInt(value, 10))
+	}
+
+	_, err := valkeyClient.CustomCommand(args)
+	return err
+}
+
+// scanNames lists every db entry matching the pattern
+func scanNames(valkeyClient api.GlideClientCommands, pattern string) ([]string, error) {
+	var names []string
+
+	cursor := "0"
+	for {
+		res, err := valkeyClient.CustomCommand([]string{"SCAN", cursor, "MATCH", pattern, "COUNT", "1000"})
+		if err != nil {
+			return nil, err
+		}
+
+		page, ok := res.([]interface{})
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf("incompatible scan result: %v", res)
+		}
+
+		cursor, ok = page[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("incompatible scan cursor: %v", page[0])
+		}
+
+		entries, ok := page[1].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("incompatible scan entries: %v", page[1])
+		}
+
+		for _, e := range entries {
+			if name, ok := e.(string); ok {
+				names = append(names, name)
+			}
+		}
+
+		if cursor == "0" {
+			return names, nil
+		}
+	}
+}
+
+func (k *ValkeyBitmap) keysName() string {
+	return namespacedName(KeysBitmapName, k.Namespace)
+}
+
+func (k *ValkeyBitmap) takenKeysName() string {
+	return namespacedName(TakenKeysBitmapName, k.Namespace)
+}
+
+func (k *ValkeyBitmap) reservedKeysName() string {
+	return namespacedName(ReservedKeysName, k.Namespace)
+}
+
+func (k *ValkeyBitmap) bannedKeysName() string {
+	return namespacedName(BannedKeysName, k.Namespace)
+}
+
+func (k *ValkeyBitmap) quarantinedKeysName() string {
+	return namespacedName(QuarantinedKeysName, k.Namespace)
+}
+
+func (k *ValkeyBitmap) segmentsName() string {
+	return namespacedName(KeysBitmapSegmentsName, k.Namespace)
+}
+
+func (k *ValkeyBitmap) indexName() string {
+	return namespacedName(KeysBitmapIndexName, k.Namespace)
+}
+
+func bitmapPosition(key *ShortKey) (uint64, int64) {
+	index := key.Index()
+
+	// #nosec G115 -- bounded by BitmapSegmentSize
+	return index / BitmapSegmentSize, int64(index % BitmapSegmentSize)
+}
+
+func bitmapSegmentName(bitmap string, segment uint64) string {
+	return fmt.Sprintf("%s:%d", bitmap, segment)
+}
@@ -0,0 +1,360 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"strings"
+	"testing"
+
+	"github.com/valkey-io/valkey-glide/go/api"
+)
+
+func TestValkeyBitmap(t *testing.T) {
+	client := testClient()
+
+	conn, err := client.GetConn()
+	if err != nil {
+		t.Fatalf("could not connect to test db: %v", err)
+	}
+
+	valkeyClient, ok := conn.(api.GlideClientCommands)
+	if !ok {
+		t.Fatal("incompatible test db client")
+	}
+	defer client.Close()
+
+	if err := deleteBitmaps(valkeyClient); err != nil {
+		t.Fatalf("could not clean test db: %v", err)
+	}
+	defer deleteBitmaps(valkeyClient) //nolint:errcheck
+
+	bitmap := &ValkeyBitmap{Client: client}
+	generator, err := NextKeyInSegments(MinKeyLength, 1)
+	if err != nil {
+		t.Fatalf("NextKeyInSegments() failed: %v", err)
+	}
+
+	created := map[string]bool{}
+	for len(created) < 3 {
+		key, err := generator()
+		if err != nil {
+			t.Fatalf("invalid generated test keys: %v", err)
+		}
+
+		if err := bitmap.Create(key); err != nil {
+			t.Fatalf("could not create test keys: %v", err)
+		}
+		created[string(*key)] = true
+	}
+
+	t.Run("TestCreate_GivenExistingKey", func(t *testing.T) {
+		for c := range created {
+			k := ShortKey(c)
+			err := bitmap.Create(&k)
+			if err == nil || !strings.Contains(err.Error(), "already exist") {
+				t.Errorf("Create(%s) = %v, want already exist error", k, err)
+			}
+		}
+	})
+
+	allocated := []ShortKey{}
+
+	t.Run("TestAllocateFirst_GivenSomeAvailableKeys", func(t *testing.T) {
+		for range created {
+			i, err := bitmap.AllocateFirst()
+			if err != nil {
+				t.Fatalf("AllocateFirst() failed: %v", err)
+			}
+
+			k, ok := i.(ShortKey)
+			if !ok {
+				t.Fatalf("AllocateFirst() = %v, want a ShortKey", i)
+			}
+
+			if !created[string(k)] {
+				t.Errorf("AllocateFirst() = %s, want any in %v", k, created)
+			}
+
+			allocated = append(allocated, k)
+		}
+	})
+
+	t.Run("TestAllocateFirst_GivenNoAvailableKeys", func(t *testing.T) {
+		i, err := bitmap.AllocateFirst()
+		if err == nil {
+			t.Fatalf("AllocateFirst() = %v, want an error", i)
+		}
+	})
+
+	t.Run("TestCreate_GivenTakenKey", func(t *testing.T) {
+		err := bitmap.Create(&allocated[0])
+		if err == nil || !strings.Contains(err.Error(), "already exist") {
+			t.Errorf("Create(%s) = %v, want already exist error", allocated[0], err)
+		}
+	})
+
+	t.Run("TestDeallocate_GivenTakenKeys", func(t *testing.T) {
+		for _, k := range allocated {
+			if err := bitmap.Deallocate(&k); err != nil {
+				t.Fatalf("Deallocate(%s) failed: %v", k, err)
+			}
+		}
+	})
+
+	t.Run("TestDeallocate_GivenAvailableKey", func(t *testing.T) {
+		err := bitmap.Deallocate(&allocated[0])
+		if err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("Deallocate(%s) = %v, want not found error", allocated[0], err)
+		}
+	})
+
+	t.Run("TestListKeys_GivenAvailableKeys", func(t *testing.T) {
+		listed := map[string]bool{}
+		for cursor, pages := "", 0; pages == 0 || cursor != ""; pages++ {
+			page, next, err := bitmap.ListKeys(KeyAvailable, cursor, 2)
+			if err != nil {
+				t.Fatalf("ListKeys() failed: %v", err)
+			}
+
+			for _, k := range page {
+				listed[string(k)] = true
+			}
+			cursor = next
+		}
+
+		if len(listed) != len(created) {
+			t.Errorf("ListKeys() listed %v, want %v", listed, created)
+		}
+	})
+
+	t.Run("TestCountStates_GivenAvailableKeys", func(t *testing.T) {
+		stats, err := bitmap.CountStates(true)
+		if err != nil || stats.Available != 3 || stats.Lengths[MinKeyLength].Available != 3 {
+			t.Errorf("CountStates() = %+v, %v, want 3 available keys of %d characters", stats, err, MinKeyLength)
+		}
+	})
+
+	t.Run("TestQuarantine_GivenAvailableKey", func(t *testing.T) {
+		k := allocated[0]
+		if err := bitmap.Quarantine(k); err != nil {
+			t.Fatalf("Quarantine(%s) failed: %v", k, err)
+		}
+
+		if state, err := bitmap.State(k); state != KeyQuarantined || err != nil {
+			t.Errorf("State(%s) = %v, %v, want %v", k, state, err, KeyQuarantined)
+		}
+
+		if err := bitmap.Deallocate(&k); !errors.Is(err, ErrKeyQuarantined) {
+			t.Errorf("Deallocate(%s) = %v, want %v", k, err, ErrKeyQuarantined)
+		}
+
+		if stats, err := bitmap.CountStates(false); err != nil || stats.Quarantined != 1 || stats.Taken != 1 {
+			t.Errorf("CountStates() = %+v, %v, want 1 quarantined key", stats, err)
+		}
+
+		if err := bitmap.Lift(k); err != nil {
+			t.Fatalf("Lift(%s) failed: %v", k, err)
+		}
+
+		if state, err := bitmap.State(k); state != KeyAvailable || err != nil {
+			t.Errorf("State(%s) = %v, %v, want %v", k, state, err, KeyAvailable)
+		}
+
+		if err := bitmap.Lift(k); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Lift(%s) = %v, want %v", k, err, ErrKeyNotFound)
+		}
+	})
+}
+
+func TestNextKeyInSegments_GivenSegments(t *testing.T) {
+	for _, length := range []int{MinKeyLength, MaxKeyLength} {
+		generator, err := NextKeyInSegments(length, 4)
+		if err != nil {
+			t.Fatalf("NextKeyInSegments() failed: %v", err)
+		}
+
+		segments := map[uint64]bool{}
+		for range 1000 {
+			key, err := generator()
+			if err != nil {
+				t.Fatalf("generator() failed: %v", err)
+			}
+
+			if len(*key) != length {
+				t.Fatalf("generator() = %s, want a key of %d characters", *key, length)
+			}
+
+			segment, _ := bitmapPosition(key)
+			segments[segment] = true
+		}
+
+		if len(segments) != 4 {
+			t.Errorf("generator() spread keys over %d segments, want 4", len(segments))
+		}
+
+		first, leading := KeySpaceOffset(length)/BitmapSegmentSize, 0
+		for segment := range segments {
+			if segment < first+4 {
+				leading++
+			}
+		}
+
+		if leading == 4 {
+			t.Errorf("generator() spread keys over the first segments %v, want random ones", segments)
+		}
+	}
+}
+
+func TestSegmentedKeys_GivenLengths(t *testing.T) {
+	factory := SegmentedKeys(1)
+
+	prefixes := map[int]string{}
+	for range 100 {
+		for _, length := range []int{MinKeyLength, MaxKeyLength} {
+			key, err := factory(length)()
+			if err != nil {
+				t.Fatalf("factory() failed: %v", err)
+			}
+
+			prefix := string((*key)[:length-4]) // keys of a segment share all but 4 characters
+			if p, ok := prefixes[length]; ok && p != prefix {
+				t.Fatalf("factory(%d)() = %s, want keys of the first drawn segment %s", length, *key, p)
+			}
+			prefixes[length] = prefix
+		}
+	}
+}
+
+// BenchmarkStorage compares latency and memory of
+// set-based and bitmap-based key storages, run with
+// go test -run=^$ -bench=Storage ./keys
+func BenchmarkStorage(b *testing.B) {
+	client := testClient()
+
+	conn, err := client.GetConn()
+	if err != nil {
+		b.Fatalf("could not connect to test db: %v", err)
+	}
+
+	valkeyClient, ok := conn.(api.GlideClientCommands)
+	if !ok {
+		b.Fatal("incompatible test db client")
+	}
+	defer client.Close()
+
+	storages := []struct {
+		name    string
+		entity  app.KeyValueEntity
+		clean   func(api.GlideClientCommands) error
+		dbNames func(api.GlideClientCommands) ([]string, error)
+	}{
+		{"Valkey", &Valkey{Client: client}, deleteSets, func(api.GlideClientCommands) ([]string, error) {
+			return []string{KeysListName, TakenKeysListName}, nil
+		}},
+		{"ValkeyBitmap", &ValkeyBitmap{Client: client}, deleteBitmaps, bitmapNames},
+	}
+
+	for _, s := range storages {
+		b.Run(s.name+"/Create", func(b *testing.B) {
+			if err := s.clean(valkeyClient); err != nil {
+				b.Fatalf("could not clean test db: %v", err)
+			}
+
+			generator, err := NextKeyInSegments(MinKeyLength, 1)
+			if err != nil {
+				b.Fatalf("NextKeyInSegments() failed: %v", err)
+			}
+			created := 0
+			for b.Loop() {
+				key, err := generator()
+				if err != nil {
+					b.Fatalf("invalid generated test keys: %v", err)
+				}
+
+				if err := s.entity.Create(key); err == nil {
+					created++
+				}
+			}
+
+			names, err := s.dbNames(valkeyClient)
+			if err != nil {
+				b.Fatalf("could not list storage names: %v", err)
+			}
+
+			usage, err := memoryUsage(valkeyClient, names)
+			if err != nil {
+				b.Fatalf("could not measure memory usage: %v", err)
+			}
+
+			if created > 0 {
+				b.ReportMetric(float64(usage)/float64(created), "bytes/key")
+			}
+		})
+
+		b.Run(s.name+"/AllocateFirst", func(b *testing.B) {
+			for b.Loop() {
+				if _, err := s.entity.AllocateFirst(); err != nil {
+					b.Fatalf("AllocateFirst() failed: %v", err)
+				}
+			}
+		})
+
+		if err := s.clean(valkeyClient); err != nil {
+			b.Fatalf("could not clean test db: %v", err)
+		}
+	}
+}
+
+func bitmapNames(valkeyClient api.GlideClientCommands) ([]string, error) {
+	names := []string{KeysBitmapSegmentsName, KeysBitmapIndexName}
+
+	for _, bitmap := range []string{KeysBitmapName, TakenKeysBitmapName} {
+		segments, err := scanNames(valkeyClient, bitmap+":*")
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, segments...)
+	}
+
+	return names, nil
+}
+
+func deleteBitmaps(valkeyClient api.GlideClientCommands) error {
+	names, err := bitmapNames(valkeyClient)
+	if err != nil {
+		return err
+	}
+
+	_, err = valkeyClient.Del(append(names, ReservedKeysName, BannedKeysName, QuarantinedKeysName))
+	return err
+}
+
+func deleteSets(valkeyClient api.GlideClientCommands) error {
+	_, err := valkeyClient.Del([]string{KeysListName, TakenKeysListName})
+	return err
+}
+
+func memoryUsage(valkeyClient api.GlideClientCommands, names []string) (int64, error) {
+	var total int64
+	for _, name := range names {
+		res, err := valkeyClient.CustomCommand([]string{"MEMORY", "USAGE", name})
+		if err != nil {
+			return 0, fmt.Errorf("failed to get memory usage of %s: %w", name, err)
+		}
+
+		if res == nil { // missing key
+			continue
+		}
+
+		usage, ok := res.(int64)
+		if !ok {
+			return 0, fmt.Errorf("incompatible memory usage of %s: %v", name, res)
+		}
+
+		total += usage
+	}
+
+	return total, nil
+}
@@ -0,0 +1,115 @@
+package keys
+
+import (
+	"encoding/json"
+	"fmt"
+	"keygen-service/app"
+	"slices"
+	"strings"
+)
+
+const KeysNamespacesName = "keysNamespaces"
+
+// ValkeyNamespaces keeps the namespaces
+// in a hash of JSON encoded namespaces
+type ValkeyNamespaces struct {
+	// Client connects to the db, required
+	Client app.KeyValueDbClient
+}
+
+// Create saves a new namespace
+func (v *ValkeyNamespaces) Create(namespace KeyNamespace) error {
+	value, err := json.Marshal(namespace)
+	if err != nil {
+		return fmt.Errorf("failed to encode namespace: %w", err)
+	}
+
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return err
+	}
+
+	created, err := valkeyClient.HSetNX(KeysNamespacesName, namespace.Name, string(value))
+	if err != nil {
+		return fmt.Errorf("failed to save namespace: %w", err)
+	}
+
+	if !created {
+		return ErrNamespaceExists
+	}
+
+	return nil
+}
+
+// Get returns a saved namespace
+func (v *ValkeyNamespaces) Get(name string) (KeyNamespace, error) {
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return KeyNamespace{}, err
+	}
+
+	res, err := valkeyClient.HGet(KeysNamespacesName, name)
+	if err != nil {
+		return KeyNamespace{}, fmt.Errorf("failed to get namespace: %w", err)
+	}
+
+	if res.IsNil() {
+		return KeyNamespace{}, fmt.Errorf("%w: %s", ErrNamespaceNotFound, name)
+	}
+
+	return decodeNamespace(res.Value())
+}
+
+// List returns the saved namespaces sorted by name
+func (v *ValkeyNamespaces) List() ([]KeyNamespace, error) {
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := valkeyClient.HGetAll(KeysNamespacesName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	namespaces := make([]KeyNamespace, 0, len(values))
+	for _, value := range values {
+		namespace, err := decodeNamespace(value)
+		if err != nil {
+			return nil, err
+		}
+
+		namespaces = append(namespaces, namespace)
+	}
+
+	slices.SortFunc(namespaces, func(a, b KeyNamespace) int { return strings.Compare(a.Name, b.Name) })
+	return namespaces, nil
+}
+
+// Update saves a known namespace
+func (v *ValkeyNamespaces) Update(namespace KeyNamespace) error {
+	value, err := json.Marshal(namespace)
+	if err != nil {
+		return fmt.Errorf("failed to encode namespace: %w", err)
+	}
+
+	valkeyClient, err := valkeyConn(v.Client)
+	if err != nil {
+		return err
+	}
+
+	if _, err := valkeyClient.HSet(KeysNamespacesName, map[string]string{namespace.Name: string(value)}); err != nil {
+		return fmt.Errorf("failed to save namespace: %w", err)
+	}
+
+	return nil
+}
+
+func decodeNamespace(value string) (KeyNamespace, error) {
+	var namespace KeyNamespace
+	if err := json.Unmarshal([]byte(value), &namespace); err != nil {
+		return KeyNamespace{}, fmt.Errorf("failed to decode namespace: %w", err)
+	}
+
+	return namespace, nil
+}
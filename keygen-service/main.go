@@ -1,47 +1,460 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"keygen-service/app"
+	"keygen-service/auth"
+	"keygen-service/jobs"
 	"keygen-service/keys"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
 )
 
+// generatorHealthService names the keys generator
+// in the gRPC health checks
+const generatorHealthService = "keys.Generator"
+
 func main() {
-	if err := Initialize(); err != nil {
+	application, monitor, err := Initialize()
+	if err != nil {
 		log.Fatal("could not initialize the application: ", err)
 	}
 
-	launchKeysGenerator() // failures here aren't fatal to the service
+	healthServer := health.NewServer()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db := application.GetKeyValueDb()
+	if err := checkKeysMigration(db); errors.Is(err, keys.ErrMigrationPhase) {
+		log.Fatal("invalid keys migration configuration: ", err)
+	} else if err != nil {
+		log.Printf("keys migration not checked: %v", err)
+	}
+
+	prefetch, prefetchDone := launchPrefetchBuffer(ctx, db)
+	events, eventsReader, stopEvents := launchEventPublisher(db)
+
+	allocator := keys.NewAllocator(db.Keys, nil)
+	allocator.Prefetch = prefetch
+	allocator.Events = events
+	replenishments, closeReplenishments, err := keysWait(db, allocator)
+	if err != nil {
+		log.Fatal("invalid keys wait configuration: ", err)
+	}
+	if replenishments != nil {
+		launchReplenishments(ctx, replenishments, closeReplenishments)
+	}
+	expvar.Publish("keysAllocator", expvar.Func(func() any { return allocator.Stats() }))
+
+	audit, err := keysAudit(db)
+	if err != nil {
+		log.Fatal("invalid keys audit configuration: ", err)
+	}
+	if audit != nil {
+		allocator.Audit = audit("")
+	}
+
+	owners, allowUnowned, err := keysOwnership(db)
+	if err != nil {
+		log.Fatal("invalid keys ownership configuration: ", err)
+	}
+	if owners != nil {
+		allocator.Owners, allocator.AllowUnowned = owners(""), allowUnowned
+	}
+
+	namespaces := keysNamespaces(db, allocator, owners, audit)
+
+	generators, err := keysGeneratorFactory()
+	if err != nil {
+		log.Fatal("invalid keys generator configuration: ", err)
+	}
 
-	if err := startKeysRPCServer(); err != nil {
+	launchKeysGenerator(ctx, db, monitor, generators, namespaces, healthServer) // failures here aren't fatal to the service
+	launchKeySpaceMonitor(db, monitor)
+	scheduler := launchJobs(ctx, db, namespaces)
+	launchMonitoringServer()
+
+	handler := &keys.RPCHandler{Allocator: allocator, Namespaces: namespaces, Events: eventsReader}
+	admin := &keys.AdminRPCHandler{Namespaces: namespaces, Generator: generators, Jobs: scheduler}
+	if migration, ok := app.As[*keys.MigratingEntity](db.Keys); ok {
+		admin.Migration = migration
+		expvar.Publish("keysMigration", expvar.Func(func() any { return migration.Stats() }))
+	}
+	if err := startKeysRPCServer(ctx, db, healthServer, handler, admin); err != nil {
 		log.Fatal("failed to start keys server: ", err)
 	}
+
+	stop()
+	<-prefetchDone // buffered keys are available again
+	stopEvents()   // once the last requests published theirs
+	db.Client.Close()
+}
+
+// launchReplenishments receives the replenishment signals of the
+// other replicas until ctx is done, then closes their connection
+func launchReplenishments(ctx context.Context, replenishments *keys.Replenishments, closeSignals func()) {
+	expvar.Publish("keysReplenishment", expvar.Func(func() any { return replenishments.Stats() }))
+
+	ch := make(chan error)
+	go replenishments.Run(ctx, ch)
+
+	go func() {
+		defer closeSignals()
+
+		for e := range ch {
+			log.Printf("keys replenishment sent a error: %v", e)
+		}
+	}()
+}
+
+// launchEventPublisher starts publishing the keys events when
+// configured; the returned func delivers the pending events
+// and waits for the publisher to stop
+func launchEventPublisher(db *app.KeyValueDb) (*keys.EventPublisher, keys.EventReader, func()) {
+	publisher, reader, err := keysEventPublisher(db)
+	if err != nil || publisher == nil {
+		if err != nil {
+			log.Printf("keys events publisher not launched: %v", err)
+		}
+
+		return nil, nil, func() {}
+	}
+
+	expvar.Publish("keysEvents", expvar.Func(func() any { return publisher.Stats() }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	ch := make(chan error)
+	go publisher.Run(ctx, ch)
+
+	go func() {
+		defer close(done)
+
+		for e := range ch {
+			log.Printf("keys events publisher sent a error: %v", e)
+		}
+	}()
+
+	return publisher, reader, func() {
+		cancel()
+		<-done
+	}
 }
 
-func launchKeysGenerator() {
+// launchPrefetchBuffer starts buffering keys for GetKey when
+// configured, the returned channel is closed once the buffer
+// has returned its keys after ctx is done
+func launchPrefetchBuffer(ctx context.Context, db *app.KeyValueDb) (*keys.PrefetchBuffer, chan struct{}) {
+	done := make(chan struct{})
+
+	buffer, err := keysPrefetchBuffer(db)
+	if err != nil || buffer == nil {
+		if err != nil {
+			log.Printf("keys prefetch buffer not launched: %v", err)
+		}
+		close(done)
+
+		return nil, done
+	}
+
+	expvar.Publish("keysPrefetch", expvar.Func(func() any { return buffer.Stats() }))
+
 	ch := make(chan error)
-	go keys.GenerateKeys(keys.NextKey, time.Second, ch)
+	go buffer.Run(ctx, ch)
 
 	go func() {
+		defer close(done)
+
 		for e := range ch {
+			log.Printf("keys prefetch buffer sent a error: %v", e)
+		}
+		log.Printf("keys prefetch buffer returned %d keys", buffer.Stats().Returned)
+	}()
+
+	return buffer, done
+}
+
+// launchKeysGenerator generates keys with the generators while
+// leading, until ctx is done
+func launchKeysGenerator(ctx context.Context, db *app.KeyValueDb, monitor *keys.KeySpaceMonitor,
+	generators func(length int) func() (*keys.ShortKey, error), namespaces *keys.Namespaces, healthServer *health.Server) {
+	generator := monitor.Generator(generators)
+
+	newPool, err := keysGeneratorPool(db)
+	if err != nil {
+		log.Printf("keys generator not launched: %v", err)
+		return
+	}
+	pool := newPool()
+
+	election, err := keysGeneratorElection(db)
+	if err != nil {
+		log.Printf("keys generator not launched: %v", err)
+		return
+	}
+
+	planner, err := keysSupplyPlanner(monitor)
+	if err != nil {
+		log.Printf("keys generator not launched: %v", err)
+		return
+	}
+
+	if planner != nil {
+		pool.Supply = planner
+		expvar.Publish("keysSupply", expvar.Func(func() any { return planner.Plan() }))
+	}
+
+	healthServer.SetServingStatus(generatorHealthService, healthgrpc.HealthCheckResponse_SERVING)
+	pool.Breaker.OnChange = func(state keys.CircuitState) {
+		status := healthgrpc.HealthCheckResponse_SERVING
+		if state == keys.CircuitOpen {
+			status = healthgrpc.HealthCheckResponse_NOT_SERVING
+		}
+
+		healthServer.SetServingStatus(generatorHealthService, status)
+	}
+
+	var leaseToken atomic.Int64
+	pool.Fence = func() error { return election.Fence(leaseToken.Load())() }
+	pool.Events = namespaces.Default.Events
+	pool.Replenishment = namespaces.Default.Replenishment
+
+	namespaceGenerators := &keys.NamespaceGenerators{
+		Namespaces: namespaces,
+		Pool: func() *keys.GeneratorPool {
+			namespacePool := newPool()
+			namespacePool.Fence = pool.Fence
+			namespacePool.Events = pool.Events
+
+			return namespacePool
+		},
+		Generator: namespaceKeysGenerator(generators),
+		Interval:  time.Minute,
+	}
+
+	expvar.Publish("keysGenerator", expvar.Func(func() any { return pool.Stats() }))
+	expvar.Publish("keysGeneratorElection", expvar.Func(func() any { return election.Status() }))
+
+	ch := make(chan error)
+	go election.Run(ctx, func(ctx context.Context, token int64) {
+		log.Printf("leading keys generation with lease %d", token)
+		leaseToken.Store(token)
+
+		termCh := make(chan error)
+		go pool.GenerateKeys(ctx, generator, termCh)
+
+		namespacesCh := make(chan error)
+		go namespaceGenerators.Run(ctx, namespacesCh)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for e := range namespacesCh {
+				log.Printf("namespaces keys generator sent a error: %v", e)
+			}
+		}()
+
+		for e := range termCh {
 			log.Printf("keys generator sent a error: %v", e)
 		}
-		log.Println("keys generator closed with an error")
+		wg.Wait()
+
+		log.Printf("stopped leading keys generation with lease %d", token)
+	}, ch)
+
+	go func() {
+		for e := range ch {
+			log.Printf("keys generator election sent a error: %v", e)
+		}
+		log.Println("keys generator election closed")
 	}()
 }
 
-func startKeysRPCServer() error {
-    // #nosec G102
+func launchKeySpaceMonitor(db *app.KeyValueDb, monitor *keys.KeySpaceMonitor) {
+	expvar.Publish("keySpace", expvar.Func(func() any { return monitor.Stats() }))
+
+	counter, ok := app.As[app.KeyValueCounter](db.Keys)
+	if !ok {
+		log.Println("key space monitor not launched: keys storage can't be counted")
+		return
+	}
+
+	interval, err := monitorInterval()
+	if err != nil {
+		log.Printf("key space monitor not launched: %v", err)
+		return
+	}
+
+	ch := make(chan error)
+	go monitor.Watch(counter, interval, ch)
+
+	go func() {
+		for e := range ch {
+			log.Printf("key space monitor sent a error: %v", e)
+		}
+		log.Println("key space monitor closed with an error")
+	}()
+}
+
+// launchJobs runs the configured background jobs, each scheduled
+// time on a single replica, until ctx is done; failures aren't
+// fatal, the returned scheduler may have no job
+func launchJobs(ctx context.Context, db *app.KeyValueDb, namespaces *keys.Namespaces) *jobs.Scheduler {
+	scheduler, jitter, err := keysScheduler(db)
+	if err != nil {
+		log.Printf("background jobs not launched: %v", err)
+		return nil
+	}
+
+	reconcile, schedule, err := keysReconcileJob(namespaces)
+	if err != nil {
+		log.Printf("keys reconciliation not scheduled: %v", err)
+	} else if reconcile != nil {
+		job := jobs.Job{Name: "reconcile", Schedule: schedule, Jitter: jitter, Run: reconcile.Run}
+		if err := scheduler.Add(job); err != nil {
+			log.Printf("keys reconciliation not scheduled: %v", err)
+		}
+		expvar.Publish("keysReconcile", expvar.Func(func() any { return reconcile.Stats() }))
+	}
+
+	expvar.Publish("keysJobs", expvar.Func(func() any { return scheduler.Stats() }))
+
+	ch := make(chan error)
+	go scheduler.Run(ctx, ch)
+
+	go func() {
+		for e := range ch {
+			log.Printf("background jobs sent a error: %v", e)
+		}
+		log.Println("background jobs stopped")
+	}()
+
+	return scheduler
+}
+
+// launchMonitoringServer serves expvar metrics
+// at /debug/vars, failures aren't fatal
+func launchMonitoringServer() {
+	go func() {
+		server := &http.Server{Addr: monitoringAddress(), Handler: expvar.Handler(), ReadHeaderTimeout: 5 * time.Second}
+
+		log.Printf("monitoring server listening at %v", server.Addr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("monitoring server closed: %v", err)
+		}
+	}()
+}
+
+// launchCertificateReloader reloads rotated server
+// certificates, logging failed reloads
+func launchCertificateReloader(reloader *auth.CertificateReloader, interval time.Duration) {
+	ch := make(chan error)
+	go reloader.Watch(interval, ch)
+
+	go func() {
+		for e := range ch {
+			log.Printf("server certificate reloader sent a error: %v", e)
+		}
+	}()
+}
+
+// startKeysRPCServer serves until ctx is done, then waits
+// for the pending requests; the admin API is served on
+// its own listener, loopback only by default
+func startKeysRPCServer(ctx context.Context, db *app.KeyValueDb, healthServer *health.Server, handler keys.KeysServer, admin keys.KeysAdminServer) error {
+	authenticator, err := keysAuthenticator()
+	if err != nil {
+		return fmt.Errorf("failed to configure authentication: %w", err)
+	}
+
+	tlsConfig, reloader, reloadInterval, err := keysServerTLS()
+	if err != nil {
+		return fmt.Errorf("failed to configure TLS: %w", err)
+	}
+
+	var options []grpc.ServerOption
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		launchCertificateReloader(reloader, reloadInterval)
+	} else {
+		log.Println("KEYS_TLS_CERT_FILE not set, keys API served in plaintext")
+	}
+
+	if authenticator != nil {
+		options = append(options,
+			grpc.ChainUnaryInterceptor(authenticator.UnaryServerInterceptor()),
+			grpc.ChainStreamInterceptor(authenticator.StreamServerInterceptor()),
+		)
+	} else {
+		log.Println("KEYS_AUTH_CONFIG not set, keys API open to any client")
+	}
+
+	adminAddress, err := keysAdminAddress(authenticator != nil)
+	if err != nil {
+		return fmt.Errorf("failed to configure admin API: %w", err)
+	}
+
+	limiter, err := keysLimiter(db)
+	if err != nil {
+		return fmt.Errorf("failed to configure limits: %w", err)
+	}
+
+	if limiter != nil { // chained after authentication, limits are per client
+		options = append(options, grpc.ChainUnaryInterceptor(limiter.UnaryServerInterceptor()))
+		expvar.Publish("keysLimits", expvar.Func(func() any { return limiter.Stats() }))
+	}
+
+	// #nosec G102
 	lis, err := net.Listen("tcp", "0.0.0.0:8080")
 	if err != nil {
 		return err
 	}
 
-	s := grpc.NewServer()
-	keys.RegisterKeysServer(s, keys.NewRPCHandler())
+	adminLis, err := net.Listen("tcp", adminAddress)
+	if err != nil {
+		_ = lis.Close()
+		return err
+	}
+
+	s := grpc.NewServer(options...)
+	keys.RegisterKeysServer(s, handler)
+	healthgrpc.RegisterHealthServer(s, healthServer)
+
+	adminServer := grpc.NewServer(options...)
+	keys.RegisterKeysAdminServer(adminServer, admin)
+
+	go func() {
+		<-ctx.Done()
+		log.Println("server shutting down")
+		adminServer.GracefulStop()
+		s.GracefulStop()
+	}()
+
+	go func() {
+		log.Printf("admin server listening at %v", adminLis.Addr())
+		if err := adminServer.Serve(adminLis); err != nil {
+			log.Printf("admin server closed: %v", err)
+		}
+	}()
 
 	log.Printf("server listening at %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
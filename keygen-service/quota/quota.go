@@ -0,0 +1,283 @@
+// Package quota limits the rate and daily volume of calls
+// of each client of the keys service, with counters kept
+// in a store shared by the replicas
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"keygen-service/auth"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultUserMetadata carries the end-user ID of calls
+const DefaultUserMetadata = "x-user-id"
+
+// Limit bounds the calls of a subject, zero values
+// leaving the calls unbounded
+type Limit struct {
+	// Rate of calls per second, refilling a bucket of
+	// Burst calls, the rate rounded up when zero
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+
+	// Daily calls, counted by UTC day
+	Daily int64 `json:"daily"`
+}
+
+// burst returns the bucket size of the limit
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+
+	return max(int(math.Ceil(l.Rate)), 1)
+}
+
+// Config sets the limits of each client, Default applying
+// to clients without limits, and the limits of each end-user
+// of a client, identified by the UserMetadata of calls
+type Config struct {
+	Default      Limit            `json:"default"`
+	Clients      map[string]Limit `json:"clients"`
+	Users        Limit            `json:"users"`
+	UserMetadata string           `json:"user_metadata"`
+}
+
+// LoadConfig reads a JSON config file
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from the operator
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read limits config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to decode limits config: %w", err)
+	}
+
+	for name, limit := range config.Clients {
+		if err := limit.validate(); err != nil {
+			return Config{}, fmt.Errorf("invalid limits of client %s: %w", name, err)
+		}
+	}
+
+	for _, limit := range []Limit{config.Default, config.Users} {
+		if err := limit.validate(); err != nil {
+			return Config{}, fmt.Errorf("invalid limits: %w", err)
+		}
+	}
+
+	return config, nil
+}
+
+func (l Limit) validate() error {
+	if l.Rate < 0 || l.Burst < 0 || l.Daily < 0 {
+		return fmt.Errorf("negative limit %+v", l)
+	}
+
+	return nil
+}
+
+// Bucket is a token bucket refilled at Rate
+// calls per second up to Burst calls
+type Bucket struct {
+	Name  string
+	Rate  float64
+	Burst int
+}
+
+// Counter counts calls up to Quota
+type Counter struct {
+	Name  string
+	Quota int64
+}
+
+// Store keeps the counters of the limits
+type Store interface {
+	// TakeTokens takes a call out of every bucket, or out of
+	// none when one is empty, returning how long to wait for
+	// all of them to have one, zero when taken
+	TakeTokens(buckets []Bucket) (time.Duration, error)
+
+	// Exhausted returns the name of the first counter
+	// whose quota is reached, empty when none is
+	Exhausted(counters []Counter) (string, error)
+
+	// Count counts a call on the counters,
+	// which expire after ttl
+	Count(counters []string, ttl time.Duration) error
+}
+
+// LimiterStats counts the limited calls
+type LimiterStats struct {
+	Allowed   int64
+	Throttled int64 // calls over the rate
+	Exhausted int64 // calls over the daily quota
+	Failures  int64 // calls allowed as the store failed
+}
+
+// Limiter enforces the limits of Config on Methods,
+// calls being allowed when the store fails
+type Limiter struct {
+	Store  Store
+	Config Config
+
+	// Methods limited, "/package.Service/Method"
+	Methods []string
+
+	allowed   atomic.Int64
+	throttled atomic.Int64
+	exhausted atomic.Int64
+	failures  atomic.Int64
+}
+
+// NewLimiter limits GetKey calls
+func NewLimiter(store Store, config Config) *Limiter {
+	return &Limiter{Store: store, Config: config, Methods: []string{"/keys.Keys/GetKey"}}
+}
+
+// Allow checks every limit of the caller before taking the
+// call out of any: an exhausted daily quota or an exceeded
+// rate fails with ResourceExhausted, telling when to retry,
+// the next UTC day for a daily quota. The returned func counts the call on the daily quotas, to be
+// called once it succeeded
+func (l *Limiter) Allow(ctx context.Context) (func(), error) {
+	client := "anonymous"
+	if identity, ok := auth.IdentityFromContext(ctx); ok {
+		client = identity.Name
+	}
+
+	limit, ok := l.Config.Clients[client]
+	if !ok {
+		limit = l.Config.Default
+	}
+
+	subjects := []string{"client:" + client}
+	limits := []Limit{limit}
+	if user := l.user(ctx); user != "" {
+		subjects = append(subjects, "user:"+client+":"+user)
+		limits = append(limits, l.Config.Users)
+	}
+
+	now := time.Now().UTC()
+	day := now.Format(time.DateOnly)
+
+	var buckets []Bucket
+	var counters []Counter
+	for i, subject := range subjects {
+		if limits[i].Rate > 0 {
+			buckets = append(buckets, Bucket{Name: "keysRate:" + subject, Rate: limits[i].Rate, Burst: limits[i].burst()})
+		}
+
+		if limits[i].Daily > 0 {
+			counters = append(counters, Counter{Name: "keysQuota:" + subject + ":" + day, Quota: limits[i].Daily})
+		}
+	}
+
+	if len(counters) > 0 {
+		exhausted, err := l.Store.Exhausted(counters)
+		if err != nil {
+			l.fail(client, err)
+		} else if exhausted != "" {
+			l.exhausted.Add(1)
+			retry := now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now).Round(time.Second)
+			return nil, status.Errorf(codes.ResourceExhausted, "daily quota %s exhausted, retry in %v", exhausted, retry)
+		}
+	}
+
+	if len(buckets) > 0 {
+		wait, err := l.Store.TakeTokens(buckets)
+		if err != nil {
+			l.fail(client, err)
+		} else if wait > 0 {
+			l.throttled.Add(1)
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit of %s exceeded, retry in %v", client, wait)
+		}
+	}
+
+	l.allowed.Add(1)
+	return func() {
+		if len(counters) == 0 {
+			return
+		}
+
+		names := make([]string, len(counters))
+		for i, counter := range counters {
+			names[i] = counter.Name
+		}
+
+		if err := l.Store.Count(names, 48*time.Hour); err != nil {
+			l.fail(client, err)
+		}
+	}, nil
+}
+
+// UnaryServerInterceptor limits unary calls, chained
+// after the authentication of the callers; only
+// successful calls count on the daily quotas
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !slices.Contains(l.Methods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		count, err := l.Allow(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := handler(ctx, req)
+		if err == nil {
+			count()
+		}
+
+		return res, err
+	}
+}
+
+// Stats returns the limiter counters
+func (l *Limiter) Stats() LimiterStats {
+	return LimiterStats{
+		Allowed:   l.allowed.Load(),
+		Throttled: l.throttled.Load(),
+		Exhausted: l.exhausted.Load(),
+		Failures:  l.failures.Load(),
+	}
+}
+
+func (l *Limiter) fail(subject string, err error) {
+	l.failures.Add(1)
+	log.Printf("limits of %s not enforced: %v", subject, err)
+}
+
+// user returns the end-user ID of the call, if any
+func (l *Limiter) user(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	name := l.Config.UserMetadata
+	if name == "" {
+		name = DefaultUserMetadata
+	}
+
+	if values := md.Get(name); len(values) > 0 {
+		return strings.TrimSpace(values[0])
+	}
+
+	return ""
+}
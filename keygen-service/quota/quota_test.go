@@ -0,0 +1,252 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"keygen-service/auth"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// storeMock keeps the counters in memory, buckets
+// being refilled only by refill
+type storeMock struct {
+	mu      sync.Mutex
+	tokens  map[string]int
+	counts  map[string]int64
+	err     error
+	buckets []string
+}
+
+func (s *storeMock) TakeTokens(buckets []Bucket) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	if s.tokens == nil {
+		s.tokens = map[string]int{}
+	}
+
+	for _, bucket := range buckets {
+		if _, ok := s.tokens[bucket.Name]; !ok {
+			s.tokens[bucket.Name] = bucket.Burst
+			s.buckets = append(s.buckets, bucket.Name)
+		}
+
+		if s.tokens[bucket.Name] == 0 {
+			return time.Second, nil
+		}
+	}
+
+	for _, bucket := range buckets {
+		s.tokens[bucket.Name]--
+	}
+
+	return 0, nil
+}
+
+func (s *storeMock) Exhausted(counters []Counter) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return "", s.err
+	}
+
+	for _, counter := range counters {
+		if s.counts[counter.Name] >= counter.Quota {
+			return counter.Name, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (s *storeMock) Count(counters []string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	if s.counts == nil {
+		s.counts = map[string]int64{}
+	}
+
+	for _, counter := range counters {
+		s.counts[counter]++
+	}
+
+	return nil
+}
+
+func (s *storeMock) tokensOf(bucket string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tokens[bucket]
+}
+
+func (s *storeMock) refill() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = nil
+}
+
+func clientContext(name string, user string) context.Context {
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Name: name})
+	if user != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(DefaultUserMetadata, user))
+	}
+
+	return ctx
+}
+
+// allowed counts the calls allowed, and succeeding,
+// before the first refusal, failing unless refused
+// with the given code
+func allowed(t *testing.T, l *Limiter, ctx context.Context, calls int, code codes.Code) int {
+	t.Helper()
+
+	for i := range calls {
+		count, err := l.Allow(ctx)
+		if err != nil {
+			if status.Code(err) != code {
+				t.Fatalf("Allow() = %v, want %v", err, code)
+			}
+
+			return i
+		}
+		count()
+	}
+
+	return calls
+}
+
+func TestLimiter_GivenRateLimits(t *testing.T) {
+	store := &storeMock{}
+	l := NewLimiter(store, Config{
+		Default: Limit{Rate: 2},
+		Clients: map[string]Limit{"url-shortener": {Rate: 100, Burst: 5}},
+	})
+
+	if got := allowed(t, l, clientContext("url-shortener", ""), 10, codes.ResourceExhausted); got != 5 {
+		t.Errorf("Allow() allowed %v calls, want the 5 calls burst", got)
+	}
+
+	if got := allowed(t, l, clientContext("other", ""), 10, codes.ResourceExhausted); got != 2 {
+		t.Errorf("Allow() allowed %v calls, want the 2 calls default burst", got)
+	}
+
+	store.refill()
+	if got := allowed(t, l, clientContext("url-shortener", ""), 10, codes.ResourceExhausted); got != 5 {
+		t.Errorf("Allow() allowed %v calls once refilled, want 5", got)
+	}
+
+	if stats := l.Stats(); stats.Allowed != 12 || stats.Throttled != 3 {
+		t.Errorf("Stats() = %+v, want 12 calls allowed and 3 throttled", stats)
+	}
+}
+
+func TestLimiter_GivenDailyQuotas(t *testing.T) {
+	store := &storeMock{}
+	l := NewLimiter(store, Config{
+		Clients: map[string]Limit{"url-shortener": {Daily: 100}},
+		Users:   Limit{Daily: 3},
+	})
+
+	if got := allowed(t, l, clientContext("url-shortener", "user1"), 10, codes.ResourceExhausted); got != 3 {
+		t.Errorf("Allow() allowed %v calls of user1, want its 3 calls quota", got)
+	}
+
+	if got := allowed(t, l, clientContext("url-shortener", "user2"), 10, codes.ResourceExhausted); got != 3 {
+		t.Errorf("Allow() allowed %v calls of user2, want its own 3 calls quota", got)
+	}
+
+	if got := allowed(t, l, clientContext("url-shortener", ""), 200, codes.ResourceExhausted); got != 94 {
+		t.Errorf("Allow() allowed %v calls without user, want the rest of the 100 calls client quota", got)
+	}
+
+	if stats := l.Stats(); stats.Exhausted != 3 {
+		t.Errorf("Stats() = %+v, want 3 exhausted quotas", stats)
+	}
+}
+
+func TestLimiter_GivenFailingStore(t *testing.T) {
+	l := NewLimiter(&storeMock{err: errors.New("db down")}, Config{Default: Limit{Rate: 1, Daily: 1}})
+
+	if got := allowed(t, l, clientContext("url-shortener", ""), 5, codes.OK); got != 5 {
+		t.Errorf("Allow() allowed %v calls, want every call allowed while the store fails", got)
+	}
+
+	if stats := l.Stats(); stats.Failures != 15 {
+		t.Errorf("Stats() = %+v, want 15 failures", stats)
+	}
+}
+
+func TestLimiter_GivenUnlimitedMethod(t *testing.T) {
+	l := NewLimiter(&storeMock{}, Config{Default: Limit{Daily: 1}})
+	interceptor := l.UnaryServerInterceptor()
+	handler := func(context.Context, any) (any, error) { return nil, nil }
+
+	for range 3 {
+		if _, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/keys.Keys/ReleaseKey"}, handler); err != nil {
+			t.Errorf("interceptor() = %v, want ReleaseKey unlimited", err)
+		}
+	}
+
+	if _, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/keys.Keys/GetKey"}, handler); err != nil {
+		t.Errorf("interceptor() = %v, want the first GetKey allowed", err)
+	}
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/keys.Keys/GetKey"}, handler)
+	if status.Code(err) != codes.ResourceExhausted || !strings.Contains(status.Convert(err).Message(), "retry in") {
+		t.Errorf("interceptor() = %v, want %v with a retry hint", err, codes.ResourceExhausted)
+	}
+}
+
+func TestLimiter_GivenFailingCalls(t *testing.T) {
+	l := NewLimiter(&storeMock{}, Config{Default: Limit{Daily: 1}})
+	interceptor := l.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/keys.Keys/GetKey"}
+
+	failing := func(context.Context, any) (any, error) { return nil, status.Error(codes.Unavailable, "no keys") }
+	for range 3 {
+		if _, err := interceptor(context.Background(), nil, info, failing); status.Code(err) != codes.Unavailable {
+			t.Errorf("interceptor() = %v, want the call failure", err)
+		}
+	}
+
+	succeeding := func(context.Context, any) (any, error) { return nil, nil }
+	if _, err := interceptor(context.Background(), nil, info, succeeding); err != nil {
+		t.Errorf("interceptor() = %v, want failed calls left out of the quota", err)
+	}
+}
+
+func TestLimiter_GivenThrottledClient(t *testing.T) {
+	store := &storeMock{}
+	l := NewLimiter(store, Config{
+		Clients: map[string]Limit{"url-shortener": {Rate: 1, Burst: 1}},
+		Users:   Limit{Rate: 1, Burst: 5},
+	})
+
+	if got := allowed(t, l, clientContext("url-shortener", "user1"), 3, codes.ResourceExhausted); got != 1 {
+		t.Errorf("Allow() allowed %v calls, want the 1 call client burst", got)
+	}
+
+	if got := store.tokensOf("keysRate:user:url-shortener:user1"); got != 4 {
+		t.Errorf("user bucket has %v tokens, want 4 left by the calls refused to the client", got)
+	}
+}
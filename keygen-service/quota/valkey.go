@@ -0,0 +1,155 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"keygen-service/app"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-glide/go/api"
+)
+
+// takeTokensScript refills each bucket KEYS[i] at ARGV[2i-1]
+// tokens per second up to ARGV[2i], timed by the server clock,
+// and takes a token out of each unless one is empty; returns 0
+// when taken, otherwise the milliseconds until every bucket
+// has a token
+const takeTokensScript = `
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local tokens, wait = {}, 0
+for i, key in ipairs(KEYS) do
+  local rate, burst = tonumber(ARGV[2 * i - 1]), tonumber(ARGV[2 * i])
+  local bucket = redis.call('HMGET', key, 'tokens', 'at')
+  local at = tonumber(bucket[2]) or now
+  tokens[i] = math.min(burst, (tonumber(bucket[1]) or burst) + math.max(now - at, 0) * rate / 1000)
+  if tokens[i] < 1 then
+    wait = math.max(wait, math.ceil((1 - tokens[i]) * 1000 / rate))
+  end
+end
+if wait > 0 then
+  return wait
+end
+for i, key in ipairs(KEYS) do
+  local rate, burst = tonumber(ARGV[2 * i - 1]), tonumber(ARGV[2 * i])
+  redis.call('HSET', key, 'tokens', tostring(tokens[i] - 1), 'at', now)
+  redis.call('PEXPIRE', key, math.ceil(burst * 1000 / rate) + 1000)
+end
+return 0`
+
+// countScript increments the KEYS counters, expiring
+// them after ARGV[1] milliseconds
+const countScript = `
+for _, key in ipairs(KEYS) do
+  if redis.call('INCR', key) == 1 then
+    redis.call('PEXPIRE', key, ARGV[1])
+  end
+end
+return 0`
+
+// ValkeyStore keeps the counters in valkey,
+// shared by every replica
+type ValkeyStore struct {
+	// Client connects to the db, required
+	Client app.KeyValueDbClient
+}
+
+// TakeTokens takes a call out of every bucket, atomically
+func (v *ValkeyStore) TakeTokens(buckets []Bucket) (time.Duration, error) {
+	valkeyClient, err := v.conn()
+	if err != nil {
+		return 0, err
+	}
+
+	args := []string{"EVAL", takeTokensScript, strconv.Itoa(len(buckets))}
+	for _, bucket := range buckets {
+		args = append(args, bucket.Name)
+	}
+	for _, bucket := range buckets {
+		args = append(args, strconv.FormatFloat(bucket.Rate, 'f', -1, 64), strconv.Itoa(bucket.Burst))
+	}
+
+	res, err := valkeyClient.CustomCommand(args)
+	if err != nil {
+		return 0, fmt.Errorf("failed to take tokens: %w", err)
+	}
+
+	wait, ok := res.(int64)
+	if !ok {
+		return 0, fmt.Errorf("incompatible token wait: %v", res)
+	}
+
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// Exhausted reads the counters, concurrent calls
+// may go over the quota by as many calls
+func (v *ValkeyStore) Exhausted(counters []Counter) (string, error) {
+	valkeyClient, err := v.conn()
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, len(counters))
+	for i, counter := range counters {
+		names[i] = counter.Name
+	}
+
+	counts, err := valkeyClient.MGet(names)
+	if err != nil {
+		return "", fmt.Errorf("failed to read call counts: %w", err)
+	}
+
+	for i, count := range counts {
+		if count.IsNil() {
+			continue
+		}
+
+		n, err := strconv.ParseInt(count.Value(), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("incompatible call count %q: %w", count.Value(), err)
+		}
+
+		if n >= counters[i].Quota {
+			return counters[i].Name, nil
+		}
+	}
+
+	return "", nil
+}
+
+// Count counts a call on the counters
+func (v *ValkeyStore) Count(counters []string, ttl time.Duration) error {
+	valkeyClient, err := v.conn()
+	if err != nil {
+		return err
+	}
+
+	args := append([]string{"EVAL", countScript, strconv.Itoa(len(counters))}, counters...)
+	args = append(args, strconv.FormatInt(ttl.Milliseconds(), 10))
+
+	if _, err := valkeyClient.CustomCommand(args); err != nil {
+		return fmt.Errorf("failed to count call: %w", err)
+	}
+
+	return nil
+}
+
+func (v *ValkeyStore) conn() (api.GlideClientCommands, error) {
+	if v.Client == nil {
+		return nil, errors.New("no db client")
+	}
+
+	conn, err := v.Client.GetConn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to db: %w", err)
+	}
+
+	valkeyClient, ok := conn.(api.GlideClientCommands)
+	if !ok {
+		return nil, errors.New("incompatible db client")
+	}
+
+	return valkeyClient, nil
+}
@@ -1,33 +1,679 @@
 package main
 
 import (
+	"cmp"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"time"
 	"keygen-service/app"
+	"keygen-service/auth"
+	"keygen-service/coordination"
 	"keygen-service/databases"
+	"keygen-service/jobs"
 	"keygen-service/keys"
+	"keygen-service/quota"
 )
 
 // Initialize the application with necessary
-// configurations
-func Initialize() error {
+// configurations, returns the app to wire explicitly
+// and the monitor watching the configured keys
+func Initialize() (app.App, *keys.KeySpaceMonitor, error) {
 	configuration := app.Configuration{}
 
-	setKeyValueDB(&configuration)
+	monitor, err := newKeySpaceMonitor()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error configuring key space monitor: %w", err)
+	}
+
+	if err := setKeyValueDB(&configuration, monitor); err != nil {
+		return nil, nil, fmt.Errorf("error configuring valkey: %w", err)
+	}
 
-	err := app.Initialize(configuration)
+	return app.New(configuration), monitor, nil
+}
+
+func setKeyValueDB(configuration *app.Configuration, monitor *keys.KeySpaceMonitor) error {
+	host, port := os.Getenv("VALKEY_DATABASE_HOST"), int16(6379)
+	client, err := valkeyClient(host, port)
 	if err != nil {
-		return fmt.Errorf("error initializing app: %w", err)
+		return err
+	}
+
+	storage, err := keysMigration(client)
+	if err != nil {
+		return err
+	}
+
+	configuration.KeyValueDb = &app.KeyValueDb{
+		Host:   host,
+		Port:   port,
+		Client: client,
+		Keys:   &keys.MonitoredEntity{KeyValueEntity: storage, Monitor: monitor},
 	}
 
 	return nil
 }
 
-func setKeyValueDB(configuration *app.Configuration) {
-	configuration.KeyValueDb = &app.KeyValueDb{
-		Host:   os.Getenv("VALKEY_DATABASE_HOST"),
-		Port:   6379,
-		Client: &databases.ValkeyClient{},
-		Keys:   &keys.Valkey{},
+// keysMigration returns the keys storage, migrating to the
+// KEYS_MIGRATION_TARGET_STORAGE storage (KEYS_STORAGE by default)
+// of the KEYS_MIGRATION_TARGET_HOST and KEYS_MIGRATION_TARGET_DB
+// database when either is set; the target database is reached
+// with the VALKEY_DATABASE_* credentials and only gets the keys
+// of the default namespace, everything else staying in the keys
+// database
+func keysMigration(client *databases.ValkeyClient) (app.KeyValueEntity, error) {
+	targetHost, targetDB := os.Getenv("KEYS_MIGRATION_TARGET_HOST"), os.Getenv("KEYS_MIGRATION_TARGET_DB")
+	if targetHost == "" && targetDB == "" {
+		return keysStorage(client, ""), nil
+	}
+
+	target, err := valkeyClient(cmp.Or(targetHost, client.Host), client.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetDB != "" {
+		db, err := strconv.Atoi(targetDB)
+		if err != nil || db < 0 {
+			return nil, fmt.Errorf("invalid KEYS_MIGRATION_TARGET_DB %q", targetDB)
+		}
+		target.Database = db
+	}
+
+	// storages of a database share their sets of reserved and banned keys
+	if target.Host == client.Host && target.Database == client.Database {
+		return nil, errors.New("KEYS_MIGRATION_TARGET_HOST and KEYS_MIGRATION_TARGET_DB point at the keys database")
+	}
+
+	kind := cmp.Or(os.Getenv("KEYS_MIGRATION_TARGET_STORAGE"), os.Getenv("KEYS_STORAGE"))
+	if kind != "" && kind != "valkey" && kind != "bitmap" {
+		return nil, fmt.Errorf("invalid KEYS_MIGRATION_TARGET_STORAGE %q, either valkey or bitmap", kind)
+	}
+
+	return &keys.MigratingEntity{
+		Source: keysStorage(client, ""),
+		Target: storageOfKind(kind, target, ""),
+		Store:  &keys.ValkeyMigrationStore{Client: client},
+	}, nil
+}
+
+// checkKeysMigration refuses a keys database recording a migration
+// while KEYS_MIGRATION_TARGET_HOST and KEYS_MIGRATION_TARGET_DB are
+// unset: the migrated keys live in the target from then on
+func checkKeysMigration(db *app.KeyValueDb) error {
+	if _, ok := app.As[*keys.MigratingEntity](db.Keys); ok {
+		return nil
+	}
+
+	phase, err := (&keys.ValkeyMigrationStore{Client: db.Client}).Phase()
+	if err != nil {
+		return err
+	}
+
+	if phase != keys.MigrationIdle {
+		return fmt.Errorf("%w: keys migration %s, KEYS_MIGRATION_TARGET_HOST or KEYS_MIGRATION_TARGET_DB required", keys.ErrMigrationPhase, phase)
+	}
+
+	return nil
+}
+
+// valkeyClient connects over TLS with VALKEY_DATABASE_TLS, authenticates
+// with VALKEY_DATABASE_USERNAME and VALKEY_DATABASE_PASSWORD and selects
+// the VALKEY_DATABASE_DB logical database
+func valkeyClient(host string, port int16) (*databases.ValkeyClient, error) {
+	client := &databases.ValkeyClient{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("VALKEY_DATABASE_USERNAME"),
+		Password: os.Getenv("VALKEY_DATABASE_PASSWORD"),
+	}
+
+	if v := os.Getenv("VALKEY_DATABASE_TLS"); v != "" {
+		useTLS, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VALKEY_DATABASE_TLS %q", v)
+		}
+		client.TLS = useTLS
+	}
+
+	if v := os.Getenv("VALKEY_DATABASE_DB"); v != "" {
+		db, err := strconv.Atoi(v)
+		if err != nil || db < 0 {
+			return nil, fmt.Errorf("invalid VALKEY_DATABASE_DB %q", v)
+		}
+		client.Database = db
+	}
+
+	if client.Username != "" && client.Password == "" {
+		return nil, fmt.Errorf("VALKEY_DATABASE_USERNAME %q set without VALKEY_DATABASE_PASSWORD", client.Username)
+	}
+
+	return client, nil
+}
+
+// keysStorage returns the configured keys storage of a namespace
+func keysStorage(client app.KeyValueDbClient, namespace string) app.KeyValueEntity {
+	return storageOfKind(os.Getenv("KEYS_STORAGE"), client, namespace)
+}
+
+// storageOfKind returns a keys storage, bitmap or valkey sets by default
+func storageOfKind(kind string, client app.KeyValueDbClient, namespace string) app.KeyValueEntity {
+	if kind == "bitmap" {
+		return &keys.ValkeyBitmap{Client: client, Namespace: namespace}
+	}
+
+	return &keys.Valkey{Client: client, Namespace: namespace}
+}
+
+// keysNamespaces serves the namespaces registered in the db,
+// the default namespace through the given allocator
+func keysNamespaces(db *app.KeyValueDb, allocator *keys.Allocator, owners func(namespace string) keys.Ownership, audit func(namespace string) keys.AuditLog) *keys.Namespaces {
+	return &keys.Namespaces{
+		Registry: &keys.ValkeyNamespaces{Client: db.Client},
+		Storage:  func(namespace string) app.KeyValueEntity { return keysStorage(db.Client, namespace) },
+		Default:  allocator,
+		Owners:   owners,
+		Audit:    audit,
+	}
+}
+
+// keysOwnership records a token with each allocation, as
+// KEYS_OWNERSHIP says: "grace" (by default) lets keys without
+// token, allocated before tokens were recorded, be released
+// whatever the token given, "enforce" requires the token of
+// every key and "off" records none; nil when off
+func keysOwnership(db *app.KeyValueDb) (func(namespace string) keys.Ownership, bool, error) {
+	owners := func(namespace string) keys.Ownership {
+		return &keys.ValkeyOwnership{Client: db.Client, Namespace: namespace}
+	}
+
+	switch v := os.Getenv("KEYS_OWNERSHIP"); v {
+	case "", "grace":
+		return owners, true, nil
+	case "enforce":
+		return owners, false, nil
+	case "off":
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("invalid KEYS_OWNERSHIP %q, either grace, enforce or off", v)
+	}
+}
+
+// keysAudit returns the audit log of each namespace, keeping
+// events for KEYS_AUDIT_RETENTION (90 days by default);
+// nil, auditing nothing, with a retention of 0
+func keysAudit(db *app.KeyValueDb) (func(namespace string) keys.AuditLog, error) {
+	retention := keys.DefaultAuditRetention
+	if v := os.Getenv("KEYS_AUDIT_RETENTION"); v != "" {
+		var err error
+		if retention, err = time.ParseDuration(v); err != nil || (retention != 0 && retention < time.Hour) {
+			return nil, fmt.Errorf("invalid KEYS_AUDIT_RETENTION %q, an hour at least", v)
+		}
+	}
+
+	if retention == 0 {
+		return nil, nil
+	}
+
+	return func(namespace string) keys.AuditLog {
+		return &keys.ValkeyAuditLog{Client: db.Client, Namespace: namespace, Retention: retention}
+	}, nil
+}
+
+// keysWait lets the GetKey calls asking for it wait for new keys
+// up to KEYS_WAIT_MAX (5s) when the call has no deadline, the
+// replicas signalling waits and new keys to each other through
+// streams of the keys db, read on a connection of their own
+// closed by the returned func; no wait with a maximum of 0
+func keysWait(db *app.KeyValueDb, allocator *keys.Allocator) (*keys.Replenishments, func(), error) {
+	maxWait := 5 * time.Second
+	if v := os.Getenv("KEYS_WAIT_MAX"); v != "" {
+		var err error
+		if maxWait, err = time.ParseDuration(v); err != nil || maxWait < 0 {
+			return nil, nil, fmt.Errorf("invalid KEYS_WAIT_MAX %q", v)
+		}
+	}
+
+	if maxWait == 0 {
+		return nil, func() {}, nil
+	}
+
+	identity, err := replicaIdentity()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listener, err := valkeyClient(db.Host, db.Port)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replenishments := &keys.Replenishments{
+		Signals: &keys.ValkeyReplenishmentSignals{Client: db.Client, Listener: listener, Replica: identity},
+		Backoff: keys.Backoff{Initial: time.Second, Max: time.Minute},
+	}
+	allocator.Replenishment, allocator.MaxWait = replenishments.Of(""), maxWait
+
+	return replenishments, listener.Close, nil
+}
+
+func newKeySpaceMonitor() (*keys.KeySpaceMonitor, error) {
+	threshold := 0.0 // no escalation by default
+	if v := os.Getenv("KEYS_ESCALATION_THRESHOLD"); v != "" {
+		var err error
+		if threshold, err = strconv.ParseFloat(v, 64); err != nil || threshold < 0 || threshold > 1 {
+			return nil, fmt.Errorf("invalid KEYS_ESCALATION_THRESHOLD %q", v)
+		}
+	}
+
+	monitor := keys.NewKeySpaceMonitor(1000, threshold)
+	for name, field := range map[string]*float64{
+		"KEYS_ALLOCATION_SMOOTHING":       &monitor.Smoothing,
+		"KEYS_ALLOCATION_TREND_SMOOTHING": &monitor.TrendSmoothing,
+	} {
+		if v := os.Getenv(name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f <= 0 || f > 1 {
+				return nil, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*field = f
+		}
+	}
+
+	return monitor, nil
+}
+
+// monitorInterval is the pace of key space samples, a minute unless configured
+func monitorInterval() (time.Duration, error) {
+	interval := time.Minute
+	if v := os.Getenv("KEYS_MONITOR_INTERVAL"); v != "" {
+		var err error
+		if interval, err = time.ParseDuration(v); err != nil || interval <= 0 {
+			return 0, fmt.Errorf("invalid KEYS_MONITOR_INTERVAL %q", v)
+		}
+	}
+
+	return interval, nil
+}
+
+// keysSupplyPlanner sizes generation to the forecast demand
+// when KEYS_SUPPLY_TARGET is set, nil otherwise
+func keysSupplyPlanner(monitor *keys.KeySpaceMonitor) (*keys.DemandPlanner, error) {
+	v := os.Getenv("KEYS_SUPPLY_TARGET")
+	if v == "" {
+		return nil, nil
+	}
+
+	supply, err := time.ParseDuration(v)
+	if err != nil || supply <= 0 {
+		return nil, fmt.Errorf("invalid KEYS_SUPPLY_TARGET %q", v)
+	}
+
+	planner := &keys.DemandPlanner{Monitor: monitor, Supply: supply}
+	if v := os.Getenv("KEYS_SUPPLY_MIN_AVAILABLE"); v != "" {
+		if planner.MinAvailable, err = strconv.ParseInt(v, 10, 64); err != nil || planner.MinAvailable < 0 {
+			return nil, fmt.Errorf("invalid KEYS_SUPPLY_MIN_AVAILABLE %q", v)
+		}
+	}
+
+	return planner, nil
+}
+
+// namespaceKeysGenerator picks the generator of the
+// factory with the namespace key length
+func namespaceKeysGenerator(factory func(length int) func() (*keys.ShortKey, error)) func(keys.KeyNamespace) func() (*keys.ShortKey, error) {
+	return func(namespace keys.KeyNamespace) func() (*keys.ShortKey, error) {
+		return factory(cmp.Or(namespace.KeyLength, keys.MinKeyLength))
+	}
+}
+
+// keysGeneratorFactory returns generators of keys
+// of a given length fitting the keys storage
+func keysGeneratorFactory() (func(length int) func() (*keys.ShortKey, error), error) {
+	if os.Getenv("KEYS_STORAGE") != "bitmap" {
+		return keys.NextKeyOfLength, nil
+	}
+
+	segments := uint64(16)
+	if v := os.Getenv("KEYS_BITMAP_SEGMENTS"); v != "" {
+		var err error
+		if segments, err = strconv.ParseUint(v, 10, 64); err != nil || segments == 0 {
+			return nil, fmt.Errorf("invalid KEYS_BITMAP_SEGMENTS %q", v)
+		}
 	}
+
+	return keys.SegmentedKeys(segments), nil
+}
+
+// keysGeneratorPool configures concurrent generation,
+// by default a key per second like a single loop, opening
+// its circuit after 5 consecutive failed writes; returns
+// a func building pools of that configuration, each with
+// its own circuit
+func keysGeneratorPool(db *app.KeyValueDb) (func() *keys.GeneratorPool, error) {
+	pool := keys.GeneratorPool{
+		Keys:      db.Keys,
+		Workers:   1,
+		Writers:   1,
+		BatchSize: 1,
+		Buffer:    1,
+		Interval:  time.Second,
+		Backoff:   keys.Backoff{Initial: 100 * time.Millisecond, Max: 30 * time.Second},
+	}
+
+	for name, field := range map[string]*int{
+		"KEYS_GENERATOR_WORKERS":    &pool.Workers,
+		"KEYS_GENERATOR_WRITERS":    &pool.Writers,
+		"KEYS_GENERATOR_BATCH_SIZE": &pool.BatchSize,
+		"KEYS_GENERATOR_BUFFER":     &pool.Buffer,
+	} {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*field = n
+		}
+	}
+
+	if v := os.Getenv("KEYS_GENERATOR_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval < 0 {
+			return nil, fmt.Errorf("invalid KEYS_GENERATOR_INTERVAL %q", v)
+		}
+		pool.Interval = interval
+	}
+
+	return func() *keys.GeneratorPool {
+		return &keys.GeneratorPool{
+			Keys:      pool.Keys,
+			Workers:   pool.Workers,
+			Writers:   pool.Writers,
+			BatchSize: pool.BatchSize,
+			Buffer:    pool.Buffer,
+			Interval:  pool.Interval,
+			Backoff:   pool.Backoff,
+			Breaker:   &keys.CircuitBreaker{Threshold: 5, Cooldown: 30 * time.Second},
+		}
+	}, nil
+}
+
+// keysGeneratorElection picks a single replica to generate
+// keys, a replica dying unexpectedly is replaced after the
+// lease TTL, 10s unless configured
+func keysGeneratorElection(db *app.KeyValueDb) (*coordination.Election, error) {
+	return keysElection(db, "keysGenerator")
+}
+
+// keysElection picks a single replica to run the named
+// job, with the KEYS_GENERATOR_LEASE_TTL lease
+func keysElection(db *app.KeyValueDb, name string) (*coordination.Election, error) {
+	identity, err := replicaIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := 10 * time.Second
+	if v := os.Getenv("KEYS_GENERATOR_LEASE_TTL"); v != "" {
+		var err error
+		if ttl, err = time.ParseDuration(v); err != nil || ttl < time.Second {
+			return nil, fmt.Errorf("invalid KEYS_GENERATOR_LEASE_TTL %q", v)
+		}
+	}
+
+	return &coordination.Election{
+		Elector:  &coordination.ValkeyElector{Name: name, Client: db.Client},
+		Identity: identity,
+		TTL:      ttl,
+	}, nil
+}
+
+// replicaIdentity names the replica in leases and locks,
+// KEYGEN_REPLICA_ID or its host name and process ID
+func replicaIdentity() (string, error) {
+	if identity := os.Getenv("KEYGEN_REPLICA_ID"); identity != "" {
+		return identity, nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to identify replica: %w", err)
+	}
+
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid()), nil
+}
+
+// keysScheduler runs the background jobs, each scheduled time
+// on the first replica locking it in the keys db, delayed by
+// up to KEYS_JOBS_JITTER (30s)
+func keysScheduler(db *app.KeyValueDb) (*jobs.Scheduler, time.Duration, error) {
+	identity, err := replicaIdentity()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jitter := 30 * time.Second
+	if v := os.Getenv("KEYS_JOBS_JITTER"); v != "" {
+		if jitter, err = time.ParseDuration(v); err != nil || jitter < 0 {
+			return nil, 0, fmt.Errorf("invalid KEYS_JOBS_JITTER %q", v)
+		}
+	}
+
+	return &jobs.Scheduler{Locker: &jobs.ValkeyLocker{Client: db.Client}, Identity: identity}, jitter, nil
+}
+
+// keysPrefetchBuffer buffers KEYS_PREFETCH_SIZE keys for GetKey,
+// refilled below KEYS_PREFETCH_THRESHOLD, half of it by default;
+// nil when no size is configured
+func keysPrefetchBuffer(db *app.KeyValueDb) (*keys.PrefetchBuffer, error) {
+	v := os.Getenv("KEYS_PREFETCH_SIZE")
+	if v == "" {
+		return nil, nil
+	}
+
+	size, err := strconv.Atoi(v)
+	if err != nil || size < 1 {
+		return nil, fmt.Errorf("invalid KEYS_PREFETCH_SIZE %q", v)
+	}
+
+	threshold := (size + 1) / 2
+	if v := os.Getenv("KEYS_PREFETCH_THRESHOLD"); v != "" {
+		if threshold, err = strconv.Atoi(v); err != nil || threshold < 1 || threshold > size {
+			return nil, fmt.Errorf("invalid KEYS_PREFETCH_THRESHOLD %q", v)
+		}
+	}
+
+	return keys.NewPrefetchBuffer(db.Keys, size, threshold), nil
+}
+
+// keysEventPublisher publishes the keys events to the
+// KEYS_EVENTS_SINK, a valkey stream capped to about
+// KEYS_EVENTS_MAX_LEN events or the KEYS_EVENTS_FILE, through
+// the outbox stream shared by the replicas, buffering up to
+// KEYS_EVENTS_BUFFER events the outbox failed to take; the
+// reader is nil for unreadable sinks, both nil when no sink
+// is configured
+func keysEventPublisher(db *app.KeyValueDb) (*keys.EventPublisher, keys.EventReader, error) {
+	var sink keys.EventSink
+	var reader keys.EventReader
+
+	switch v := os.Getenv("KEYS_EVENTS_SINK"); v {
+	case "":
+		return nil, nil, nil
+	case "valkey":
+		valkeySink := &keys.ValkeyEventSink{Client: db.Client}
+		if v := os.Getenv("KEYS_EVENTS_MAX_LEN"); v != "" {
+			var err error
+			if valkeySink.MaxLen, err = strconv.ParseInt(v, 10, 64); err != nil || valkeySink.MaxLen < 1 {
+				return nil, nil, fmt.Errorf("invalid KEYS_EVENTS_MAX_LEN %q", v)
+			}
+		}
+		sink, reader = valkeySink, valkeySink
+	case "file":
+		path := os.Getenv("KEYS_EVENTS_FILE")
+		if path == "" {
+			return nil, nil, errors.New("KEYS_EVENTS_FILE is required by the file sink")
+		}
+		sink = &keys.FileEventSink{Path: path}
+	default:
+		return nil, nil, fmt.Errorf("invalid KEYS_EVENTS_SINK %q, valkey or file", v)
+	}
+
+	buffer := 10000
+	if v := os.Getenv("KEYS_EVENTS_BUFFER"); v != "" {
+		var err error
+		if buffer, err = strconv.Atoi(v); err != nil || buffer < 1 {
+			return nil, nil, fmt.Errorf("invalid KEYS_EVENTS_BUFFER %q", v)
+		}
+	}
+
+	identity, err := replicaIdentity()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	publisher := keys.NewEventPublisher(sink, buffer)
+	publisher.Outbox = &keys.ValkeyEventOutbox{Client: db.Client, Consumer: identity}
+
+	return publisher, reader, nil
+}
+
+// keysAdminAddress serves the KeysAdmin API on its own
+// listener, at KEYS_ADMIN_ADDRESS or 127.0.0.1:8081; fails
+// when reachable beyond loopback without authentication
+func keysAdminAddress(authenticated bool) (string, error) {
+	address := cmp.Or(os.Getenv("KEYS_ADMIN_ADDRESS"), "127.0.0.1:8081")
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid KEYS_ADMIN_ADDRESS %q: %w", address, err)
+	}
+
+	if ip := net.ParseIP(host); !authenticated && host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", fmt.Errorf("KEYS_ADMIN_ADDRESS %q beyond loopback requires KEYS_AUTH_CONFIG", address)
+	}
+
+	return address, nil
+}
+
+// monitoringAddress serves expvar metrics at
+// KEYS_MONITORING_ADDRESS or 127.0.0.1:9090
+func monitoringAddress() string {
+	return cmp.Or(os.Getenv("KEYS_MONITORING_ADDRESS"), "127.0.0.1:9090")
+}
+
+// keysAuthenticator authenticates the clients configured
+// in the KEYS_AUTH_CONFIG file; nil, leaving the API open,
+// when no file is configured
+func keysAuthenticator() (*auth.Authenticator, error) {
+	path := os.Getenv("KEYS_AUTH_CONFIG")
+	if path == "" {
+		return nil, nil
+	}
+
+	config, err := auth.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.NewAuthenticator(config)
+}
+
+// keysServerTLS serves TLS with the KEYS_TLS_CERT_FILE and KEYS_TLS_KEY_FILE
+// certificate, reloaded every KEYS_TLS_RELOAD_INTERVAL (1m) when rotated;
+// client certificates signed by KEYS_TLS_CLIENT_CA_FILE are verified, and
+// required with KEYS_TLS_CLIENT_AUTH=require. Nil without certificate
+func keysServerTLS() (*tls.Config, *auth.CertificateReloader, time.Duration, error) {
+	certFile, keyFile := os.Getenv("KEYS_TLS_CERT_FILE"), os.Getenv("KEYS_TLS_KEY_FILE")
+	if certFile == "" && keyFile == "" {
+		return nil, nil, 0, nil
+	}
+
+	if certFile == "" || keyFile == "" {
+		return nil, nil, 0, errors.New("KEYS_TLS_CERT_FILE and KEYS_TLS_KEY_FILE must be set together")
+	}
+
+	reloader, err := auth.NewCertificateReloader(certFile, keyFile)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	var requireClientCert bool
+	switch v := os.Getenv("KEYS_TLS_CLIENT_AUTH"); v {
+	case "", "optional":
+	case "require":
+		requireClientCert = true
+	default:
+		return nil, nil, 0, fmt.Errorf("invalid KEYS_TLS_CLIENT_AUTH %q, either optional or require", v)
+	}
+
+	config, err := auth.ServerTLSConfig(reloader, os.Getenv("KEYS_TLS_CLIENT_CA_FILE"), requireClientCert)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	interval := time.Minute
+	if v := os.Getenv("KEYS_TLS_RELOAD_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil || interval <= 0 {
+			return nil, nil, 0, fmt.Errorf("invalid KEYS_TLS_RELOAD_INTERVAL %q", v)
+		}
+	}
+
+	return config, reloader, interval, nil
+}
+
+// keysLimiter enforces the client limits of the KEYS_LIMITS_CONFIG
+// file on GetKey, counted in the db; nil when no file is configured
+func keysLimiter(db *app.KeyValueDb) (*quota.Limiter, error) {
+	path := os.Getenv("KEYS_LIMITS_CONFIG")
+	if path == "" {
+		return nil, nil
+	}
+
+	config, err := quota.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return quota.NewLimiter(&quota.ValkeyStore{Client: db.Client}, config), nil
+}
+
+// keysReconcileJob reconciles the keys of every namespace on the
+// KEYS_RECONCILE_SCHEDULE (a cron expression or "@every 1h") or
+// every KEYS_RECONCILE_INTERVAL, repairing the anomalies found with
+// KEYS_RECONCILE_REPAIR=true; nil when not scheduled
+func keysReconcileJob(namespaces *keys.Namespaces) (*keys.ReconcileJob, jobs.Schedule, error) {
+	v := os.Getenv("KEYS_RECONCILE_SCHEDULE")
+	if v == "" {
+		if interval := os.Getenv("KEYS_RECONCILE_INTERVAL"); interval != "" {
+			v = "@every " + interval
+		}
+	}
+
+	if v == "" {
+		return nil, nil, nil
+	}
+
+	schedule, err := jobs.ParseSchedule(v)
+	if every, ok := schedule.(jobs.Every); err != nil || ok && time.Duration(every) < time.Minute {
+		return nil, nil, fmt.Errorf("invalid keys reconciliation schedule %q, every minute at most", v)
+	}
+
+	if os.Getenv("KEYS_STORAGE") == "bitmap" {
+		return nil, nil, errors.New("keys reconciliation needs the sets storage, bitmaps are checked by keygenctl check")
+	}
+
+	// pages are paced to spare the storage serving allocations
+	reconciler := &keys.Reconciler{BatchSize: 1000, Pause: 10 * time.Millisecond}
+	if v := os.Getenv("KEYS_RECONCILE_REPAIR"); v != "" {
+		if reconciler.Repair, err = strconv.ParseBool(v); err != nil {
+			return nil, nil, fmt.Errorf("invalid KEYS_RECONCILE_REPAIR %q", v)
+		}
+	}
+
+	return &keys.ReconcileJob{Namespaces: namespaces, Reconciler: reconciler}, schedule, nil
 }
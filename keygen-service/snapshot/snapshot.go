@@ -0,0 +1,346 @@
+// Package snapshot exports keys pools to versioned JSONL or CSV
+// snapshots and imports them back, streaming the keys so pools of
+// millions of keys are moved in constant memory
+package snapshot
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"keygen-service/keys"
+	"strconv"
+	"time"
+)
+
+const (
+	// Kind names the snapshots in their header
+	Kind = "keygen-snapshot"
+
+	// Version of the snapshots written, older ones are read too
+	Version = 1
+)
+
+// Format of a snapshot
+type Format string
+
+const (
+	// JSONL snapshots hold a JSON object per line
+	JSONL Format = "jsonl"
+
+	// CSV snapshots hold a header row, a columns row, a row
+	// per key and an end row whose first column is "#end"
+	CSV Format = "csv"
+)
+
+// ErrInvalidSnapshot is returned when reading
+// a snapshot truncated, altered or malformed
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+// Header opens a snapshot
+type Header struct {
+	Kind      string    `json:"kind"`
+	Version   int       `json:"version"`
+	Namespace string    `json:"namespace"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Record is a key of a snapshot with its state,
+// the ownership token of taken keys included
+type Record struct {
+	Key   string `json:"key"`
+	State string `json:"state"`
+	Token string `json:"token,omitempty"`
+}
+
+// Trailer closes a snapshot, counting its records and
+// with the SHA-256 of their "key,state,token\n" lines
+// whatever the format
+type Trailer struct {
+	End    bool   `json:"end"`
+	Count  int64  `json:"count"`
+	SHA256 string `json:"sha256"`
+}
+
+// Validate checks the record key and state
+func (r Record) Validate() error {
+	if _, err := keys.NewKeyFromBytes([]byte(r.Key)); err != nil {
+		return fmt.Errorf("invalid key %q: %w", r.Key, err)
+	}
+
+	switch keys.KeyState(r.State) {
+	case keys.KeyAvailable, keys.KeyReserved, keys.KeyQuarantined, keys.KeyBanned:
+		if r.Token != "" {
+			return fmt.Errorf("token of %s key %q", r.State, r.Key)
+		}
+	case keys.KeyTaken:
+	default:
+		return fmt.Errorf("invalid state %q of key %q", r.State, r.Key)
+	}
+
+	return nil
+}
+
+// checksum sums records independently of their format
+type checksum struct {
+	hash  hash.Hash
+	count int64
+}
+
+func newChecksum() *checksum {
+	return &checksum{hash: sha256.New()}
+}
+
+func (c *checksum) add(r Record) {
+	fmt.Fprintf(c.hash, "%s,%s,%s\n", r.Key, r.State, r.Token)
+	c.count++
+}
+
+func (c *checksum) trailer() Trailer {
+	return Trailer{End: true, Count: c.count, SHA256: hex.EncodeToString(c.hash.Sum(nil))}
+}
+
+// writer encodes a snapshot in a format
+type writer interface {
+	header(Header) error
+	record(Record) error
+	trailer(Trailer) error
+}
+
+func newWriter(w io.Writer, format Format) (writer, error) {
+	switch format {
+	case JSONL, "":
+		return &jsonlWriter{encoder: json.NewEncoder(w)}, nil
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown snapshot format %q", format)
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (j *jsonlWriter) header(h Header) error   { return j.encoder.Encode(h) }
+func (j *jsonlWriter) record(r Record) error   { return j.encoder.Encode(r) }
+func (j *jsonlWriter) trailer(t Trailer) error { return j.encoder.Encode(t) }
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) header(h Header) error {
+	rows := [][]string{
+		{h.Kind, strconv.Itoa(h.Version), h.Namespace, h.CreatedAt.Format(time.RFC3339)},
+		{"key", "state", "token"},
+	}
+
+	return c.w.WriteAll(rows)
+}
+
+func (c *csvWriter) record(r Record) error {
+	return c.w.Write([]string{r.Key, r.State, r.Token})
+}
+
+func (c *csvWriter) trailer(t Trailer) error {
+	return c.w.WriteAll([][]string{{"#end", strconv.FormatInt(t.Count, 10), t.SHA256}})
+}
+
+// reader decodes a snapshot, telling
+// its format from the first byte
+type reader struct {
+	header Header
+
+	// next returns the next record, or
+	// the trailer once at the end
+	next func() (Record, *Trailer, error)
+}
+
+func newReader(r io.Reader) (*reader, error) {
+	buffered := bufio.NewReader(r)
+
+	first, err := buffered.Peek(1)
+	if err != nil {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidSnapshot)
+	}
+
+	var rd *reader
+	if first[0] == '{' {
+		rd, err = newJSONLReader(buffered)
+	} else {
+		rd, err = newCSVReader(buffered)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rd.header.Kind != Kind {
+		return nil, fmt.Errorf("%w: not a %s", ErrInvalidSnapshot, Kind)
+	}
+
+	if rd.header.Version < 1 || rd.header.Version > Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, rd.header.Version)
+	}
+
+	return rd, nil
+}
+
+func newJSONLReader(r io.Reader) (*reader, error) {
+	scanner := bufio.NewScanner(r)
+
+	line := 0
+	scan := func(v any) error {
+		line++
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return fmt.Errorf("failed to read snapshot: %w", err)
+			}
+			return fmt.Errorf("%w: truncated", ErrInvalidSnapshot)
+		}
+
+		if err := json.Unmarshal(scanner.Bytes(), v); err != nil {
+			return fmt.Errorf("%w: line %d: %w", ErrInvalidSnapshot, line, err)
+		}
+
+		return nil
+	}
+
+	rd := &reader{}
+	if err := scan(&rd.header); err != nil {
+		return nil, err
+	}
+
+	rd.next = func() (Record, *Trailer, error) {
+		var l struct {
+			Record
+			Trailer
+		}
+		if err := scan(&l); err != nil {
+			return Record{}, nil, err
+		}
+
+		if l.End {
+			return Record{}, &l.Trailer, nil
+		}
+
+		return l.Record, nil, nil
+	}
+
+	return rd, nil
+}
+
+func newCSVReader(r io.Reader) (*reader, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+
+	read := func(fields int) ([]string, error) {
+		row, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: truncated", ErrInvalidSnapshot)
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+		}
+
+		if len(row) != fields {
+			line, _ := csvReader.FieldPos(0)
+			return nil, fmt.Errorf("%w: line %d: %d fields, want %d", ErrInvalidSnapshot, line, len(row), fields)
+		}
+
+		return row, nil
+	}
+
+	row, err := read(4)
+	if err != nil {
+		return nil, err
+	}
+
+	rd := &reader{header: Header{Kind: row[0], Namespace: row[2]}}
+	if rd.header.Version, err = strconv.Atoi(row[1]); err != nil {
+		return nil, fmt.Errorf("%w: invalid version %q", ErrInvalidSnapshot, row[1])
+	}
+
+	if rd.header.CreatedAt, err = time.Parse(time.RFC3339, row[3]); err != nil {
+		return nil, fmt.Errorf("%w: invalid creation time %q", ErrInvalidSnapshot, row[3])
+	}
+
+	if _, err := read(3); err != nil { // columns
+		return nil, err
+	}
+
+	rd.next = func() (Record, *Trailer, error) {
+		row, err := read(3)
+		if err != nil {
+			return Record{}, nil, err
+		}
+
+		if row[0] != "#end" {
+			return Record{Key: row[0], State: row[1], Token: row[2]}, nil, nil
+		}
+
+		count, err := strconv.ParseInt(row[1], 10, 64)
+		if err != nil {
+			return Record{}, nil, fmt.Errorf("%w: invalid count %q", ErrInvalidSnapshot, row[1])
+		}
+
+		return Record{}, &Trailer{End: true, Count: count, SHA256: row[2]}, nil
+	}
+
+	return rd, nil
+}
+
+// Verify reads a whole snapshot, checking its records
+// and that its checksum matches them
+func Verify(r io.Reader) (Header, Trailer, error) {
+	var header Header
+	trailer, err := read(r, func(h Header) error {
+		header = h
+		return nil
+	}, func(Record) error { return nil })
+
+	return header, trailer, err
+}
+
+// read calls the functions with the header and each
+// valid record of the snapshot, then checks its trailer
+func read(r io.Reader, onHeader func(Header) error, onRecord func(Record) error) (Trailer, error) {
+	rd, err := newReader(r)
+	if err != nil {
+		return Trailer{}, err
+	}
+
+	if err := onHeader(rd.header); err != nil {
+		return Trailer{}, err
+	}
+
+	sum := newChecksum()
+	for {
+		record, trailer, err := rd.next()
+		if err != nil {
+			return Trailer{}, err
+		}
+
+		if trailer != nil {
+			if *trailer != sum.trailer() {
+				return Trailer{}, fmt.Errorf("%w: checksum mismatch, %d records read", ErrInvalidSnapshot, sum.count)
+			}
+
+			return *trailer, nil
+		}
+
+		if err := record.Validate(); err != nil {
+			return Trailer{}, fmt.Errorf("%w: record %d: %w", ErrInvalidSnapshot, sum.count+1, err)
+		}
+		sum.add(record)
+
+		if err := onRecord(record); err != nil {
+			return Trailer{}, err
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"keygen-service/keys"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// poolMock keeps the state of each key in memory,
+// listing them by pages of two in key order
+type poolMock struct {
+	states  map[string]keys.KeyState
+	tokens  map[string]string
+	cleared bool
+}
+
+func newPoolMock() *poolMock {
+	return &poolMock{states: map[string]keys.KeyState{}, tokens: map[string]string{}}
+}
+
+func (p *poolMock) ListKeys(_ context.Context, state keys.KeyState, cursor string, _ int) ([]keys.ShortKey, string, error) {
+	var listed []keys.ShortKey
+	for k, s := range p.states {
+		// banned and reserved keys are taken too
+		if s == state || (state == keys.KeyTaken && s != keys.KeyAvailable) {
+			listed = append(listed, keys.ShortKey(k))
+		}
+	}
+	slices.SortFunc(listed, func(a, b keys.ShortKey) int { return slices.Compare(a, b) })
+
+	start, _ := strconv.Atoi(cursor)
+	end := min(start+2, len(listed))
+	if end == len(listed) {
+		return listed[start:end], "", nil
+	}
+
+	return listed[start:end], strconv.Itoa(end), nil
+}
+
+func (p *poolMock) Restore(state keys.KeyState, batch []keys.ShortKey, keep bool) ([]keys.ShortKey, error) {
+	var restored []keys.ShortKey
+	for _, k := range batch {
+		if _, known := p.states[string(k)]; known && keep {
+			continue
+		}
+
+		p.states[string(k)] = state
+		restored = append(restored, k)
+	}
+
+	return restored, nil
+}
+
+// Clear clears both the keys and their tokens
+func (p *poolMock) Clear() error {
+	p.states, p.tokens, p.cleared = map[string]keys.KeyState{}, map[string]string{}, true
+	return nil
+}
+
+func (p *poolMock) Owners(batch []keys.ShortKey) ([]string, error) {
+	tokens := make([]string, len(batch))
+	for i, k := range batch {
+		tokens[i] = p.tokens[string(k)]
+	}
+
+	return tokens, nil
+}
+
+func (p *poolMock) OwnAll(tokens map[string]string) error {
+	for k, token := range tokens {
+		p.tokens[k] = token
+	}
+
+	return nil
+}
+
+func (p *poolMock) Own(key keys.ShortKey, token string) error {
+	p.tokens[string(key)] = token
+	return nil
+}
+
+func (p *poolMock) Owner(key keys.ShortKey) (string, error) {
+	return p.tokens[string(key)], nil
+}
+
+func (p *poolMock) Disown(key keys.ShortKey, token string) (bool, error) {
+	owner, ok := p.tokens[string(key)]
+	if ok && owner != token {
+		return false, keys.ErrNotOwner
+	}
+
+	delete(p.tokens, string(key))
+	return ok, nil
+}
+
+func testPool() *poolMock {
+	pool := newPoolMock()
+	pool.states = map[string]keys.KeyState{
+		"aaaaaa": keys.KeyAvailable,
+		"bbbbbb": keys.KeyAvailable,
+		"cccccc": keys.KeyAvailable,
+		"dddddd": keys.KeyTaken,
+		"eeeeee": keys.KeyTaken,
+		"ffffff": keys.KeyReserved,
+		"gggggg": keys.KeyBanned,
+	}
+	pool.tokens = map[string]string{"dddddd": "token"}
+
+	return pool
+}
+
+func export(t *testing.T, pool *poolMock, format Format) []byte {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	exporter := &Exporter{Source: pool, Owners: pool, Namespace: "brand", Format: format}
+	if trailer, err := exporter.Export(context.Background(), out); err != nil || trailer.Count != 7 {
+		t.Fatalf("Export() = %+v, %v, want 7 keys", trailer, err)
+	}
+
+	return out.Bytes()
+}
+
+func TestExport_GivenFormats(t *testing.T) {
+	pool := testPool()
+
+	var checksums []string
+	for _, format := range []Format{JSONL, CSV} {
+		snapshot := export(t, pool, format)
+
+		header, trailer, err := Verify(bytes.NewReader(snapshot))
+		if err != nil {
+			t.Fatalf("Verify() of %s failed: %v\n%s", format, err, snapshot)
+		}
+
+		if header.Namespace != "brand" || header.Version != Version {
+			t.Errorf("Verify() = %+v, want the header of a brand snapshot", header)
+		}
+
+		checksums = append(checksums, trailer.SHA256)
+	}
+
+	if checksums[0] != checksums[1] {
+		t.Errorf("Verify() = %v, want the same checksum for every format", checksums)
+	}
+}
+
+func TestImport_GivenModes(t *testing.T) {
+	for _, format := range []Format{JSONL, CSV} {
+		snapshot := export(t, testPool(), format)
+
+		target := newPoolMock()
+		target.states["aaaaaa"] = keys.KeyTaken
+		target.states["zzzzzz"] = keys.KeyAvailable
+
+		importer := &Importer{Storage: target, Owners: target, BatchSize: 2}
+		_, stats, err := importer.Import(context.Background(), bytes.NewReader(snapshot))
+		if err != nil || stats.Restored != 6 || stats.Skipped != 1 {
+			t.Fatalf("Import() = %+v, %v, want 6 keys restored and 1 skipped", stats, err)
+		}
+
+		if target.states["aaaaaa"] != keys.KeyTaken || target.states["ffffff"] != keys.KeyReserved || target.tokens["dddddd"] != "token" {
+			t.Errorf("Import() merged %v, %v, want known keys kept and the others restored", target.states, target.tokens)
+		}
+
+		importer.Mode = Replace
+		if _, stats, err := importer.Import(context.Background(), bytes.NewReader(snapshot)); err != nil || stats.Restored != 7 {
+			t.Fatalf("Import() = %+v, %v, want 7 keys restored", stats, err)
+		}
+
+		if _, ok := target.states["zzzzzz"]; !target.cleared || ok || target.states["aaaaaa"] != keys.KeyAvailable || target.tokens["dddddd"] != "token" {
+			t.Errorf("Import() replaced %v, want the snapshot keys only", target.states)
+		}
+	}
+}
+
+func TestImport_GivenInvalidSnapshots(t *testing.T) {
+	snapshot := string(export(t, testPool(), JSONL))
+
+	tests := map[string]string{
+		"invalid key":     strings.Replace(snapshot, `"key":"bbbbbb"`, `"key":"bb/bbb"`, 1),
+		"altered state":   strings.Replace(snapshot, `"key":"bbbbbb","state":"available"`, `"key":"bbbbbb","state":"taken"`, 1),
+		"truncated":       snapshot[:strings.LastIndex(snapshot, `{"end"`)],
+		"unknown version": strings.Replace(snapshot, `"version":1`, `"version":99`, 1),
+		"empty":           "",
+	}
+
+	for name, s := range tests {
+		target := newPoolMock()
+		importer := &Importer{Storage: target, Mode: Replace}
+
+		if _, _, err := importer.Import(context.Background(), strings.NewReader(s)); !errors.Is(err, ErrInvalidSnapshot) {
+			t.Errorf("Import() of %s snapshot = %v, want %v", name, err, ErrInvalidSnapshot)
+		}
+
+		if len(target.states) != 0 || target.cleared {
+			t.Errorf("Import() of %s snapshot changed the storage, want it refused as a whole", name)
+		}
+	}
+}
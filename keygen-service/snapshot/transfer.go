@@ -0,0 +1,342 @@
+package snapshot
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"keygen-service/keys"
+	"time"
+)
+
+// DefaultBatchSize keys are listed, or
+// restored, at once by default
+const DefaultBatchSize = 1000
+
+// Source lists the keys of a pool, e.g. a keys.Allocator
+type Source interface {
+	ListKeys(ctx context.Context, state keys.KeyState, cursor string, count int) ([]keys.ShortKey, string, error)
+}
+
+// Exporter writes snapshots of a keys pool
+type Exporter struct {
+	Source Source
+
+	// Owners, when set, adds the ownership
+	// tokens of the taken keys
+	Owners keys.Ownership
+
+	// Namespace names the pool in the snapshot header
+	Namespace string
+
+	// Format of the snapshot, JSONL when empty
+	Format Format
+
+	// BatchSize keys are listed at once,
+	// DefaultBatchSize when zero
+	BatchSize int
+}
+
+// Export writes a snapshot of every key; reserved, quarantined and
+// banned keys are held in memory to tell them from the other taken
+// keys, and keys changing state meanwhile may be written twice, so
+// pools should be quiet for exact snapshots
+func (e *Exporter) Export(ctx context.Context, w io.Writer) (Trailer, error) {
+	out, err := newWriter(w, e.Format)
+	if err != nil {
+		return Trailer{}, err
+	}
+
+	header := Header{Kind: Kind, Version: Version, Namespace: e.Namespace, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	if err := out.header(header); err != nil {
+		return Trailer{}, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	sum := newChecksum()
+	write := func(r Record) error {
+		sum.add(r)
+		if err := out.record(r); err != nil {
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+
+		return nil
+	}
+
+	special := map[string]bool{}
+	for _, state := range []keys.KeyState{keys.KeyBanned, keys.KeyQuarantined, keys.KeyReserved} {
+		err := e.list(ctx, state, func(page []keys.ShortKey) error {
+			for _, k := range page {
+				if special[string(k)] {
+					continue
+				}
+				special[string(k)] = true
+
+				if err := write(Record{Key: string(k), State: string(state)}); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return Trailer{}, err
+		}
+	}
+
+	err = e.list(ctx, keys.KeyTaken, func(page []keys.ShortKey) error {
+		tokens, err := e.tokens(page)
+		if err != nil {
+			return err
+		}
+
+		for i, k := range page {
+			if special[string(k)] {
+				continue
+			}
+
+			if err := write(Record{Key: string(k), State: string(keys.KeyTaken), Token: tokens[i]}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Trailer{}, err
+	}
+
+	err = e.list(ctx, keys.KeyAvailable, func(page []keys.ShortKey) error {
+		for _, k := range page {
+			if err := write(Record{Key: string(k), State: string(keys.KeyAvailable)}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Trailer{}, err
+	}
+
+	trailer := sum.trailer()
+	if err := out.trailer(trailer); err != nil {
+		return Trailer{}, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return trailer, nil
+}
+
+// list calls f with each page of the keys in the state
+func (e *Exporter) list(ctx context.Context, state keys.KeyState, f func([]keys.ShortKey) error) error {
+	cursor := ""
+	for {
+		page, next, err := e.Source.ListKeys(ctx, state, cursor, cmp.Or(e.BatchSize, DefaultBatchSize))
+		if err != nil {
+			return fmt.Errorf("failed to list %s keys: %w", state, err)
+		}
+
+		if err := f(page); err != nil {
+			return err
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// tokens returns the ownership tokens of the keys
+func (e *Exporter) tokens(page []keys.ShortKey) ([]string, error) {
+	tokens := make([]string, len(page))
+	if e.Owners == nil {
+		return tokens, nil
+	}
+
+	if batch, ok := e.Owners.(keys.BatchOwnership); ok {
+		owners, err := batch.Owners(page)
+		if err != nil {
+			return nil, err
+		}
+
+		copy(tokens, owners)
+		return tokens, nil
+	}
+
+	for i, k := range page {
+		token, err := e.Owners.Owner(k)
+		if err != nil {
+			return nil, err
+		}
+		tokens[i] = token
+	}
+
+	return tokens, nil
+}
+
+// Mode of an import
+type Mode string
+
+const (
+	// Merge leaves the keys known by the storage as they are
+	Merge Mode = "merge"
+
+	// Replace clears the storage before importing
+	Replace Mode = "replace"
+)
+
+// ImportStats counts the keys of an import
+type ImportStats struct {
+	Restored int64
+	Skipped  int64 // known by the storage when merging
+}
+
+// Importer restores snapshots into a keys storage
+type Importer struct {
+	Storage keys.KeyRestorer
+
+	// Owners, when set, records the
+	// ownership tokens of the taken keys
+	Owners keys.Ownership
+
+	// Mode of the import, Merge when empty
+	Mode Mode
+
+	// BatchSize keys are restored at once,
+	// DefaultBatchSize when zero
+	BatchSize int
+}
+
+// Import verifies the whole snapshot before restoring it, so
+// that snapshots with a single invalid key change nothing
+func (i *Importer) Import(ctx context.Context, r io.ReadSeeker) (Header, ImportStats, error) {
+	header, _, err := Verify(r)
+	if err != nil {
+		return Header{}, ImportStats{}, err
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Header{}, ImportStats{}, fmt.Errorf("failed to rewind snapshot: %w", err)
+	}
+
+	keep := true
+	switch i.Mode {
+	case Merge, "":
+	case Replace:
+		keep = false
+		if err := i.clear(); err != nil {
+			return Header{}, ImportStats{}, err
+		}
+	default:
+		return Header{}, ImportStats{}, fmt.Errorf("unknown import mode %q", i.Mode)
+	}
+
+	var stats ImportStats
+	var batch []Record
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		restored, err := i.restore(batch, keep)
+		stats.Restored += restored
+		stats.Skipped += int64(len(batch)) - restored
+		batch = batch[:0]
+
+		return err
+	}
+
+	_, err = read(r, func(Header) error { return nil }, func(record Record) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if len(batch) > 0 && (batch[0].State != record.State || len(batch) >= cmp.Or(i.BatchSize, DefaultBatchSize)) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		batch = append(batch, record)
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+
+	return header, stats, err
+}
+
+// restore restores a batch of keys sharing a state
+// with their tokens, returns how many were restored
+func (i *Importer) restore(batch []Record, keep bool) (int64, error) {
+	state := keys.KeyState(batch[0].State)
+
+	batchKeys := make([]keys.ShortKey, len(batch))
+	tokens := map[string]string{}
+	for n, r := range batch {
+		batchKeys[n] = keys.ShortKey(r.Key)
+		if r.Token != "" {
+			tokens[r.Key] = r.Token
+		}
+	}
+
+	restored, err := i.Storage.Restore(state, batchKeys, keep)
+	if err != nil {
+		return int64(len(restored)), fmt.Errorf("failed to restore %s keys: %w", state, err)
+	}
+
+	if i.Owners == nil || len(tokens) == 0 {
+		return int64(len(restored)), nil
+	}
+
+	restoredTokens := map[string]string{}
+	for _, k := range restored {
+		if token, ok := tokens[string(k)]; ok {
+			restoredTokens[string(k)] = token
+		}
+	}
+
+	if err := i.own(restoredTokens); err != nil {
+		return int64(len(restored)), fmt.Errorf("failed to restore keys owners: %w", err)
+	}
+
+	return int64(len(restored)), nil
+}
+
+func (i *Importer) own(tokens map[string]string) error {
+	if batch, ok := i.Owners.(keys.BatchOwnership); ok {
+		return batch.OwnAll(tokens)
+	}
+
+	for k, token := range tokens {
+		if err := i.Owners.Own(keys.ShortKey(k), token); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// clear removes the keys, and their tokens, before replacing them
+func (i *Importer) clear() error {
+	batch, ok := i.Owners.(keys.BatchOwnership)
+	if i.Owners != nil && !ok {
+		return errors.New("keys owners can't be cleared")
+	}
+
+	if err := i.Storage.Clear(); err != nil {
+		return fmt.Errorf("failed to clear keys: %w", err)
+	}
+
+	if batch == nil {
+		return nil
+	}
+
+	if err := batch.Clear(); err != nil {
+		return fmt.Errorf("failed to clear keys owners: %w", err)
+	}
+
+	return nil
+}
@@ -55,8 +55,9 @@ if (!process.env.KEYGEN_SERVICE_URL) {
     throw new Error("could not configure keygen client: service url is missing");
 }
 
-const keysClient = new KeysClient(process.env.KEYGEN_SERVICE_URL, grpc.credentials.createInsecure());
-const keyService = new KeygenKeyService(keysClient);
+const keysCredentials = process.env.KEYGEN_SERVICE_TLS === "true" ? grpc.credentials.createSsl() : grpc.credentials.createInsecure();
+const keysClient = new KeysClient(process.env.KEYGEN_SERVICE_URL, keysCredentials);
+const keyService = new KeygenKeyService(keysClient, process.env.KEYGEN_SERVICE_TOKEN);
 
 if (!process.env.BASE_REDIRECTION_URL) {
     throw new Error("could not configure redirections: base redirection url is missing");
@@ -2,7 +2,18 @@
 
 'use strict';
 import {makeGenericClientConstructor} from '@grpc/grpc-js';
-import {KeyRequest, KeyResponse, Void} from './keys-contract_pb.js';
+import {GetKeyRequest, KeyRequest, KeyResponse, Void} from './keys-contract_pb.js';
+
+function serialize_keys_GetKeyRequest(arg) {
+  if (!(arg instanceof GetKeyRequest)) {
+    throw new Error('Expected argument of type keys.GetKeyRequest');
+  }
+  return Buffer.from(arg.serializeBinary());
+}
+
+function deserialize_keys_GetKeyRequest(buffer_arg) {
+  return GetKeyRequest.deserializeBinary(new Uint8Array(buffer_arg));
+}
 
 function serialize_keys_KeyRequest(arg) {
   if (!(arg instanceof KeyRequest)) {
@@ -43,10 +54,10 @@ var KeysService = {
     path: '/keys.Keys/GetKey',
     requestStream: false,
     responseStream: false,
-    requestType: Void,
+    requestType: GetKeyRequest,
     responseType: KeyResponse,
-    requestSerialize: serialize_keys_Void,
-    requestDeserialize: deserialize_keys_Void,
+    requestSerialize: serialize_keys_GetKeyRequest,
+    requestDeserialize: deserialize_keys_GetKeyRequest,
     responseSerialize: serialize_keys_KeyResponse,
     responseDeserialize: deserialize_keys_KeyResponse,
   },
@@ -23,11 +23,36 @@ export namespace Void {
     }
 }
 
+export class GetKeyRequest extends jspb.Message { 
+    getNamespace(): string;
+    setNamespace(value: string): GetKeyRequest;
+    getWait(): boolean;
+    setWait(value: boolean): GetKeyRequest;
+
+    serializeBinary(): Uint8Array;
+    toObject(includeInstance?: boolean): GetKeyRequest.AsObject;
+    static toObject(includeInstance: boolean, msg: GetKeyRequest): GetKeyRequest.AsObject;
+    static extensions: {[key: number]: jspb.ExtensionFieldInfo<jspb.Message>};
+    static extensionsBinary: {[key: number]: jspb.ExtensionFieldBinaryInfo<jspb.Message>};
+    static serializeBinaryToWriter(message: GetKeyRequest, writer: jspb.BinaryWriter): void;
+    static deserializeBinary(bytes: Uint8Array): GetKeyRequest;
+    static deserializeBinaryFromReader(message: GetKeyRequest, reader: jspb.BinaryReader): GetKeyRequest;
+}
+
+export namespace GetKeyRequest {
+    export type AsObject = {
+        namespace: string,
+        wait: boolean,
+    }
+}
+
 export class KeyResponse extends jspb.Message { 
     getKey(): Uint8Array | string;
     getKey_asU8(): Uint8Array;
     getKey_asB64(): string;
     setKey(value: Uint8Array | string): KeyResponse;
+    getToken(): string;
+    setToken(value: string): KeyResponse;
 
     serializeBinary(): Uint8Array;
     toObject(includeInstance?: boolean): KeyResponse.AsObject;
@@ -42,6 +67,7 @@ export class KeyResponse extends jspb.Message {
 export namespace KeyResponse {
     export type AsObject = {
         key: Uint8Array | string,
+        token: string,
     }
 }
 
@@ -50,6 +76,10 @@ export class KeyRequest extends jspb.Message {
     getKey_asU8(): Uint8Array;
     getKey_asB64(): string;
     setKey(value: Uint8Array | string): KeyRequest;
+    getNamespace(): string;
+    setNamespace(value: string): KeyRequest;
+    getToken(): string;
+    setToken(value: string): KeyRequest;
 
     serializeBinary(): Uint8Array;
     toObject(includeInstance?: boolean): KeyRequest.AsObject;
@@ -64,5 +94,7 @@ export class KeyRequest extends jspb.Message {
 export namespace KeyRequest {
     export type AsObject = {
         key: Uint8Array | string,
+        namespace: string,
+        token: string,
     }
 }
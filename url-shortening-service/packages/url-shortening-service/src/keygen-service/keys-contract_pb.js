@@ -30,6 +30,7 @@ var global = (function () {
   return Function('return this')();
 }.call(null));
 
+goog.exportSymbol('proto.keys.GetKeyRequest', null, global);
 goog.exportSymbol('proto.keys.KeyRequest', null, global);
 goog.exportSymbol('proto.keys.KeyResponse', null, global);
 goog.exportSymbol('proto.keys.Void', null, global);
@@ -54,6 +55,27 @@ if (goog.DEBUG && !COMPILED) {
    */
   proto.keys.Void.displayName = 'proto.keys.Void';
 }
+/**
+ * Generated by JsPbCodeGenerator.
+ * @param {Array=} opt_data Optional initial data array, typically from a
+ * server response, or constructed directly in Javascript. The array is used
+ * in place and becomes part of the constructed object. It is not cloned.
+ * If no data is provided, the constructed object will be empty, but still
+ * valid.
+ * @extends {jspb.Message}
+ * @constructor
+ */
+proto.keys.GetKeyRequest = function (opt_data) {
+  Message.initialize(this, opt_data, 0, -1, null, null);
+};
+goog.inherits(proto.keys.GetKeyRequest, Message);
+if (goog.DEBUG && !COMPILED) {
+  /**
+   * @public
+   * @override
+   */
+  proto.keys.GetKeyRequest.displayName = 'proto.keys.GetKeyRequest';
+}
 /**
  * Generated by JsPbCodeGenerator.
  * @param {Array=} opt_data Optional initial data array, typically from a
@@ -194,6 +216,163 @@ proto.keys.Void.serializeBinaryToWriter = function (message, writer) {
 };
 
 
+if (Message.GENERATE_TO_OBJECT) {
+  /**
+   * Creates an object representation of this proto.
+   * Field names that are reserved in JavaScript and will be renamed to pb_name.
+   * Optional fields that are not set will be set to undefined.
+   * To access a reserved field use, foo.pb_<name>, eg, foo.pb_default.
+   * For the list of reserved names please see:
+   *     net/proto2/compiler/js/internal/generator.cc#kKeyword.
+   * @param {boolean=} opt_includeInstance Deprecated. whether to include the
+   *     JSPB instance for transitional soy proto support:
+   *     http://goto/soy-param-migration
+   * @return {!Object}
+   */
+  proto.keys.GetKeyRequest.prototype.toObject = function (opt_includeInstance) {
+    return proto.keys.GetKeyRequest.toObject(opt_includeInstance, this);
+  };
+
+
+  /**
+   * Static version of the {@see toObject} method.
+   * @param {boolean|undefined} includeInstance Deprecated. Whether to include
+   *     the JSPB instance for transitional soy proto support:
+   *     http://goto/soy-param-migration
+   * @param {!proto.keys.GetKeyRequest} msg The msg instance to transform.
+   * @return {!Object}
+   * @suppress {unusedLocalVariables} f is only used for nested messages
+   */
+  proto.keys.GetKeyRequest.toObject = function (includeInstance, msg) {
+    var f, obj = {
+      namespace: Message.getFieldWithDefault(msg, 1, ""),
+      wait: Message.getBooleanFieldWithDefault(msg, 2, false)
+    };
+
+    if (includeInstance) {
+      obj.$jspbMessageInstance = msg;
+    }
+    return obj;
+  };
+}
+
+
+/**
+ * Deserializes binary data (in protobuf wire format).
+ * @param {jspb.ByteSource} bytes The bytes to deserialize.
+ * @return {!proto.keys.GetKeyRequest}
+ */
+proto.keys.GetKeyRequest.deserializeBinary = function (bytes) {
+  var reader = new BinaryReader(bytes);
+  var msg = new proto.keys.GetKeyRequest;
+  return proto.keys.GetKeyRequest.deserializeBinaryFromReader(msg, reader);
+};
+
+
+/**
+ * Deserializes binary data (in protobuf wire format) from the
+ * given reader into the given message object.
+ * @param {!proto.keys.GetKeyRequest} msg The message object to deserialize into.
+ * @param {!jspb.BinaryReader} reader The BinaryReader to use.
+ * @return {!proto.keys.GetKeyRequest}
+ */
+proto.keys.GetKeyRequest.deserializeBinaryFromReader = function (msg, reader) {
+  while (reader.nextField()) {
+    if (reader.isEndGroup()) {
+      break;
+    }
+    var field = reader.getFieldNumber();
+    switch (field) {
+      case 1:
+        var value = /** @type {string} */ (reader.readString());
+        msg.setNamespace(value);
+        break;
+      case 2:
+        var value = /** @type {boolean} */ (reader.readBool());
+        msg.setWait(value);
+        break;
+      default:
+        reader.skipField();
+        break;
+    }
+  }
+  return msg;
+};
+
+
+/**
+ * Serializes the message to binary data (in protobuf wire format).
+ * @return {!Uint8Array}
+ */
+proto.keys.GetKeyRequest.prototype.serializeBinary = function () {
+  var writer = new BinaryWriter();
+  proto.keys.GetKeyRequest.serializeBinaryToWriter(this, writer);
+  return writer.getResultBuffer();
+};
+
+
+/**
+ * Serializes the given message to binary data (in protobuf wire
+ * format), writing to the given BinaryWriter.
+ * @param {!proto.keys.GetKeyRequest} message
+ * @param {!jspb.BinaryWriter} writer
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.keys.GetKeyRequest.serializeBinaryToWriter = function (message, writer) {
+  var f = undefined;
+  f = message.getNamespace();
+  if (f.length > 0) {
+    writer.writeString(
+      1,
+      f
+    );
+  }
+  f = message.getWait();
+  if (f) {
+    writer.writeBool(
+      2,
+      f
+    );
+  }
+};
+
+
+/**
+ * optional string namespace = 1;
+ * @return {string}
+ */
+proto.keys.GetKeyRequest.prototype.getNamespace = function () {
+  return /** @type {string} */ (Message.getFieldWithDefault(this, 1, ""));
+};
+
+
+/**
+ * @param {string} value
+ * @return {!proto.keys.GetKeyRequest} returns this
+ */
+proto.keys.GetKeyRequest.prototype.setNamespace = function (value) {
+  return Message.setProto3StringField(this, 1, value);
+};
+
+
+/**
+ * optional bool wait = 2;
+ * @return {boolean}
+ */
+proto.keys.GetKeyRequest.prototype.getWait = function () {
+  return /** @type {boolean} */ (Message.getBooleanFieldWithDefault(this, 2, false));
+};
+
+
+/**
+ * @param {boolean} value
+ * @return {!proto.keys.GetKeyRequest} returns this
+ */
+proto.keys.GetKeyRequest.prototype.setWait = function (value) {
+  return Message.setProto3BooleanField(this, 2, value);
+};
+
+
 if (Message.GENERATE_TO_OBJECT) {
   /**
    * Creates an object representation of this proto.
@@ -223,7 +402,8 @@ if (Message.GENERATE_TO_OBJECT) {
    */
   proto.keys.KeyResponse.toObject = function (includeInstance, msg) {
     var f, obj = {
-      key: msg.getKey_asB64()
+      key: msg.getKey_asB64(),
+      token: Message.getFieldWithDefault(msg, 2, "")
     };
 
     if (includeInstance) {
@@ -264,6 +444,10 @@ proto.keys.KeyResponse.deserializeBinaryFromReader = function (msg, reader) {
         var value = /** @type {!Uint8Array} */ (reader.readBytes());
         msg.setKey(value);
         break;
+      case 2:
+        var value = /** @type {string} */ (reader.readString());
+        msg.setToken(value);
+        break;
       default:
         reader.skipField();
         break;
@@ -300,6 +484,13 @@ proto.keys.KeyResponse.serializeBinaryToWriter = function (message, writer) {
       f
     );
   }
+  f = message.getToken();
+  if (f.length > 0) {
+    writer.writeString(
+      2,
+      f
+    );
+  }
 };
 
 
@@ -345,6 +536,24 @@ proto.keys.KeyResponse.prototype.setKey = function (value) {
 };
 
 
+/**
+ * optional string token = 2;
+ * @return {string}
+ */
+proto.keys.KeyResponse.prototype.getToken = function () {
+  return /** @type {string} */ (Message.getFieldWithDefault(this, 2, ""));
+};
+
+
+/**
+ * @param {string} value
+ * @return {!proto.keys.KeyResponse} returns this
+ */
+proto.keys.KeyResponse.prototype.setToken = function (value) {
+  return Message.setProto3StringField(this, 2, value);
+};
+
+
 if (Message.GENERATE_TO_OBJECT) {
   /**
    * Creates an object representation of this proto.
@@ -374,7 +583,9 @@ if (Message.GENERATE_TO_OBJECT) {
    */
   proto.keys.KeyRequest.toObject = function (includeInstance, msg) {
     var f, obj = {
-      key: msg.getKey_asB64()
+      key: msg.getKey_asB64(),
+      namespace: Message.getFieldWithDefault(msg, 2, ""),
+      token: Message.getFieldWithDefault(msg, 3, "")
     };
 
     if (includeInstance) {
@@ -415,6 +626,14 @@ proto.keys.KeyRequest.deserializeBinaryFromReader = function (msg, reader) {
         var value = /** @type {!Uint8Array} */ (reader.readBytes());
         msg.setKey(value);
         break;
+      case 2:
+        var value = /** @type {string} */ (reader.readString());
+        msg.setNamespace(value);
+        break;
+      case 3:
+        var value = /** @type {string} */ (reader.readString());
+        msg.setToken(value);
+        break;
       default:
         reader.skipField();
         break;
@@ -451,6 +670,20 @@ proto.keys.KeyRequest.serializeBinaryToWriter = function (message, writer) {
       f
     );
   }
+  f = message.getNamespace();
+  if (f.length > 0) {
+    writer.writeString(
+      2,
+      f
+    );
+  }
+  f = message.getToken();
+  if (f.length > 0) {
+    writer.writeString(
+      3,
+      f
+    );
+  }
 };
 
 
@@ -496,5 +729,41 @@ proto.keys.KeyRequest.prototype.setKey = function (value) {
 };
 
 
+/**
+ * optional string namespace = 2;
+ * @return {string}
+ */
+proto.keys.KeyRequest.prototype.getNamespace = function () {
+  return /** @type {string} */ (Message.getFieldWithDefault(this, 2, ""));
+};
+
+
+/**
+ * @param {string} value
+ * @return {!proto.keys.KeyRequest} returns this
+ */
+proto.keys.KeyRequest.prototype.setNamespace = function (value) {
+  return Message.setProto3StringField(this, 2, value);
+};
+
+
+/**
+ * optional string token = 3;
+ * @return {string}
+ */
+proto.keys.KeyRequest.prototype.getToken = function () {
+  return /** @type {string} */ (Message.getFieldWithDefault(this, 3, ""));
+};
+
+
+/**
+ * @param {string} value
+ * @return {!proto.keys.KeyRequest} returns this
+ */
+proto.keys.KeyRequest.prototype.setToken = function (value) {
+  return Message.setProto3StringField(this, 3, value);
+};
+
+
 // goog.object.extend(exports, proto.keys);
-export const { Void, KeyRequest, KeyResponse } = proto.keys;
\ No newline at end of file
+export const { Void, GetKeyRequest, KeyRequest, KeyResponse } = proto.keys;
\ No newline at end of file
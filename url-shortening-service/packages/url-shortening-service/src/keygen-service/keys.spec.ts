@@ -3,27 +3,27 @@ import {type CallOptions, type ClientUnaryCall, Metadata, type ServiceError} fro
 import {Status} from "@grpc/grpc-js/build/src/constants.js";
 import {KeygenKeyService} from "./keys.js";
 import type {KeyService} from "../keys/keys.js";
-import {KeyResponse, Void} from "./keys-contract_pb.js";
+import {GetKeyRequest, KeyRequest, KeyResponse, Void} from "./keys-contract_pb.js";
 import type {KeysClient} from "./keys-contract_grpc_pb.js";
 
-type KeyCallback = (err: ServiceError | null, data: KeyResponse) => void;
+type KeyCallback<T> = (err: ServiceError | null, data: T) => void;
 
-const getKeyMockTemplate = (err: ServiceError | null, res: KeyResponse) => {
-    return vi.fn((request: Void, arg2: Metadata | KeyCallback, arg3?: Partial<CallOptions> | KeyCallback, arg4?: KeyCallback): ClientUnaryCall => {
-        let callback: KeyCallback;
+const callMockTemplate = <Req, Res>(err: ServiceError | null, res: Res) => {
+    return vi.fn((request: Req, arg2: Metadata | KeyCallback<Res>, arg3?: Partial<CallOptions> | KeyCallback<Res>, arg4?: KeyCallback<Res>): ClientUnaryCall => {
+        let callback: KeyCallback<Res>;
 
         // Determine the actual callback function based on the arguments provided
         if (typeof arg2 === 'function') {
-            // Signature 1: getKey(request, callback)
+            // Signature 1: call(request, callback)
             callback = arg2;
         } else if (typeof arg3 === 'function') {
-            // Signature 2: getKey(request, metadata, callback)
+            // Signature 2: call(request, metadata, callback)
             callback = arg3;
         } else if (typeof arg4 === 'function') {
-            // Signature 3: getKey(request, metadata, options, callback)
+            // Signature 3: call(request, metadata, options, callback)
             callback = arg4;
         } else {
-            throw new Error("Mock getKey was called without a valid callback function.");
+            throw new Error("Mock call was called without a valid callback function.");
         }
 
         callback(err, res);
@@ -36,10 +36,23 @@ const getKeyMockTemplate = (err: ServiceError | null, res: KeyResponse) => {
     });
 };
 
+const getKeyMockTemplate = (err: ServiceError | null, res: KeyResponse) => callMockTemplate<GetKeyRequest, KeyResponse>(err, res);
+
+const releaseKeyMockTemplate = (err: ServiceError | null) => callMockTemplate<KeyRequest, Void>(err, new Void());
+
+const serviceError = (code: Status): ServiceError => ({
+    code,
+    details: "mocked error",
+    metadata: new Metadata(),
+    name: "mocked error",
+    message: "mocked error",
+    toString: vi.fn(() => "mocked error")
+});
+
 describe("keygen keys service", () => {
-    const mockClient: Partial<KeysClient> = {getKey: vi.fn()};
+    const mockClient: Partial<KeysClient> = {getKey: vi.fn(), releaseKey: vi.fn()};
 
-    const service: KeyService = new KeygenKeyService(mockClient as KeysClient);
+    const service: KeyService = new KeygenKeyService(mockClient as KeysClient, "client-token");
 
     describe("allocate key", () => {
         const allocate = () => service.allocate();
@@ -48,6 +61,7 @@ describe("keygen keys service", () => {
             beforeEach(() => {
                 const res = new KeyResponse();
                 res.setKey(new Uint8Array([116, 101, 115, 116, 45, 107, 101, 121])); // "test-key"
+                res.setToken("test-token");
 
                 mockClient.getKey = getKeyMockTemplate(null, res);
             });
@@ -55,7 +69,7 @@ describe("keygen keys service", () => {
             it("should return the allocated key in a practical format", async () => {
                 const key = await allocate();
 
-                expect(key).to.equal("test-key");
+                expect(key).toEqual({hash: "test-key", token: "test-token"});
             });
         });
 
@@ -63,6 +77,7 @@ describe("keygen keys service", () => {
             beforeEach(() => {
                 const res = new KeyResponse();
                 res.setKey("test-key");
+                res.setToken("test-token");
 
                 mockClient.getKey = getKeyMockTemplate(null, res);
             });
@@ -70,24 +85,13 @@ describe("keygen keys service", () => {
             it("should return the allocated key", async () => {
                 const key = await allocate();
 
-                expect(key).to.equal("test-key");
+                expect(key).toEqual({hash: "test-key", token: "test-token"});
             });
         });
 
         describe("when the service return a error", () => {
             beforeEach(() => {
-                const keyResponse = new KeyResponse();
-
-                const serviceErr = {
-                    code: Status.INTERNAL,
-                    details: "mocked error",
-                    metadata: new Metadata(),
-                    name: "mocked error",
-                    message: "mocked error",
-                    toString: vi.fn(() => "mocked error")
-                }
-
-                mockClient.getKey = getKeyMockTemplate(serviceErr, keyResponse);
+                mockClient.getKey = getKeyMockTemplate(serviceError(Status.INTERNAL), new KeyResponse());
             });
 
             it("should throw an error", async () => {
@@ -95,4 +99,51 @@ describe("keygen keys service", () => {
             });
         });
     });
+
+    describe("deallocate key", () => {
+        const deallocate = () => service.deallocate({hash: "test-key", token: "test-token"});
+
+        describe("when the service releases the key", () => {
+            beforeEach(() => {
+                mockClient.releaseKey = releaseKeyMockTemplate(null);
+            });
+
+            it("should send the key with its token", async () => {
+                await deallocate();
+
+                const request = vi.mocked(mockClient.releaseKey)?.mock.calls[0]?.[0] as KeyRequest;
+
+                expect(new TextDecoder("utf-8").decode(request.getKey_asU8())).to.equal("test-key");
+                expect(request.getToken()).to.equal("test-token");
+            });
+
+            it("should authenticate the call with the client token", async () => {
+                await deallocate();
+
+                const metadata = vi.mocked(mockClient.releaseKey)?.mock.calls[0]?.[1] as Metadata;
+
+                expect(metadata.get("authorization")).toEqual(["Bearer client-token"]);
+            });
+        });
+
+        describe("when the service does not find the key", () => {
+            beforeEach(() => {
+                mockClient.releaseKey = releaseKeyMockTemplate(serviceError(Status.NOT_FOUND));
+            });
+
+            it("should not throw an error", async () => {
+                await expect(deallocate()).resolves.toBeUndefined();
+            });
+        });
+
+        describe("when the service return a error", () => {
+            beforeEach(() => {
+                mockClient.releaseKey = releaseKeyMockTemplate(serviceError(Status.PERMISSION_DENIED));
+            });
+
+            it("should throw an error", async () => {
+                await expect(deallocate()).rejects.toThrow(/failed to deallocate key.*mocked error/i);
+            });
+        });
+    });
 });
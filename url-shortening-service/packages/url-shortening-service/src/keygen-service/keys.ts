@@ -1,22 +1,29 @@
-import type {KeyService} from "../keys/keys.js";
+import {Metadata, status} from "@grpc/grpc-js";
+import type {Key, KeyService} from "../keys/keys.js";
 import type {KeysClient} from "./keys-contract_grpc_pb.js";
-import {KeyResponse, Void} from "./keys-contract_pb.js";
+import {GetKeyRequest, KeyRequest, KeyResponse} from "./keys-contract_pb.js";
 
 /**
  * KeygenKeyService for keys storage management at the keygen service
  */
 export class KeygenKeyService implements KeyService {
     private readonly keysClient: KeysClient;
+    private readonly token: string | undefined;
 
-    constructor(keysClient: KeysClient) {
+    /**
+     * @param keysClient to call the keygen service
+     * @param token authenticating the calls as a bearer token, when given
+     */
+    constructor(keysClient: KeysClient, token?: string) {
         this.keysClient = keysClient;
+        this.token = token;
     }
 
-    async allocate(): Promise<string> {
+    async allocate(): Promise<Key> {
         console.log(`allocating key at the keygen service`);
 
         return await new Promise((resolve, reject) => {
-            this.keysClient.getKey(new Void(), (err, keyResponse: KeyResponse) => {
+            this.keysClient.getKey(new GetKeyRequest(), this.metadata(), (err, keyResponse: KeyResponse) => {
                 if (err) {
                     reject(new Error(`failed to allocate key: ${err}`));
 
@@ -24,9 +31,10 @@ export class KeygenKeyService implements KeyService {
                 }
 
                 const key = keyResponse.getKey();
+                const token = keyResponse.getToken();
 
                 if (typeof key === "string") {
-                    resolve(key);
+                    resolve({hash: key, token});
 
                     return;
                 }
@@ -34,7 +42,7 @@ export class KeygenKeyService implements KeyService {
                 try {
                     const decoder = new TextDecoder("utf-8");
 
-                    resolve(decoder.decode(key));
+                    resolve({hash: decoder.decode(key), token});
                 } catch (err: unknown) {
                     reject(new Error(`failed to allocate key: failed to decode key: ${err instanceof Error ? err : "unknown error"}`));
                 }
@@ -42,9 +50,32 @@ export class KeygenKeyService implements KeyService {
         });
     }
 
-    deallocate(key: string): Promise<void> {
-        console.log(`deallocating key ${key} at the keygen service`);
+    async deallocate(key: Key): Promise<void> {
+        console.log(`deallocating key ${key.hash} at the keygen service`);
 
-        throw Error("not implemented");
+        const keyRequest = new KeyRequest();
+        keyRequest.setKey(new TextEncoder().encode(key.hash));
+        keyRequest.setToken(key.token);
+
+        return await new Promise((resolve, reject) => {
+            this.keysClient.releaseKey(keyRequest, this.metadata(), (err) => {
+                if (err && err.code !== status.NOT_FOUND) {
+                    reject(new Error(`failed to deallocate key: ${err}`));
+
+                    return;
+                }
+
+                resolve();
+            });
+        });
+    }
+
+    private metadata(): Metadata {
+        const metadata = new Metadata();
+        if (this.token) {
+            metadata.set("authorization", `Bearer ${this.token}`);
+        }
+
+        return metadata;
     }
 }
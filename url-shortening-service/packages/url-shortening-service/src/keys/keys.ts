@@ -1,14 +1,23 @@
+/**
+ * Key allocated at the keys storage, including the token
+ * required to deallocate it
+ */
+export interface Key {
+    hash: string;
+    token: string;
+}
+
 /**
  * KeyService for keys storage access
  */
 export interface KeyService {
-    allocate(): Promise<string>;
+    allocate(): Promise<Key>;
 
     /**
      * deallocate a given taken key.
      *
      * This won't throw an error if the key is not taken/found
-     * @param key to be deallocated
+     * @param key to be deallocated, with the token given at allocation
      */
-    deallocate(key: string): Promise<void>;
+    deallocate(key: Key): Promise<void>;
 }
@@ -15,7 +15,7 @@ describe("mongodb shorts service", () => {
     const service: ShortService = new MongoDbShortService(mockClient as MongoClient);
 
     describe("create short", () => {
-        const short: Short = {};
+        const short: Short = {hash: "111111", keyToken: "key-token"};
         const create = async () => service.create(short);
 
         describe("when mongodb succeeds", () => {
@@ -30,7 +30,7 @@ describe("create short use case", () => {
 
         describe("when services respond with success", () => {
             beforeEach(() => {
-                keyService.allocate = vi.fn().mockResolvedValue("valid-hash");
+                keyService.allocate = vi.fn().mockResolvedValue({hash: "valid-hash", token: "valid-token"});
                 shortService.create = vi.fn((short: Short) => Promise.resolve(short));
             });
 
@@ -41,6 +41,7 @@ describe("create short use case", () => {
                 expectedExpire.setFullYear(expectedExpire.getFullYear() + 1);
 
                 expect(short.hash).toEqual("valid-hash");
+                expect(short.keyToken).toEqual("valid-token");
                 expect(short.originalUrl).toEqual(originalUrl);
                 expect(short.expire?.getFullYear()).toEqual(expectedExpire.getFullYear());
             });
@@ -58,7 +59,7 @@ describe("create short use case", () => {
 
         describe("when shorts service fails", () => {
             beforeEach(() => {
-                keyService.allocate = vi.fn().mockResolvedValue("valid-hash");
+                keyService.allocate = vi.fn().mockResolvedValue({hash: "valid-hash", token: "valid-token"});
                 keyService.deallocate = vi.fn(() => Promise.resolve());
                 shortService.create = vi.fn().mockRejectedValue(new Error("mocked create"));
             });
@@ -70,7 +71,7 @@ describe("create short use case", () => {
             it("should deallocate the key", async () => {
                 await expect(run).rejects.toThrow();
 
-                expect(keyService.deallocate).toHaveBeenCalledWith("valid-hash");
+                expect(keyService.deallocate).toHaveBeenCalledWith({hash: "valid-hash", token: "valid-token"});
             });
 
             describe("when keys service fails to deallocate", () => {
@@ -78,10 +79,15 @@ describe("create short use case", () => {
                     keyService.deallocate = vi.fn().mockRejectedValue(new Error("mocked deallocate"));
                 });
 
-                it("should do nothing", async () => {
+                it("should log the failure", async () => {
+                    const consoleError = vi.spyOn(console, "error").mockImplementation(() => undefined);
+
                     await expect(run).rejects.toThrow(/mocked create/i); // not mocked deallocate!
 
                     expect(keyService.deallocate).toHaveBeenCalled();
+                    expect(consoleError).toHaveBeenCalledWith(expect.stringMatching(/could not release key valid-hash.*mocked deallocate/i));
+
+                    consoleError.mockRestore();
                 });
             });
         });
@@ -1,4 +1,4 @@
-import type {KeyService} from "../keys/keys.js";
+import type {Key, KeyService} from "../keys/keys.js";
 import {type Validation, ValidationError} from "../errors/validations.js";
 
 /**
@@ -9,6 +9,7 @@ export interface Short {
     originalUrl?: URL;
     expire?: Date;
     userId?: string;
+    keyToken?: string;
 }
 
 /**
@@ -98,17 +99,22 @@ export async function createShort(shortParams: CreateShortParams, keyService: Ke
         throw new ValidationError(validation);
     }
 
+    let key: Key;
     try {
-        short.hash = await keyService.allocate();
+        key = await keyService.allocate();
     } catch (err: unknown) {
         throw Error(`failed to create short: key service failed: ${err instanceof Error ? err : "unknown error"}`);
     }
 
+    short.hash = key.hash;
+    short.keyToken = key.token;
+
     try {
         return await shortService.create(short);
     } catch (err: unknown) {
-        await keyService.deallocate(short.hash).catch(() => {
-            return; // nothing to do about it
+        await keyService.deallocate(key).catch((releaseErr: unknown) => {
+            // the key stays taken until released by the cleaner or an admin
+            console.error(`could not release key ${key.hash}: ${releaseErr instanceof Error ? releaseErr.message : "unknown error"}`);
         });
 
         throw Error(`failed to create short: short service failed: ${err instanceof Error ? err : "unknown error"}`);